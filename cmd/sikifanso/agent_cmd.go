@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/alicanalbayrak/sikifanso/internal/agent"
 	"github.com/alicanalbayrak/sikifanso/internal/argocd/grpcsync"
+	"github.com/alicanalbayrak/sikifanso/internal/litellm"
 	"github.com/alicanalbayrak/sikifanso/internal/session"
 	"github.com/fatih/color"
 	"github.com/urfave/cli/v3"
@@ -19,7 +22,17 @@ func agentCmd() *cli.Command {
 		Commands: []*cli.Command{
 			agentCreateCmd(),
 			agentListCmd(),
+			agentInfoCmd(),
 			agentDeleteCmd(),
+			agentReapCmd(),
+			agentRunCmd(),
+			agentTemplatesCmd(),
+			agentUpdateCmd(),
+			agentEgressCmd(),
+			agentFlowsCmd(),
+			agentFreezeCmd(),
+			agentPolicyCmd(),
+			agentUnfreezeCmd(),
 		},
 	}
 }
@@ -35,27 +48,61 @@ func agentCreateCmd() *cli.Command {
 			&cli.StringFlag{Name: "memory-request", Usage: "Memory request quota (guaranteed)", Value: agent.DefaultMemoryRequest},
 			&cli.StringFlag{Name: "memory-limit", Usage: "Memory limit quota (burst ceiling)", Value: agent.DefaultMemoryLimit},
 			&cli.StringFlag{Name: "pods", Usage: "Max pods", Value: agent.DefaultPods},
-		}, waitSyncFlags()...),
+			&cli.DurationFlag{Name: "ttl", Usage: "Delete the agent this long after creation with agent reap, e.g. 4h (default: never)"},
+			&cli.StringFlag{Name: "template", Usage: "Start from an agent template (see agent templates)"},
+			&cli.StringFlag{Name: "image", Usage: "Replace the template's workload image and command"},
+		}, append(llmKeyFlags(), waitSyncFlags()...)...),
 		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
 			name := cmd.Args().First()
 			if name == "" {
 				return fmt.Errorf("agent name is required: sikifanso agent create NAME")
 			}
+			if err := checkLLMKeyFlags(cmd, sess.GitOpsPath); err != nil {
+				return err
+			}
+			if err := checkRegistryImage(sess, cmd.String("image")); err != nil {
+				return err
+			}
+			// Connect before committing so an unreachable proxy fails the
+			// create instead of leaving an agent without its key.
+			gw, err := agent.ConnectGateway(ctx, sess.GitOpsPath, sess.ClusterName)
+			if err != nil {
+				return fmt.Errorf("connecting to the LLM gateway: %w", err)
+			}
 
+			pool := agent.NodePoolFor(sess.K3dConfig)
 			if err := agent.Create(sess.GitOpsPath, agent.CreateOpts{
 				Name:          name,
-				CPURequest:    cmd.String("cpu-request"),
-				CPULimit:      cmd.String("cpu-limit"),
-				MemoryRequest: cmd.String("memory-request"),
-				MemoryLimit:   cmd.String("memory-limit"),
-				Pods:          cmd.String("pods"),
+				CPURequest:    explicitString(cmd, "cpu-request"),
+				CPULimit:      explicitString(cmd, "cpu-limit"),
+				MemoryRequest: explicitString(cmd, "memory-request"),
+				MemoryLimit:   explicitString(cmd, "memory-limit"),
+				Pods:          explicitString(cmd, "pods"),
+				TTL:           cmd.Duration("ttl"),
+				Template:      cmd.String("template"),
+				Image:         cmd.String("image"),
+				NodePool:      pool,
+				ChartRepoURL:  agent.ChartRepoFor(sess.Bundle),
 			}); err != nil {
 				return err
 			}
 
 			fmt.Fprintf(os.Stderr, "%s created (namespace: agent-%s)\n", color.GreenString(name), name)
+			if pool != "" {
+				fmt.Fprintf(os.Stderr, "pinned to the %s node pool\n", pool)
+			}
+			if t := cmd.String("template"); t != "" {
+				fmt.Fprintf(os.Stderr, "workload from template %s; see its egress with: sikifanso agent egress list %s\n", t, name)
+			}
+			if ttl := cmd.Duration("ttl"); ttl > 0 {
+				fmt.Fprintf(os.Stderr, "expires in %s; reaped by: sikifanso agent reap\n", formatLifetime(ttl))
+			}
 			fmt.Fprintln(os.Stderr, "committed to gitops repo")
 
+			if err := provisionAgentKey(ctx, cmd, sess, gw, name); err != nil {
+				return err
+			}
+
 			if err := syncAfterMutation(ctx, cmd, sess, MutationOpts{
 				Operation:  grpcsync.OpEnable,
 				Apps:       []string{name},
@@ -72,15 +119,13 @@ func agentListCmd() *cli.Command {
 	return &cli.Command{
 		Name:  "list",
 		Usage: "List agent namespaces",
-		Action: withSession(func(_ context.Context, cmd *cli.Command, sess *session.Session) error {
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
 			agents, err := agent.List(sess.GitOpsPath)
 			if err != nil {
 				return fmt.Errorf("listing agents: %w", err)
 			}
-			if agents == nil {
-				agents = []agent.Info{}
-			}
-			if outputJSON(cmd, agents) {
+			usages := agentUsages(ctx, sess, agents)
+			if outputJSON(cmd, withUsage(agents, usages)) {
 				return nil
 			}
 			if len(agents) == 0 {
@@ -88,10 +133,12 @@ func agentListCmd() *cli.Command {
 				return nil
 			}
 
-			headers := []string{"NAME", "NAMESPACE", "CPU REQ", "CPU LIM", "MEM REQ", "MEM LIM", "PODS"}
+			headers := []string{"NAME", "NAMESPACE", "CPU REQ", "CPU LIM", "MEM REQ", "MEM LIM", "PODS", "USAGE", "EXPIRES"}
 			rows := make([][]string, 0, len(agents))
+			now := time.Now()
 			for _, a := range agents {
-				rows = append(rows, []string{a.Name, a.Namespace, a.CPURequest, a.CPULimit, a.MemoryRequest, a.MemoryLimit, a.Pods})
+				rows = append(rows, []string{a.Name, a.Namespace, a.CPURequest, a.CPULimit, a.MemoryRequest, a.MemoryLimit, a.Pods,
+					formatTopUsage(usages[a.Name]), agentExpiry(a, now)})
 			}
 			printTable(os.Stderr, headers, rows)
 			return nil
@@ -99,6 +146,296 @@ func agentListCmd() *cli.Command {
 	}
 }
 
+// agentWithUsage is the JSON form of an agent in agent list.
+type agentWithUsage struct {
+	agent.Info
+	Usage *agent.Usage `json:"usage,omitempty"`
+}
+
+func withUsage(agents []agent.Info, usages map[string]*agent.Usage) []agentWithUsage {
+	out := make([]agentWithUsage, 0, len(agents))
+	for _, a := range agents {
+		out = append(out, agentWithUsage{Info: a, Usage: usages[a.Name]})
+	}
+	return out
+}
+
+// agentUsages reads the live quota usage of agents, keyed by name. Agents
+// whose usage cannot be read are left out, and the first error is printed
+// as a warning, so a stopped cluster still lists its agents.
+func agentUsages(ctx context.Context, sess *session.Session, agents []agent.Info) map[string]*agent.Usage {
+	if len(agents) == 0 {
+		return nil
+	}
+	r, err := agent.NewUsageReader(sess.ClusterName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s could not read quota usage: %v\n", color.YellowString("warning:"), err)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	usages := make(map[string]*agent.Usage, len(agents))
+	var firstErr error
+	for _, a := range agents {
+		u, err := r.Read(ctx, a.Namespace)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		usages[a.Name] = u
+	}
+	if firstErr != nil {
+		fmt.Fprintf(os.Stderr, "%s could not read quota usage: %v\n", color.YellowString("warning:"), firstErr)
+	}
+	return usages
+}
+
+// formatTopUsage renders the quota entry closest to its limit, yellow from
+// agent.QuotaWarnPercent and red once full.
+func formatTopUsage(u *agent.Usage) string {
+	if u == nil {
+		return "-"
+	}
+	top, ok := u.Highest()
+	if !ok {
+		return "-"
+	}
+	return colorUsage(top, top.Resource+" "+top.String())
+}
+
+func colorUsage(q agent.QuotaUsage, s string) string {
+	switch {
+	case q.Percent >= 100:
+		return color.RedString(s)
+	case q.Percent >= agent.QuotaWarnPercent:
+		return color.YellowString(s)
+	default:
+		return s
+	}
+}
+
+func agentInfoCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "info",
+		Usage:     "Show an agent's quotas and LLM key usage",
+		ArgsUsage: "NAME",
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			name := cmd.Args().First()
+			if name == "" {
+				return fmt.Errorf("agent name is required: sikifanso agent info NAME")
+			}
+			info, err := agent.Find(sess.GitOpsPath, name)
+			if err != nil {
+				return err
+			}
+
+			var key *litellm.Key
+			gw, err := agent.ConnectGateway(ctx, sess.GitOpsPath, sess.ClusterName)
+			if err == nil && gw != nil {
+				key, err = gw.KeyUsage(ctx, name)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s could not read LLM key usage: %v\n", color.YellowString("warning:"), err)
+			}
+
+			usages := agentUsages(ctx, sess, []agent.Info{*info})
+			frozen := agentFrozen(ctx, sess, name)
+			if outputJSON(cmd, struct {
+				*agent.Info
+				Frozen *agent.FreezeRecord `json:"frozen,omitempty"`
+				Usage  *agent.Usage        `json:"usage,omitempty"`
+				LLMKey *litellm.Key        `json:"llmKey,omitempty"`
+			}{info, frozen, usages[name], key}) {
+				return nil
+			}
+			rows := [][]string{
+				{"Name:", info.Name},
+				{"Namespace:", info.Namespace},
+				{"CPU:", info.CPURequest + " request / " + info.CPULimit + " limit"},
+				{"Memory:", info.MemoryRequest + " request / " + info.MemoryLimit + " limit"},
+				{"Max pods:", info.Pods},
+			}
+			if info.Template != "" {
+				rows = append(rows, []string{"Template:", info.Template})
+			}
+			if frozen != nil {
+				rows = append(rows, []string{"Frozen:", formatFrozen(frozen)})
+			}
+			if u := usages[name]; u != nil {
+				for i, q := range u.Quota {
+					label := ""
+					if i == 0 {
+						label = "Quota used:"
+					}
+					rows = append(rows, []string{label, colorUsage(q, fmt.Sprintf("%-16s %s", q.Resource, q.String()))})
+				}
+				if u.CPU != "" {
+					rows = append(rows, []string{"Live usage:", u.CPU + " CPU, " + u.Memory + " memory"})
+				}
+			}
+			if key != nil {
+				rows = append(rows,
+					[]string{"LLM key:", key.Alias},
+					[]string{"LLM spend:", formatKeySpend(key)},
+					[]string{"LLM limits:", formatKeyLimits(key)},
+				)
+			} else {
+				rows = append(rows, []string{"LLM key:", "none"})
+			}
+			for _, r := range rows {
+				fmt.Fprintf(os.Stderr, "%-12s %s\n", r[0], r[1])
+			}
+			return nil
+		}),
+	}
+}
+
+func agentUpdateCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "update",
+		Usage:     "Change an agent's quotas or chart version without recreating it",
+		ArgsUsage: "NAME",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{Name: "cpu-request", Usage: "CPU request quota (guaranteed)"},
+			&cli.StringFlag{Name: "cpu-limit", Usage: "CPU limit quota (burst ceiling)"},
+			&cli.StringFlag{Name: "memory-request", Usage: "Memory request quota (guaranteed)"},
+			&cli.StringFlag{Name: "memory-limit", Usage: "Memory limit quota (burst ceiling)"},
+			&cli.StringFlag{Name: "pods", Usage: "Max pods"},
+			&cli.StringFlag{Name: "chart-version", Usage: "Agent template chart version"},
+			&cli.BoolFlag{Name: "dry-run", Usage: "Print the changes without applying them"},
+		}, waitSyncFlags()...),
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			name := cmd.Args().First()
+			if name == "" {
+				return fmt.Errorf("agent name is required: sikifanso agent update NAME [--memory-limit 2Gi ...]")
+			}
+
+			changes, err := agent.Update(sess.GitOpsPath, agent.UpdateOpts{
+				Name:          name,
+				CPURequest:    cmd.String("cpu-request"),
+				CPULimit:      cmd.String("cpu-limit"),
+				MemoryRequest: cmd.String("memory-request"),
+				MemoryLimit:   cmd.String("memory-limit"),
+				Pods:          cmd.String("pods"),
+				ChartVersion:  cmd.String("chart-version"),
+				DryRun:        cmd.Bool("dry-run"),
+			})
+			if err != nil {
+				return err
+			}
+			if !outputJSON(cmd, changes) {
+				if len(changes) == 0 {
+					fmt.Fprintf(os.Stderr, "%s is unchanged\n", name)
+				} else {
+					printAgentChanges(name, changes)
+				}
+			}
+			if len(changes) == 0 || cmd.Bool("dry-run") {
+				return nil
+			}
+			fmt.Fprintln(os.Stderr, "committed to gitops repo")
+
+			return syncAfterMutation(ctx, cmd, sess, MutationOpts{
+				Operation:  grpcsync.OpSync,
+				Apps:       []string{name},
+				AppSetName: "agents",
+			})
+		}),
+	}
+}
+
+// printAgentChanges writes a before/after view of an agent update.
+func printAgentChanges(name string, changes []agent.Change) {
+	fmt.Fprintf(os.Stderr, "%s:\n", color.GreenString(name))
+	rows := make([][]string, 0, len(changes))
+	for _, c := range changes {
+		from := c.From
+		if from == "" {
+			from = "(unset)"
+		}
+		rows = append(rows, []string{"  " + c.Field, color.RedString(from), "->", color.GreenString(c.To)})
+	}
+	printTable(os.Stderr, []string{"  FIELD", "BEFORE", "", "AFTER"}, rows)
+}
+
+// agentExpiry renders an agent's remaining lifetime for agent list.
+func agentExpiry(a agent.Info, now time.Time) string {
+	switch {
+	case a.ExpiresAt == nil:
+		return "-"
+	case a.Expired(now):
+		return color.RedString("expired")
+	default:
+		return "in " + formatLifetime(a.Remaining(now))
+	}
+}
+
+// formatLifetime renders d to the minute, e.g. "3h12m" or "45m".
+func formatLifetime(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "<1m"
+	}
+	s := strings.TrimSuffix(d.String(), "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+func agentReapCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "reap",
+		Usage: "Delete agents whose TTL has elapsed",
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{Name: "dry-run", Usage: "List expired agents without deleting them"},
+		}, waitSyncFlags()...),
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			expired, err := agent.ListExpired(sess.GitOpsPath, time.Now())
+			if err != nil {
+				return err
+			}
+			names := make([]string, len(expired))
+			for i, a := range expired {
+				names[i] = a.Name
+			}
+			if cmd.Bool("dry-run") || len(names) == 0 {
+				if !outputJSON(cmd, names) {
+					if len(names) == 0 {
+						fmt.Fprintln(os.Stderr, "No expired agents")
+					} else {
+						fmt.Fprintf(os.Stderr, "Would reap: %s\n", strings.Join(names, ", "))
+					}
+				}
+				return nil
+			}
+
+			reaped, err := agent.Reap(sess.GitOpsPath, time.Now())
+			if err != nil {
+				return err
+			}
+			if !outputJSON(cmd, reaped) {
+				for _, name := range reaped {
+					fmt.Fprintf(os.Stderr, "%s reaped\n", color.GreenString(name))
+				}
+			}
+			fmt.Fprintln(os.Stderr, "committed to gitops repo")
+			for _, name := range reaped {
+				revokeAgentKey(ctx, sess, name)
+			}
+
+			return syncAfterMutation(ctx, cmd, sess, MutationOpts{
+				Operation:  grpcsync.OpDisable,
+				Apps:       reaped,
+				AppSetName: "agents",
+			})
+		}),
+	}
+}
+
 func agentDeleteCmd() *cli.Command {
 	return &cli.Command{
 		Name:      "delete",
@@ -117,6 +454,9 @@ func agentDeleteCmd() *cli.Command {
 
 			fmt.Fprintf(os.Stderr, "%s deleted\n", color.GreenString(name))
 			fmt.Fprintln(os.Stderr, "committed to gitops repo")
+			// Revoked once the delete is committed and before the sync
+			// removes the namespace holding the key.
+			revokeAgentKey(ctx, sess, name)
 
 			if err := syncAfterMutation(ctx, cmd, sess, MutationOpts{
 				Operation:  grpcsync.OpDisable,
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/argocd/grpcsync"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+func agentEgressCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "egress",
+		Usage: "Manage the egress allowlist of an agent sandbox",
+		Commands: []*cli.Command{
+			agentEgressAllowCmd(),
+			agentEgressDenyCmd(),
+			agentEgressListCmd(),
+		},
+	}
+}
+
+// egressRuleFlags select the destination of an allow or deny.
+func egressRuleFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{Name: "fqdn", Usage: "DNS name, e.g. api.openai.com (*.example.com matches subdomains)"},
+		&cli.StringFlag{Name: "cidr", Usage: "IP range or address, e.g. 203.0.113.0/24"},
+		&cli.StringFlag{Name: "app", Usage: "Enabled catalog app, e.g. qdrant"},
+		&cli.IntSliceFlag{Name: "port", Usage: "Destination port (repeatable; default: all ports)"},
+	}, waitSyncFlags()...)
+}
+
+func agentEgressAllowCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "allow",
+		Usage:     "Allow an agent to reach an FQDN, CIDR or catalog app",
+		ArgsUsage: "AGENT",
+		Flags:     egressRuleFlags(),
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			return agentEgressMutate(ctx, cmd, sess, "allow", agent.AllowEgress)
+		}),
+	}
+}
+
+func agentEgressDenyCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "deny",
+		Usage:     "Remove a destination (or some of its ports) from an agent's allowlist",
+		ArgsUsage: "AGENT",
+		Flags:     egressRuleFlags(),
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			return agentEgressMutate(ctx, cmd, sess, "deny", agent.DenyEgress)
+		}),
+	}
+}
+
+func agentEgressListCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "list",
+		Usage:     "List the egress allowlist of an agent",
+		ArgsUsage: "AGENT",
+		Action: withSession(func(_ context.Context, cmd *cli.Command, sess *session.Session) error {
+			name := cmd.Args().First()
+			if name == "" {
+				return fmt.Errorf("agent name is required: sikifanso agent egress list AGENT")
+			}
+			rules, err := agent.ListEgress(sess.GitOpsPath, name)
+			if err != nil {
+				return err
+			}
+			if rules == nil {
+				rules = []agent.EgressRule{}
+			}
+			if outputJSON(cmd, rules) {
+				return nil
+			}
+			if len(rules) == 0 {
+				fmt.Fprintf(os.Stderr, "%s has no egress rules (default deny)\n", name)
+				return nil
+			}
+
+			headers := []string{"TYPE", "DESTINATION", "NAMESPACE", "PORTS"}
+			rows := make([][]string, 0, len(rules))
+			for _, r := range rules {
+				target := r.FQDN + r.CIDR + r.App
+				ns := r.Namespace
+				if ns == "" {
+					ns = "-"
+				}
+				rows = append(rows, []string{r.Kind(), target, ns, formatPorts(r.Ports)})
+			}
+			printTable(os.Stderr, headers, rows)
+			return nil
+		}),
+	}
+}
+
+// agentEgressMutate applies an allow or deny and syncs the agent.
+func agentEgressMutate(ctx context.Context, cmd *cli.Command, sess *session.Session, verb string, apply func(gitOpsPath, name string, rule agent.EgressRule) (bool, error)) error {
+	name := cmd.Args().First()
+	if name == "" {
+		return fmt.Errorf("agent name is required: sikifanso agent egress %s AGENT --fqdn HOST|--cidr RANGE|--app APP [--port N]", verb)
+	}
+	rule := agent.EgressRule{
+		FQDN:  cmd.String("fqdn"),
+		CIDR:  cmd.String("cidr"),
+		App:   cmd.String("app"),
+		Ports: cmd.IntSlice("port"),
+	}
+	changed, err := apply(sess.GitOpsPath, name, rule)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		fmt.Fprintf(os.Stderr, "%s egress unchanged\n", name)
+		return nil
+	}
+
+	past := "allowed"
+	if verb == "deny" {
+		past = "denied"
+	}
+	fmt.Fprintf(os.Stderr, "%s egress to %s %s\n", color.GreenString(name), rule, past)
+	fmt.Fprintln(os.Stderr, "committed to gitops repo")
+
+	return syncAfterMutation(ctx, cmd, sess, MutationOpts{
+		Operation:  grpcsync.OpSync,
+		Apps:       []string{name},
+		AppSetName: "agents",
+	})
+}
+
+func formatPorts(ports []int) string {
+	if len(ports) == 0 {
+		return "all"
+	}
+	s := make([]string, len(ports))
+	for i, p := range ports {
+		s[i] = strconv.Itoa(p)
+	}
+	return strings.Join(s, ",")
+}
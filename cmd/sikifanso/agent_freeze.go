@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+func agentFreezeCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "freeze",
+		Usage:     "Cut an agent off at once: deny all traffic, scale to zero and pause its sync",
+		ArgsUsage: "NAME",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "reason", Usage: "Why the agent is frozen, kept in the audit log"},
+		},
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			name := cmd.Args().First()
+			if name == "" {
+				return fmt.Errorf("agent name is required: sikifanso agent freeze NAME")
+			}
+			if _, err := agent.Find(sess.GitOpsPath, name); err != nil {
+				return err
+			}
+			f, err := agent.NewFreezer(sess)
+			if err != nil {
+				return err
+			}
+			rec, err := f.Freeze(ctx, name, agent.CurrentActor("cli", cmd.String("reason")))
+			if err != nil {
+				return err
+			}
+			if outputJSON(cmd, rec) {
+				return nil
+			}
+
+			fmt.Fprintf(os.Stderr, "%s frozen: all traffic denied by %s\n", color.RedString(name), agent.FreezePolicyName)
+			printFrozenWorkloads("stopped", rec.Workloads)
+			if !rec.SyncPaused {
+				fmt.Fprintf(os.Stderr, "%s no ArgoCD application for %s; nothing to pause\n", color.YellowString("warning:"), name)
+			}
+			fmt.Fprintf(os.Stderr, "restore with: sikifanso agent unfreeze %s\n", name)
+			return nil
+		}),
+	}
+}
+
+func agentUnfreezeCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "unfreeze",
+		Usage:     "Undo agent freeze: restore replicas, traffic and sync",
+		ArgsUsage: "NAME",
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			name := cmd.Args().First()
+			if name == "" {
+				return fmt.Errorf("agent name is required: sikifanso agent unfreeze NAME")
+			}
+			f, err := agent.NewFreezer(sess)
+			if err != nil {
+				return err
+			}
+			rec, err := f.Unfreeze(ctx, name, agent.CurrentActor("cli", ""))
+			if err != nil {
+				return err
+			}
+			if outputJSON(cmd, rec) {
+				return nil
+			}
+
+			fmt.Fprintf(os.Stderr, "%s unfrozen\n", color.GreenString(name))
+			printFrozenWorkloads("restored", rec.Workloads)
+			return nil
+		}),
+	}
+}
+
+func printFrozenWorkloads(verb string, workloads []string) {
+	if len(workloads) == 0 {
+		fmt.Fprintf(os.Stderr, "no workloads %s\n", verb)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s\n", verb, strings.Join(workloads, ", "))
+}
+
+// agentFrozen returns the agent's current freeze, or nil when it is not
+// frozen or the cluster cannot be reached.
+func agentFrozen(ctx context.Context, sess *session.Session, name string) *agent.FreezeRecord {
+	f, err := agent.NewFreezer(sess)
+	if err != nil {
+		return nil
+	}
+	rec, err := f.Frozen(ctx, name)
+	if err != nil {
+		return nil
+	}
+	return rec
+}
+
+// formatFrozen renders a freeze record for agent info.
+func formatFrozen(rec *agent.FreezeRecord) string {
+	s := fmt.Sprintf("since %s by %s via %s", rec.At, rec.By, rec.Via)
+	if rec.Reason != "" {
+		s += " (" + rec.Reason + ")"
+	}
+	return color.RedString(s)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/litellm"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+// llmKeyFlags limit the LiteLLM virtual key provisioned for a new agent.
+func llmKeyFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.FloatFlag{Name: "llm-budget", Usage: "Max LLM spend in USD for the agent's LiteLLM key (default: unlimited)"},
+		&cli.IntFlag{Name: "llm-rpm", Usage: "Max LLM requests per minute for the agent's LiteLLM key (default: unlimited)"},
+		&cli.StringSliceFlag{Name: "llm-model", Usage: "Model the agent's LiteLLM key may use (repeatable; default: all)"},
+	}
+}
+
+func llmKeyOpts(cmd *cli.Command) agent.KeyOpts {
+	return agent.KeyOpts{
+		MaxBudget: cmd.Float("llm-budget"),
+		RPMLimit:  int(cmd.Int("llm-rpm")),
+		Models:    cmd.StringSlice("llm-model"),
+	}
+}
+
+// checkLLMKeyFlags rejects key limits when there is no gateway to enforce
+// them, before the agent is committed.
+func checkLLMKeyFlags(cmd *cli.Command, gitOpsPath string) error {
+	err := agent.CheckKeyOpts(gitOpsPath, llmKeyOpts(cmd))
+	if errors.Is(err, agent.ErrNoGateway) {
+		return fmt.Errorf("--llm-* flags need the %s app; enable it first: sikifanso app enable %s", litellm.AppName, litellm.AppName)
+	}
+	return err
+}
+
+// provisionAgentKey gives a just-committed agent its LiteLLM key when gw is
+// non-nil. If the key cannot be provisioned the agent is rolled back.
+func provisionAgentKey(ctx context.Context, cmd *cli.Command, sess *session.Session, gw *agent.Gateway, name string) error {
+	if gw == nil {
+		return nil
+	}
+	key, err := gw.ProvisionNewKey(ctx, sess.GitOpsPath, name, llmKeyOpts(cmd))
+	if err != nil {
+		return fmt.Errorf("agent %s not created: %w", name, err)
+	}
+	fmt.Fprintf(os.Stderr, "LLM key %s stored in secret agent-%s/%s (%s)\n",
+		color.GreenString(key.Alias), name, agent.KeySecretName, formatKeyLimits(key))
+	return nil
+}
+
+// revokeAgentKey revokes the LiteLLM key of an agent whose deletion is
+// committed. Failures are only reported since the delete already happened.
+func revokeAgentKey(ctx context.Context, sess *session.Session, name string) {
+	gw, err := agent.ConnectGateway(ctx, sess.GitOpsPath, sess.ClusterName)
+	if err == nil && gw != nil {
+		var revoked bool
+		revoked, err = gw.RevokeKey(ctx, name)
+		if revoked && err == nil {
+			fmt.Fprintf(os.Stderr, "LLM key of %s revoked\n", name)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s could not revoke the LLM key of %s: %v\n", color.YellowString("warning:"), name, err)
+	}
+}
+
+// formatKeyLimits renders a key's budget, rate limit and models.
+func formatKeyLimits(k *litellm.Key) string {
+	budget := "unlimited"
+	if k.MaxBudget != nil {
+		budget = fmt.Sprintf("$%.2f", *k.MaxBudget)
+	}
+	rpm := "unlimited"
+	if k.RPMLimit != nil {
+		rpm = strconv.Itoa(*k.RPMLimit)
+	}
+	models := "all"
+	if len(k.Models) > 0 {
+		models = strings.Join(k.Models, ",")
+	}
+	return fmt.Sprintf("budget %s, rpm %s, models %s", budget, rpm, models)
+}
+
+// formatKeySpend renders a key's spend against its budget, e.g.
+// "$1.20 of $5.00 (24%)".
+func formatKeySpend(k *litellm.Key) string {
+	if k.MaxBudget == nil || *k.MaxBudget <= 0 {
+		return fmt.Sprintf("$%.2f", k.Spend)
+	}
+	return fmt.Sprintf("$%.2f of $%.2f (%.0f%%)", k.Spend, *k.MaxBudget, k.Spend / *k.MaxBudget * 100)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+func agentPolicyCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "policy",
+		Usage: "Inspect the network policy of an agent sandbox",
+		Commands: []*cli.Command{
+			agentPolicyTestCmd(),
+		},
+	}
+}
+
+func agentPolicyTestCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "test",
+		Usage:     "Probe what an agent can reach and compare it with the sandbox policy",
+		ArgsUsage: "NAME",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "external", Usage: "Outside host to probe on port 443", Value: agent.DefaultProbeExternal},
+			&cli.StringFlag{Name: "image", Usage: "Probe image; needs sh and nc", Value: agent.DefaultProbeImage},
+			&cli.DurationFlag{Name: "timeout", Usage: "Timeout for the probe pod, including the image pull", Value: agent.DefaultProbeTimeout},
+		},
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			name := cmd.Args().First()
+			if name == "" {
+				return fmt.Errorf("agent name is required: sikifanso agent policy test NAME")
+			}
+			if _, err := agent.Find(sess.GitOpsPath, name); err != nil {
+				return err
+			}
+			probe, err := agent.NewPolicyProbe(sess.ClusterName)
+			if err != nil {
+				return err
+			}
+			probe.Image = cmd.String("image")
+			probe.Timeout = cmd.Duration("timeout")
+
+			fmt.Fprintf(os.Stderr, "Probing from a pod in agent-%s...\n", name)
+			results, err := probe.Test(ctx, sess.GitOpsPath, name, cmd.String("external"))
+			if err != nil {
+				return err
+			}
+
+			failed := 0
+			for _, r := range results {
+				if !r.Pass() {
+					failed++
+				}
+			}
+			if !outputJSON(cmd, results) {
+				printProbeResults(results)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d targets do not match the sandbox policy", failed, len(results))
+			}
+			fmt.Fprintf(os.Stderr, "%s all %d targets match the sandbox policy\n", color.GreenString("ok:"), len(results))
+			return nil
+		}),
+	}
+}
+
+func printProbeResults(results []agent.ProbeResult) {
+	headers := []string{"TARGET", "ADDRESS", "EXPECTED", "RESULT", "CHECK"}
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		check := color.GreenString("PASS")
+		if !r.Pass() {
+			check = color.RedString("FAIL")
+		}
+		rows = append(rows, []string{r.Name, r.Address(), verdict(r.Expected), verdict(r.Allowed), check})
+	}
+	printTable(os.Stderr, headers, rows)
+}
+
+func verdict(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
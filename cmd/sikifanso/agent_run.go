@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+func agentRunCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "run",
+		Usage:     "Run a one-off job in an agent sandbox and stream its logs",
+		ArgsUsage: "NAME [-- COMMAND [ARGS...]]",
+		Description: "Creates a Job in the agent namespace with the agent's service account and its template's env,\n" +
+			"streams the logs to stdout and exits with the container's exit code.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "image", Usage: "Image to run (default: the agent's template workload image)"},
+			&cli.StringFlag{Name: "keep", Usage: "Keep the job afterwards: never, always or on-failure", Value: agent.KeepNever},
+			&cli.StringFlag{Name: "cpu", Usage: "CPU limit of the job", Value: agent.DefaultRunCPU},
+			&cli.StringFlag{Name: "memory", Usage: "Memory limit of the job", Value: agent.DefaultRunMemory},
+			&cli.DurationFlag{Name: "timeout", Usage: "Give up waiting for the job after this long", Value: 30 * time.Minute},
+		},
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			args := cmd.Args().Slice()
+			if len(args) == 0 {
+				return fmt.Errorf("agent name is required: sikifanso agent run NAME -- COMMAND")
+			}
+			name := args[0]
+			if err := checkRegistryImage(sess, cmd.String("image")); err != nil {
+				return err
+			}
+			runner, err := agent.NewRunner(sess.ClusterName, sess.GitOpsPath)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, cmd.Duration("timeout"))
+			defer cancel()
+			res, err := runner.Run(ctx, agent.RunOpts{
+				Name:    name,
+				Image:   cmd.String("image"),
+				Command: args[1:],
+				Keep:    cmd.String("keep"),
+				CPU:     cmd.String("cpu"),
+				Memory:  cmd.String("memory"),
+			}, os.Stdout)
+			if errors.Is(err, agent.ErrQuotaExceeded) {
+				return fmt.Errorf("%w\nfree up the namespace or raise the quota: sikifanso agent update %s --pods/--cpu-limit/--memory-limit", err, name)
+			}
+			if err != nil {
+				return err
+			}
+
+			if res.Kept {
+				fmt.Fprintf(os.Stderr, "job kept: kubectl -n agent-%s get job %s\n", name, res.Job)
+			}
+			if res.ExitCode != 0 {
+				fmt.Fprintf(os.Stderr, "%s %s exited with code %d\n", color.RedString("error:"), res.Job, res.ExitCode)
+				return cli.Exit("", res.ExitCode)
+			}
+			return nil
+		}),
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/urfave/cli/v3"
+)
+
+func agentTemplatesCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "templates",
+		Usage: "List agent templates for agent create --template",
+		Action: withSession(func(_ context.Context, cmd *cli.Command, sess *session.Session) error {
+			templates, err := agent.ListTemplates(sess.GitOpsPath)
+			if err != nil {
+				return fmt.Errorf("listing templates: %w", err)
+			}
+			if outputJSON(cmd, templates) {
+				return nil
+			}
+
+			headers := []string{"NAME", "SOURCE", "REQUIRES", "IMAGE", "DESCRIPTION"}
+			rows := make([][]string, 0, len(templates))
+			for _, t := range templates {
+				requires := "-"
+				if len(t.Requires) > 0 {
+					requires = strings.Join(t.Requires, ",")
+				}
+				rows = append(rows, []string{t.Name, t.Source, requires, t.Workload.Image, t.Description})
+			}
+			printTable(os.Stderr, headers, rows)
+			return nil
+		}),
+	}
+}
+
+// explicitString returns the flag's value only when it was given, so
+// agent.Create can fall back to a template's quota before the default.
+func explicitString(cmd *cli.Command, name string) string {
+	if !cmd.IsSet(name) {
+		return ""
+	}
+	return cmd.String(name)
+}
@@ -32,6 +32,6 @@ func newApp() *cli.Command {
 			},
 		},
 		Before:   setupAction,
-		Commands: []*cli.Command{clusterCmd(), appCmd(), agentCmd(), snapshotCmd(), mcpCmd()},
+		Commands: []*cli.Command{clusterCmd(), appCmd(), catalogCmd(), agentCmd(), imageCmd(), bundleCmd(), networkCmd(), snapshotCmd(), mcpCmd()},
 	}
 }
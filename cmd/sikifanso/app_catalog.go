@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+func catalogCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "catalog",
+		Usage: "Maintain the app catalog definitions in the gitops repo",
+		Commands: []*cli.Command{
+			catalogLintCmd(),
+			catalogSourceCmd(),
+		},
+	}
+}
+
+func catalogLintCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "lint",
+		Usage: "Validate catalog entries (fields, dependencies, cycles, tiers, names, namespaces)",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "path",
+				Usage: "Lint the gitops repo at this path instead of the --cluster session's (useful in CI)",
+			},
+		},
+		Action: wrapAction(catalogLintAction),
+	}
+}
+
+func catalogLintAction(_ context.Context, cmd *cli.Command) error {
+	gitOpsPath := cmd.String("path")
+	if gitOpsPath == "" {
+		clusterName := cmd.String("cluster")
+		sess, err := session.Load(clusterName)
+		if err != nil {
+			return fmt.Errorf("loading session for cluster %q (or pass --path): %w", clusterName, err)
+		}
+		gitOpsPath = sess.GitOpsPath
+	}
+
+	issues, err := catalog.Lint(gitOpsPath)
+	if err != nil {
+		return fmt.Errorf("linting catalog: %w", err)
+	}
+
+	if !outputJSON(cmd, issues) {
+		printLintIssues(issues)
+	}
+	if catalog.HasErrors(issues) {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+func printLintIssues(issues []catalog.Issue) {
+	if len(issues) == 0 {
+		fmt.Fprintf(os.Stderr, "%s catalog is valid\n", color.GreenString("ok"))
+		return
+	}
+
+	headers := []string{"SEVERITY", "FILE", "CHECK", "MESSAGE"}
+	rows := make([][]string, 0, len(issues))
+	errs, warns := 0, 0
+	for _, i := range issues {
+		sev := color.YellowString(string(i.Severity))
+		if i.Severity == catalog.SeverityError {
+			sev = color.RedString(string(i.Severity))
+			errs++
+		} else {
+			warns++
+		}
+		rows = append(rows, []string{sev, i.File, i.Check, i.Message})
+	}
+	printTable(os.Stderr, headers, rows)
+	fmt.Fprintf(os.Stderr, "\n%d error(s), %d warning(s)\n", errs, warns)
+}
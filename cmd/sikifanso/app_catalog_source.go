@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+func catalogSourceCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "source",
+		Usage: "Vendor third-party catalogs into the gitops repo",
+		Commands: []*cli.Command{
+			catalogSourceAddCmd(),
+			catalogSourceListCmd(),
+			catalogSourceUpdateCmd(),
+			catalogSourceRemoveCmd(),
+		},
+	}
+}
+
+func catalogSourceAddCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "add",
+		Usage:     "Vendor the catalog of a git repo; its apps are added disabled as <name>-<app>",
+		ArgsUsage: "GIT-URL",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "name",
+				Usage: "Source name, used as the app name prefix (default: repo name from the URL)",
+			},
+			&cli.StringFlag{
+				Name:  "ref",
+				Usage: "Branch or tag to track (default: the remote HEAD)",
+			},
+			&cli.StringFlag{
+				Name:  "path",
+				Value: catalog.DefaultSourcePath,
+				Usage: "Catalog directory within the repo",
+			},
+		},
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			url := cmd.Args().First()
+			if url == "" {
+				return fmt.Errorf("git URL is required: sikifanso catalog source add GIT-URL")
+			}
+			res, err := catalog.AddSource(ctx, sess.GitOpsPath, catalog.SourceOptions{
+				Name: cmd.String("name"),
+				URL:  url,
+				Ref:  cmd.String("ref"),
+				Path: cmd.String("path"),
+			})
+			if err != nil {
+				return err
+			}
+			printSourceResult(cmd, res)
+			return nil
+		}),
+	}
+}
+
+func catalogSourceListCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "list",
+		Usage: "List vendored catalog sources",
+		Action: withSession(func(_ context.Context, cmd *cli.Command, sess *session.Session) error {
+			sources, err := catalog.ListSources(sess.GitOpsPath)
+			if err != nil {
+				return err
+			}
+			if outputJSON(cmd, sources) {
+				return nil
+			}
+			if len(sources) == 0 {
+				fmt.Fprintln(os.Stderr, "No catalog sources. Add one with: sikifanso catalog source add GIT-URL")
+				return nil
+			}
+			headers := []string{"NAME", "URL", "REF", "COMMIT", "APPS", "UPDATED"}
+			rows := make([][]string, 0, len(sources))
+			for _, s := range sources {
+				ref := s.Ref
+				if ref == "" {
+					ref = "HEAD"
+				}
+				commit := s.Commit
+				if len(commit) > 7 {
+					commit = commit[:7]
+				}
+				rows = append(rows, []string{s.Name, s.URL, ref, commit, fmt.Sprint(len(s.Apps)), s.UpdatedAt.Format("2006-01-02 15:04")})
+			}
+			printTable(os.Stderr, headers, rows)
+			return nil
+		}),
+	}
+}
+
+func catalogSourceUpdateCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "update",
+		Usage:     "Re-fetch sources and refresh their vendored apps (all sources when none is named)",
+		ArgsUsage: "[NAME...]",
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			names := cmd.Args().Slice()
+			if len(names) == 0 {
+				sources, err := catalog.ListSources(sess.GitOpsPath)
+				if err != nil {
+					return err
+				}
+				for _, s := range sources {
+					names = append(names, s.Name)
+				}
+				if len(names) == 0 {
+					fmt.Fprintln(os.Stderr, "No catalog sources to update")
+					return nil
+				}
+			}
+			for _, name := range names {
+				res, err := catalog.UpdateSource(ctx, sess.GitOpsPath, name)
+				if err != nil {
+					return err
+				}
+				printSourceResult(cmd, res)
+			}
+			return nil
+		}),
+	}
+}
+
+func catalogSourceRemoveCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "remove",
+		Usage:     "Remove a source and its vendored apps (they must be disabled)",
+		ArgsUsage: "NAME",
+		Action: withSession(func(_ context.Context, cmd *cli.Command, sess *session.Session) error {
+			name := cmd.Args().First()
+			if name == "" {
+				return fmt.Errorf("source name is required: sikifanso catalog source remove NAME")
+			}
+			removed, err := catalog.RemoveSource(sess.GitOpsPath, name)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%s source removed with %d apps ✓\n", color.GreenString(name), len(removed))
+			fmt.Fprintln(os.Stderr, "committed to gitops repo")
+			return nil
+		}),
+	}
+}
+
+func printSourceResult(cmd *cli.Command, res *catalog.SourceResult) {
+	if outputJSON(cmd, res) {
+		return
+	}
+	commit := res.Source.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	fmt.Fprintf(os.Stderr, "%s source at %s ✓\n", color.GreenString(res.Source.Name), commit)
+	for _, group := range []struct {
+		label string
+		names []string
+	}{{"added", res.Added}, {"updated", res.Updated}, {"removed", res.Removed}} {
+		if len(group.names) > 0 {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", group.label, strings.Join(group.names, ", "))
+		}
+	}
+	fmt.Fprintln(os.Stderr, "committed to gitops repo")
+	if len(res.Added) > 0 {
+		fmt.Fprintf(os.Stderr, "New apps are disabled; enable with: sikifanso app enable %s\n", res.Added[0])
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/alicanalbayrak/sikifanso/internal/argocd/grpcsync"
 	"github.com/alicanalbayrak/sikifanso/internal/catalog"
 	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
 	"github.com/alicanalbayrak/sikifanso/internal/prompt"
 	"github.com/alicanalbayrak/sikifanso/internal/session"
 	"github.com/alicanalbayrak/sikifanso/internal/tui"
@@ -31,6 +32,11 @@ func appCmd() *cli.Command {
 			appRemoveCmd(),
 			appEnableCmd(),
 			appDisableCmd(),
+			appPruneCmd(),
+			appGraphCmd(),
+			appValuesCmd(),
+			appOutdatedCmd(),
+			appUpgradeCmd(),
 			appSyncCmd(),
 			appStatusCmd(),
 			appDiffCmd(),
@@ -98,6 +104,33 @@ func isTerminal() bool {
 	return term.IsTerminal(int(os.Stdin.Fd()))
 }
 
+// commitCatalogToggles writes the catalog browser's toggles and commits
+// them together under the gitops lock. It returns the toggled names, sorted.
+func commitCatalogToggles(gitOpsPath string, toggled map[string]bool) ([]string, error) {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	var paths, names []string
+	for name, enabled := range toggled {
+		if err := catalog.SetEnabled(gitOpsPath, name, enabled); err != nil {
+			return nil, fmt.Errorf("setting %s enabled=%v: %w", name, enabled, err)
+		}
+		paths = append(paths, fmt.Sprintf("catalog/%s.yaml", name))
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	commitMsg := fmt.Sprintf("catalog: toggle %s", strings.Join(names, ", "))
+	if err := gitops.Commit(gitOpsPath, commitMsg, paths...); err != nil {
+		zapLogger.Error("failed to commit catalog changes", zap.Error(err))
+		return nil, fmt.Errorf("committing changes: %w", err)
+	}
+	return names, nil
+}
+
 func appAddAction(ctx context.Context, cmd *cli.Command, sess *session.Session) error { //nolint:gocyclo // interactive flow with multiple input paths
 	// If no args and no flags set and stdin is a TTY, launch interactive catalog browser.
 	if cmd.Args().Len() == 0 && !cmd.IsSet("repo") && !cmd.IsSet("chart") && isTerminal() {
@@ -119,21 +152,9 @@ func appAddAction(ctx context.Context, cmd *cli.Command, sess *session.Session)
 			return nil
 		}
 
-		// Apply toggled changes to disk and collect paths for commit.
-		var paths, names []string
-		for name, enabled := range toggled {
-			if err := catalog.SetEnabled(sess.GitOpsPath, name, enabled); err != nil {
-				return fmt.Errorf("setting %s enabled=%v: %w", name, enabled, err)
-			}
-			paths = append(paths, fmt.Sprintf("catalog/%s.yaml", name))
-			names = append(names, name)
-		}
-		sort.Strings(names)
-
-		commitMsg := fmt.Sprintf("catalog: toggle %s", strings.Join(names, ", "))
-		if err := gitops.Commit(sess.GitOpsPath, commitMsg, paths...); err != nil {
-			zapLogger.Error("failed to commit catalog changes", zap.Error(err))
-			return fmt.Errorf("committing changes: %w", err)
+		names, err := commitCatalogToggles(sess.GitOpsPath, toggled)
+		if err != nil {
+			return err
 		}
 
 		fmt.Fprintf(os.Stderr, "%s toggled: %s\n", color.GreenString("catalog"), strings.Join(names, ", "))
@@ -214,12 +235,31 @@ type appListItem struct {
 	Namespace string `json:"namespace"`
 	Source    string `json:"source"`
 	Enabled   *bool  `json:"enabled,omitempty"`
+	// Provenance is "explicit" or "auto" for enabled apps; AutoEnabledBy
+	// names the apps that pulled an auto-enabled dependency in.
+	Provenance    string   `json:"provenance,omitempty"`
+	AutoEnabledBy []string `json:"autoEnabledBy,omitempty"`
+}
+
+// provenanceLabel renders an item's provenance for the table view.
+func (i appListItem) provenanceLabel() string {
+	switch {
+	case i.Provenance == "":
+		return "-"
+	case len(i.AutoEnabledBy) > 0:
+		return fmt.Sprintf("%s (%s)", i.Provenance, strings.Join(i.AutoEnabledBy, ", "))
+	default:
+		return i.Provenance
+	}
 }
 
 func buildAppListItems(apps []app.AppInfo, catalogEntries []catalog.Entry, showAll bool) []appListItem {
 	items := make([]appListItem, 0, len(apps)+len(catalogEntries))
 	for _, a := range apps {
-		item := appListItem{Name: a.Name, Chart: a.Chart, Version: a.Version, Namespace: a.Namespace, Source: "custom"}
+		item := appListItem{
+			Name: a.Name, Chart: a.Chart, Version: a.Version, Namespace: a.Namespace,
+			Source: "custom", Provenance: catalog.ProvenanceExplicit,
+		}
 		if showAll {
 			item.Enabled = ptr.To(true)
 		}
@@ -227,7 +267,16 @@ func buildAppListItems(apps []app.AppInfo, catalogEntries []catalog.Entry, showA
 	}
 	for _, e := range catalogEntries {
 		if showAll || e.Enabled {
-			item := appListItem{Name: e.Name, Chart: e.Chart, Version: e.TargetRevision, Namespace: e.Namespace, Source: "catalog"}
+			item := appListItem{
+				Name: e.Name, Chart: e.Chart, Version: e.TargetRevision, Namespace: e.Namespace,
+				Source: "catalog", Provenance: e.Provenance(),
+			}
+			if e.Source != "" {
+				item.Source = "catalog:" + e.Source
+			}
+			if e.Enabled {
+				item.AutoEnabledBy = e.AutoEnabledBy
+			}
 			if showAll {
 				item.Enabled = ptr.To(e.Enabled)
 			}
@@ -262,13 +311,13 @@ func appListAction(_ context.Context, cmd *cli.Command, sess *session.Session) e
 		return nil
 	}
 
-	headers := []string{"NAME", "CHART", "VERSION", "NAMESPACE", "SOURCE"}
+	headers := []string{"NAME", "CHART", "VERSION", "NAMESPACE", "SOURCE", "PROVENANCE"}
 	if showAll {
 		headers = append(headers, "ENABLED")
 	}
 	rows := make([][]string, 0, len(items))
 	for _, item := range items {
-		row := []string{item.Name, item.Chart, item.Version, item.Namespace, item.Source}
+		row := []string{item.Name, item.Chart, item.Version, item.Namespace, item.Source, item.provenanceLabel()}
 		if showAll {
 			row = append(row, fmt.Sprintf("%v", ptr.Deref(item.Enabled, false)))
 		}
@@ -307,7 +356,7 @@ func appEnableCmd() *cli.Command {
 		Name:      "enable",
 		Usage:     "Enable a catalog application",
 		ArgsUsage: "NAME",
-		Flags:     waitSyncFlags(),
+		Flags:     append(waitSyncFlags(), capacityFlag()),
 		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
 			return appToggleAction(ctx, cmd, sess, true)
 		}),
@@ -320,10 +369,16 @@ func appDisableCmd() *cli.Command {
 		Name:      "disable",
 		Usage:     "Disable a catalog application",
 		ArgsUsage: "NAME",
-		Flags: append(waitSyncFlags(), &cli.BoolFlag{
-			Name:  "force",
-			Usage: "Bypass dependent-app safety check",
-		}),
+		Flags: append(waitSyncFlags(),
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Bypass dependent-app safety check",
+			},
+			&cli.BoolFlag{
+				Name:  "cascade",
+				Usage: "Also disable every app that depends on this one, directly or transitively",
+			},
+		),
 		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
 			return appToggleAction(ctx, cmd, sess, false)
 		}),
@@ -344,8 +399,23 @@ func appToggleAction(ctx context.Context, cmd *cli.Command, sess *session.Sessio
 		return fmt.Errorf("app name is required: sikifanso app %s NAME", verb)
 	}
 
-	force := !enable && cmd.Bool("force")
-	result, err := catalog.ToggleWithDeps(sess.GitOpsPath, name, enable, force)
+	mode := catalog.DisableSafe
+	if !enable {
+		switch {
+		case cmd.Bool("cascade") && cmd.Bool("force"):
+			return fmt.Errorf("--cascade and --force are mutually exclusive")
+		case cmd.Bool("cascade"):
+			mode = catalog.DisableCascade
+		case cmd.Bool("force"):
+			mode = catalog.DisableForce
+		}
+	}
+	if enable {
+		if err := checkCapacityAfter(ctx, cmd, sess.GitOpsPath, []string{name}, nil); err != nil {
+			return err
+		}
+	}
+	result, err := catalog.ToggleWithDeps(sess.GitOpsPath, name, enable, mode)
 	if err != nil {
 		return err
 	}
@@ -357,6 +427,9 @@ func appToggleAction(ctx context.Context, cmd *cli.Command, sess *session.Sessio
 	if len(result.AutoDeps) > 0 {
 		fmt.Fprintf(os.Stderr, "auto-enabled: %s\n", strings.Join(result.AutoDeps, ", "))
 	}
+	if len(result.Cascaded) > 0 {
+		fmt.Fprintf(os.Stderr, "cascade-disabled: %s\n", strings.Join(result.Cascaded, ", "))
+	}
 
 	fmt.Fprintf(os.Stderr, "%s committed to gitops repo\n", name)
 
@@ -369,6 +442,10 @@ func appToggleAction(ctx context.Context, cmd *cli.Command, sess *session.Sessio
 	if len(result.AutoDeps) > 0 {
 		syncApps = append(append([]string{}, result.AutoDeps...), name)
 	}
+	if len(result.Cascaded) > 0 {
+		// The orchestrator tears these down in reverse tier order.
+		syncApps = append(append([]string{}, result.Cascaded...), name)
+	}
 
 	if err := syncAfterMutation(ctx, cmd, sess, MutationOpts{
 		Operation:  op,
@@ -404,6 +481,10 @@ func catalogDisabledNamesComplete(ctx context.Context, cmd *cli.Command) {
 	catalogNamesComplete(ctx, cmd, func(e catalog.Entry) bool { return !e.Enabled })
 }
 
+func catalogAllNamesComplete(ctx context.Context, cmd *cli.Command) {
+	catalogNamesComplete(ctx, cmd, func(catalog.Entry) bool { return true })
+}
+
 func catalogEnabledNamesComplete(ctx context.Context, cmd *cli.Command) {
 	catalogNamesComplete(ctx, cmd, func(e catalog.Entry) bool { return e.Enabled })
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/urfave/cli/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	graphFormatDOT     = "dot"
+	graphFormatMermaid = "mermaid"
+
+	// graphHealthTimeout bounds the ArgoCD lookup so an unreachable cluster
+	// only costs a short delay before the graph is rendered without health.
+	graphHealthTimeout = 5 * time.Second
+)
+
+func appGraphCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "graph",
+		Usage:     "Render the catalog dependency graph as DOT, Mermaid or JSON",
+		ArgsUsage: "[APP...]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Graph format: dot, mermaid, json (--output json also selects json)",
+				Value: graphFormatDOT,
+			},
+			&cli.BoolFlag{
+				Name:  "offline",
+				Usage: "Skip the ArgoCD health lookup and colour nodes by enabled state only",
+			},
+		},
+		ShellComplete: catalogAllNamesComplete,
+		Action:        withSession(appGraphAction),
+	}
+}
+
+func appGraphAction(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+	format := cmd.String("format")
+	if cmd.String("output") == outputFormatJSON {
+		format = outputFormatJSON
+	}
+	switch format {
+	case graphFormatDOT, graphFormatMermaid, outputFormatJSON:
+	default:
+		return fmt.Errorf("unknown graph format %q: must be %s, %s or %s", format, graphFormatDOT, graphFormatMermaid, outputFormatJSON)
+	}
+
+	entries, err := catalog.List(sess.GitOpsPath)
+	if err != nil {
+		return fmt.Errorf("listing catalog: %w", err)
+	}
+	graph, err := catalog.BuildGraph(entries, cmd.Args().Slice())
+	if err != nil {
+		return err
+	}
+
+	if !cmd.Bool("offline") {
+		addGraphHealth(ctx, sess, &graph)
+	}
+
+	switch format {
+	case graphFormatMermaid:
+		fmt.Fprint(os.Stdout, graph.Mermaid())
+	case outputFormatJSON:
+		writeJSON(graph)
+	default:
+		fmt.Fprint(os.Stdout, graph.DOT())
+	}
+	return nil
+}
+
+// addGraphHealth fills sync and health status from ArgoCD for enabled nodes.
+// The graph is still useful without it, so failures are only logged.
+func addGraphHealth(ctx context.Context, sess *session.Session, graph *catalog.Graph) {
+	ctx, cancel := context.WithTimeout(ctx, graphHealthTimeout)
+	defer cancel()
+
+	client, err := grpcClientFromSession(ctx, sess)
+	if err != nil {
+		zapLogger.Debug("ArgoCD unavailable, rendering graph without health", zap.Error(err))
+		return
+	}
+	defer client.Close()
+
+	apps, err := client.ListApplications(ctx)
+	if err != nil {
+		zapLogger.Debug("listing ArgoCD applications, rendering graph without health", zap.Error(err))
+		return
+	}
+	byName := make(map[string]int, len(apps))
+	for i, a := range apps {
+		byName[a.Name] = i
+	}
+	for i := range graph.Nodes {
+		n := &graph.Nodes[i]
+		idx, ok := byName[n.Name]
+		if !ok || !n.Enabled {
+			continue
+		}
+		n.Sync = apps[idx].SyncStatus
+		n.Health = apps[idx].Health
+	}
+}
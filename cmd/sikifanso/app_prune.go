@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/argocd/grpcsync"
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+func appPruneCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "prune",
+		Usage: "Disable auto-enabled dependencies no longer required by any explicitly enabled app",
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "List orphaned dependencies without disabling them",
+			},
+		}, waitSyncFlags()...),
+		Action: withSession(appPruneAction),
+	}
+}
+
+func appPruneAction(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+	if cmd.Bool("dry-run") {
+		all, err := catalog.List(sess.GitOpsPath)
+		if err != nil {
+			return fmt.Errorf("listing catalog: %w", err)
+		}
+		orphans, err := catalog.Orphans(all)
+		if err != nil {
+			return err
+		}
+		if outputJSON(cmd, orphans) {
+			return nil
+		}
+		if len(orphans) == 0 {
+			fmt.Fprintln(os.Stderr, "No orphaned dependencies")
+			return nil
+		}
+		fmt.Fprintf(os.Stderr, "would prune: %s\n", strings.Join(orphans, ", "))
+		return nil
+	}
+
+	pruned, err := catalog.Prune(sess.GitOpsPath)
+	if err != nil {
+		return err
+	}
+	if len(pruned) == 0 {
+		fmt.Fprintln(os.Stderr, "No orphaned dependencies")
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "pruned: %s\n", strings.Join(pruned, ", "))
+	fmt.Fprintln(os.Stderr, "committed to gitops repo")
+
+	if err := syncAfterMutation(ctx, cmd, sess, MutationOpts{
+		Operation:  grpcsync.OpDisable,
+		Apps:       pruned,
+		AppSetName: "catalog",
+	}); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%s ✓\n", color.GreenString("prune complete"))
+	return nil
+}
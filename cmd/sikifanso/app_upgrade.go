@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/alicanalbayrak/sikifanso/internal/argocd/grpcsync"
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/helm"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+func appOutdatedCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "outdated",
+		Usage: "Show catalog apps whose charts have newer versions upstream",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "all",
+				Aliases: []string{"a"},
+				Usage:   "Show every catalog entry, including up-to-date ones",
+			},
+		},
+		Action: withSession(appOutdatedAction),
+	}
+}
+
+func appUpgradeCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "upgrade",
+		Usage:     "Bump the targetRevision of catalog apps to the newest chart version",
+		ArgsUsage: "[NAME...]",
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{
+				Name:  "all",
+				Usage: "Upgrade every outdated catalog entry",
+			},
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Allow major version upgrades",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print the upgrades without changing anything",
+			},
+		}, waitSyncFlags()...),
+		ShellComplete: catalogAllNamesComplete,
+		Action:        withSession(appUpgradeAction),
+	}
+}
+
+func appOutdatedAction(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+	entries, err := catalog.List(sess.GitOpsPath)
+	if err != nil {
+		return fmt.Errorf("listing catalog: %w", err)
+	}
+	reports := catalog.CheckVersions(ctx, entries, helm.ChartVersions)
+	if !cmd.Bool("all") {
+		filtered := reports[:0]
+		for _, r := range reports {
+			if r.Outdated || r.Error != "" {
+				filtered = append(filtered, r)
+			}
+		}
+		reports = filtered
+	}
+
+	if outputJSON(cmd, reports) {
+		return nil
+	}
+	if len(reports) == 0 {
+		fmt.Fprintln(os.Stderr, "All catalog charts are up to date")
+		return nil
+	}
+
+	headers := []string{"NAME", "CHART", "TARGET", "CURRENT", "LATEST", "MAJOR"}
+	rows := make([][]string, 0, len(reports))
+	for _, r := range reports {
+		latest, major := r.Latest, ""
+		if r.MajorBump {
+			major = color.YellowString("yes")
+		}
+		if r.Error != "" {
+			latest = color.RedString("error: " + r.Error)
+		}
+		rows = append(rows, []string{r.Name, r.Chart, r.TargetRevision, r.Current, latest, major})
+	}
+	printTable(os.Stderr, headers, rows)
+	return nil
+}
+
+func appUpgradeAction(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+	names := cmd.Args().Slice()
+	all := cmd.Bool("all")
+	if all == (len(names) > 0) {
+		return fmt.Errorf("specify app names or --all: sikifanso app upgrade NAME... | --all")
+	}
+
+	entries, err := catalog.List(sess.GitOpsPath)
+	if err != nil {
+		return fmt.Errorf("listing catalog: %w", err)
+	}
+	if !all {
+		byName := make(map[string]catalog.Entry, len(entries))
+		for _, e := range entries {
+			byName[e.Name] = e
+		}
+		selected := make([]catalog.Entry, 0, len(names))
+		for _, n := range names {
+			e, ok := byName[n]
+			if !ok {
+				return fmt.Errorf("app %q not found in catalog", n)
+			}
+			selected = append(selected, e)
+		}
+		entries = selected
+	}
+
+	enabled := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		enabled[e.Name] = e.Enabled
+	}
+
+	var (
+		bumps   []catalog.RevisionBump
+		refused []string
+	)
+	for _, r := range catalog.CheckVersions(ctx, entries, helm.ChartVersions) {
+		switch {
+		case r.Error != "":
+			if !all {
+				return fmt.Errorf("checking %s: %s", r.Name, r.Error)
+			}
+			fmt.Fprintf(os.Stderr, "  %s %s: %s\n", color.RedString("!"), r.Name, r.Error)
+		case !r.Outdated:
+			if !all {
+				fmt.Fprintf(os.Stderr, "%s is already at the latest version (%s)\n", r.Name, r.Current)
+			}
+		case r.MajorBump && !cmd.Bool("force"):
+			refused = append(refused, fmt.Sprintf("%s (%s -> %s)", r.Name, r.Current, r.Latest))
+		default:
+			to, err := catalog.BumpedRevision(r.TargetRevision, r.Latest)
+			if err != nil {
+				return err
+			}
+			bumps = append(bumps, catalog.RevisionBump{Name: r.Name, From: r.TargetRevision, To: to})
+		}
+	}
+
+	for _, r := range refused {
+		fmt.Fprintf(os.Stderr, "  %s %s is a major upgrade; re-run with --force\n", color.YellowString("skip"), r)
+	}
+	if !all && len(refused) > 0 && len(bumps) == 0 {
+		return fmt.Errorf("refusing major version upgrade without --force")
+	}
+	if !outputJSON(cmd, bumps) {
+		for _, b := range bumps {
+			fmt.Fprintf(os.Stderr, "  %s %s: %s -> %s\n", color.GreenString("↑"), b.Name, b.From, b.To)
+		}
+	}
+	if len(bumps) == 0 || cmd.Bool("dry-run") {
+		return nil
+	}
+
+	if err := catalog.ApplyRevisionBumps(sess.GitOpsPath, bumps); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "committed to gitops repo")
+
+	var toSync []string
+	for _, b := range bumps {
+		if enabled[b.Name] {
+			toSync = append(toSync, b.Name)
+		}
+	}
+	if len(toSync) == 0 {
+		return nil
+	}
+	return syncAfterMutation(ctx, cmd, sess, MutationOpts{
+		Operation:  grpcsync.OpSync,
+		Apps:       toSync,
+		AppSetName: "catalog",
+	})
+}
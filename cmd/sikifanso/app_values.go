@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/argocd/grpcsync"
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+func appValuesCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "values",
+		Usage: "Show and change Helm values overrides of catalog apps",
+		Commands: []*cli.Command{
+			appValuesShowCmd(),
+			appValuesSetCmd(),
+			appValuesUnsetCmd(),
+			appValuesEditCmd(),
+		},
+	}
+}
+
+// valuesMutationFlags are shared by the commands that change a values file.
+func valuesMutationFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.BoolFlag{
+			Name:  "skip-schema",
+			Usage: "Do not fetch the chart to validate against its values.schema.json",
+		},
+	}, waitSyncFlags()...)
+}
+
+func appValuesShowCmd() *cli.Command {
+	return &cli.Command{
+		Name:          "show",
+		Usage:         "Print the values overrides of a catalog app",
+		ArgsUsage:     "APP",
+		ShellComplete: catalogAllNamesComplete,
+		Action: withSession(func(_ context.Context, cmd *cli.Command, sess *session.Session) error {
+			name, err := valuesAppArg(cmd, "show")
+			if err != nil {
+				return err
+			}
+			data, err := catalog.ReadValues(sess.GitOpsPath, name)
+			if err != nil {
+				return err
+			}
+			if cmd.String("output") == outputFormatJSON {
+				vals, err := catalog.ParseValues(data)
+				if err != nil {
+					return err
+				}
+				outputJSON(cmd, vals)
+				return nil
+			}
+			if len(bytes.TrimSpace(data)) == 0 {
+				fmt.Fprintf(os.Stderr, "%s has no values overrides\n", name)
+				return nil
+			}
+			_, err = os.Stdout.Write(data)
+			return err
+		}),
+	}
+}
+
+func appValuesSetCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "set",
+		Usage:     "Set values of a catalog app using dotted paths (a.b.c=value)",
+		ArgsUsage: "APP [KEY=VALUE...]",
+		Flags: append([]cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "set",
+				Usage: "Value to set as a.b.c=value (repeatable)",
+			},
+		}, valuesMutationFlags()...),
+		ShellComplete: catalogAllNamesComplete,
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			name, err := valuesAppArg(cmd, "set")
+			if err != nil {
+				return err
+			}
+			assignments := append(cmd.Args().Tail(), cmd.StringSlice("set")...)
+			if len(assignments) == 0 {
+				return fmt.Errorf("at least one KEY=VALUE is required: sikifanso app values set %s --set a.b=c", name)
+			}
+			set := make(map[string]string, len(assignments))
+			for _, a := range assignments {
+				key, value, err := catalog.ParseSet(a)
+				if err != nil {
+					return err
+				}
+				set[key] = value
+			}
+			return editAppValues(ctx, cmd, sess, name, set, nil)
+		}),
+	}
+}
+
+func appValuesUnsetCmd() *cli.Command {
+	return &cli.Command{
+		Name:          "unset",
+		Usage:         "Remove values of a catalog app by dotted path",
+		ArgsUsage:     "APP KEY...",
+		Flags:         valuesMutationFlags(),
+		ShellComplete: catalogAllNamesComplete,
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			name, err := valuesAppArg(cmd, "unset")
+			if err != nil {
+				return err
+			}
+			keys := cmd.Args().Tail()
+			if len(keys) == 0 {
+				return fmt.Errorf("at least one KEY is required: sikifanso app values unset %s a.b.c", name)
+			}
+			return editAppValues(ctx, cmd, sess, name, nil, keys)
+		}),
+	}
+}
+
+func appValuesEditCmd() *cli.Command {
+	return &cli.Command{
+		Name:          "edit",
+		Usage:         "Edit the values overrides of a catalog app in $EDITOR",
+		ArgsUsage:     "APP",
+		Flags:         valuesMutationFlags(),
+		ShellComplete: catalogAllNamesComplete,
+		Action:        withSession(appValuesEditAction),
+	}
+}
+
+func appValuesEditAction(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+	name, err := valuesAppArg(cmd, "edit")
+	if err != nil {
+		return err
+	}
+	current, err := catalog.ReadValues(sess.GitOpsPath, name)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "sikifanso-values-"+name+"-*.yaml")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(current); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+
+	editor := editorCommand(os.Getenv("EDITOR"))
+	c := exec.CommandContext(ctx, editor[0], append(editor[1:], tmp.Name())...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("running editor %q: %w", strings.Join(editor, " "), err)
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return fmt.Errorf("reading edited values: %w", err)
+	}
+	return saveAppValues(ctx, cmd, sess, name, edited)
+}
+
+// editorCommand splits $EDITOR into a program and its arguments, e.g.
+// "code --wait", without a shell so it also works on Windows. Single or
+// double quotes group a path with spaces. It defaults to vi, or notepad on
+// Windows.
+func editorCommand(env string) []string {
+	var (
+		args    []string
+		cur     strings.Builder
+		quote   rune
+		inField bool
+	)
+	for _, r := range env {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inField = r, true
+		case r == ' ' || r == '\t':
+			if inField {
+				args = append(args, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		default:
+			cur.WriteRune(r)
+			inField = true
+		}
+	}
+	if inField {
+		args = append(args, cur.String())
+	}
+	if len(args) == 0 {
+		if runtime.GOOS == "windows" {
+			return []string{"notepad"}
+		}
+		return []string{"vi"}
+	}
+	return args
+}
+
+// valuesAppArg returns the APP argument or a usage error naming the subcommand.
+func valuesAppArg(cmd *cli.Command, sub string) (string, error) {
+	name := cmd.Args().First()
+	if name == "" {
+		return "", fmt.Errorf("app name is required: sikifanso app values %s APP", sub)
+	}
+	return name, nil
+}
+
+func editAppValues(ctx context.Context, cmd *cli.Command, sess *session.Session, name string, set map[string]string, unset []string) error {
+	_, changed, err := catalog.EditAndSaveValues(zapLogger, sess.GitOpsPath, name, set, unset, cmd.Bool("skip-schema"))
+	if err != nil {
+		return err
+	}
+	return afterValuesSaved(ctx, cmd, sess, name, changed)
+}
+
+// saveAppValues validates and commits new values, then syncs the app if it
+// is enabled.
+func saveAppValues(ctx context.Context, cmd *cli.Command, sess *session.Session, name string, data []byte) error {
+	changed, err := catalog.UpdateValues(zapLogger, sess.GitOpsPath, name, data, cmd.Bool("skip-schema"))
+	if err != nil {
+		return err
+	}
+	return afterValuesSaved(ctx, cmd, sess, name, changed)
+}
+
+// afterValuesSaved reports a values change and syncs the app if it is
+// enabled.
+func afterValuesSaved(ctx context.Context, cmd *cli.Command, sess *session.Session, name string, changed bool) error {
+	if !changed {
+		fmt.Fprintf(os.Stderr, "%s values unchanged\n", name)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%s values updated\n", color.GreenString(name))
+	fmt.Fprintln(os.Stderr, "committed to gitops repo")
+
+	entry, err := catalog.Find(sess.GitOpsPath, name)
+	if err != nil {
+		return err
+	}
+	if !entry.Enabled {
+		fmt.Fprintf(os.Stderr, "%s is disabled; values apply when it is enabled\n", name)
+		return nil
+	}
+	return syncAfterMutation(ctx, cmd, sess, MutationOpts{
+		Operation:  grpcsync.OpSync,
+		Apps:       []string{name},
+		AppSetName: "catalog",
+	})
+}
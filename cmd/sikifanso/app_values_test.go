@@ -0,0 +1,30 @@
+package main
+
+import (
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestEditorCommand(t *testing.T) {
+	defaultEditor := "vi"
+	if runtime.GOOS == "windows" {
+		defaultEditor = "notepad"
+	}
+	for _, tc := range []struct {
+		env  string
+		want []string
+	}{
+		{"", []string{defaultEditor}},
+		{"  ", []string{defaultEditor}},
+		{"nano", []string{"nano"}},
+		{"code --wait", []string{"code", "--wait"}},
+		{`"C:\Program Files\Notepad++\notepad++.exe" -multiInst`, []string{`C:\Program Files\Notepad++\notepad++.exe`, "-multiInst"}},
+		{"'/opt/my editor/bin/ed' -n", []string{"/opt/my editor/bin/ed", "-n"}},
+		{`emacs -nw ""`, []string{"emacs", "-nw", ""}},
+	} {
+		if got := editorCommand(tc.env); !slices.Equal(got, tc.want) {
+			t.Errorf("editorCommand(%q) = %q, want %q", tc.env, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/bundle"
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/preflight"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+func bundleCmd() *cli.Command {
+	return &cli.Command{
+		Name:     "bundle",
+		Usage:    "Build and inspect offline bundles for air-gapped cluster creation",
+		Commands: []*cli.Command{bundleCreateCmd(), bundleInspectCmd()},
+	}
+}
+
+func bundleCreateCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "create",
+		Usage:     "Download everything a cluster needs into one archive",
+		ArgsUsage: "FILE",
+		Description: "Bundles the k3s and k3d images, the Cilium, ArgoCD, agent and catalog charts\n" +
+			"with the images they run, and the bootstrap tree. Needs network access and Docker;\n" +
+			"create clusters from the result with: sikifanso cluster create --bundle FILE",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "bootstrap",
+				Usage: "Bootstrap template repo URL",
+				Value: gitops.DefaultBootstrapURL,
+			},
+			&cli.StringFlag{
+				Name:  "bootstrap-version",
+				Usage: "Bootstrap repo tag to clone (default: match CLI version; empty string forces HEAD)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "app",
+				Usage: "Bundle only these catalog apps and their dependencies (repeatable; default: the whole catalog)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "image",
+				Usage: "Extra image to preload, e.g. an agent workload (repeatable)",
+			},
+		},
+		Action: bundleCreateAction,
+	}
+}
+
+func bundleCreateAction(ctx context.Context, cmd *cli.Command) error {
+	file := cmd.Args().First()
+	if file == "" {
+		return fmt.Errorf("output file is required: sikifanso bundle create FILE")
+	}
+	bootstrap := cmd.String("bootstrap")
+	if err := preflight.CheckDocker(ctx); err != nil {
+		return err
+	}
+
+	m, err := bundle.Create(ctx, zapLogger, bundle.CreateOptions{
+		Output:       file,
+		BootstrapURL: bootstrap,
+		BootstrapVersion: resolveBootstrapVersion(
+			version,
+			bootstrap == gitops.DefaultBootstrapURL,
+			cmd.String("bootstrap-version"),
+			cmd.IsSet("bootstrap-version"),
+		),
+		Apps:       cmd.StringSlice("app"),
+		Images:     cmd.StringSlice("image"),
+		CLIVersion: version,
+	}, os.Stderr)
+	if err != nil {
+		return fmt.Errorf("creating bundle: %w", err)
+	}
+	if outputJSON(cmd, m) {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%s %s: %d charts, %d images\n", color.GreenString("bundled"), file,
+		len(m.Charts), len(m.HostImages)+len(m.Images))
+	fmt.Fprintf(os.Stderr, "Create a cluster from it with: sikifanso cluster create --bundle %s\n", file)
+	return nil
+}
+
+func bundleInspectCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "inspect",
+		Usage:     "Show what a bundle contains",
+		ArgsUsage: "FILE",
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			file := cmd.Args().First()
+			if file == "" {
+				return fmt.Errorf("bundle file is required: sikifanso bundle inspect FILE")
+			}
+			m, err := bundle.Inspect(file)
+			if err != nil {
+				return err
+			}
+			if outputJSON(cmd, m) {
+				return nil
+			}
+			bootstrap := m.BootstrapURL
+			if m.BootstrapVersion != "" {
+				bootstrap += " @ " + m.BootstrapVersion
+			}
+			fmt.Fprintf(os.Stderr, "Created:   %s by sikifanso %s\n", m.CreatedAt.Format("2006-01-02 15:04:05"), m.CLIVersion)
+			fmt.Fprintf(os.Stderr, "Bootstrap: %s\n", bootstrap)
+			fmt.Fprintf(os.Stderr, "k3s:       %s\n\n", m.K3sImage)
+
+			rows := make([][]string, 0, len(m.Charts))
+			for _, c := range m.Charts {
+				rows = append(rows, []string{c.Name, c.Chart, c.Version, c.RepoURL})
+			}
+			printTable(os.Stderr, []string{"COMPONENT", "CHART", "VERSION", "REPO"}, rows)
+			fmt.Fprintf(os.Stderr, "\nImages (%d):\n  %s\n", len(m.HostImages)+len(m.Images),
+				strings.Join(append(append([]string{}, m.HostImages...), m.Images...), "\n  "))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/preflight"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+// capacityFlag lets commands that enable apps bypass the capacity preflight.
+func capacityFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "ignore-capacity",
+		Usage: "Enable apps even when their resource requests exceed what Docker provides",
+	}
+}
+
+// checkCapacityAfter runs the capacity preflight on the apps enabled once
+// enable (and their dependencies) are turned on and disable turned off,
+// before anything is committed. It prints a breakdown when the set is near
+// or over what Docker provides and fails in the latter case unless
+// --ignore-capacity is set. Apps with no known size are always listed. An
+// unreachable Docker daemon skips the check.
+func checkCapacityAfter(ctx context.Context, cmd *cli.Command, gitOpsPath string, enable, disable []string) error {
+	report, err := preflight.CheckCapacityAfter(ctx, gitOpsPath, enable, disable)
+	if err != nil {
+		return err
+	}
+	if report == nil {
+		zapLogger.Debug("capacity preflight skipped")
+		return nil
+	}
+	switch report.Status {
+	case preflight.CapacityOK:
+		printUnknownFootprint(report)
+		return nil
+	case preflight.CapacityWarn:
+		fmt.Fprintf(os.Stderr, "%s enabled apps use most of Docker's resources; pods may be slow to schedule\n", color.YellowString("warning:"))
+		printCapacityBreakdown(report)
+		return nil
+	}
+
+	printCapacityBreakdown(report)
+	if cmd.Bool("ignore-capacity") {
+		fmt.Fprintf(os.Stderr, "%s continuing despite insufficient capacity (--ignore-capacity)\n", color.YellowString("warning:"))
+		return nil
+	}
+	return fmt.Errorf("%w; disable apps, give Docker more resources, or pass --ignore-capacity", report.Err())
+}
+
+// printCapacityBreakdown lists the platform and per-app footprint, largest
+// memory consumers first.
+func printCapacityBreakdown(r *preflight.CapacityReport) {
+	fmt.Fprintf(os.Stderr, "Requested: %s\n", r.Summary())
+	headers := []string{"APP", "CPU", "MEMORY", "DISK"}
+	rows := make([][]string, 0, len(r.Apps.Apps)+2)
+	rows = append(rows, footprintRow("(platform)", r.Platform))
+	for _, a := range r.Apps.Apps {
+		name := a.Name
+		if a.Estimated {
+			name += " (estimate)"
+		}
+		rows = append(rows, footprintRow(name, a.Footprint))
+	}
+	rows = append(rows, footprintRow("TOTAL", r.Required))
+	printTable(os.Stderr, headers, rows)
+	printUnknownFootprint(r)
+}
+
+// printUnknownFootprint lists the apps left out of the totals because their
+// size is unknown.
+func printUnknownFootprint(r *preflight.CapacityReport) {
+	if len(r.Apps.Unknown) > 0 {
+		fmt.Fprintf(os.Stderr, "No resource metadata (not counted): %s\n", strings.Join(r.Apps.Unknown, ", "))
+	}
+}
+
+func footprintRow(name string, f catalog.Footprint) []string {
+	disk := "-"
+	if f.DiskBytes > 0 {
+		disk = catalog.FormatBytes(f.DiskBytes)
+	}
+	return []string{name, catalog.FormatCPU(f.CPUMillis), catalog.FormatBytes(f.MemoryBytes), disk}
+}
@@ -18,6 +18,8 @@ func clusterCmd() *cli.Command {
 			clusterDashboardCmd(),
 			clusterUpgradeCmd(),
 			clusterProfilesCmd(),
+			clusterApplyCmd(),
+			clusterExportCmd(),
 		},
 	}
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/alicanalbayrak/sikifanso/internal/argocd/grpcsync"
 	"github.com/alicanalbayrak/sikifanso/internal/cluster"
 	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
 	"github.com/alicanalbayrak/sikifanso/internal/kube"
 	"github.com/alicanalbayrak/sikifanso/internal/preflight"
 	"github.com/alicanalbayrak/sikifanso/internal/profile"
@@ -37,8 +39,31 @@ func clusterCreateCmd() *cli.Command {
 				Name:  "profile",
 				Usage: "Enable a predefined set of catalog apps (e.g. agent-dev, agent-safe, rag; comma-separated for composition)",
 			},
+			&cli.IntFlag{
+				Name:  "servers",
+				Usage: "Number of k3d server nodes (default: from infra/platform.yaml)",
+			},
+			&cli.IntFlag{
+				Name:  "agents",
+				Usage: "Number of general-purpose k3d agent nodes (default: from infra/platform.yaml)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "agent-pool",
+				Usage: "Add labelled and tainted agent nodes as NAME=COUNT (repeatable; e.g. sandbox=2 isolates agent sandboxes)",
+			},
+			&cli.BoolFlag{
+				Name:  "registry",
+				Usage: "Create a local image registry; push to it with sikifanso image push",
+			},
+			&cli.StringFlag{
+				Name:  "bundle",
+				Usage: "Create the cluster offline from a bundle made with sikifanso bundle create (replaces --bootstrap)",
+			},
+			specFileFlag(false),
+			capacityFlag(),
 		},
-		Action: clusterCreateAction,
+		ShellComplete: profileFlagComplete,
+		Action:        clusterCreateAction,
 	}
 }
 
@@ -46,13 +71,21 @@ func clusterCreateAction(ctx context.Context, cmd *cli.Command) error {
 	if err := rejectPositionalArgs(cmd); err != nil {
 		return err
 	}
+	if cmd.IsSet("file") {
+		return clusterCreateFromSpec(ctx, cmd)
+	}
 	name := cmd.String("cluster")
 	if !cmd.IsSet("cluster") {
 		name = prompt.String("Cluster name", defaultClusterName)
 	}
 
+	bundleFile := cmd.String("bundle")
+	if bundleFile != "" && (cmd.IsSet("bootstrap") || cmd.IsSet("bootstrap-version")) {
+		return fmt.Errorf("--bundle carries its own bootstrap tree; drop --bootstrap and --bootstrap-version")
+	}
+
 	bootstrap := cmd.String("bootstrap")
-	if !cmd.IsSet("bootstrap") {
+	if !cmd.IsSet("bootstrap") && bundleFile == "" {
 		bootstrap = prompt.String("Bootstrap repo", gitops.DefaultBootstrapURL)
 	}
 
@@ -65,13 +98,41 @@ func clusterCreateAction(ctx context.Context, cmd *cli.Command) error {
 	)
 
 	// Validate profile before creating the cluster — fail fast on typos.
+	// Only built-in and ~/.sikifanso/profiles/ are visible at this point; a
+	// name that is missing may still be defined in the bootstrap repo's
+	// profiles/ directory, so that case is re-checked after the clone.
 	profileStr := cmd.String("profile")
+	if profileStr != "" {
+		if _, err := profile.Resolve("", profileStr); err != nil {
+			if !errors.Is(err, profile.ErrNotFound) {
+				return err
+			}
+			zapLogger.Info("profile not found locally, will look in the gitops repo", zap.String("profile", profileStr))
+		}
+	}
+
+	opts := cluster.Options{
+		BootstrapURL:     bootstrap,
+		BootstrapVersion: bootstrapVersion,
+		Registry:         cmd.Bool("registry"),
+		Bundle:           bundleFile,
+	}
+	if err := topologyOptions(cmd, &opts); err != nil {
+		return err
+	}
+
+	// The profile's catalog apps are only known once the bootstrap repo is
+	// cloned, so the profile is resolved and its capacity checked between the
+	// clone and the creation of any container.
 	var profileApps []string
 	if profileStr != "" {
-		var err error
-		profileApps, err = profile.Resolve(profileStr)
-		if err != nil {
-			return err
+		opts.Preflight = func(gitOpsPath string) error {
+			apps, err := profile.Resolve(gitOpsPath, profileStr)
+			if err != nil {
+				return fmt.Errorf("resolving profile: %w", err)
+			}
+			profileApps = apps
+			return checkCapacityAfter(ctx, cmd, gitOpsPath, apps, nil)
 		}
 	}
 
@@ -82,21 +143,16 @@ func clusterCreateAction(ctx context.Context, cmd *cli.Command) error {
 	}
 	zapLogger.Info("all preflight checks passed")
 
-	sess, err := cluster.Create(ctx, zapLogger, name, cluster.Options{
-		BootstrapURL:     bootstrap,
-		BootstrapVersion: bootstrapVersion,
-	})
+	sess, err := cluster.Create(ctx, zapLogger, name, opts)
 	if err != nil {
 		zapLogger.Error("cluster creation failed", zap.Error(err))
 		return err
 	}
 
 	// Apply profile after cluster creation — enables catalog apps and commits.
-	if len(profileApps) > 0 {
+	if profileStr != "" {
 		zapLogger.Info("applying profile", zap.String("profile", profileStr), zap.Strings("apps", profileApps))
-		autoAdded, err := profile.Apply(sess.GitOpsPath, profileStr, profileApps, func(msg string) {
-			zapLogger.Warn(msg)
-		})
+		autoAdded, err := profile.Apply(sess.GitOpsPath, profileStr, profileApps)
 		if err != nil {
 			return fmt.Errorf("applying profile: %w", err)
 		}
@@ -151,6 +207,44 @@ func clusterCreateAction(ctx context.Context, cmd *cli.Command) error {
 	return nil
 }
 
+// topologyOptions sets the node topology flags that were given on opts;
+// the rest comes from the bootstrap repo's infra/platform.yaml.
+func topologyOptions(cmd *cli.Command, opts *cluster.Options) error {
+	if cmd.IsSet("servers") {
+		servers := int(cmd.Int("servers"))
+		opts.Servers = &servers
+	}
+	if cmd.IsSet("agents") {
+		agents := int(cmd.Int("agents"))
+		opts.Agents = &agents
+	}
+	if cmd.IsSet("agent-pool") {
+		pools, err := parseAgentPools(cmd.StringSlice("agent-pool"))
+		if err != nil {
+			return err
+		}
+		opts.AgentPools = pools
+	}
+	return nil
+}
+
+// parseAgentPools parses NAME=COUNT --agent-pool values.
+func parseAgentPools(specs []string) ([]infraconfig.NodePool, error) {
+	pools := make([]infraconfig.NodePool, 0, len(specs))
+	for _, spec := range specs {
+		pool, err := infraconfig.ParseNodePool(spec)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	topo := infraconfig.PlatformConfig{Servers: 1, AgentPools: pools}
+	if err := topo.ValidateTopology(); err != nil {
+		return nil, err
+	}
+	return pools, nil
+}
+
 // resolveBootstrapVersion returns the bootstrap tag to pin, or "" for HEAD.
 //
 // Resolution order:
@@ -87,3 +87,19 @@ func TestResolveBootstrapVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestParseAgentPools(t *testing.T) {
+	pools, err := parseAgentPools([]string{"sandbox=2", "gpu=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pools) != 2 || pools[0].Name != "sandbox" || pools[0].Count != 2 || pools[1].Name != "gpu" {
+		t.Errorf("pools = %+v", pools)
+	}
+
+	for _, specs := range [][]string{{"sandbox"}, {"sandbox=0"}, {"sandbox=1", "sandbox=2"}} {
+		if _, err := parseAgentPools(specs); err == nil {
+			t.Errorf("%v: expected an error", specs)
+		}
+	}
+}
@@ -92,8 +92,8 @@ func showClusterInfo(ctx context.Context, cmd *cli.Command, name string) error {
 	return nil
 }
 
-// printClusterHealth queries the Kubernetes API and displays node readiness
-// and pod counts per namespace. Gracefully degrades on errors.
+// printClusterHealth queries the Kubernetes API and displays node readiness,
+// per node pool, and pod counts per namespace. Gracefully degrades on errors.
 func printClusterHealth(ctx context.Context, clusterName string) {
 	cs, err := kube.ClientForCluster(clusterName)
 	if err != nil {
@@ -116,7 +116,19 @@ func printClusterHealth(ctx context.Context, clusterName string) {
 		if kube.NodeReady(n) {
 			status = color.GreenString("Ready")
 		}
-		fmt.Fprintf(os.Stderr, "    %-40s %s\n", n.Name, status)
+		fmt.Fprintf(os.Stderr, "    %-40s %-10s %s\n", n.Name, kube.NodePool(n), status)
+	}
+
+	fmt.Fprintf(os.Stderr, "\n  Node pools:\n")
+	fmt.Fprintf(os.Stderr, "    %-30s %8s\n", "POOL", "READY")
+	for _, p := range kube.PoolStatuses(nodes.Items) {
+		ready := fmt.Sprintf("%d/%d", p.Ready, p.Nodes)
+		if p.Ready < p.Nodes {
+			ready = color.RedString("%8s", ready)
+		} else {
+			ready = fmt.Sprintf("%8s", ready)
+		}
+		fmt.Fprintf(os.Stderr, "    %-30s %s\n", p.Name, ready)
 	}
 
 	// Pods
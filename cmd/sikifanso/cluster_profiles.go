@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"strings"
 
+	"github.com/alicanalbayrak/sikifanso/internal/argocd/grpcsync"
 	"github.com/alicanalbayrak/sikifanso/internal/profile"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
 	"github.com/urfave/cli/v3"
 )
 
@@ -14,18 +18,172 @@ func clusterProfilesCmd() *cli.Command {
 		Name:  "profiles",
 		Usage: "List available cluster profiles for --profile flag",
 		Action: func(_ context.Context, cmd *cli.Command) error {
-			profiles := profile.List()
+			profiles, err := profile.List(profileGitOpsPath(cmd))
+			if err != nil {
+				return fmt.Errorf("loading profiles: %w", err)
+			}
 			if outputJSON(cmd, profiles) {
 				return nil
 			}
 
-			headers := []string{"NAME", "DESCRIPTION", "APPS"}
+			headers := []string{"NAME", "SOURCE", "DESCRIPTION", "APPS"}
 			rows := make([][]string, 0, len(profiles))
 			for _, p := range profiles {
-				rows = append(rows, []string{p.Name, p.Description, strings.Join(p.Apps, ", ")})
+				rows = append(rows, []string{p.Name, p.Source, p.Description, strings.Join(p.Apps, ", ")})
 			}
 			printTable(os.Stderr, headers, rows)
 			return nil
 		},
+		Commands: []*cli.Command{
+			profileApplyCmd(),
+		},
+	}
+}
+
+func profileApplyCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "apply",
+		Usage:     "Apply a profile to a running cluster",
+		ArgsUsage: "NAME",
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{
+				Name:  "exact",
+				Usage: "Converge the catalog on the profile: also disable enabled apps it does not need",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print the plan without changing anything",
+			},
+			capacityFlag(),
+		}, waitSyncFlags()...),
+		ShellComplete: profileNameComplete,
+		Action:        withSession(profileApplyAction),
+	}
+}
+
+func profileApplyAction(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+	name := cmd.Args().First()
+	if name == "" {
+		return fmt.Errorf("profile name is required: sikifanso cluster profiles apply NAME")
+	}
+
+	apps, err := profile.Resolve(sess.GitOpsPath, name)
+	if err != nil {
+		return err
+	}
+	plan, err := profile.NewPlan(sess.GitOpsPath, name, apps, cmd.Bool("exact"))
+	if err != nil {
+		return err
+	}
+
+	// The plan is always shown before anything is written.
+	if !outputJSON(cmd, plan) {
+		printProfilePlan(plan)
+	}
+	if plan.Empty() {
+		fmt.Fprintf(os.Stderr, "%s already applied, nothing to do\n", color.GreenString(name))
+		return nil
+	}
+	if cmd.Bool("dry-run") {
+		return nil
+	}
+	if err := checkCapacityAfter(ctx, cmd, sess.GitOpsPath, plan.Enable, plan.Disable); err != nil {
+		return err
+	}
+
+	if err := profile.ApplyPlan(sess.GitOpsPath, plan); err != nil {
+		return fmt.Errorf("applying profile: %w", err)
+	}
+
+	// Tear down first so removed apps free their resources before new ones start.
+	if len(plan.Disable) > 0 {
+		if err := syncAfterMutation(ctx, cmd, sess, MutationOpts{
+			Operation:  grpcsync.OpDisable,
+			Apps:       plan.Disable,
+			AppSetName: "catalog",
+		}); err != nil {
+			return err
+		}
+	}
+	if len(plan.Enable) > 0 {
+		if err := syncAfterMutation(ctx, cmd, sess, MutationOpts{
+			Operation:  grpcsync.OpEnable,
+			Apps:       plan.Enable,
+			AppSetName: "catalog",
+		}); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%s applied ✓\n", color.GreenString(name))
+	return nil
+}
+
+// printProfilePlan writes the apps a profile will add and remove.
+func printProfilePlan(plan profile.Plan) {
+	mode := "additive"
+	if plan.Exact {
+		mode = "exact"
+	}
+	fmt.Fprintf(os.Stderr, "Plan for profile %s (%s):\n", color.GreenString(plan.Profile), mode)
+	printCatalogPlan(plan)
+}
+
+// printCatalogPlan writes the catalog apps a plan enables, disables and
+// promotes, and a one-line summary.
+func printCatalogPlan(plan profile.Plan) {
+	auto := make(map[string]bool, len(plan.AutoAdded))
+	for _, a := range plan.AutoAdded {
+		auto[a] = true
+	}
+	for _, a := range plan.Enable {
+		line := "  " + color.GreenString("+ "+a)
+		if auto[a] {
+			line += " (dependency)"
+		}
+		fmt.Fprintln(os.Stderr, line)
+	}
+	for _, a := range plan.Disable {
+		fmt.Fprintln(os.Stderr, "  "+color.RedString("- "+a))
+	}
+	for _, a := range plan.Promote {
+		fmt.Fprintf(os.Stderr, "  ~ %s (mark explicit)\n", a)
+	}
+	for _, a := range plan.Reattribute {
+		fmt.Fprintf(os.Stderr, "  ~ %s (dependency of %s)\n", a, strings.Join(plan.RequiredBy(a), ", "))
+	}
+	fmt.Fprintf(os.Stderr, "  %d to enable, %d to disable, %d unchanged\n",
+		len(plan.Enable), len(plan.Disable), len(plan.Unchanged))
+}
+
+// profileGitOpsPath returns the gitops repo of the --cluster session so its
+// profiles/ directory is included, or "" when the cluster does not exist yet.
+func profileGitOpsPath(cmd *cli.Command) string {
+	sess, err := session.Load(cmd.String("cluster"))
+	if err != nil {
+		return ""
+	}
+	return sess.GitOpsPath
+}
+
+// profileNameComplete suggests profile names for positional arguments.
+func profileNameComplete(_ context.Context, cmd *cli.Command) {
+	names, err := profile.Names(profileGitOpsPath(cmd))
+	if err != nil {
+		return
+	}
+	for _, n := range names {
+		_, _ = fmt.Fprintln(cmd.Root().Writer, n)
+	}
+}
+
+// profileFlagComplete suggests profile names when completing the value of
+// --profile, and falls back to the default flag/command completion otherwise.
+func profileFlagComplete(ctx context.Context, cmd *cli.Command) {
+	args := os.Args
+	if n := len(args); n < 2 || args[n-2] != "--profile" {
+		cli.DefaultCompleteWithFlags(ctx, cmd)
+		return
 	}
+	profileNameComplete(ctx, cmd)
 }
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/argocd/grpcsync"
+	"github.com/alicanalbayrak/sikifanso/internal/cluster"
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/preflight"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/alicanalbayrak/sikifanso/internal/spec"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+	"go.uber.org/zap"
+)
+
+// specFileFlag is the --file flag of the commands that read a cluster spec.
+func specFileFlag(required bool) cli.Flag {
+	return &cli.StringFlag{
+		Name:     "file",
+		Aliases:  []string{"f"},
+		Usage:    "Cluster spec file (see cluster export), e.g. " + spec.DefaultFile,
+		Required: required,
+	}
+}
+
+// specOnlyFlags are the cluster create flags a spec file replaces.
+var specOnlyFlags = []string{"bootstrap", "bootstrap-version", "profile", "servers", "agents", "agent-pool", "registry"}
+
+// clusterCreateFromSpec creates the cluster described by the --file spec
+// and then applies its apps, values and agents.
+func clusterCreateFromSpec(ctx context.Context, cmd *cli.Command) error {
+	file := cmd.String("file")
+	for _, f := range specOnlyFlags {
+		if cmd.IsSet(f) {
+			return fmt.Errorf("--%s cannot be combined with --file; set it in %s", f, file)
+		}
+	}
+	s, err := spec.Load(file)
+	if err != nil {
+		return err
+	}
+	name, err := specClusterName(cmd, s)
+	if err != nil {
+		return err
+	}
+
+	bundleFile := cmd.String("bundle")
+	opts := cluster.Options{
+		BootstrapURL: gitops.DefaultBootstrapURL,
+		Registry:     s.Registry,
+		Bundle:       bundleFile,
+	}
+	var explicitVersion *string
+	if b := s.Bootstrap; b != nil {
+		if bundleFile != "" {
+			return fmt.Errorf("--bundle carries its own bootstrap tree; drop bootstrap from %s", file)
+		}
+		if b.URL != "" {
+			opts.BootstrapURL = b.URL
+		}
+		explicitVersion = b.Version
+	}
+	opts.BootstrapVersion = resolveBootstrapVersion(
+		version,
+		opts.BootstrapURL == gitops.DefaultBootstrapURL,
+		ptrValue(explicitVersion),
+		explicitVersion != nil,
+	)
+	if t := s.Topology; t != nil {
+		opts.Servers, opts.Agents, opts.AgentPools = t.Servers, t.Agents, t.AgentPools
+	}
+
+	// The catalog is only readable after the clone, so the capacity check
+	// runs between the clone and the creation of any container.
+	opts.Preflight = func(gitOpsPath string) error {
+		apps, err := spec.CatalogApps(gitOpsPath, s)
+		if err != nil {
+			return err
+		}
+		return checkCapacityAfter(ctx, cmd, gitOpsPath, apps, nil)
+	}
+
+	zapLogger.Info("running preflight checks")
+	if err := preflight.CheckDocker(ctx); err != nil {
+		zapLogger.Error("preflight check failed", zap.Error(err))
+		return err
+	}
+	zapLogger.Info("all preflight checks passed")
+
+	sess, err := cluster.Create(ctx, zapLogger, name, opts)
+	if err != nil {
+		zapLogger.Error("cluster creation failed", zap.Error(err))
+		return err
+	}
+
+	plan, err := spec.NewPlan(sess, s, false)
+	if err != nil {
+		return fmt.Errorf("%s not applied: %w", file, err)
+	}
+	if !plan.Empty() {
+		printSpecPlan(file, plan)
+		// Bundled charts are served in-cluster, out of reach of the schema check.
+		if err := plan.Apply(zapLogger, sess, sess.Bundle != nil); err != nil {
+			return fmt.Errorf("applying %s: %w", file, err)
+		}
+		if err := syncSpecPlan(ctx, cmd, sess, plan); err != nil {
+			zapLogger.Warn("post-create sync did not fully succeed", zap.Error(err))
+		}
+		if err := provisionSpecKeys(ctx, sess, plan); err != nil {
+			return fmt.Errorf("applying %s: %w", file, err)
+		}
+	}
+
+	printClusterInfo(sess)
+	return nil
+}
+
+func clusterApplyCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "apply",
+		Usage: "Converge a running cluster on a cluster spec file",
+		Flags: append([]cli.Flag{
+			specFileFlag(true),
+			&cli.BoolFlag{
+				Name:  "exact",
+				Usage: "Also disable catalog apps and remove custom apps and agents the spec does not list",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Print the plan without changing anything",
+			},
+			&cli.BoolFlag{
+				Name:  "skip-schema",
+				Usage: "Do not fetch charts to validate values against their values.schema.json",
+			},
+			capacityFlag(),
+		}, waitSyncFlags()...),
+		Action: wrapAction(clusterApplyAction),
+	}
+}
+
+func clusterApplyAction(ctx context.Context, cmd *cli.Command) error {
+	if err := rejectPositionalArgs(cmd); err != nil {
+		return err
+	}
+	file := cmd.String("file")
+	s, err := spec.Load(file)
+	if err != nil {
+		return err
+	}
+	name, err := specClusterName(cmd, s)
+	if err != nil {
+		return err
+	}
+	sess, err := session.Load(name)
+	if err != nil {
+		return fmt.Errorf("loading session for cluster %q: %w", name, err)
+	}
+
+	plan, err := spec.NewPlan(sess, s, cmd.Bool("exact"))
+	if err != nil {
+		return err
+	}
+	// The plan is always shown before anything is written.
+	if !outputJSON(cmd, plan) {
+		printSpecPlan(file, plan)
+	}
+	if plan.Empty() {
+		fmt.Fprintf(os.Stderr, "%s already matches %s, nothing to do\n", color.GreenString(name), file)
+		return nil
+	}
+	if cmd.Bool("dry-run") {
+		return nil
+	}
+	if err := checkCapacityAfter(ctx, cmd, sess.GitOpsPath, plan.Catalog.Enable, plan.Catalog.Disable); err != nil {
+		return err
+	}
+
+	if err := plan.Apply(zapLogger, sess, cmd.Bool("skip-schema")); err != nil {
+		return fmt.Errorf("applying %s: %w", file, err)
+	}
+	fmt.Fprintln(os.Stderr, "committed to gitops repo")
+	// New agents get their keys even when the sync fails, or they would
+	// stay without one.
+	syncErr := syncSpecPlan(ctx, cmd, sess, plan)
+	if err := errors.Join(syncErr, provisionSpecKeys(ctx, sess, plan)); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%s converged on %s ✓\n", color.GreenString(name), file)
+	return nil
+}
+
+func clusterExportCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "export",
+		Usage: "Print the cluster spec of a running cluster",
+		Description: "Writes a spec that cluster create -f recreates the cluster from:\n" +
+			"  sikifanso cluster export > " + spec.DefaultFile,
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			if err := rejectPositionalArgs(cmd); err != nil {
+				return err
+			}
+			s, warnings, err := spec.Export(ctx, sess)
+			if err != nil {
+				return err
+			}
+			// Warnings go to stderr so the spec on stdout stays clean.
+			for _, w := range warnings {
+				fmt.Fprintf(os.Stderr, "%s %s\n", color.YellowString("warning:"), w)
+			}
+			if outputJSON(cmd, s) {
+				return nil
+			}
+			data, err := s.Marshal()
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(data)
+			return err
+		}),
+	}
+}
+
+// specClusterName returns the cluster a spec targets: its name, which
+// --cluster may repeat but not contradict, or the --cluster default.
+func specClusterName(cmd *cli.Command, s *spec.Spec) (string, error) {
+	name := cmd.String("cluster")
+	switch {
+	case s.Name == "":
+		return name, nil
+	case cmd.IsSet("cluster") && name != s.Name:
+		return "", fmt.Errorf("--cluster %s does not match the spec's name %s", name, s.Name)
+	default:
+		return s.Name, nil
+	}
+}
+
+// syncSpecPlan syncs everything an applied spec plan changed: removals
+// first so they free their resources, then additions and updates.
+func syncSpecPlan(ctx context.Context, cmd *cli.Command, sess *session.Session, plan *spec.Plan) error {
+	for _, m := range []MutationOpts{
+		{Operation: grpcsync.OpDisable, Apps: plan.Catalog.Disable, AppSetName: "catalog"},
+		{Operation: grpcsync.OpDisable, Apps: plan.CustomApps.Remove, AppSetName: "root"},
+		{Operation: grpcsync.OpDisable, Apps: plan.Agents.Remove, AppSetName: "agents"},
+		{Operation: grpcsync.OpEnable, Apps: plan.Catalog.Enable, AppSetName: "catalog"},
+		{Operation: grpcsync.OpSync, Apps: plan.Resync(), AppSetName: "catalog"},
+		{Operation: grpcsync.OpEnable, Apps: plan.CustomApps.Add, AppSetName: "root"},
+		{Operation: grpcsync.OpSync, Apps: plan.CustomApps.Update, AppSetName: "root"},
+		{Operation: grpcsync.OpEnable, Apps: plan.Agents.Add, AppSetName: "agents"},
+		{Operation: grpcsync.OpSync, Apps: plan.Agents.Update, AppSetName: "agents"},
+	} {
+		if len(m.Apps) == 0 {
+			continue
+		}
+		if err := syncAfterMutation(ctx, cmd, sess, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// provisionSpecKeys gives the agents an applied plan created their LiteLLM
+// keys once the plan is synced.
+func provisionSpecKeys(ctx context.Context, sess *session.Session, plan *spec.Plan) error {
+	keys, err := plan.ProvisionKeys(ctx, sess)
+	for _, name := range plan.Agents.Add {
+		if key, ok := keys[name]; ok {
+			fmt.Fprintf(os.Stderr, "LLM key %s stored in secret agent-%s/%s (%s)\n",
+				color.GreenString(key.Alias), name, agent.KeySecretName, formatKeyLimits(key))
+		}
+	}
+	return err
+}
+
+// printSpecPlan writes the changes applying a spec makes.
+func printSpecPlan(file string, plan *spec.Plan) {
+	mode := "additive"
+	if plan.Catalog.Exact {
+		mode = "exact"
+	}
+	fmt.Fprintf(os.Stderr, "Plan for %s (%s):\n", color.GreenString(file), mode)
+	printCatalogPlan(plan.Catalog)
+	for _, a := range plan.Values {
+		fmt.Fprintf(os.Stderr, "  ~ %s (values)\n", a)
+	}
+	printSpecChanges("app", plan.CustomApps)
+	printSpecChanges("agent", plan.Agents)
+	for _, w := range plan.Warnings {
+		fmt.Fprintf(os.Stderr, "%s %s\n", color.YellowString("warning:"), w)
+	}
+}
+
+func printSpecChanges(kind string, c spec.Changes) {
+	for _, n := range c.Add {
+		fmt.Fprintln(os.Stderr, "  "+color.GreenString("+ "+kind+" "+n))
+	}
+	for _, n := range c.Remove {
+		fmt.Fprintln(os.Stderr, "  "+color.RedString("- "+kind+" "+n))
+	}
+	for _, n := range c.Update {
+		fmt.Fprintf(os.Stderr, "  ~ %s %s\n", kind, n)
+	}
+}
+
+func ptrValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
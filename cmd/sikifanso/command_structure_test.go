@@ -32,7 +32,7 @@ func findCommand(cmds []*cli.Command, name string) *cli.Command {
 func TestTopLevelVisibleCommands(t *testing.T) {
 	app := newApp()
 	got := collectCommandNames(app.Commands, false)
-	want := []string{"agent", "app", "cluster", "snapshot"}
+	want := []string{"agent", "app", "bundle", "catalog", "cluster", "image", "network", "snapshot"}
 
 	if !slices.Equal(got, want) {
 		t.Errorf("visible top-level commands = %v, want %v", got, want)
@@ -52,7 +52,7 @@ func TestMCPIsHidden(t *testing.T) {
 
 func TestOldCommandsAbsent(t *testing.T) {
 	app := newApp()
-	removed := []string{"argocd", "catalog", "profile", "status", "doctor", "dashboard", "upgrade", "restore"}
+	removed := []string{"argocd", "profile", "status", "doctor", "dashboard", "upgrade", "restore"}
 	all := collectCommandNames(app.Commands, true)
 
 	for _, name := range removed {
@@ -70,13 +70,32 @@ func TestClusterSubcommands(t *testing.T) {
 	}
 
 	got := collectCommandNames(cluster.Commands, false)
-	want := []string{"create", "dashboard", "delete", "doctor", "info", "profiles", "start", "stop", "upgrade"}
+	want := []string{"apply", "create", "dashboard", "delete", "doctor", "export", "info", "profiles", "start", "stop", "upgrade"}
 
 	if !slices.Equal(got, want) {
 		t.Errorf("cluster subcommands = %v, want %v", got, want)
 	}
 }
 
+func TestClusterProfilesSubcommands(t *testing.T) {
+	app := newApp()
+	cluster := findCommand(app.Commands, "cluster")
+	if cluster == nil {
+		t.Fatal("cluster command not found")
+	}
+	profiles := findCommand(cluster.Commands, "profiles")
+	if profiles == nil {
+		t.Fatal("cluster profiles command not found")
+	}
+
+	got := collectCommandNames(profiles.Commands, false)
+	want := []string{"apply"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("cluster profiles subcommands = %v, want %v", got, want)
+	}
+}
+
 func TestAppSubcommands(t *testing.T) {
 	app := newApp()
 	appCmd := findCommand(app.Commands, "app")
@@ -85,13 +104,52 @@ func TestAppSubcommands(t *testing.T) {
 	}
 
 	got := collectCommandNames(appCmd.Commands, false)
-	want := []string{"add", "diff", "disable", "enable", "list", "logs", "remove", "rollback", "status", "sync"}
+	want := []string{"add", "diff", "disable", "enable", "graph", "list", "logs", "outdated", "prune", "remove", "rollback", "status", "sync", "upgrade", "values"}
 
 	if !slices.Equal(got, want) {
 		t.Errorf("app subcommands = %v, want %v", got, want)
 	}
 }
 
+func TestCatalogSubcommands(t *testing.T) {
+	app := newApp()
+	catalogCmd := findCommand(app.Commands, "catalog")
+	if catalogCmd == nil {
+		t.Fatal("catalog command not found")
+	}
+	got := collectCommandNames(catalogCmd.Commands, false)
+	want := []string{"lint", "source"}
+	if !slices.Equal(got, want) {
+		t.Errorf("catalog subcommands = %v, want %v", got, want)
+	}
+
+	sourceCmd := findCommand(catalogCmd.Commands, "source")
+	got = collectCommandNames(sourceCmd.Commands, false)
+	want = []string{"add", "list", "remove", "update"}
+	if !slices.Equal(got, want) {
+		t.Errorf("catalog source subcommands = %v, want %v", got, want)
+	}
+}
+
+func TestAppValuesSubcommands(t *testing.T) {
+	app := newApp()
+	appCmd := findCommand(app.Commands, "app")
+	if appCmd == nil {
+		t.Fatal("app command not found")
+	}
+	valuesCmd := findCommand(appCmd.Commands, "values")
+	if valuesCmd == nil {
+		t.Fatal("app values command not found")
+	}
+
+	got := collectCommandNames(valuesCmd.Commands, false)
+	want := []string{"edit", "set", "show", "unset"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("app values subcommands = %v, want %v", got, want)
+	}
+}
+
 func TestAgentSubcommands(t *testing.T) {
 	app := newApp()
 	agent := findCommand(app.Commands, "agent")
@@ -100,11 +158,68 @@ func TestAgentSubcommands(t *testing.T) {
 	}
 
 	got := collectCommandNames(agent.Commands, false)
-	want := []string{"create", "delete", "list"}
+	want := []string{"create", "delete", "egress", "flows", "freeze", "info", "list", "policy", "reap", "run", "templates", "unfreeze", "update"}
 
 	if !slices.Equal(got, want) {
 		t.Errorf("agent subcommands = %v, want %v", got, want)
 	}
+
+	egress := findCommand(agent.Commands, "egress")
+	got = collectCommandNames(egress.Commands, false)
+	want = []string{"allow", "deny", "list"}
+	if !slices.Equal(got, want) {
+		t.Errorf("agent egress subcommands = %v, want %v", got, want)
+	}
+
+	policy := findCommand(agent.Commands, "policy")
+	got = collectCommandNames(policy.Commands, false)
+	want = []string{"test"}
+	if !slices.Equal(got, want) {
+		t.Errorf("agent policy subcommands = %v, want %v", got, want)
+	}
+}
+
+func TestNetworkSubcommands(t *testing.T) {
+	app := newApp()
+	network := findCommand(app.Commands, "network")
+	if network == nil {
+		t.Fatal("network command not found")
+	}
+
+	got := collectCommandNames(network.Commands, false)
+	want := []string{"flows"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("network subcommands = %v, want %v", got, want)
+	}
+}
+
+func TestImageSubcommands(t *testing.T) {
+	app := newApp()
+	image := findCommand(app.Commands, "image")
+	if image == nil {
+		t.Fatal("image command not found")
+	}
+
+	got := collectCommandNames(image.Commands, false)
+	want := []string{"load", "push"}
+	if !slices.Equal(got, want) {
+		t.Errorf("image subcommands = %v, want %v", got, want)
+	}
+}
+
+func TestBundleSubcommands(t *testing.T) {
+	app := newApp()
+	bundle := findCommand(app.Commands, "bundle")
+	if bundle == nil {
+		t.Fatal("bundle command not found")
+	}
+
+	got := collectCommandNames(bundle.Commands, false)
+	want := []string{"create", "inspect"}
+	if !slices.Equal(got, want) {
+		t.Errorf("bundle subcommands = %v, want %v", got, want)
+	}
 }
 
 func TestSnapshotSubcommands(t *testing.T) {
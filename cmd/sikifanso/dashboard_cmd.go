@@ -11,7 +11,9 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
 	"github.com/alicanalbayrak/sikifanso/internal/dashboard"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
 	"github.com/alicanalbayrak/sikifanso/internal/session"
 	"github.com/fatih/color"
 	"github.com/urfave/cli/v3"
@@ -38,8 +40,10 @@ func clusterDashboardCmd() *cli.Command {
 }
 
 func dashboardAction(ctx context.Context, cmd *cli.Command) error {
+	lock.SetSurface("dashboard")
+
 	clusterName := cmd.String("cluster")
-	_, err := session.Load(clusterName)
+	sess, err := session.Load(clusterName)
 	if err != nil {
 		return fmt.Errorf("loading session for cluster %q: %w", clusterName, err)
 	}
@@ -73,9 +77,12 @@ func dashboardAction(ctx context.Context, cmd *cli.Command) error {
 		openBrowser(url)
 	}
 
-	// Wait for interrupt.
+	// Wait for interrupt, reaping expired agents meanwhile.
 	sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer stop()
+	go agent.RunReaper(sigCtx, zapLogger, agent.DefaultReapInterval, func() ([]*session.Session, error) {
+		return []*session.Session{sess}, nil
+	})
 	<-sigCtx.Done()
 
 	fmt.Fprintln(os.Stderr, "\nShutting down...")
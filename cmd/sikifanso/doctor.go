@@ -17,8 +17,11 @@ import (
 
 func clusterDoctorCmd() *cli.Command {
 	return &cli.Command{
-		Name:   "doctor",
-		Usage:  "Run health checks on the cluster and its components",
+		Name:  "doctor",
+		Usage: "Run health checks on the cluster and its components",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "probe-policy", Usage: "Also run the network policy probe from an agent sandbox (starts a short-lived pod)"},
+		},
 		Action: doctorAction,
 	}
 }
@@ -48,6 +51,8 @@ func doctorAction(ctx context.Context, cmd *cli.Command) error {
 		return renderResults(results)
 	}
 
+	checks = append(checks, doctor.CapacityCheck{GitOpsPath: sess.GitOpsPath})
+
 	cs, err := kube.ClientForCluster(clusterName)
 	if err != nil {
 		zapLogger.Warn("could not create Kubernetes client", zap.Error(err))
@@ -66,18 +71,21 @@ func doctorAction(ctx context.Context, cmd *cli.Command) error {
 		zapLogger.Warn("could not load infrastructure config, using defaults", zap.Error(cfgErr))
 		cfg = infraconfig.Defaults()
 	}
-	checks = append(checks, doctor.ClusterChecks(cs, cfg)...)
+	checks = append(checks, doctor.ClusterChecks(cs, cfg, sess.K3dConfig.AgentPools)...)
 
 	restCfg, err := kube.RESTConfigForCluster(clusterName)
 	if err == nil {
 		dynClient, dynErr := dynamic.NewForConfig(restCfg)
 		if dynErr == nil {
 			grpcClient, _ := grpcClientFromSession(ctx, sess)
-			checks = append(checks, doctor.AppChecks(dynClient, sess.GitOpsPath, cfg, grpcClient)...)
+			checks = append(checks, doctor.AppChecks(dynClient, cs, sess.GitOpsPath, cfg, grpcClient)...)
 		} else {
 			zapLogger.Warn("could not create dynamic client", zap.Error(dynErr))
 		}
 	}
+	if cmd.Bool("probe-policy") {
+		checks = append(checks, doctor.AgentPolicyCheck{Client: cs, GitOpsPath: sess.GitOpsPath})
+	}
 
 	results := doctor.Run(ctx, checks)
 	return renderResults(results)
@@ -23,12 +23,18 @@ func outputJSON(cmd *cli.Command, data any) bool {
 	if cmd.String("output") != outputFormatJSON {
 		return false
 	}
+	writeJSON(data)
+	return true
+}
+
+// writeJSON writes data as indented JSON to stdout. Encode errors are written
+// to stderr.
+func writeJSON(data any) {
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
 	if err := enc.Encode(data); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: encoding JSON output: %v\n", err)
 	}
-	return true
 }
 
 // printTable writes a tabwriter-formatted table to w.
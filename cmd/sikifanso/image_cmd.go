@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/image"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+)
+
+func imageCmd() *cli.Command {
+	return &cli.Command{
+		Name:     "image",
+		Usage:    "Get locally built images into the cluster",
+		Commands: []*cli.Command{imagePushCmd(), imageLoadCmd()},
+	}
+}
+
+func imagePushCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "push",
+		Usage:     "Push local images to the cluster's registry (needs cluster create --registry)",
+		ArgsUsage: "IMAGE [IMAGE...]",
+		Description: "Tags each image for the registry and pushes it. Pods, agent run and custom apps\n" +
+			"reference it as " + session.RegistryHost + "/<name>:<tag>.",
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			images := cmd.Args().Slice()
+			if len(images) == 0 {
+				return fmt.Errorf("image is required: sikifanso image push IMAGE")
+			}
+			pushed := make([]*image.Pushed, 0, len(images))
+			for _, src := range images {
+				p, err := image.Push(ctx, sess, src, os.Stderr)
+				if err != nil {
+					return err
+				}
+				pushed = append(pushed, p)
+			}
+			if outputJSON(cmd, pushed) {
+				return nil
+			}
+			for _, p := range pushed {
+				fmt.Fprintf(os.Stderr, "%s %s -> use %s\n", color.GreenString("pushed"), p.Source, p.Ref)
+			}
+			return nil
+		}),
+	}
+}
+
+func imageLoadCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "load",
+		Usage:     "Import local images or image tarballs directly into the cluster's nodes",
+		ArgsUsage: "IMAGE [IMAGE...]",
+		Description: "Pods use loaded images by their original names. They are never pulled, so the\n" +
+			"image must not be tagged :latest or use imagePullPolicy: Always.",
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			images := cmd.Args().Slice()
+			if len(images) == 0 {
+				return fmt.Errorf("image is required: sikifanso image load IMAGE")
+			}
+			if err := image.Load(ctx, sess.ClusterName, images); err != nil {
+				return err
+			}
+			for _, img := range images {
+				fmt.Fprintf(os.Stderr, "%s %s into %s\n", color.GreenString("loaded"), img, sess.ClusterName)
+			}
+			return nil
+		}),
+	}
+}
+
+// checkRegistryImage rejects a session.RegistryHost image on a cluster
+// without a local registry, which would otherwise fail later as a pull
+// error in the pod.
+func checkRegistryImage(sess *session.Session, img string) error {
+	if strings.HasPrefix(img, session.RegistryHost+"/") && sess.Registry == nil {
+		return fmt.Errorf("image %s needs a local registry, but cluster %s has none; use sikifanso image load instead, or recreate the cluster with --registry", img, sess.ClusterName)
+	}
+	return nil
+}
@@ -31,15 +31,26 @@ func printClusterInfo(sess *session.Session) {
 	lines := []string{
 		fmt.Sprintf("State:           %s", stateString(sess.State)),
 		fmt.Sprintf("Bootstrap:       %s", bootstrapDisplay),
+		fmt.Sprintf("Nodes:           %s", sess.K3dConfig.Topology()),
+	}
+	if sess.Bundle != nil {
+		lines = append(lines, fmt.Sprintf("Offline bundle:  %s", sess.Bundle.File))
+	}
+	lines = append(lines,
 		"",
 		fmt.Sprintf("ArgoCD URL:      %s", sess.Services.ArgoCD.URL),
 		fmt.Sprintf("ArgoCD User:     %s", sess.Services.ArgoCD.Username),
 		fmt.Sprintf("ArgoCD Password: %s", sess.Services.ArgoCD.Password),
 		"",
 		fmt.Sprintf("Hubble URL:      %s", sess.Services.Hubble.URL),
+	)
+	if sess.Registry != nil {
+		lines = append(lines, fmt.Sprintf("Registry:        %s (pull as %s)", sess.Registry.PushHost(), session.RegistryHost))
+	}
+	lines = append(lines,
 		"",
 		fmt.Sprintf("GitOps Path:     %s", sess.GitOpsPath),
-	}
+	)
 
 	title := fmt.Sprintf("Cluster: %s", sess.ClusterName)
 	footer := "Run: sikifanso cluster info"
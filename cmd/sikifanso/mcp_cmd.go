@@ -3,7 +3,10 @@ package main
 import (
 	"context"
 
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
 	mcpserver "github.com/alicanalbayrak/sikifanso/internal/mcp"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
 	"github.com/urfave/cli/v3"
 )
 
@@ -23,6 +26,13 @@ func mcpServeCmd() *cli.Command {
 		Name:  "serve",
 		Usage: "Start the MCP server (stdio transport)",
 		Action: wrapAction(func(ctx context.Context, _ *cli.Command) error {
+			lock.SetSurface("mcp")
+
+			// Expired agents of every cluster are reaped while the server runs.
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			go agent.RunReaper(ctx, zapLogger, agent.DefaultReapInterval, session.ListAll)
+
 			return mcpserver.Run(ctx, &mcpserver.Deps{
 				Logger: zapLogger,
 			})
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/hubble"
+	"github.com/alicanalbayrak/sikifanso/internal/kube"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v3"
+	"k8s.io/client-go/kubernetes"
+)
+
+func networkCmd() *cli.Command {
+	return &cli.Command{
+		Name:     "network",
+		Usage:    "Inspect cluster network traffic",
+		Commands: []*cli.Command{networkFlowsCmd()},
+	}
+}
+
+func networkFlowsCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "flows",
+		Usage: "Show network flows observed by Hubble",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{Name: "namespace", Aliases: []string{"n"}, Usage: "Only flows to or from pods in this namespace (default: all)"},
+		}, flowFlags()...),
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			return streamFlows(ctx, cmd, sess, cmd.String("namespace"))
+		}),
+	}
+}
+
+func agentFlowsCmd() *cli.Command {
+	return &cli.Command{
+		Name:      "flows",
+		Usage:     "Show network flows of an agent sandbox, with the reason for each drop",
+		ArgsUsage: "NAME",
+		Flags:     flowFlags(),
+		Action: withSession(func(ctx context.Context, cmd *cli.Command, sess *session.Session) error {
+			name := cmd.Args().First()
+			if name == "" {
+				return fmt.Errorf("agent name is required: sikifanso agent flows NAME")
+			}
+			info, err := agent.Find(sess.GitOpsPath, name)
+			if err != nil {
+				return err
+			}
+			return streamFlows(ctx, cmd, sess, info.Namespace)
+		}),
+	}
+}
+
+// flowFlags are shared by agent flows and network flows.
+func flowFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{Name: "follow", Aliases: []string{"f"}, Usage: "Keep streaming new flows"},
+		&cli.StringSliceFlag{Name: "verdict", Usage: "Only flows with this verdict: FORWARDED, DROPPED, ERROR, AUDIT (repeatable)"},
+		&cli.DurationFlag{Name: "since", Usage: "Only flows from this long ago onward, e.g. 10m"},
+		&cli.IntFlag{Name: "last", Usage: "Number of past flows to show without --since or --follow", Value: 20},
+	}
+}
+
+// streamFlows port-forwards to Hubble Relay and prints flows for namespace
+// (all namespaces when empty) until the stream ends or the user interrupts.
+// With --output json each flow is written as one JSON line.
+func streamFlows(ctx context.Context, cmd *cli.Command, sess *session.Session, namespace string) error {
+	req := hubble.Request{
+		Namespace: namespace,
+		Verdicts:  cmd.StringSlice("verdict"),
+		Follow:    cmd.Bool("follow"),
+		Number:    uint64(max(cmd.Int("last"), 0)),
+	}
+	if d := cmd.Duration("since"); d > 0 {
+		req.Since = time.Now().Add(-d)
+		req.Number = 0
+	}
+
+	restCfg, err := kube.RESTConfigForCluster(sess.ClusterName)
+	if err != nil {
+		return err
+	}
+	cs, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return fmt.Errorf("creating clientset: %w", err)
+	}
+	client, err := hubble.Connect(ctx, restCfg, cs)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+
+	asJSON := cmd.String("output") == outputFormatJSON
+	enc := json.NewEncoder(os.Stdout)
+	count := 0
+	err = client.Flows(ctx, req, func(f hubble.Flow) error {
+		count++
+		if asJSON {
+			return enc.Encode(f)
+		}
+		printFlow(f)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if count == 0 && !asJSON {
+		fmt.Fprintln(os.Stderr, "No flows found")
+	}
+	return nil
+}
+
+// printFlow writes one flow per line. Drops are red and followed by the
+// policy or reason that dropped them.
+func printFlow(f hubble.Flow) {
+	verdict := f.Verdict
+	switch f.Verdict {
+	case hubble.VerdictDropped, hubble.VerdictError:
+		verdict = color.RedString(verdict)
+	case hubble.VerdictForwarded:
+		verdict = color.GreenString(verdict)
+	}
+	line := fmt.Sprintf("%s  %-7s  %s -> %s  %s  %s",
+		f.Time.Local().Format("15:04:05"), f.Direction, f.Source, f.Destination, f.Protocol, verdict)
+	if why := f.Explain(); why != "" {
+		line += "  " + color.YellowString(why)
+	}
+	fmt.Fprintln(os.Stdout, line)
+}
@@ -5,10 +5,18 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"sigs.k8s.io/yaml"
 )
@@ -19,7 +27,14 @@ const (
 	DefaultChartRepoURL = "https://sikifanso.github.io/sikifanso-agent-template"
 	// DefaultChartVersion is the default chart version to deploy.
 	// renovate: datasource=helm depName=sikifanso-agent-template registryUrl=https://sikifanso.github.io/sikifanso-agent-template
-	DefaultChartVersion = "0.1.0"
+	DefaultChartVersion = "0.2.0"
+	// MinFeatureChartVersion is the first chart release that reads
+	// agent.workload, agent.egress, agent.affinity and agent.tolerations.
+	// Older releases ignore them, so templates, egress rules and node pool
+	// placement are refused on agents pinned below it.
+	MinFeatureChartVersion = "0.2.0"
+	// ChartName is the agent template chart in DefaultChartRepoURL.
+	ChartName = "sikifanso-agent-template"
 
 	// DefaultCPURequest is the default CPU request quota for an agent namespace.
 	DefaultCPURequest = "250m"
@@ -40,6 +55,10 @@ type entry struct {
 	Chart          string `json:"chart"`
 	TargetRevision string `json:"targetRevision"`
 	Namespace      string `json:"namespace"`
+	// ExpiresAt is when an ephemeral agent becomes eligible for reaping.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Template is the agent template the sandbox was created from.
+	Template string `json:"template,omitempty"`
 }
 
 // values is the YAML structure written to agents/values/<name>.yaml.
@@ -54,6 +73,63 @@ type agentValues struct {
 	MemoryRequest string `json:"memoryRequest"`
 	MemoryLimit   string `json:"memoryLimit"`
 	Pods          string `json:"pods"`
+	// Workload is set on agents created from a template.
+	Workload *Workload `json:"workload,omitempty"`
+	placement
+}
+
+// placement pins an agent's pods to a node pool. Both fields are empty for
+// agents on clusters without a sandbox pool.
+type placement struct {
+	Affinity    *corev1.Affinity    `json:"affinity,omitempty"`
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+// poolPlacement returns the placement that schedules pods only on the nodes
+// of the named pool: a required node affinity for the pool label and a
+// toleration for the pool's NoSchedule taint.
+func poolPlacement(pool string) placement {
+	if pool == "" {
+		return placement{}
+	}
+	return placement{
+		Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key:      infraconfig.PoolLabel,
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   []string{pool},
+					}},
+				}},
+			},
+		}},
+		Tolerations: []corev1.Toleration{{
+			Key:      infraconfig.PoolLabel,
+			Operator: corev1.TolerationOpEqual,
+			Value:    pool,
+			Effect:   corev1.TaintEffectNoSchedule,
+		}},
+	}
+}
+
+// NodePoolFor returns the node pool new sandboxes on a cluster are pinned
+// to: infraconfig.SandboxPool when the cluster was created with it, else "".
+func NodePoolFor(k3d session.K3dConfigInfo) string {
+	if k3d.HasPool(infraconfig.SandboxPool) {
+		return infraconfig.SandboxPool
+	}
+	return ""
+}
+
+// ChartRepoFor returns the agent chart repository for a cluster: the
+// in-cluster one serving an offline bundle's charts, else "" for
+// DefaultChartRepoURL.
+func ChartRepoFor(b *session.BundleInfo) string {
+	if b != nil {
+		return b.ChartRepo
+	}
+	return ""
 }
 
 // CreateOpts configures agent creation.
@@ -66,6 +142,17 @@ type CreateOpts struct {
 	Pods          string
 	ChartRepoURL  string
 	ChartVersion  string
+	// TTL makes the agent ephemeral: it expires this long after creation
+	// and is deleted by Reap. Zero means it lives until deleted.
+	TTL time.Duration
+	// Template names an agent template whose workload, egress and values
+	// are added to the sandbox.
+	Template string
+	// Image replaces the template's workload image and its command.
+	Image string
+	// NodePool pins the sandbox's pods to the nodes of this agent pool,
+	// usually infraconfig.SandboxPool. Empty lets them run on any node.
+	NodePool string
 }
 
 // Info holds agent metadata for display.
@@ -77,6 +164,15 @@ type Info struct {
 	MemoryRequest string `json:"memoryRequest"`
 	MemoryLimit   string `json:"memoryLimit"`
 	Pods          string `json:"pods"`
+	// ExpiresAt is set for ephemeral agents created with a TTL.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Template  string     `json:"template,omitempty"`
+	// ChartVersion is the agent template chart version the sandbox runs.
+	ChartVersion string `json:"chartVersion,omitempty"`
+	// Image is the workload image of an agent created from a template.
+	Image string `json:"image,omitempty"`
+	// NodePool is the agent pool the sandbox is pinned to, if any.
+	NodePool string `json:"nodePool,omitempty"`
 }
 
 var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
@@ -91,6 +187,11 @@ func validateName(name string) error {
 	return nil
 }
 
+// namespaceFor returns the namespace an agent's sandbox runs in.
+func namespaceFor(name string) string {
+	return "agent-" + name
+}
+
 // checkPair validates that a resource request does not exceed its limit.
 func checkPair(req, lim, label string) error {
 	r, err := resource.ParseQuantity(req)
@@ -115,6 +216,14 @@ func validateQuotas(cpuReq, cpuLim, memReq, memLim string) error {
 	return checkPair(memReq, memLim, "memory")
 }
 
+// validatePods checks that a pod quota is a positive integer.
+func validatePods(pods string) error {
+	if n, err := strconv.Atoi(pods); err != nil || n < 1 {
+		return fmt.Errorf("invalid pods %q: must be a positive integer", pods)
+	}
+	return nil
+}
+
 // AgentsDir returns the path to the agents directory within gitOpsPath.
 func AgentsDir(gitOpsPath string) string {
 	return filepath.Join(gitOpsPath, "agents")
@@ -122,6 +231,11 @@ func AgentsDir(gitOpsPath string) string {
 
 // Create writes agent entry and values files, then commits to the gitops repo.
 func Create(gitOpsPath string, opts CreateOpts) error {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
 	if err := validateName(opts.Name); err != nil {
 		return err
 	}
@@ -132,45 +246,65 @@ func Create(gitOpsPath string, opts CreateOpts) error {
 		return fmt.Errorf("agent %q already exists", opts.Name)
 	}
 
-	repoURL := opts.ChartRepoURL
-	if repoURL == "" {
-		repoURL = DefaultChartRepoURL
-	}
-	chartVersion := opts.ChartVersion
-	if chartVersion == "" {
-		chartVersion = DefaultChartVersion
-	}
-	cpuReq := opts.CPURequest
-	if cpuReq == "" {
-		cpuReq = DefaultCPURequest
-	}
-	cpuLim := opts.CPULimit
-	if cpuLim == "" {
-		cpuLim = DefaultCPULimit
-	}
-	memReq := opts.MemoryRequest
-	if memReq == "" {
-		memReq = DefaultMemoryRequest
-	}
-	memLim := opts.MemoryLimit
-	if memLim == "" {
-		memLim = DefaultMemoryLimit
+	var tmpl *Template
+	if opts.Template != "" {
+		t, err := RenderTemplate(gitOpsPath, opts.Template, opts.Name)
+		if err != nil {
+			return err
+		}
+		if opts.Image != "" {
+			t.Workload.Image = opts.Image
+			t.Workload.Command, t.Workload.Args = nil, nil
+		}
+		tmpl = t
+	} else if opts.Image != "" {
+		return fmt.Errorf("an image can only be set together with a template")
+	}
+
+	repoURL := firstSet(opts.ChartRepoURL, DefaultChartRepoURL)
+	chartVersion := firstSet(opts.ChartVersion, DefaultChartVersion)
+	// Explicit quotas win over a template's, which win over the defaults.
+	var tq map[string]interface{}
+	if tmpl != nil {
+		tq, _ = tmpl.Values["agent"].(map[string]interface{})
+	}
+	cpuReq := firstSet(opts.CPURequest, valueString(tq, "cpuRequest"), DefaultCPURequest)
+	cpuLim := firstSet(opts.CPULimit, valueString(tq, "cpuLimit"), DefaultCPULimit)
+	memReq := firstSet(opts.MemoryRequest, valueString(tq, "memoryRequest"), DefaultMemoryRequest)
+	memLim := firstSet(opts.MemoryLimit, valueString(tq, "memoryLimit"), DefaultMemoryLimit)
+	pods := firstSet(opts.Pods, valueString(tq, "pods"), DefaultPods)
+
+	if tmpl != nil {
+		if err := requireFeatureChart(chartVersion, "agent templates"); err != nil {
+			return err
+		}
 	}
-	pods := opts.Pods
-	if pods == "" {
-		pods = DefaultPods
+	if opts.NodePool != "" {
+		if err := requireFeatureChart(chartVersion, "node pool placement"); err != nil {
+			return err
+		}
 	}
-
 	if err := validateQuotas(cpuReq, cpuLim, memReq, memLim); err != nil {
 		return err
 	}
+	if err := validatePods(pods); err != nil {
+		return err
+	}
+	if opts.TTL < 0 {
+		return fmt.Errorf("invalid TTL %s: must be positive", opts.TTL)
+	}
 
 	e := entry{
 		Name:           opts.Name,
 		RepoURL:        repoURL,
-		Chart:          "sikifanso-agent-template",
+		Chart:          ChartName,
 		TargetRevision: chartVersion,
-		Namespace:      "agent-" + opts.Name,
+		Namespace:      namespaceFor(opts.Name),
+		Template:       opts.Template,
+	}
+	if opts.TTL > 0 {
+		expires := time.Now().Add(opts.TTL).UTC().Truncate(time.Second)
+		e.ExpiresAt = &expires
 	}
 
 	v := values{
@@ -181,6 +315,7 @@ func Create(gitOpsPath string, opts CreateOpts) error {
 			MemoryRequest: memReq,
 			MemoryLimit:   memLim,
 			Pods:          pods,
+			placement:     poolPlacement(opts.NodePool),
 		},
 	}
 
@@ -194,9 +329,9 @@ func Create(gitOpsPath string, opts CreateOpts) error {
 
 	valuesPath := filepath.Join("agents", "values", opts.Name+".yaml")
 	absValues := filepath.Join(gitOpsPath, valuesPath)
-	valuesData, err := yaml.Marshal(v)
+	valuesData, err := marshalValues(gitOpsPath, v, tmpl)
 	if err != nil {
-		return fmt.Errorf("marshaling agent values: %w", err)
+		return err
 	}
 	if err := os.WriteFile(absValues, valuesData, 0o644); err != nil {
 		return fmt.Errorf("writing agent values: %w", err)
@@ -205,7 +340,85 @@ func Create(gitOpsPath string, opts CreateOpts) error {
 	return gitops.Commit(gitOpsPath, fmt.Sprintf("agent: create %s", opts.Name), entryPath, valuesPath)
 }
 
-// populateQuota reads an agent values file and fills quota fields on info.
+// marshalValues encodes v, merged over the template's values, workload and
+// egress when the agent is created from a template.
+func marshalValues(gitOpsPath string, v values, tmpl *Template) ([]byte, error) {
+	if tmpl == nil {
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling agent values: %w", err)
+		}
+		return data, nil
+	}
+
+	doc := map[string]interface{}{}
+	for k, val := range tmpl.Values {
+		doc[k] = val
+	}
+	agentDoc := map[string]interface{}{}
+	if tv, ok := tmpl.Values["agent"].(map[string]interface{}); ok {
+		for k, val := range tv {
+			agentDoc[k] = val
+		}
+	}
+	agentDoc["name"] = v.Agent.Name
+	agentDoc["cpuRequest"] = v.Agent.CPURequest
+	agentDoc["cpuLimit"] = v.Agent.CPULimit
+	agentDoc["memoryRequest"] = v.Agent.MemoryRequest
+	agentDoc["memoryLimit"] = v.Agent.MemoryLimit
+	agentDoc["pods"] = v.Agent.Pods
+	if v.Agent.Affinity != nil {
+		agentDoc["affinity"] = v.Agent.Affinity
+		agentDoc["tolerations"] = v.Agent.Tolerations
+	}
+	agentDoc["workload"] = tmpl.Workload
+	rules := slices.Clone(tmpl.Egress)
+	for _, r := range tmpl.appEgress(gitOpsPath) {
+		if !slices.ContainsFunc(rules, r.sameTarget) {
+			rules = append(rules, r)
+		}
+	}
+	if len(rules) > 0 {
+		agentDoc["egress"] = rules
+	}
+	doc["agent"] = agentDoc
+
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling agent values: %w", err)
+	}
+	return data, nil
+}
+
+// featureKeys are the agent values that need MinFeatureChartVersion.
+var featureKeys = []string{"workload", "egress", "affinity", "tolerations"}
+
+// requireFeatureChart fails when chart version v predates
+// MinFeatureChartVersion, naming the feature that needs the newer chart.
+// Versions that are not semver, such as a development branch, pass.
+func requireFeatureChart(v, feature string) error {
+	sv, err := semver.NewVersion(v)
+	if err != nil {
+		return nil
+	}
+	if sv.LessThan(semver.MustParse(MinFeatureChartVersion)) {
+		return fmt.Errorf("agent chart %s does not support %s; use chart version %s or later", v, feature, MinFeatureChartVersion)
+	}
+	return nil
+}
+
+// firstSet returns the first non-empty value.
+func firstSet(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// populateQuota reads an agent values file and fills the quota, image and
+// node pool fields on info.
 func populateQuota(info *Info, valuesFile string) {
 	vData, err := os.ReadFile(valuesFile)
 	if err != nil {
@@ -222,6 +435,15 @@ func populateQuota(info *Info, valuesFile string) {
 	info.MemoryRequest = v.Agent.MemoryRequest
 	info.MemoryLimit = v.Agent.MemoryLimit
 	info.Pods = v.Agent.Pods
+	if v.Agent.Workload != nil {
+		info.Image = v.Agent.Workload.Image
+	}
+	for _, t := range v.Agent.Tolerations {
+		if t.Key == infraconfig.PoolLabel {
+			info.NodePool = t.Value
+			break
+		}
+	}
 }
 
 // List reads all agent entries from the agents directory.
@@ -252,8 +474,11 @@ func List(gitOpsPath string) ([]Info, error) {
 		}
 
 		info := Info{
-			Name:      ent.Name,
-			Namespace: ent.Namespace,
+			Name:         ent.Name,
+			Namespace:    ent.Namespace,
+			ExpiresAt:    ent.ExpiresAt,
+			Template:     ent.Template,
+			ChartVersion: ent.TargetRevision,
 		}
 		valuesFile := filepath.Join(dir, "values", ent.Name+".yaml")
 		populateQuota(&info, valuesFile)
@@ -297,7 +522,7 @@ func Find(gitOpsPath, name string) (*Info, error) {
 		return nil, fmt.Errorf("parsing agent file: %w", err)
 	}
 
-	info := &Info{Name: ent.Name, Namespace: ent.Namespace}
+	info := &Info{Name: ent.Name, Namespace: ent.Namespace, ExpiresAt: ent.ExpiresAt, Template: ent.Template, ChartVersion: ent.TargetRevision}
 	valuesFile := filepath.Join(AgentsDir(gitOpsPath), "values", name+".yaml")
 	populateQuota(info, valuesFile)
 	return info, nil
@@ -305,6 +530,11 @@ func Find(gitOpsPath, name string) (*Info, error) {
 
 // Delete removes agent entry and values files, then commits.
 func Delete(gitOpsPath, name string) error {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
 	if err := validateName(name); err != nil {
 		return err
 	}
@@ -324,3 +554,157 @@ func Delete(gitOpsPath, name string) error {
 
 	return gitops.Commit(gitOpsPath, fmt.Sprintf("agent: delete %s", name), entryPath, valuesPath)
 }
+
+// UpdateOpts changes an existing agent. Empty fields keep their current value.
+type UpdateOpts struct {
+	Name          string
+	CPURequest    string
+	CPULimit      string
+	MemoryRequest string
+	MemoryLimit   string
+	Pods          string
+	ChartVersion  string
+	// DryRun computes the changes without writing or committing them.
+	DryRun bool
+}
+
+// Change is a single setting modified by Update.
+type Change struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Update changes an agent's quotas and chart version in place, keeping its
+// namespace and workloads. The resulting quotas are validated as a whole, so
+// raising a request above the current limit fails unless the limit is raised
+// too. It returns the changes made; none means nothing was written.
+func Update(gitOpsPath string, opts UpdateOpts) ([]Change, error) {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+	if _, err := Find(gitOpsPath, opts.Name); err != nil {
+		return nil, err
+	}
+
+	entryPath := filepath.Join("agents", opts.Name+".yaml")
+	entryData, err := os.ReadFile(filepath.Join(gitOpsPath, entryPath))
+	if err != nil {
+		return nil, fmt.Errorf("reading agent entry: %w", err)
+	}
+	var e entry
+	if err := yaml.Unmarshal(entryData, &e); err != nil {
+		return nil, fmt.Errorf("parsing agent entry: %w", err)
+	}
+	doc, err := readAgentValues(gitOpsPath, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	agentDoc, ok := doc["agent"].(map[string]interface{})
+	if !ok {
+		agentDoc = map[string]interface{}{"name": opts.Name}
+		doc["agent"] = agentDoc
+	}
+
+	var changes []Change
+	for _, f := range []struct{ key, value string }{
+		{"cpuRequest", opts.CPURequest},
+		{"cpuLimit", opts.CPULimit},
+		{"memoryRequest", opts.MemoryRequest},
+		{"memoryLimit", opts.MemoryLimit},
+		{"pods", opts.Pods},
+	} {
+		current := valueString(agentDoc, f.key)
+		if f.value == "" || f.value == current {
+			continue
+		}
+		changes = append(changes, Change{Field: f.key, From: current, To: f.value})
+		agentDoc[f.key] = f.value
+	}
+	entryChanged := opts.ChartVersion != "" && opts.ChartVersion != e.TargetRevision
+	if entryChanged {
+		for _, key := range featureKeys {
+			if _, ok := agentDoc[key]; !ok {
+				continue
+			}
+			if err := requireFeatureChart(opts.ChartVersion, "agent."+key); err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, Change{Field: "chartVersion", From: e.TargetRevision, To: opts.ChartVersion})
+		e.TargetRevision = opts.ChartVersion
+	}
+
+	if err := validateQuotas(valueString(agentDoc, "cpuRequest"), valueString(agentDoc, "cpuLimit"),
+		valueString(agentDoc, "memoryRequest"), valueString(agentDoc, "memoryLimit")); err != nil {
+		return nil, err
+	}
+	if pods := valueString(agentDoc, "pods"); pods != "" {
+		if err := validatePods(pods); err != nil {
+			return nil, err
+		}
+	}
+	if len(changes) == 0 || opts.DryRun {
+		return changes, nil
+	}
+
+	// The original files are written back if the update cannot be committed.
+	valuesAbs := filepath.Join(gitOpsPath, agentValuesPath(opts.Name))
+	orig := map[string][]byte{filepath.Join(gitOpsPath, entryPath): entryData}
+	if data, err := os.ReadFile(valuesAbs); err == nil {
+		orig[valuesAbs] = data
+	}
+	if err := writeAgentFiles(gitOpsPath, opts.Name, doc, e, entryChanged); err != nil {
+		restoreFiles(orig)
+		return nil, err
+	}
+	paths := []string{agentValuesPath(opts.Name)}
+	if entryChanged {
+		paths = append(paths, entryPath)
+	}
+
+	summary := make([]string, len(changes))
+	for i, c := range changes {
+		summary[i] = fmt.Sprintf("%s %s -> %s", c.Field, c.From, c.To)
+	}
+	msg := fmt.Sprintf("agent: update %s (%s)", opts.Name, strings.Join(summary, ", "))
+	if err := gitops.Commit(gitOpsPath, msg, paths...); err != nil {
+		restoreFiles(orig)
+		return nil, fmt.Errorf("committing agent update: %w", err)
+	}
+	return changes, nil
+}
+
+// writeAgentFiles writes the values document and, when entryChanged, the
+// entry file of an agent.
+func writeAgentFiles(gitOpsPath, name string, doc map[string]interface{}, e entry, entryChanged bool) error {
+	valuesData, err := yaml.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("marshaling agent values: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(gitOpsPath, agentValuesPath(name)), valuesData, 0o644); err != nil {
+		return fmt.Errorf("writing agent values: %w", err)
+	}
+	if !entryChanged {
+		return nil
+	}
+	entryData, err := yaml.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshaling agent entry: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(gitOpsPath, "agents", name+".yaml"), entryData, 0o644); err != nil {
+		return fmt.Errorf("writing agent entry: %w", err)
+	}
+	return nil
+}
+
+// valueString returns m[key] as a string, or "" when unset. Hand-edited
+// values files may hold quotas as YAML numbers.
+func valueString(m map[string]interface{}, key string) string {
+	if v, ok := m[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
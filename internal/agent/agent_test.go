@@ -93,6 +93,41 @@ func TestCreate_DefaultValues(t *testing.T) {
 	}
 }
 
+func TestCreate_NodePoolPinsSandbox(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+
+	if err := Create(dir, CreateOpts{Name: "pinned", NodePool: "sandbox"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Create(dir, CreateOpts{Name: "anywhere"}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := readPlacement(dir, "pinned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Affinity == nil || p.Affinity.NodeAffinity == nil {
+		t.Fatal("pinned agent has no node affinity")
+	}
+	req := p.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[0]
+	if req.Key != "sikifanso.io/pool" || req.Values[0] != "sandbox" {
+		t.Errorf("node affinity = %+v, want sikifanso.io/pool in (sandbox)", req)
+	}
+	if len(p.Tolerations) != 1 || p.Tolerations[0].Value != "sandbox" || p.Tolerations[0].Effect != "NoSchedule" {
+		t.Errorf("tolerations = %+v, want one for the sandbox taint", p.Tolerations)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "agents", "values", "anywhere.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contains(string(data), "affinity") || contains(string(data), "tolerations") {
+		t.Errorf("agent without a pool got a placement:\n%s", data)
+	}
+}
+
 func TestCreate_DuplicateReturnsError(t *testing.T) {
 	t.Parallel()
 	dir := setupGitOps(t)
@@ -294,3 +329,116 @@ func stringContains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestUpdate_ChangesQuotasInPlace(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	if err := Create(dir, CreateOpts{Name: "my-agent"}); err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	if _, err := AllowEgress(dir, "my-agent", EgressRule{FQDN: "api.openai.com"}); err != nil {
+		t.Fatalf("AllowEgress error: %v", err)
+	}
+
+	changes, err := Update(dir, UpdateOpts{Name: "my-agent", MemoryLimit: "2Gi", Pods: "10", ChartVersion: "0.3.0"})
+	if err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	// Pods is unchanged (the default is 10) and not reported.
+	if len(changes) != 2 || changes[0].Field != "memoryLimit" || changes[0].From != DefaultMemoryLimit || changes[1].Field != "chartVersion" {
+		t.Errorf("changes = %+v", changes)
+	}
+
+	info, err := Find(dir, "my-agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MemoryLimit != "2Gi" || info.CPURequest != DefaultCPURequest {
+		t.Errorf("info = %+v", info)
+	}
+	entryData, _ := os.ReadFile(filepath.Join(dir, "agents", "my-agent.yaml"))
+	if !contains(string(entryData), "targetRevision: 0.3.0") {
+		t.Errorf("entry not updated:\n%s", entryData)
+	}
+	if rules, _ := ListEgress(dir, "my-agent"); len(rules) != 1 {
+		t.Errorf("egress rules lost on update: %+v", rules)
+	}
+
+	if changes, err := Update(dir, UpdateOpts{Name: "my-agent", MemoryLimit: "2Gi"}); err != nil || len(changes) != 0 {
+		t.Errorf("no-op update: changes=%+v err=%v", changes, err)
+	}
+}
+
+func TestFeatureChartVersion(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	if err := Create(dir, CreateOpts{Name: "pinned", ChartVersion: "0.1.0", NodePool: "sandbox"}); err == nil {
+		t.Error("Create placed an agent on a pool with a chart that ignores affinity")
+	}
+	if err := Create(dir, CreateOpts{Name: "old", ChartVersion: "0.1.0"}); err != nil {
+		t.Fatalf("Create without features on an old chart: %v", err)
+	}
+	if _, err := AllowEgress(dir, "old", EgressRule{FQDN: "api.openai.com"}); err == nil {
+		t.Error("AllowEgress wrote egress rules the old chart ignores")
+	}
+
+	if err := Create(dir, CreateOpts{Name: "current"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AllowEgress(dir, "current", EgressRule{FQDN: "api.openai.com"}); err != nil {
+		t.Fatalf("AllowEgress: %v", err)
+	}
+	if _, err := Update(dir, UpdateOpts{Name: "current", ChartVersion: "0.1.0"}); err == nil {
+		t.Error("Update downgraded an agent with egress rules to a chart that ignores them")
+	}
+}
+
+func TestUpdate_RestoresFilesWhenCommitFails(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	if err := Create(dir, CreateOpts{Name: "my-agent"}); err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, ".git")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Update(dir, UpdateOpts{Name: "my-agent", Pods: "3", ChartVersion: "0.3.0"}); err == nil {
+		t.Fatal("Update succeeded without a git repo")
+	}
+	info, err := Find(dir, "my-agent")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if info.Pods != DefaultPods || info.ChartVersion != DefaultChartVersion {
+		t.Errorf("files not restored: pods=%s chart=%s", info.Pods, info.ChartVersion)
+	}
+}
+
+func TestUpdate_ValidatesMergedQuotas(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	if err := Create(dir, CreateOpts{Name: "my-agent"}); err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	// Request above the existing 1Gi limit.
+	if _, err := Update(dir, UpdateOpts{Name: "my-agent", MemoryRequest: "2Gi"}); err == nil {
+		t.Error("expected error when request exceeds current limit")
+	}
+	if _, err := Update(dir, UpdateOpts{Name: "missing", Pods: "3"}); err == nil {
+		t.Error("expected error for unknown agent")
+	}
+	for _, pods := range []string{"0", "-1", "ten", "1.5"} {
+		if _, err := Update(dir, UpdateOpts{Name: "my-agent", Pods: pods}); err == nil {
+			t.Errorf("expected error for pods %q", pods)
+		}
+	}
+
+	changes, err := Update(dir, UpdateOpts{Name: "my-agent", MemoryRequest: "2Gi", MemoryLimit: "4Gi", DryRun: true})
+	if err != nil || len(changes) != 2 {
+		t.Fatalf("dry run: changes=%+v err=%v", changes, err)
+	}
+	if info, _ := Find(dir, "my-agent"); info.MemoryLimit != DefaultMemoryLimit {
+		t.Errorf("dry run wrote changes: %+v", info)
+	}
+}
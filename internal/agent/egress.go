@@ -0,0 +1,468 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
+	yamlv3 "gopkg.in/yaml.v3"
+	"sigs.k8s.io/yaml"
+)
+
+// EgressRule allows an agent to reach one destination. Exactly one of FQDN,
+// CIDR or App is set. Empty Ports means every port. Rules are stored under
+// agent.egress in agents/values/<name>.yaml, where the agent-template chart
+// renders them into the sandbox's CiliumNetworkPolicy.
+type EgressRule struct {
+	// FQDN is a DNS name such as api.openai.com; a leading "*." matches
+	// any subdomain.
+	FQDN string `json:"fqdn,omitempty"`
+	CIDR string `json:"cidr,omitempty"`
+	// App is an enabled catalog app; Namespace is filled from its entry so
+	// the chart can select its pods.
+	App       string `json:"app,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Ports     []int  `json:"ports,omitempty"`
+}
+
+// Target returns the rule's destination without ports.
+func (r EgressRule) Target() string {
+	switch {
+	case r.FQDN != "":
+		return r.FQDN
+	case r.CIDR != "":
+		return r.CIDR
+	default:
+		return "app:" + r.App
+	}
+}
+
+// Kind returns "fqdn", "cidr" or "app".
+func (r EgressRule) Kind() string {
+	switch {
+	case r.FQDN != "":
+		return "fqdn"
+	case r.CIDR != "":
+		return "cidr"
+	default:
+		return "app"
+	}
+}
+
+// String renders the rule as target[:port,port].
+func (r EgressRule) String() string {
+	if len(r.Ports) == 0 {
+		return r.Target()
+	}
+	ports := make([]string, len(r.Ports))
+	for i, p := range r.Ports {
+		ports[i] = strconv.Itoa(p)
+	}
+	return r.Target() + ":" + strings.Join(ports, ",")
+}
+
+func (r EgressRule) sameTarget(o EgressRule) bool {
+	return r.FQDN == o.FQDN && r.CIDR == o.CIDR && r.App == o.App
+}
+
+var fqdnRe = regexp.MustCompile(`^(\*\.)?([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z][a-z0-9-]*[a-z0-9]$`)
+
+// validate checks the rule's shape and normalizes FQDN case and CIDR form.
+func (r *EgressRule) validate() error {
+	set := 0
+	for _, v := range []string{r.FQDN, r.CIDR, r.App} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("egress rule needs exactly one of fqdn, cidr or app")
+	}
+	if r.FQDN != "" {
+		r.FQDN = strings.ToLower(strings.TrimSuffix(r.FQDN, "."))
+		if !fqdnRe.MatchString(r.FQDN) {
+			return fmt.Errorf("invalid FQDN %q", r.FQDN)
+		}
+	}
+	if r.CIDR != "" {
+		cidr := r.CIDR
+		if !strings.Contains(cidr, "/") {
+			// A bare IP is a single-host range.
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %q: %w", r.CIDR, err)
+		}
+		r.CIDR = ipNet.String()
+	}
+	for _, p := range r.Ports {
+		if p < 1 || p > 65535 {
+			return fmt.Errorf("invalid port %d: must be 1-65535", p)
+		}
+	}
+	slices.Sort(r.Ports)
+	r.Ports = slices.Compact(r.Ports)
+	return nil
+}
+
+// ListEgress returns the egress rules of the named agent.
+func ListEgress(gitOpsPath, name string) ([]EgressRule, error) {
+	doc, err := readAgentValues(gitOpsPath, name)
+	if err != nil {
+		return nil, err
+	}
+	return egressRules(doc)
+}
+
+// AllowEgress adds rule to the agent's allowlist and commits. Ports are merged
+// into an existing rule for the same destination. App rules must name an
+// enabled catalog app. It returns false when the rule was already allowed.
+func AllowEgress(gitOpsPath, name string, rule EgressRule) (bool, error) {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return false, err
+	}
+	defer l.Release()
+	if err := rule.validate(); err != nil {
+		return false, err
+	}
+	info, err := Find(gitOpsPath, name)
+	if err != nil {
+		return false, err
+	}
+	if err := requireFeatureChart(info.ChartVersion, "egress rules"); err != nil {
+		return false, err
+	}
+	if rule.App != "" {
+		e, err := catalog.Find(gitOpsPath, rule.App)
+		if err != nil {
+			return false, err
+		}
+		if !e.Enabled {
+			return false, fmt.Errorf("catalog app %s is not enabled; enable it first: sikifanso app enable %s", rule.App, rule.App)
+		}
+		rule.Namespace = e.Namespace
+	}
+
+	doc, err := readAgentValues(gitOpsPath, name)
+	if err != nil {
+		return false, err
+	}
+	rules, err := egressRules(doc)
+	if err != nil {
+		return false, err
+	}
+
+	i := slices.IndexFunc(rules, rule.sameTarget)
+	switch {
+	case i < 0:
+		rules = append(rules, rule)
+	case len(rules[i].Ports) == 0:
+		// Already open on every port.
+		return false, nil
+	case len(rule.Ports) == 0:
+		rules[i].Ports = nil
+	default:
+		merged := slices.Concat(rules[i].Ports, rule.Ports)
+		slices.Sort(merged)
+		merged = slices.Compact(merged)
+		if slices.Equal(merged, rules[i].Ports) {
+			return false, nil
+		}
+		rules[i].Ports = merged
+	}
+
+	msg := fmt.Sprintf("agent: allow %s egress to %s", name, rule)
+	return true, writeEgress(gitOpsPath, name, rules, msg)
+}
+
+// DenyEgress removes rule from the agent's allowlist and commits. With ports,
+// only those ports are removed from the matching rule; without, the whole
+// destination is. It returns false when nothing matched.
+func DenyEgress(gitOpsPath, name string, rule EgressRule) (bool, error) {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return false, err
+	}
+	defer l.Release()
+	if err := rule.validate(); err != nil {
+		return false, err
+	}
+	doc, err := readAgentValues(gitOpsPath, name)
+	if err != nil {
+		return false, err
+	}
+	rules, err := egressRules(doc)
+	if err != nil {
+		return false, err
+	}
+
+	i := slices.IndexFunc(rules, rule.sameTarget)
+	if i < 0 {
+		return false, nil
+	}
+	switch {
+	case len(rule.Ports) == 0:
+		rules = slices.Delete(rules, i, i+1)
+	case len(rules[i].Ports) == 0:
+		return false, fmt.Errorf("%s is allowed on every port; deny it without --port and allow the ports to keep", rules[i].Target())
+	default:
+		kept := slices.DeleteFunc(slices.Clone(rules[i].Ports), func(p int) bool { return slices.Contains(rule.Ports, p) })
+		if len(kept) == len(rules[i].Ports) {
+			return false, nil
+		}
+		if len(kept) == 0 {
+			rules = slices.Delete(rules, i, i+1)
+		} else {
+			rules[i].Ports = kept
+		}
+	}
+
+	msg := fmt.Sprintf("agent: deny %s egress to %s", name, rule)
+	return true, writeEgress(gitOpsPath, name, rules, msg)
+}
+
+// SetEgress replaces the agent's allowlist with rules and commits. App rules
+// must name an enabled catalog app. It returns false when the allowlist
+// already allows exactly these destinations and ports. With dryRun nothing
+// is written and app rules are not checked against the catalog, so a plan
+// can include apps it has yet to enable.
+func SetEgress(gitOpsPath, name string, rules []EgressRule, dryRun bool) (bool, error) {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return false, err
+	}
+	defer l.Release()
+	info, err := Find(gitOpsPath, name)
+	if err != nil {
+		return false, err
+	}
+	// validate sorts ports in place; work on copies of the caller's rules.
+	rules = slices.Clone(rules)
+	for i := range rules {
+		rules[i].Ports = slices.Clone(rules[i].Ports)
+		if err := rules[i].validate(); err != nil {
+			return false, err
+		}
+		if slices.ContainsFunc(rules[:i], rules[i].sameTarget) {
+			return false, fmt.Errorf("egress to %s is listed twice", rules[i].Target())
+		}
+	}
+	if len(rules) > 0 {
+		if err := requireFeatureChart(info.ChartVersion, "egress rules"); err != nil {
+			return false, err
+		}
+	}
+
+	doc, err := readAgentValues(gitOpsPath, name)
+	if err != nil {
+		return false, err
+	}
+	current, err := egressRules(doc)
+	if err != nil {
+		return false, err
+	}
+	if sameEgress(current, rules) {
+		return false, nil
+	}
+	if dryRun {
+		return true, nil
+	}
+	for i := range rules {
+		if rules[i].App == "" {
+			continue
+		}
+		e, err := catalog.Find(gitOpsPath, rules[i].App)
+		if err != nil {
+			return false, err
+		}
+		if !e.Enabled {
+			return false, fmt.Errorf("catalog app %s is not enabled; enable it first: sikifanso app enable %s", rules[i].App, rules[i].App)
+		}
+		rules[i].Namespace = e.Namespace
+	}
+
+	msg := fmt.Sprintf("agent: set %s egress", name)
+	return true, writeEgress(gitOpsPath, name, rules, msg)
+}
+
+// sameEgress reports whether a and b allow the same destinations and
+// ports, in any order.
+func sameEgress(a, b []EgressRule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, r := range a {
+		i := slices.IndexFunc(b, r.sameTarget)
+		if i < 0 || !slices.Equal(r.Ports, b[i].Ports) {
+			return false
+		}
+	}
+	return true
+}
+
+func agentValuesPath(name string) string {
+	return filepath.Join("agents", "values", name+".yaml")
+}
+
+// readAgentValues loads an agent's values file as a generic document so
+// keys this package does not model survive a rewrite.
+func readAgentValues(gitOpsPath, name string) (map[string]interface{}, error) {
+	if _, err := Find(gitOpsPath, name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(gitOpsPath, agentValuesPath(name)))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading agent values: %w", err)
+	}
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing agent values: %w", err)
+	}
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	return doc, nil
+}
+
+func egressRules(doc map[string]interface{}) ([]EgressRule, error) {
+	agentDoc, _ := doc["agent"].(map[string]interface{})
+	raw, ok := agentDoc["egress"]
+	if !ok {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("encoding egress rules: %w", err)
+	}
+	var rules []EgressRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parsing agent.egress: %w", err)
+	}
+	return rules, nil
+}
+
+// writeEgress sets agent.egress in the agent's values file and commits. The
+// file is edited as a YAML node tree so comments and key order survive; it is
+// restored if the commit fails.
+func writeEgress(gitOpsPath, name string, rules []EgressRule, msg string) error {
+	abs := filepath.Join(gitOpsPath, agentValuesPath(name))
+	orig, err := os.ReadFile(abs)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading agent values: %w", err)
+	}
+	existed := err == nil
+	data, err := setEgressNode(orig, name, rules)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(abs, data, 0o644); err != nil {
+		return fmt.Errorf("writing agent values: %w", err)
+	}
+	if err := gitops.Commit(gitOpsPath, msg, agentValuesPath(name)); err != nil {
+		if existed {
+			restoreFiles(map[string][]byte{abs: orig})
+		} else {
+			_ = os.Remove(abs)
+		}
+		return err
+	}
+	return nil
+}
+
+// setEgressNode returns data with agent.egress replaced by rules, or removed
+// when rules is empty.
+func setEgressNode(data []byte, name string, rules []EgressRule) ([]byte, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing agent values: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc = yamlv3.Node{Kind: yamlv3.DocumentNode, HeadComment: strings.TrimSpace(string(data))}
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = []*yamlv3.Node{{Kind: yamlv3.MappingNode, Tag: "!!map"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yamlv3.MappingNode {
+		return nil, fmt.Errorf("agent values must be a YAML mapping")
+	}
+	agentNode := nodeValue(root, "agent")
+	if agentNode == nil {
+		agentNode = &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map", Content: []*yamlv3.Node{strNode("name"), strNode(name)}}
+		root.Content = append(root.Content, strNode("agent"), agentNode)
+	}
+	if agentNode.Kind != yamlv3.MappingNode {
+		return nil, fmt.Errorf("agent values: agent is not a map")
+	}
+
+	i := -1
+	for k := 0; k < len(agentNode.Content)-1; k += 2 {
+		if agentNode.Content[k].Value == "egress" {
+			i = k
+			break
+		}
+	}
+	if len(rules) == 0 {
+		if i >= 0 {
+			agentNode.Content = slices.Delete(agentNode.Content, i, i+2)
+		}
+	} else {
+		// Rules go through their json tags so empty fields are omitted.
+		raw, err := yaml.Marshal(rules)
+		if err != nil {
+			return nil, fmt.Errorf("encoding egress rules: %w", err)
+		}
+		var rulesDoc yamlv3.Node
+		if err := yamlv3.Unmarshal(raw, &rulesDoc); err != nil {
+			return nil, fmt.Errorf("encoding egress rules: %w", err)
+		}
+		value := rulesDoc.Content[0]
+		if i >= 0 {
+			value.LineComment = agentNode.Content[i+1].LineComment
+			agentNode.Content[i+1] = value
+		} else {
+			agentNode.Content = append(agentNode.Content, strNode("egress"), value)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yamlv3.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encoding agent values: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encoding agent values: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// nodeValue returns the value node for key in mapping, or nil.
+func nodeValue(mapping *yamlv3.Node, key string) *yamlv3.Node {
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func strNode(s string) *yamlv3.Node {
+	return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: s}
+}
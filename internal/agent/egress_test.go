@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupAgentWithCatalog(t *testing.T) string {
+	t.Helper()
+	dir := setupGitOps(t)
+	catalogDir := filepath.Join(dir, "catalog")
+	if err := os.MkdirAll(catalogDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, enabled := range map[string]string{"qdrant": "true", "presidio": "false"} {
+		content := "name: " + name + "\nnamespace: " + name + "\nenabled: " + enabled + "\n"
+		if err := os.WriteFile(filepath.Join(catalogDir, name+".yaml"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Create(dir, CreateOpts{Name: "bot"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return dir
+}
+
+func TestAllowEgress_MergesPorts(t *testing.T) {
+	t.Parallel()
+	dir := setupAgentWithCatalog(t)
+
+	for _, r := range []EgressRule{
+		{FQDN: "API.OpenAI.com", Ports: []int{443}},
+		{FQDN: "api.openai.com", Ports: []int{80, 443}},
+		{CIDR: "10.1.2.3", Ports: []int{5432}},
+		{App: "qdrant", Ports: []int{6333}},
+	} {
+		if _, err := AllowEgress(dir, "bot", r); err != nil {
+			t.Fatalf("AllowEgress(%s): %v", r, err)
+		}
+	}
+	changed, err := AllowEgress(dir, "bot", EgressRule{FQDN: "api.openai.com", Ports: []int{443}})
+	if err != nil || changed {
+		t.Errorf("re-allowing an allowed port: changed=%v err=%v, want false, nil", changed, err)
+	}
+
+	rules, err := ListEgress(dir, "bot")
+	if err != nil {
+		t.Fatalf("ListEgress: %v", err)
+	}
+	var got []string
+	for _, r := range rules {
+		got = append(got, r.String())
+	}
+	want := "api.openai.com:80,443 10.1.2.3/32:5432 app:qdrant:6333"
+	if strings.Join(got, " ") != want {
+		t.Errorf("rules = %v, want %s", got, want)
+	}
+	if rules[2].Namespace != "qdrant" {
+		t.Errorf("app rule namespace = %q, want qdrant", rules[2].Namespace)
+	}
+
+	// Quotas written by Create survive the rewrite.
+	info, err := Find(dir, "bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.CPURequest != DefaultCPURequest {
+		t.Errorf("CPURequest = %q after egress edit, want %q", info.CPURequest, DefaultCPURequest)
+	}
+}
+
+func TestAllowEgress_Validation(t *testing.T) {
+	t.Parallel()
+	dir := setupAgentWithCatalog(t)
+
+	for _, r := range []EgressRule{
+		{},
+		{FQDN: "a.com", CIDR: "10.0.0.0/8"},
+		{FQDN: "not a host"},
+		{CIDR: "10.0.0.0/99"},
+		{FQDN: "a.com", Ports: []int{70000}},
+		{App: "presidio"},
+		{App: "missing"},
+	} {
+		if _, err := AllowEgress(dir, "bot", r); err == nil {
+			t.Errorf("AllowEgress(%+v) succeeded, want error", r)
+		}
+	}
+	if _, err := AllowEgress(dir, "ghost", EgressRule{FQDN: "a.com"}); err == nil {
+		t.Error("AllowEgress for unknown agent succeeded, want error")
+	}
+}
+
+func TestDenyEgress(t *testing.T) {
+	t.Parallel()
+	dir := setupAgentWithCatalog(t)
+	if _, err := AllowEgress(dir, "bot", EgressRule{FQDN: "api.openai.com", Ports: []int{80, 443}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AllowEgress(dir, "bot", EgressRule{CIDR: "10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, err := DenyEgress(dir, "bot", EgressRule{FQDN: "api.openai.com", Ports: []int{80}}); err != nil || !changed {
+		t.Fatalf("deny port: changed=%v err=%v", changed, err)
+	}
+	if _, err := DenyEgress(dir, "bot", EgressRule{CIDR: "10.0.0.0/8", Ports: []int{22}}); err == nil {
+		t.Error("denying one port of an all-ports rule should fail")
+	}
+	if changed, err := DenyEgress(dir, "bot", EgressRule{CIDR: "10.0.0.0/8"}); err != nil || !changed {
+		t.Fatalf("deny target: changed=%v err=%v", changed, err)
+	}
+	if changed, _ := DenyEgress(dir, "bot", EgressRule{FQDN: "example.com"}); changed {
+		t.Error("denying an unknown target reported a change")
+	}
+
+	rules, err := ListEgress(dir, "bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].String() != "api.openai.com:443" {
+		t.Errorf("rules = %+v, want [api.openai.com:443]", rules)
+	}
+}
+
+func TestSetEgress(t *testing.T) {
+	t.Parallel()
+	dir := setupAgentWithCatalog(t)
+	if _, err := AllowEgress(dir, "bot", EgressRule{FQDN: "example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []EgressRule{{App: "qdrant", Ports: []int{6333}}, {FQDN: "API.OpenAI.com", Ports: []int{443, 443}}}
+	changed, err := SetEgress(dir, "bot", want, true)
+	if err != nil || !changed {
+		t.Fatalf("dry run: changed=%v err=%v, want true, nil", changed, err)
+	}
+	if rules, _ := ListEgress(dir, "bot"); len(rules) != 1 {
+		t.Errorf("dry run wrote rules: %v", rules)
+	}
+
+	if changed, err = SetEgress(dir, "bot", want, false); err != nil || !changed {
+		t.Fatalf("SetEgress: changed=%v err=%v, want true, nil", changed, err)
+	}
+	rules, err := ListEgress(dir, "bot")
+	if err != nil || len(rules) != 2 || rules[0].Namespace != "qdrant" || rules[1].String() != "api.openai.com:443" {
+		t.Errorf("rules = %+v (%v), want qdrant and api.openai.com:443", rules, err)
+	}
+	// The same rules in another order change nothing.
+	if changed, err = SetEgress(dir, "bot", []EgressRule{want[1], want[0]}, false); err != nil || changed {
+		t.Errorf("re-setting the allowlist: changed=%v err=%v, want false, nil", changed, err)
+	}
+
+	if _, err := SetEgress(dir, "bot", []EgressRule{{App: "presidio"}}, false); err == nil {
+		t.Error("expected an error for a disabled app")
+	}
+	if _, err := SetEgress(dir, "bot", []EgressRule{{FQDN: "a.com"}, {FQDN: "A.com", Ports: []int{80}}}, true); err == nil {
+		t.Error("expected an error for a destination listed twice")
+	}
+	if changed, err = SetEgress(dir, "bot", nil, false); err != nil || !changed {
+		t.Errorf("clearing the allowlist: changed=%v err=%v", changed, err)
+	}
+	if rules, _ := ListEgress(dir, "bot"); len(rules) != 0 {
+		t.Errorf("rules after clearing = %v", rules)
+	}
+}
+
+func TestAllowEgress_KeepsCommentsAndOrder(t *testing.T) {
+	t.Parallel()
+	dir := setupAgentWithCatalog(t)
+	path := filepath.Join(dir, agentValuesPath("bot"))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := "# tuned by hand\nzeta: 1 # keep me\n" + string(data)
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AllowEgress(dir, "bot", EgressRule{FQDN: "api.openai.com", Ports: []int{443}}); err != nil {
+		t.Fatalf("AllowEgress: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(got), "# tuned by hand\nzeta: 1 # keep me\n") {
+		t.Errorf("comments or key order lost:\n%s", got)
+	}
+	if rules, err := ListEgress(dir, "bot"); err != nil || len(rules) != 1 {
+		t.Errorf("rules = %v, %v", rules, err)
+	}
+}
+
+func TestAllowEgress_RestoresValuesWhenCommitFails(t *testing.T) {
+	t.Parallel()
+	dir := setupAgentWithCatalog(t)
+	path := filepath.Join(dir, agentValuesPath("bot"))
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, ".git")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := AllowEgress(dir, "bot", EgressRule{FQDN: "example.com"}); err == nil {
+		t.Fatal("AllowEgress succeeded without a git repo")
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("values not restored:\n%s\nwant:\n%s", after, before)
+	}
+}
@@ -0,0 +1,647 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
+	"github.com/alicanalbayrak/sikifanso/internal/kube"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// FreezePolicyName is the deny-all CiliumNetworkPolicy Freeze applies.
+const FreezePolicyName = "sikifanso-freeze"
+
+// Annotations recording what Freeze changed, so Unfreeze can restore it.
+const (
+	// frozenAnnotation holds the FreezeRecord on the agent namespace.
+	frozenAnnotation = "sikifanso.io/frozen"
+	// replicasAnnotation holds a Deployment's or StatefulSet's replicas.
+	replicasAnnotation = "sikifanso.io/frozen-replicas"
+	// suspendAnnotation marks a Job or CronJob suspended by Freeze.
+	suspendAnnotation = "sikifanso.io/frozen-suspend"
+	// syncPolicyAnnotation holds the Application's automated sync policy.
+	syncPolicyAnnotation = "sikifanso.io/frozen-sync-policy"
+	// skipReconcileAnnotation makes ArgoCD ignore an Application.
+	skipReconcileAnnotation = "argocd.argoproj.io/skip-reconcile"
+)
+
+// Freeze actions recorded in FreezeRecord.Action.
+const (
+	ActionFreeze   = "freeze"
+	ActionUnfreeze = "unfreeze"
+)
+
+var (
+	ciliumPolicyGVR   = schema.GroupVersionResource{Group: "cilium.io", Version: "v2", Resource: "ciliumnetworkpolicies"}
+	applicationGVR    = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "applications"}
+	applicationSetGVR = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "applicationsets"}
+)
+
+// agentsAppSet is the ApplicationSet that generates the agents' Applications.
+const agentsAppSet = "agents"
+
+// frozenFields are the Application fields pauseSync changes, as JSON
+// pointers. The agents ApplicationSet is told to ignore them on a frozen
+// agent so regenerating its Application does not resume sync.
+var frozenFields = []interface{}{
+	"/spec/syncPolicy/automated",
+	"/metadata/annotations/argocd.argoproj.io~1skip-reconcile",
+	"/metadata/annotations/sikifanso.io~1frozen-sync-policy",
+}
+
+// Actor is who freezes or unfreezes an agent, for the audit record.
+type Actor struct {
+	// By is user@host.
+	By string
+	// Via is the interface used: cli, mcp or dashboard.
+	Via    string
+	Reason string
+}
+
+// CurrentActor returns the local user as an Actor acting through via.
+func CurrentActor(via, reason string) Actor {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return Actor{By: name, Via: via, Reason: reason}
+}
+
+// FreezeRecord is the audit record of a freeze or unfreeze. The record of
+// the current freeze is also kept on the agent namespace.
+type FreezeRecord struct {
+	Agent  string `json:"agent"`
+	Action string `json:"action"`
+	By     string `json:"by"`
+	Via    string `json:"via"`
+	Reason string `json:"reason,omitempty"`
+	At     string `json:"at"`
+	// Workloads are the workloads stopped or restored, as kind/name.
+	Workloads []string `json:"workloads,omitempty"`
+	// SyncPaused reports whether an ArgoCD Application was paused or resumed.
+	SyncPaused bool `json:"syncPaused"`
+}
+
+// Freezer stops agents through the Kubernetes API rather than GitOps, so a
+// misbehaving agent is cut off at once instead of at the next sync.
+type Freezer struct {
+	Kube kubernetes.Interface
+	Dyn  dynamic.Interface
+	// ArgoCDNamespace is where the agents' Applications live.
+	ArgoCDNamespace string
+	// AuditLog is the JSON-lines file records are appended to; empty skips it.
+	AuditLog string
+}
+
+// AuditLogPath returns the freeze audit log of the cluster's session.
+func AuditLogPath(clusterName string) (string, error) {
+	dir, err := session.Dir(clusterName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "audit.log"), nil
+}
+
+// NewFreezer returns a Freezer for the session's cluster that audits to its
+// session directory.
+func NewFreezer(sess *session.Session) (*Freezer, error) {
+	restCfg, err := kube.RESTConfigForCluster(sess.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating clientset: %w", err)
+	}
+	dyn, err := dynamic.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating dynamic client: %w", err)
+	}
+	auditLog, err := AuditLogPath(sess.ClusterName)
+	if err != nil {
+		return nil, err
+	}
+	argoNS := "argocd"
+	if cfg, err := infraconfig.Load(sess.GitOpsPath); err == nil {
+		argoNS = cfg.ArgoCD.Namespace
+	}
+	return &Freezer{Kube: cs, Dyn: dyn, ArgoCDNamespace: argoNS, AuditLog: auditLog}, nil
+}
+
+// Freeze cuts the agent off: a deny-all network policy is applied first,
+// then the agent's ArgoCD Application stops syncing so it cannot undo the
+// freeze, with the agents ApplicationSet told to leave the paused fields
+// alone when it regenerates the Application. Finally every Deployment and
+// StatefulSet is scaled to zero, every Job and CronJob suspended, and every
+// running Pod without an owner deleted. Freezing a frozen agent applies all
+// steps again, keeping the replica counts recorded the first time.
+func (f *Freezer) Freeze(ctx context.Context, name string, actor Actor) (*FreezeRecord, error) {
+	ns := namespaceFor(name)
+	if _, err := f.Kube.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{}); err != nil {
+		return nil, fmt.Errorf("getting namespace %s: %w", ns, err)
+	}
+	rec := newRecord(name, ActionFreeze, actor)
+
+	if err := f.applyDenyAll(ctx, ns); err != nil {
+		return nil, err
+	}
+	if err := f.ignoreFrozenFields(ctx, name, true); err != nil {
+		return nil, err
+	}
+	paused, err := f.pauseSync(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	rec.SyncPaused = paused
+	if rec.Workloads, err = f.scaleDown(ctx, ns); err != nil {
+		return nil, err
+	}
+	if err := f.setNamespaceRecord(ctx, ns, rec); err != nil {
+		return nil, err
+	}
+	return rec, f.audit(rec)
+}
+
+// Unfreeze reverses Freeze: workloads get their replicas back, the network
+// policy is removed and the Application syncs again. Deleted bare Pods are
+// not recreated here; ArgoCD does so on its next sync if they are part of
+// the agent's manifests.
+func (f *Freezer) Unfreeze(ctx context.Context, name string, actor Actor) (*FreezeRecord, error) {
+	ns := namespaceFor(name)
+	frozen, err := f.Frozen(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if frozen == nil {
+		return nil, fmt.Errorf("agent %s is not frozen", name)
+	}
+	rec := newRecord(name, ActionUnfreeze, actor)
+
+	if rec.Workloads, err = f.scaleUp(ctx, ns); err != nil {
+		return nil, err
+	}
+	err = f.Dyn.Resource(ciliumPolicyGVR).Namespace(ns).Delete(ctx, FreezePolicyName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("deleting freeze policy: %w", err)
+	}
+	if rec.SyncPaused, err = f.resumeSync(ctx, name); err != nil {
+		return nil, err
+	}
+	if err := f.ignoreFrozenFields(ctx, name, false); err != nil {
+		return nil, err
+	}
+	if err := f.setNamespaceRecord(ctx, ns, nil); err != nil {
+		return nil, err
+	}
+	return rec, f.audit(rec)
+}
+
+// Frozen returns the record of the agent's current freeze, or nil when it is
+// not frozen.
+func (f *Freezer) Frozen(ctx context.Context, name string) (*FreezeRecord, error) {
+	ns := namespaceFor(name)
+	obj, err := f.Kube.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("getting namespace %s: %w", ns, err)
+	}
+	raw, ok := obj.Annotations[frozenAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var rec FreezeRecord
+	if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+		return nil, fmt.Errorf("parsing freeze record of %s: %w", name, err)
+	}
+	return &rec, nil
+}
+
+func newRecord(name, action string, actor Actor) *FreezeRecord {
+	return &FreezeRecord{
+		Agent:  name,
+		Action: action,
+		By:     actor.By,
+		Via:    actor.Via,
+		Reason: actor.Reason,
+		At:     time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// applyDenyAll creates the freeze policy. Deny rules take precedence over
+// the agent's allow rules, so the policy cuts all traffic regardless of its
+// egress allowlist.
+func (f *Freezer) applyDenyAll(ctx context.Context, ns string) error {
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cilium.io/v2",
+		"kind":       "CiliumNetworkPolicy",
+		"metadata": map[string]interface{}{
+			"name":      FreezePolicyName,
+			"namespace": ns,
+			"labels":    map[string]interface{}{"app.kubernetes.io/managed-by": "sikifanso"},
+		},
+		"spec": map[string]interface{}{
+			"endpointSelector": map[string]interface{}{},
+			"ingressDeny":      []interface{}{map[string]interface{}{"fromEntities": []interface{}{"all"}}},
+			"egressDeny":       []interface{}{map[string]interface{}{"toEntities": []interface{}{"all"}}},
+		},
+	}}
+	_, err := f.Dyn.Resource(ciliumPolicyGVR).Namespace(ns).Create(ctx, policy, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("applying freeze policy: %w", err)
+	}
+	return nil
+}
+
+// pauseSync stops ArgoCD reconciling the agent's Application, keeping its
+// automated sync policy for resumeSync. It reports false when the agent has
+// no Application yet.
+func (f *Freezer) pauseSync(ctx context.Context, name string) (bool, error) {
+	apps := f.Dyn.Resource(applicationGVR).Namespace(f.ArgoCDNamespace)
+	app, err := apps.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("getting application %s: %w", name, err)
+	}
+
+	annotations := app.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if automated, found, _ := unstructured.NestedFieldCopy(app.Object, "spec", "syncPolicy", "automated"); found {
+		data, err := json.Marshal(automated)
+		if err != nil {
+			return false, fmt.Errorf("encoding sync policy: %w", err)
+		}
+		annotations[syncPolicyAnnotation] = string(data)
+		unstructured.RemoveNestedField(app.Object, "spec", "syncPolicy", "automated")
+	}
+	annotations[skipReconcileAnnotation] = "true"
+	app.SetAnnotations(annotations)
+
+	if _, err := apps.Update(ctx, app, metav1.UpdateOptions{}); err != nil {
+		return false, fmt.Errorf("pausing sync of %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// ignoreFrozenFields adds the agent's rule to, or removes it from, the
+// ignoreApplicationDifferences of the agents ApplicationSet. Without it the
+// ApplicationSet controller rewrites a frozen agent's Application from its
+// template, restoring automated sync. Other rules are left untouched, and a
+// cluster without the ApplicationSet is skipped.
+func (f *Freezer) ignoreFrozenFields(ctx context.Context, name string, ignore bool) error {
+	appSets := f.Dyn.Resource(applicationSetGVR).Namespace(f.ArgoCDNamespace)
+	appSet, err := appSets.Get(ctx, agentsAppSet, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("getting applicationset %s: %w", agentsAppSet, err)
+	}
+
+	rules, _, _ := unstructured.NestedSlice(appSet.Object, "spec", "ignoreApplicationDifferences")
+	kept := make([]interface{}, 0, len(rules)+1)
+	present := false
+	for _, r := range rules {
+		if isFrozenRule(r, name) {
+			present = true
+			if !ignore {
+				continue
+			}
+		}
+		kept = append(kept, r)
+	}
+	if present == ignore {
+		return nil
+	}
+	if ignore {
+		kept = append(kept, map[string]interface{}{
+			"name":         name,
+			"jsonPointers": append([]interface{}(nil), frozenFields...),
+		})
+	}
+	if len(kept) == 0 {
+		unstructured.RemoveNestedField(appSet.Object, "spec", "ignoreApplicationDifferences")
+	} else if err := unstructured.SetNestedSlice(appSet.Object, kept, "spec", "ignoreApplicationDifferences"); err != nil {
+		return fmt.Errorf("setting ignored differences: %w", err)
+	}
+	if _, err := appSets.Update(ctx, appSet, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating applicationset %s: %w", agentsAppSet, err)
+	}
+	return nil
+}
+
+// isFrozenRule reports whether r is the rule ignoreFrozenFields adds for
+// the agent.
+func isFrozenRule(r interface{}, name string) bool {
+	m, ok := r.(map[string]interface{})
+	if !ok || m["name"] != name {
+		return false
+	}
+	pointers, _ := m["jsonPointers"].([]interface{})
+	if len(pointers) != len(frozenFields) {
+		return false
+	}
+	for i, p := range pointers {
+		if p != frozenFields[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// resumeSync restores what pauseSync changed.
+func (f *Freezer) resumeSync(ctx context.Context, name string) (bool, error) {
+	apps := f.Dyn.Resource(applicationGVR).Namespace(f.ArgoCDNamespace)
+	app, err := apps.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("getting application %s: %w", name, err)
+	}
+
+	annotations := app.GetAnnotations()
+	if raw, ok := annotations[syncPolicyAnnotation]; ok {
+		var automated interface{}
+		if err := json.Unmarshal([]byte(raw), &automated); err != nil {
+			return false, fmt.Errorf("parsing saved sync policy of %s: %w", name, err)
+		}
+		if err := unstructured.SetNestedField(app.Object, automated, "spec", "syncPolicy", "automated"); err != nil {
+			return false, fmt.Errorf("restoring sync policy of %s: %w", name, err)
+		}
+	}
+	delete(annotations, syncPolicyAnnotation)
+	delete(annotations, skipReconcileAnnotation)
+	app.SetAnnotations(annotations)
+
+	if _, err := apps.Update(ctx, app, metav1.UpdateOptions{}); err != nil {
+		return false, fmt.Errorf("resuming sync of %s: %w", name, err)
+	}
+	return true, nil
+}
+
+// scaleDown scales Deployments and StatefulSets to zero and suspends Jobs and
+// CronJobs, recording their previous state in annotations. Running Pods with
+// no owner would keep running, so they are deleted.
+func (f *Freezer) scaleDown(ctx context.Context, ns string) ([]string, error) {
+	var changed []string
+	deployments, err := f.Kube.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing deployments: %w", err)
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		if !freezeReplicas(&d.ObjectMeta, &d.Spec.Replicas) {
+			continue
+		}
+		if _, err := f.Kube.AppsV1().Deployments(ns).Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("scaling down deployment %s: %w", d.Name, err)
+		}
+		changed = append(changed, "deployment/"+d.Name)
+	}
+
+	statefulSets, err := f.Kube.AppsV1().StatefulSets(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing statefulsets: %w", err)
+	}
+	for i := range statefulSets.Items {
+		s := &statefulSets.Items[i]
+		if !freezeReplicas(&s.ObjectMeta, &s.Spec.Replicas) {
+			continue
+		}
+		if _, err := f.Kube.AppsV1().StatefulSets(ns).Update(ctx, s, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("scaling down statefulset %s: %w", s.Name, err)
+		}
+		changed = append(changed, "statefulset/"+s.Name)
+	}
+
+	jobs, err := f.Kube.BatchV1().Jobs(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing jobs: %w", err)
+	}
+	for i := range jobs.Items {
+		j := &jobs.Items[i]
+		if j.Spec.Suspend != nil && *j.Spec.Suspend {
+			continue
+		}
+		if j.Annotations == nil {
+			j.Annotations = map[string]string{}
+		}
+		j.Annotations[suspendAnnotation] = "true"
+		suspend := true
+		j.Spec.Suspend = &suspend
+		if _, err := f.Kube.BatchV1().Jobs(ns).Update(ctx, j, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("suspending job %s: %w", j.Name, err)
+		}
+		changed = append(changed, "job/"+j.Name)
+	}
+
+	cronJobs, err := f.Kube.BatchV1().CronJobs(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing cronjobs: %w", err)
+	}
+	for i := range cronJobs.Items {
+		c := &cronJobs.Items[i]
+		if c.Spec.Suspend != nil && *c.Spec.Suspend {
+			continue
+		}
+		if c.Annotations == nil {
+			c.Annotations = map[string]string{}
+		}
+		c.Annotations[suspendAnnotation] = "true"
+		suspend := true
+		c.Spec.Suspend = &suspend
+		if _, err := f.Kube.BatchV1().CronJobs(ns).Update(ctx, c, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("suspending cronjob %s: %w", c.Name, err)
+		}
+		changed = append(changed, "cronjob/"+c.Name)
+	}
+
+	pods, err := f.Kube.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+	for _, p := range pods.Items {
+		if len(p.OwnerReferences) > 0 || p.DeletionTimestamp != nil ||
+			p.Status.Phase == corev1.PodSucceeded || p.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		err := f.Kube.CoreV1().Pods(ns).Delete(ctx, p.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("deleting pod %s: %w", p.Name, err)
+		}
+		changed = append(changed, "pod/"+p.Name)
+	}
+	return changed, nil
+}
+
+// scaleUp restores the state scaleDown recorded.
+func (f *Freezer) scaleUp(ctx context.Context, ns string) ([]string, error) {
+	var changed []string
+	deployments, err := f.Kube.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing deployments: %w", err)
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		if !thawReplicas(&d.ObjectMeta, &d.Spec.Replicas) {
+			continue
+		}
+		if _, err := f.Kube.AppsV1().Deployments(ns).Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("restoring deployment %s: %w", d.Name, err)
+		}
+		changed = append(changed, "deployment/"+d.Name)
+	}
+
+	statefulSets, err := f.Kube.AppsV1().StatefulSets(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing statefulsets: %w", err)
+	}
+	for i := range statefulSets.Items {
+		s := &statefulSets.Items[i]
+		if !thawReplicas(&s.ObjectMeta, &s.Spec.Replicas) {
+			continue
+		}
+		if _, err := f.Kube.AppsV1().StatefulSets(ns).Update(ctx, s, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("restoring statefulset %s: %w", s.Name, err)
+		}
+		changed = append(changed, "statefulset/"+s.Name)
+	}
+
+	jobs, err := f.Kube.BatchV1().Jobs(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing jobs: %w", err)
+	}
+	for i := range jobs.Items {
+		j := &jobs.Items[i]
+		if _, ok := j.Annotations[suspendAnnotation]; !ok {
+			continue
+		}
+		delete(j.Annotations, suspendAnnotation)
+		suspend := false
+		j.Spec.Suspend = &suspend
+		if _, err := f.Kube.BatchV1().Jobs(ns).Update(ctx, j, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("resuming job %s: %w", j.Name, err)
+		}
+		changed = append(changed, "job/"+j.Name)
+	}
+
+	cronJobs, err := f.Kube.BatchV1().CronJobs(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing cronjobs: %w", err)
+	}
+	for i := range cronJobs.Items {
+		c := &cronJobs.Items[i]
+		if _, ok := c.Annotations[suspendAnnotation]; !ok {
+			continue
+		}
+		delete(c.Annotations, suspendAnnotation)
+		suspend := false
+		c.Spec.Suspend = &suspend
+		if _, err := f.Kube.BatchV1().CronJobs(ns).Update(ctx, c, metav1.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("resuming cronjob %s: %w", c.Name, err)
+		}
+		changed = append(changed, "cronjob/"+c.Name)
+	}
+	return changed, nil
+}
+
+// freezeReplicas records *replicas in meta and sets it to zero. It reports
+// false when the workload is already frozen.
+func freezeReplicas(meta *metav1.ObjectMeta, replicas **int32) bool {
+	if _, ok := meta.Annotations[replicasAnnotation]; ok {
+		return false
+	}
+	current := int32(1) // the API default when replicas is unset
+	if *replicas != nil {
+		current = **replicas
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[replicasAnnotation] = strconv.Itoa(int(current))
+	zero := int32(0)
+	*replicas = &zero
+	return true
+}
+
+// thawReplicas restores the replicas freezeReplicas recorded. It reports
+// false when the workload was not frozen.
+func thawReplicas(meta *metav1.ObjectMeta, replicas **int32) bool {
+	raw, ok := meta.Annotations[replicasAnnotation]
+	if !ok {
+		return false
+	}
+	delete(meta.Annotations, replicasAnnotation)
+	n, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil {
+		n = 1
+	}
+	restored := int32(n)
+	*replicas = &restored
+	return true
+}
+
+// setNamespaceRecord stores rec on the namespace, or removes the stored
+// record when rec is nil.
+func (f *Freezer) setNamespaceRecord(ctx context.Context, ns string, rec *FreezeRecord) error {
+	obj, err := f.Kube.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting namespace %s: %w", ns, err)
+	}
+	if rec == nil {
+		delete(obj.Annotations, frozenAnnotation)
+	} else {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("encoding freeze record: %w", err)
+		}
+		if obj.Annotations == nil {
+			obj.Annotations = map[string]string{}
+		}
+		obj.Annotations[frozenAnnotation] = string(data)
+	}
+	if _, err := f.Kube.CoreV1().Namespaces().Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating namespace %s: %w", ns, err)
+	}
+	return nil
+}
+
+// audit appends rec to the audit log.
+func (f *Freezer) audit(rec *FreezeRecord) error {
+	if f.AuditLog == "" {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding audit record: %w", err)
+	}
+	file, err := os.OpenFile(f.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return nil
+}
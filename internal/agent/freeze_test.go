@@ -0,0 +1,308 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func setupFreezer(t *testing.T) *Freezer {
+	t.Helper()
+	replicas := int32(3)
+	cs := fake.NewClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "agent-bot"}},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "bot", Namespace: "agent-bot"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "memory", Namespace: "agent-bot"}},
+		&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "ingest", Namespace: "agent-bot"}},
+	)
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			ciliumPolicyGVR:   "CiliumNetworkPolicyList",
+			applicationGVR:    "ApplicationList",
+			applicationSetGVR: "ApplicationSetList",
+		})
+	app := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Application",
+		"metadata":   map[string]interface{}{"name": "bot", "namespace": "argocd"},
+		"spec": map[string]interface{}{
+			"syncPolicy": map[string]interface{}{
+				"automated": map[string]interface{}{"prune": true, "selfHeal": true},
+			},
+		},
+	}}
+	if _, err := dyn.Resource(applicationGVR).Namespace("argocd").Create(context.Background(), app, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	appSet := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "ApplicationSet",
+		"metadata":   map[string]interface{}{"name": agentsAppSet, "namespace": "argocd"},
+		"spec": map[string]interface{}{
+			"ignoreApplicationDifferences": []interface{}{
+				map[string]interface{}{"jsonPointers": []interface{}{"/spec/source/targetRevision"}},
+			},
+		},
+	}}
+	if _, err := dyn.Resource(applicationSetGVR).Namespace("argocd").Create(context.Background(), appSet, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	return &Freezer{
+		Kube:            cs,
+		Dyn:             dyn,
+		ArgoCDNamespace: "argocd",
+		AuditLog:        filepath.Join(t.TempDir(), "audit.log"),
+	}
+}
+
+func TestFreezer_FreezeAndUnfreeze(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f := setupFreezer(t)
+
+	rec, err := f.Freeze(ctx, "bot", Actor{By: "alice@laptop", Via: "cli", Reason: "runaway spend"})
+	if err != nil {
+		t.Fatalf("Freeze: %v", err)
+	}
+	if got := strings.Join(rec.Workloads, ","); got != "deployment/bot,statefulset/memory,job/ingest" {
+		t.Errorf("frozen workloads = %s", got)
+	}
+	if !rec.SyncPaused {
+		t.Error("sync not paused")
+	}
+
+	d, _ := f.Kube.AppsV1().Deployments("agent-bot").Get(ctx, "bot", metav1.GetOptions{})
+	if *d.Spec.Replicas != 0 {
+		t.Errorf("deployment replicas = %d, want 0", *d.Spec.Replicas)
+	}
+	j, _ := f.Kube.BatchV1().Jobs("agent-bot").Get(ctx, "ingest", metav1.GetOptions{})
+	if j.Spec.Suspend == nil || !*j.Spec.Suspend {
+		t.Error("job not suspended")
+	}
+	if _, err := f.Dyn.Resource(ciliumPolicyGVR).Namespace("agent-bot").Get(ctx, FreezePolicyName, metav1.GetOptions{}); err != nil {
+		t.Errorf("freeze policy: %v", err)
+	}
+	app, _ := f.Dyn.Resource(applicationGVR).Namespace("argocd").Get(ctx, "bot", metav1.GetOptions{})
+	if _, found, _ := unstructured.NestedMap(app.Object, "spec", "syncPolicy", "automated"); found {
+		t.Error("automated sync still set on a frozen agent")
+	}
+	if app.GetAnnotations()[skipReconcileAnnotation] != "true" {
+		t.Error("application not marked skip-reconcile")
+	}
+
+	frozen, err := f.Frozen(ctx, "bot")
+	if err != nil || frozen == nil || frozen.By != "alice@laptop" || frozen.Reason != "runaway spend" {
+		t.Fatalf("Frozen = %+v, %v", frozen, err)
+	}
+
+	// A second freeze must keep the replica count recorded by the first.
+	if _, err := f.Freeze(ctx, "bot", Actor{By: "alice@laptop", Via: "mcp"}); err != nil {
+		t.Fatalf("second Freeze: %v", err)
+	}
+
+	rec, err = f.Unfreeze(ctx, "bot", Actor{By: "bob@desk", Via: "dashboard"})
+	if err != nil {
+		t.Fatalf("Unfreeze: %v", err)
+	}
+	if len(rec.Workloads) != 3 || !rec.SyncPaused {
+		t.Errorf("unfreeze record = %+v", rec)
+	}
+	d, _ = f.Kube.AppsV1().Deployments("agent-bot").Get(ctx, "bot", metav1.GetOptions{})
+	if *d.Spec.Replicas != 3 {
+		t.Errorf("deployment replicas = %d, want 3", *d.Spec.Replicas)
+	}
+	s, _ := f.Kube.AppsV1().StatefulSets("agent-bot").Get(ctx, "memory", metav1.GetOptions{})
+	if *s.Spec.Replicas != 1 {
+		t.Errorf("statefulset replicas = %d, want 1", *s.Spec.Replicas)
+	}
+	if _, err := f.Dyn.Resource(ciliumPolicyGVR).Namespace("agent-bot").Get(ctx, FreezePolicyName, metav1.GetOptions{}); err == nil {
+		t.Error("freeze policy kept after unfreeze")
+	}
+	app, _ = f.Dyn.Resource(applicationGVR).Namespace("argocd").Get(ctx, "bot", metav1.GetOptions{})
+	if selfHeal, _, _ := unstructured.NestedBool(app.Object, "spec", "syncPolicy", "automated", "selfHeal"); !selfHeal {
+		t.Error("automated sync policy not restored")
+	}
+	if _, ok := app.GetAnnotations()[skipReconcileAnnotation]; ok {
+		t.Error("skip-reconcile kept after unfreeze")
+	}
+	if frozen, _ := f.Frozen(ctx, "bot"); frozen != nil {
+		t.Errorf("still frozen: %+v", frozen)
+	}
+
+	data, err := os.ReadFile(f.AuditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("audit log has %d records, want 3", len(lines))
+	}
+	var last FreezeRecord
+	if err := json.Unmarshal([]byte(lines[2]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if last.Action != ActionUnfreeze || last.By != "bob@desk" || last.Via != "dashboard" {
+		t.Errorf("last audit record = %+v", last)
+	}
+}
+
+func TestFreezer_UnfreezeNotFrozen(t *testing.T) {
+	t.Parallel()
+	f := setupFreezer(t)
+	if _, err := f.Unfreeze(context.Background(), "bot", Actor{Via: "cli"}); err == nil {
+		t.Error("expected an error unfreezing an agent that is not frozen")
+	}
+}
+
+// regenerate does what the ApplicationSet controller does to a generated
+// Application: it resets the fields pauseSync touches to the template's,
+// except those the agents ApplicationSet ignores for the Application.
+func regenerate(t *testing.T, f *Freezer, name string) {
+	t.Helper()
+	ctx := context.Background()
+	appSet, err := f.Dyn.Resource(applicationSetGVR).Namespace("argocd").Get(ctx, agentsAppSet, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ignored := map[string]bool{}
+	rules, _, _ := unstructured.NestedSlice(appSet.Object, "spec", "ignoreApplicationDifferences")
+	for _, r := range rules {
+		rule := r.(map[string]interface{})
+		if n, ok := rule["name"]; ok && n != name {
+			continue
+		}
+		pointers, _ := rule["jsonPointers"].([]interface{})
+		for _, p := range pointers {
+			ignored[p.(string)] = true
+		}
+	}
+
+	apps := f.Dyn.Resource(applicationGVR).Namespace("argocd")
+	app, err := apps.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ignored["/spec/syncPolicy/automated"] {
+		automated := map[string]interface{}{"prune": true, "selfHeal": true}
+		if err := unstructured.SetNestedMap(app.Object, automated, "spec", "syncPolicy", "automated"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	annotations := app.GetAnnotations()
+	if !ignored["/metadata/annotations/argocd.argoproj.io~1skip-reconcile"] {
+		delete(annotations, skipReconcileAnnotation)
+	}
+	if !ignored["/metadata/annotations/sikifanso.io~1frozen-sync-policy"] {
+		delete(annotations, syncPolicyAnnotation)
+	}
+	app.SetAnnotations(annotations)
+	if _, err := apps.Update(ctx, app, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFreezer_CronJobsAndBarePods(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f := setupFreezer(t)
+	cron := &batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "agent-bot"}}
+	if _, err := f.Kube.BatchV1().CronJobs("agent-bot").Create(ctx, cron, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	owner := []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "bot-1", UID: "1"}}
+	for _, p := range []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "scratch", Namespace: "agent-bot"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "done", Namespace: "agent-bot"}, Status: corev1.PodStatus{Phase: corev1.PodSucceeded}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "bot-1-abc", Namespace: "agent-bot", OwnerReferences: owner}},
+	} {
+		if _, err := f.Kube.CoreV1().Pods("agent-bot").Create(ctx, p, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec, err := f.Freeze(ctx, "bot", Actor{By: "alice@laptop", Via: "cli"})
+	if err != nil {
+		t.Fatalf("Freeze: %v", err)
+	}
+	if got := strings.Join(rec.Workloads, ","); !strings.HasSuffix(got, ",cronjob/nightly,pod/scratch") {
+		t.Errorf("frozen workloads = %s", got)
+	}
+	c, _ := f.Kube.BatchV1().CronJobs("agent-bot").Get(ctx, "nightly", metav1.GetOptions{})
+	if c.Spec.Suspend == nil || !*c.Spec.Suspend || c.Annotations[suspendAnnotation] != "true" {
+		t.Errorf("cronjob not suspended: %+v", c)
+	}
+	if _, err := f.Kube.CoreV1().Pods("agent-bot").Get(ctx, "scratch", metav1.GetOptions{}); err == nil {
+		t.Error("bare pod still running on a frozen agent")
+	}
+	for _, name := range []string{"done", "bot-1-abc"} {
+		if _, err := f.Kube.CoreV1().Pods("agent-bot").Get(ctx, name, metav1.GetOptions{}); err != nil {
+			t.Errorf("pod %s deleted: %v", name, err)
+		}
+	}
+
+	rec, err = f.Unfreeze(ctx, "bot", Actor{By: "alice@laptop", Via: "cli"})
+	if err != nil {
+		t.Fatalf("Unfreeze: %v", err)
+	}
+	if got := strings.Join(rec.Workloads, ","); !strings.HasSuffix(got, ",cronjob/nightly") {
+		t.Errorf("unfrozen workloads = %s", got)
+	}
+	c, _ = f.Kube.BatchV1().CronJobs("agent-bot").Get(ctx, "nightly", metav1.GetOptions{})
+	if c.Spec.Suspend == nil || *c.Spec.Suspend {
+		t.Error("cronjob not resumed")
+	}
+	if _, ok := c.Annotations[suspendAnnotation]; ok {
+		t.Error("suspend annotation kept after unfreeze")
+	}
+}
+
+func TestFreezer_SurvivesApplicationSetRegeneration(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	f := setupFreezer(t)
+
+	if _, err := f.Freeze(ctx, "bot", Actor{Via: "cli"}); err != nil {
+		t.Fatalf("Freeze: %v", err)
+	}
+	regenerate(t, f, "bot")
+
+	app, _ := f.Dyn.Resource(applicationGVR).Namespace("argocd").Get(ctx, "bot", metav1.GetOptions{})
+	if _, found, _ := unstructured.NestedMap(app.Object, "spec", "syncPolicy", "automated"); found {
+		t.Error("regenerating the application restored automated sync on a frozen agent")
+	}
+	if app.GetAnnotations()[skipReconcileAnnotation] != "true" {
+		t.Error("regenerating the application dropped skip-reconcile")
+	}
+
+	if _, err := f.Unfreeze(ctx, "bot", Actor{Via: "cli"}); err != nil {
+		t.Fatalf("Unfreeze: %v", err)
+	}
+	app, _ = f.Dyn.Resource(applicationGVR).Namespace("argocd").Get(ctx, "bot", metav1.GetOptions{})
+	if selfHeal, _, _ := unstructured.NestedBool(app.Object, "spec", "syncPolicy", "automated", "selfHeal"); !selfHeal {
+		t.Error("automated sync policy not restored")
+	}
+	appSet, _ := f.Dyn.Resource(applicationSetGVR).Namespace("argocd").Get(ctx, agentsAppSet, metav1.GetOptions{})
+	rules, _, _ := unstructured.NestedSlice(appSet.Object, "spec", "ignoreApplicationDifferences")
+	if len(rules) != 1 {
+		t.Fatalf("ignoreApplicationDifferences = %v, want only the pre-existing rule", rules)
+	}
+	if _, ok := rules[0].(map[string]interface{})["name"]; ok {
+		t.Errorf("kept rule = %v, want the unnamed pre-existing rule", rules[0])
+	}
+}
@@ -0,0 +1,210 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/kube"
+	"github.com/alicanalbayrak/sikifanso/internal/litellm"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// KeySecretName is the Secret in an agent namespace that holds its LiteLLM
+// virtual key (LITELLM_API_KEY) and the proxy address (LITELLM_BASE_URL).
+const KeySecretName = "litellm-key"
+
+// KeyOpts limits an agent's LiteLLM key. Zero values mean unlimited.
+type KeyOpts struct {
+	// MaxBudget is the total spend allowed, in USD.
+	MaxBudget float64
+	// RPMLimit caps requests per minute.
+	RPMLimit int
+	// Models restricts the key to these model names.
+	Models []string
+}
+
+// Gateway provisions per-agent virtual keys on the LiteLLM Proxy.
+type Gateway struct {
+	Kube    kubernetes.Interface
+	LiteLLM *litellm.Client
+	// URL is the in-cluster proxy address written into each key Secret.
+	URL string
+}
+
+// ErrNoGateway is returned (wrapped) by CheckKeyOpts for key limits on a
+// cluster whose litellm-proxy catalog app is not enabled.
+var ErrNoGateway = errors.New("LLM key limits need the " + litellm.AppName + " app")
+
+// gatewayEntry returns the enabled litellm-proxy catalog entry, or nil when
+// the catalog lacks it or it is disabled.
+func gatewayEntry(gitOpsPath string) (*catalog.Entry, error) {
+	e, err := catalog.Find(gitOpsPath, litellm.AppName)
+	if errors.Is(err, catalog.ErrNotFound) {
+		// A catalog without the entry simply has no gateway.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !e.Enabled {
+		return nil, nil
+	}
+	return e, nil
+}
+
+// CheckKeyOpts rejects key limits when there is no gateway to enforce them.
+// Every surface calls it before an agent is committed.
+func CheckKeyOpts(gitOpsPath string, opts KeyOpts) error {
+	if opts.MaxBudget == 0 && opts.RPMLimit == 0 && len(opts.Models) == 0 {
+		return nil
+	}
+	e, err := gatewayEntry(gitOpsPath)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		return fmt.Errorf("%w; enable it first", ErrNoGateway)
+	}
+	return nil
+}
+
+// ConnectGateway returns a Gateway for the cluster, or nil when the
+// litellm-proxy catalog app is not enabled.
+func ConnectGateway(ctx context.Context, gitOpsPath, clusterName string) (*Gateway, error) {
+	e, err := gatewayEntry(gitOpsPath)
+	if err != nil || e == nil {
+		return nil, err
+	}
+
+	restCfg, err := kube.RESTConfigForCluster(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating clientset: %w", err)
+	}
+	lc, err := litellm.NewClusterClient(ctx, restCfg, cs, e.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &Gateway{Kube: cs, LiteLLM: lc, URL: litellm.InClusterURL(e.Namespace)}, nil
+}
+
+// keyAlias names an agent's key on the proxy.
+func keyAlias(name string) string {
+	return "sikifanso-agent-" + name
+}
+
+// ProvisionKey generates a virtual key for the agent and stores it in the
+// agent namespace, creating the namespace if ArgoCD has not yet. An agent
+// that already has a key keeps it.
+func (g *Gateway) ProvisionKey(ctx context.Context, name string, opts KeyOpts) (*litellm.Key, error) {
+	existing, err := g.readKey(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != "" {
+		return g.LiteLLM.KeyInfo(ctx, existing)
+	}
+
+	ns := namespaceFor(name)
+	_, err = g.Kube.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: ns},
+	}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("creating namespace %s: %w", ns, err)
+	}
+
+	key, err := g.LiteLLM.GenerateKey(ctx, litellm.KeyRequest{
+		Alias:     keyAlias(name),
+		MaxBudget: opts.MaxBudget,
+		RPMLimit:  opts.RPMLimit,
+		Models:    opts.Models,
+		Metadata:  map[string]string{"sikifanso/agent": name},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      KeySecretName,
+			Namespace: ns,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "sikifanso"},
+		},
+		Data: map[string][]byte{
+			"LITELLM_API_KEY":  []byte(key.Key),
+			"LITELLM_BASE_URL": []byte(g.URL),
+		},
+	}
+	if _, err := g.Kube.CoreV1().Secrets(ns).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		// Do not leave an orphaned key behind.
+		_ = g.LiteLLM.DeleteKey(ctx, key.Key)
+		return nil, fmt.Errorf("storing key secret: %w", err)
+	}
+	return key, nil
+}
+
+// ProvisionNewKey provisions the key of an agent that was just committed.
+// If that fails, the agent is deleted again, along with any namespace
+// ProvisionKey created ahead of ArgoCD, so a create yields either an agent
+// with its key or nothing.
+func (g *Gateway) ProvisionNewKey(ctx context.Context, gitOpsPath, name string, opts KeyOpts) (*litellm.Key, error) {
+	key, err := g.ProvisionKey(ctx, name, opts)
+	if err == nil {
+		return key, nil
+	}
+	if delErr := Delete(gitOpsPath, name); delErr != nil {
+		return nil, fmt.Errorf("provisioning LLM key: %w (rolling back agent %s also failed: %v)", err, name, delErr)
+	}
+	nsErr := g.Kube.CoreV1().Namespaces().Delete(ctx, namespaceFor(name), metav1.DeleteOptions{})
+	if nsErr != nil && !apierrors.IsNotFound(nsErr) {
+		return nil, fmt.Errorf("provisioning LLM key: %w (agent %s rolled back, but deleting its namespace failed: %v)", err, name, nsErr)
+	}
+	return nil, fmt.Errorf("provisioning LLM key: %w (agent %s rolled back)", err, name)
+}
+
+// RevokeKey deletes the agent's virtual key and its Secret. It returns false
+// when the agent has no key.
+func (g *Gateway) RevokeKey(ctx context.Context, name string) (bool, error) {
+	key, err := g.readKey(ctx, name)
+	if err != nil || key == "" {
+		return false, err
+	}
+	if err := g.LiteLLM.DeleteKey(ctx, key); err != nil {
+		return false, err
+	}
+	err = g.Kube.CoreV1().Secrets(namespaceFor(name)).Delete(ctx, KeySecretName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return true, fmt.Errorf("deleting key secret: %w", err)
+	}
+	return true, nil
+}
+
+// KeyUsage returns the limits and current spend of the agent's key, or nil
+// when the agent has none.
+func (g *Gateway) KeyUsage(ctx context.Context, name string) (*litellm.Key, error) {
+	key, err := g.readKey(ctx, name)
+	if err != nil || key == "" {
+		return nil, err
+	}
+	return g.LiteLLM.KeyInfo(ctx, key)
+}
+
+// readKey returns the key stored in the agent namespace, or "" if none is.
+func (g *Gateway) readKey(ctx context.Context, name string) (string, error) {
+	secret, err := g.Kube.CoreV1().Secrets(namespaceFor(name)).Get(ctx, KeySecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading key secret: %w", err)
+	}
+	return string(secret.Data["LITELLM_API_KEY"]), nil
+}
@@ -0,0 +1,191 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/alicanalbayrak/sikifanso/internal/litellm"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// stubGateway returns a Gateway backed by a fake clientset and an in-memory
+// LiteLLM admin API, plus the live keys by value.
+func stubGateway(t *testing.T) (*Gateway, *fake.Clientset, map[string]litellm.KeyRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	keys := map[string]litellm.KeyRequest{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /key/generate", func(w http.ResponseWriter, r *http.Request) {
+		var req litellm.KeyRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		keys["sk-"+req.Alias] = req
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]any{"key": "sk-" + req.Alias, "key_alias": req.Alias, "max_budget": req.MaxBudget})
+	})
+	mux.HandleFunc("GET /key/info", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		req, ok := keys[r.URL.Query().Get("key")]
+		mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"info": map[string]any{"key_alias": req.Alias, "spend": 0.5, "max_budget": req.MaxBudget}})
+	})
+	mux.HandleFunc("POST /key/delete", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Keys []string `json:"keys"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		for _, k := range req.Keys {
+			delete(keys, k)
+		}
+		mu.Unlock()
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	cs := fake.NewClientset()
+	gw := &Gateway{Kube: cs, LiteLLM: litellm.NewClient(srv.URL, "sk-master", nil), URL: litellm.InClusterURL("gateway")}
+	return gw, cs, keys
+}
+
+func TestGateway_KeyLifecycle(t *testing.T) {
+	t.Parallel()
+	gw, cs, keys := stubGateway(t)
+	ctx := context.Background()
+
+	key, err := gw.ProvisionKey(ctx, "bot", KeyOpts{MaxBudget: 10, RPMLimit: 30, Models: []string{"gpt-4o"}})
+	if err != nil {
+		t.Fatalf("ProvisionKey: %v", err)
+	}
+	req := keys[key.Key]
+	if req.Alias != "sikifanso-agent-bot" || req.MaxBudget != 10 || req.RPMLimit != 30 || len(req.Models) != 1 {
+		t.Errorf("generated key request = %+v", req)
+	}
+
+	secret, err := cs.CoreV1().Secrets("agent-bot").Get(ctx, KeySecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("key secret: %v", err)
+	}
+	if string(secret.Data["LITELLM_API_KEY"]) != key.Key {
+		t.Errorf("secret key = %q, want %q", secret.Data["LITELLM_API_KEY"], key.Key)
+	}
+	if got := string(secret.Data["LITELLM_BASE_URL"]); got != "http://litellm-proxy.gateway.svc.cluster.local:4000" {
+		t.Errorf("secret base URL = %q", got)
+	}
+
+	// Provisioning again keeps the existing key.
+	if _, err := gw.ProvisionKey(ctx, "bot", KeyOpts{}); err != nil {
+		t.Fatalf("second ProvisionKey: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("%d keys after re-provisioning, want 1", len(keys))
+	}
+
+	usage, err := gw.KeyUsage(ctx, "bot")
+	if err != nil {
+		t.Fatalf("KeyUsage: %v", err)
+	}
+	if usage.Spend != 0.5 || usage.MaxBudget == nil || *usage.MaxBudget != 10 {
+		t.Errorf("usage = %+v, want spend 0.5 of 10", usage)
+	}
+
+	revoked, err := gw.RevokeKey(ctx, "bot")
+	if err != nil || !revoked {
+		t.Fatalf("RevokeKey: revoked=%v err=%v", revoked, err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("%d keys after revoke, want 0", len(keys))
+	}
+	if _, err := cs.CoreV1().Secrets("agent-bot").Get(ctx, KeySecretName, metav1.GetOptions{}); err == nil {
+		t.Error("key secret survived revoke")
+	}
+}
+
+func TestGateway_NoKey(t *testing.T) {
+	t.Parallel()
+	gw, _, _ := stubGateway(t)
+	ctx := context.Background()
+
+	usage, err := gw.KeyUsage(ctx, "ghost")
+	if err != nil || usage != nil {
+		t.Errorf("KeyUsage without key = %+v, %v; want nil, nil", usage, err)
+	}
+	revoked, err := gw.RevokeKey(ctx, "ghost")
+	if err != nil || revoked {
+		t.Errorf("RevokeKey without key = %v, %v; want false, nil", revoked, err)
+	}
+}
+
+func TestConnectGateway_DisabledProxy(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	gw, err := ConnectGateway(context.Background(), dir, "unused")
+	if err != nil || gw != nil {
+		t.Errorf("ConnectGateway without litellm-proxy = %v, %v; want nil, nil", gw, err)
+	}
+}
+
+func TestConnectGateway_UnreadableEntry(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	if err := os.MkdirAll(filepath.Join(dir, "catalog"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "catalog", litellm.AppName+".yaml"), []byte("enabled: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if gw, err := ConnectGateway(context.Background(), dir, "unused"); err == nil {
+		t.Errorf("ConnectGateway with a broken entry = %v, nil; want an error", gw)
+	}
+}
+
+func TestCheckKeyOpts(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	if err := CheckKeyOpts(dir, KeyOpts{}); err != nil {
+		t.Errorf("CheckKeyOpts without limits = %v, want nil", err)
+	}
+	if err := CheckKeyOpts(dir, KeyOpts{MaxBudget: 5}); !errors.Is(err, ErrNoGateway) {
+		t.Errorf("CheckKeyOpts without litellm-proxy = %v, want ErrNoGateway", err)
+	}
+}
+
+func TestGateway_ProvisionNewKeyRollsBack(t *testing.T) {
+	t.Parallel()
+	gw, cs, keys := stubGateway(t)
+	cs.PrependReactor("create", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("secrets are read-only")
+	})
+	dir := setupGitOps(t)
+	if err := Create(dir, CreateOpts{Name: "doomed"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := gw.ProvisionNewKey(ctx, dir, "doomed", KeyOpts{}); err == nil {
+		t.Fatal("ProvisionNewKey succeeded, want the secret error")
+	}
+	if _, err := Find(dir, "doomed"); err == nil {
+		t.Error("agent still in the gitops repo after the rollback")
+	}
+	if _, err := cs.CoreV1().Namespaces().Get(ctx, "agent-doomed", metav1.GetOptions{}); err == nil {
+		t.Error("namespace created for the key survived the rollback")
+	}
+	if len(keys) != 0 {
+		t.Errorf("keys left on the proxy: %v", keys)
+	}
+}
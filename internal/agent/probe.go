@@ -0,0 +1,372 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/kube"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Probe target kinds reported in ProbeTarget.Kind.
+const (
+	ProbeApp      = "app"
+	ProbeAgent    = "agent"
+	ProbeAPI      = "apiserver"
+	ProbeExternal = "external"
+)
+
+const (
+	// DefaultProbeImage runs the policy probe; it needs sh and nc.
+	DefaultProbeImage = "busybox:1.36"
+	// DefaultProbeTimeout bounds a probe run, including the image pull.
+	DefaultProbeTimeout = 2 * time.Minute
+	// DefaultProbeExternal is the outside host probed when none is given.
+	DefaultProbeExternal = "example.com"
+	// probeConnectTimeout is how long a probe waits for a connection before
+	// counting the target as dropped.
+	probeConnectTimeout = 3
+)
+
+// dataLayerApps are the catalog apps the agent-template chart lets every
+// sandbox reach, as listed in the agent sandboxes guide.
+var dataLayerApps = []string{"litellm-proxy", "qdrant", "postgresql", "valkey"}
+
+// ProbeTarget is one destination the policy probe tries to connect to.
+type ProbeTarget struct {
+	// Name identifies the target, e.g. app:qdrant or agent:other.
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	// Expected is whether the sandbox policy should let the agent connect.
+	Expected bool `json:"expected"`
+}
+
+// Address returns host:port.
+func (t ProbeTarget) Address() string {
+	return t.Host + ":" + strconv.Itoa(t.Port)
+}
+
+// ProbeResult is the outcome of probing one target.
+type ProbeResult struct {
+	ProbeTarget
+	// Allowed reports whether the connection got through the policy. A
+	// refused connection counts, since the packet reached the destination.
+	Allowed bool `json:"allowed"`
+	// Detail is the probe's error output when the connection failed.
+	Detail string `json:"detail,omitempty"`
+}
+
+// Pass reports whether the result matches the expected policy.
+func (r ProbeResult) Pass() bool {
+	return r.Allowed == r.Expected
+}
+
+// PolicyProbe checks an agent's network policy from inside its namespace by
+// running a short-lived pod that tries to connect to a matrix of targets.
+type PolicyProbe struct {
+	Kube  kubernetes.Interface
+	Image string
+	// Timeout bounds the whole probe run, including pulling the image.
+	Timeout time.Duration
+}
+
+// NewPolicyProbe returns a PolicyProbe for the cluster.
+func NewPolicyProbe(clusterName string) (*PolicyProbe, error) {
+	cs, err := kube.ClientForCluster(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return &PolicyProbe{Kube: cs, Image: DefaultProbeImage, Timeout: DefaultProbeTimeout}, nil
+}
+
+// Test probes the agent's targets and returns the results.
+func (p *PolicyProbe) Test(ctx context.Context, gitOpsPath, name, external string) ([]ProbeResult, error) {
+	targets, err := p.Targets(ctx, gitOpsPath, name, external)
+	if err != nil {
+		return nil, err
+	}
+	return p.Run(ctx, name, targets)
+}
+
+// Targets returns what the probe tries from the agent's sandbox:
+//   - each enabled catalog app, expected reachable when it is part of the
+//     data layer or allowed by the agent's egress rules;
+//   - a running pod of another agent, expected unreachable;
+//   - the Kubernetes API, expected unreachable;
+//   - external on port 443, expected reachable only when an FQDN rule allows it.
+func (p *PolicyProbe) Targets(ctx context.Context, gitOpsPath, name, external string) ([]ProbeTarget, error) {
+	rules, err := ListEgress(gitOpsPath, name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := catalog.List(gitOpsPath)
+	if err != nil {
+		return nil, fmt.Errorf("listing catalog: %w", err)
+	}
+
+	var targets []ProbeTarget
+	for _, e := range entries {
+		if !e.Enabled {
+			continue
+		}
+		host, port, ok := p.serviceAddress(ctx, e.Name, e.Namespace)
+		if !ok {
+			continue
+		}
+		targets = append(targets, ProbeTarget{
+			Name:     "app:" + e.Name,
+			Kind:     ProbeApp,
+			Host:     host,
+			Port:     port,
+			Expected: slices.Contains(dataLayerApps, e.Name) || allowsApp(rules, e.Name, port),
+		})
+	}
+
+	others, err := List(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range others {
+		if o.Name == name {
+			continue
+		}
+		if host, port, ok := p.podAddress(ctx, o.Namespace); ok {
+			targets = append(targets, ProbeTarget{Name: "agent:" + o.Name, Kind: ProbeAgent, Host: host, Port: port})
+			break
+		}
+	}
+
+	api, err := p.Kube.CoreV1().Services(metav1.NamespaceDefault).Get(ctx, "kubernetes", metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("getting kubernetes service: %w", err)
+	}
+	targets = append(targets, ProbeTarget{Name: "kubernetes-api", Kind: ProbeAPI, Host: api.Spec.ClusterIP, Port: 443})
+
+	if external == "" {
+		external = DefaultProbeExternal
+	}
+	ext := EgressRule{FQDN: external}
+	if err := ext.validate(); err != nil {
+		return nil, err
+	}
+	targets = append(targets, ProbeTarget{
+		Name:     "fqdn:" + ext.FQDN,
+		Kind:     ProbeExternal,
+		Host:     ext.FQDN,
+		Port:     443,
+		Expected: allowsFQDN(rules, ext.FQDN, 443),
+	})
+	return targets, nil
+}
+
+// serviceAddress returns the ClusterIP and first TCP port of the app's
+// Service, preferring one named after the app.
+func (p *PolicyProbe) serviceAddress(ctx context.Context, app, namespace string) (string, int, bool) {
+	list, err := p.Kube.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", 0, false
+	}
+	services := list.Items
+	sort.SliceStable(services, func(i, j int) bool {
+		if (services[i].Name == app) != (services[j].Name == app) {
+			return services[i].Name == app
+		}
+		return services[i].Name < services[j].Name
+	})
+	for _, svc := range services {
+		if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+			continue
+		}
+		for _, port := range svc.Spec.Ports {
+			if port.Protocol == "" || port.Protocol == corev1.ProtocolTCP {
+				return svc.Spec.ClusterIP, int(port.Port), true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// podAddress returns the IP of a running pod in namespace and its first
+// container port, or 80. A port nothing listens on still works: the refused
+// connection shows that the policy let the packet through.
+func (p *PolicyProbe) podAddress(ctx context.Context, namespace string) (string, int, bool) {
+	pods, err := p.Kube.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", 0, false
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+			continue
+		}
+		port := 80
+		for _, c := range pod.Spec.Containers {
+			if len(c.Ports) > 0 {
+				port = int(c.Ports[0].ContainerPort)
+				break
+			}
+		}
+		return pod.Status.PodIP, port, true
+	}
+	return "", 0, false
+}
+
+func allowsApp(rules []EgressRule, app string, port int) bool {
+	return slices.ContainsFunc(rules, func(r EgressRule) bool {
+		return r.App == app && allowsPort(r, port)
+	})
+}
+
+func allowsFQDN(rules []EgressRule, host string, port int) bool {
+	return slices.ContainsFunc(rules, func(r EgressRule) bool {
+		if r.FQDN == "" || !allowsPort(r, port) {
+			return false
+		}
+		if suffix, ok := strings.CutPrefix(r.FQDN, "*"); ok {
+			return strings.HasSuffix(host, suffix)
+		}
+		return r.FQDN == host
+	})
+}
+
+func allowsPort(r EgressRule, port int) bool {
+	return len(r.Ports) == 0 || slices.Contains(r.Ports, port)
+}
+
+// Run starts the probe pod in the agent namespace, waits for it to finish
+// and returns a result per target. The pod is deleted afterwards.
+func (p *PolicyProbe) Run(ctx context.Context, name string, targets []ProbeTarget) ([]ProbeResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	ns := namespaceFor(name)
+	pod, err := p.Kube.CoreV1().Pods(ns).Create(ctx, probePod(ns, p.Image, targets), metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("creating probe pod: %w", err)
+	}
+	defer func() {
+		// The run context may be done; clean up regardless.
+		_ = p.Kube.CoreV1().Pods(ns).Delete(context.Background(), pod.Name, metav1.DeleteOptions{})
+	}()
+
+	if err := p.waitDone(ctx, ns, pod.Name); err != nil {
+		return nil, err
+	}
+	logs, err := p.Kube.CoreV1().Pods(ns).GetLogs(pod.Name, &corev1.PodLogOptions{}).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading probe output: %w", err)
+	}
+	return parseProbeOutput(string(logs), targets)
+}
+
+func (p *PolicyProbe) waitDone(ctx context.Context, ns, pod string) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		got, err := p.Kube.CoreV1().Pods(ns).Get(ctx, pod, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("getting probe pod: %w", err)
+		}
+		switch got.Status.Phase {
+		case corev1.PodSucceeded:
+			return nil
+		case corev1.PodFailed:
+			return fmt.Errorf("probe pod failed: %s", got.Status.Message)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for probe pod %s/%s: %w", ns, pod, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// probePod returns a pod that tries each target in turn. It fits the
+// sandbox's quota and carries no labels of its own, so the namespace's
+// policy applies to it as to any agent workload.
+func probePod(ns, image string, targets []ProbeTarget) *corev1.Pod {
+	no, yes := false, true
+	user := int64(65534)
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "sikifanso-policy-probe-", Namespace: ns},
+		Spec: corev1.PodSpec{
+			RestartPolicy:                corev1.RestartPolicyNever,
+			AutomountServiceAccountToken: &no,
+			Containers: []corev1.Container{{
+				Name:    "probe",
+				Image:   image,
+				Command: []string{"sh", "-c", probeScript(targets)},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("10m"),
+						corev1.ResourceMemory: resource.MustParse("16Mi"),
+					},
+					Limits: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("100m"),
+						corev1.ResourceMemory: resource.MustParse("32Mi"),
+					},
+				},
+				SecurityContext: &corev1.SecurityContext{
+					AllowPrivilegeEscalation: &no,
+					RunAsNonRoot:             &yes,
+					RunAsUser:                &user,
+					Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				},
+			}},
+		},
+	}
+}
+
+// probeScript prints one "PROBE <index> ok" or "PROBE <index> fail <error>"
+// line per target. Hosts are IPs or validated DNS names, so they need no
+// quoting.
+func probeScript(targets []ProbeTarget) string {
+	var sb strings.Builder
+	for i, t := range targets {
+		fmt.Fprintf(&sb, "if out=$(nc -z -w %d %s %d 2>&1); then echo \"PROBE %d ok\"; else echo \"PROBE %d fail $out\"; fi\n",
+			probeConnectTimeout, t.Host, t.Port, i, i)
+	}
+	return sb.String()
+}
+
+// parseProbeOutput turns the probe's log into results. A refused
+// connection reached its destination, so it counts as allowed; timeouts and
+// resolution failures count as denied.
+func parseProbeOutput(out string, targets []ProbeTarget) ([]ProbeResult, error) {
+	results := make([]ProbeResult, len(targets))
+	seen := make([]bool, len(targets))
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 4)
+		if len(fields) < 3 || fields[0] != "PROBE" {
+			continue
+		}
+		i, err := strconv.Atoi(fields[1])
+		if err != nil || i < 0 || i >= len(targets) {
+			continue
+		}
+		r := ProbeResult{ProbeTarget: targets[i], Allowed: fields[2] == "ok"}
+		if !r.Allowed && len(fields) == 4 {
+			r.Detail = strings.TrimSpace(fields[3])
+			r.Allowed = strings.Contains(strings.ToLower(r.Detail), "refused")
+		}
+		results[i], seen[i] = r, true
+	}
+	for i, ok := range seen {
+		if !ok {
+			return nil, fmt.Errorf("probe output has no result for %s", targets[i].Name)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func fakeService(namespace, name, clusterIP string, port int32) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       corev1.ServiceSpec{ClusterIP: clusterIP, Ports: []corev1.ServicePort{{Port: port}}},
+	}
+}
+
+func TestPolicyProbe_Targets(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	catalogDir := filepath.Join(dir, "catalog")
+	if err := os.MkdirAll(catalogDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, app := range []struct{ name, enabled string }{
+		{"litellm-proxy", "true"},
+		{"temporal", "true"},
+		{"presidio", "true"},
+		{"ollama", "false"},
+	} {
+		content := "name: " + app.name + "\nnamespace: " + app.name + "\nenabled: " + app.enabled + "\n"
+		if err := os.WriteFile(filepath.Join(catalogDir, app.name+".yaml"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"bot", "other"} {
+		if err := Create(dir, CreateOpts{Name: name}); err != nil {
+			t.Fatalf("Create %s: %v", name, err)
+		}
+	}
+	if _, err := AllowEgress(dir, "bot", EgressRule{App: "temporal", Ports: []int{7233}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AllowEgress(dir, "bot", EgressRule{FQDN: "*.example.com", Ports: []int{443}}); err != nil {
+		t.Fatal(err)
+	}
+
+	cs := fake.NewClientset(
+		fakeService("default", "kubernetes", "10.43.0.1", 443),
+		fakeService("litellm-proxy", "litellm-proxy", "10.43.0.10", 4000),
+		fakeService("temporal", "temporal-headless", corev1.ClusterIPNone, 7233),
+		fakeService("temporal", "temporal-frontend", "10.43.0.20", 7233),
+		fakeService("presidio", "presidio-analyzer", "10.43.0.30", 3000),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "agent-other"},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.42.0.50"},
+		},
+	)
+	targets, err := (&PolicyProbe{Kube: cs}).Targets(context.Background(), dir, "bot", "api.example.com")
+	if err != nil {
+		t.Fatalf("Targets: %v", err)
+	}
+
+	want := []ProbeTarget{
+		{Name: "app:litellm-proxy", Kind: ProbeApp, Host: "10.43.0.10", Port: 4000, Expected: true},
+		{Name: "app:presidio", Kind: ProbeApp, Host: "10.43.0.30", Port: 3000},
+		{Name: "app:temporal", Kind: ProbeApp, Host: "10.43.0.20", Port: 7233, Expected: true},
+		{Name: "agent:other", Kind: ProbeAgent, Host: "10.42.0.50", Port: 80},
+		{Name: "kubernetes-api", Kind: ProbeAPI, Host: "10.43.0.1", Port: 443},
+		{Name: "fqdn:api.example.com", Kind: ProbeExternal, Host: "api.example.com", Port: 443, Expected: true},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("targets = %+v, want %+v", targets, want)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Errorf("target[%d] = %+v, want %+v", i, targets[i], want[i])
+		}
+	}
+}
+
+func TestParseProbeOutput(t *testing.T) {
+	t.Parallel()
+	targets := []ProbeTarget{
+		{Name: "app:qdrant", Expected: true},
+		{Name: "app:ollama", Expected: true},
+		{Name: "agent:other"},
+		{Name: "kubernetes-api"},
+	}
+	out := strings.Join([]string{
+		"PROBE 0 ok",
+		"PROBE 1 fail nc: 10.43.0.5 (10.43.0.5:11434): Connection refused",
+		"PROBE 2 fail nc: timed out",
+		"PROBE 3 ok",
+	}, "\n")
+
+	results, err := parseProbeOutput(out, targets)
+	if err != nil {
+		t.Fatalf("parseProbeOutput: %v", err)
+	}
+	wantAllowed := []bool{true, true, false, true}
+	wantPass := []bool{true, true, true, false}
+	for i, r := range results {
+		if r.Allowed != wantAllowed[i] || r.Pass() != wantPass[i] {
+			t.Errorf("%s: allowed=%v pass=%v, want allowed=%v pass=%v", r.Name, r.Allowed, r.Pass(), wantAllowed[i], wantPass[i])
+		}
+	}
+	if results[2].Detail != "nc: timed out" {
+		t.Errorf("detail = %q", results[2].Detail)
+	}
+
+	if _, err := parseProbeOutput("PROBE 0 ok\n", targets); err == nil {
+		t.Error("expected an error for missing results")
+	}
+}
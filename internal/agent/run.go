@@ -0,0 +1,367 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/kube"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Keep policies for RunOpts.Keep: whether the Job and its pod are left in
+// the agent namespace after the run.
+const (
+	KeepNever     = "never"
+	KeepAlways    = "always"
+	KeepOnFailure = "on-failure"
+)
+
+// Default limits of a run's container. Requests are kept small so a run
+// fits next to the agent's workload in the default quota.
+const (
+	DefaultRunCPU    = "500m"
+	DefaultRunMemory = "512Mi"
+	runCPURequest    = "50m"
+	runMemoryRequest = "64Mi"
+)
+
+// runLabel marks Jobs created by Run.
+const runLabel = "sikifanso.io/run"
+
+// ErrQuotaExceeded is returned when the agent namespace's ResourceQuota
+// rejects a run's pod.
+var ErrQuotaExceeded = errors.New("agent quota exceeded")
+
+// RunOpts configures a one-off run in an agent sandbox.
+type RunOpts struct {
+	Name string
+	// Image defaults to the agent's workload image from its template.
+	Image   string
+	Command []string
+	// Keep is KeepNever (the default), KeepAlways or KeepOnFailure.
+	Keep string
+	// CPU and Memory are the container's limits.
+	CPU    string
+	Memory string
+}
+
+// RunResult is the outcome of a run.
+type RunResult struct {
+	Job      string `json:"job"`
+	ExitCode int    `json:"exitCode"`
+	// Kept reports whether the Job was left in the namespace.
+	Kept bool `json:"kept"`
+}
+
+// Runner runs one-off Jobs in agent sandboxes.
+type Runner struct {
+	Kube       kubernetes.Interface
+	GitOpsPath string
+	// PollInterval is how often the Job's pod is checked.
+	PollInterval time.Duration
+}
+
+// NewRunner returns a Runner for the cluster.
+func NewRunner(clusterName, gitOpsPath string) (*Runner, error) {
+	cs, err := kube.ClientForCluster(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{Kube: cs, GitOpsPath: gitOpsPath, PollInterval: time.Second}, nil
+}
+
+// Run creates a Job in the agent namespace that runs opts.Command with the
+// agent's service account and workload env, copies its logs to logs, and
+// returns the container's exit code. The Job is deleted afterwards unless
+// opts.Keep says otherwise. Frozen agents cannot run anything.
+func (r *Runner) Run(ctx context.Context, opts RunOpts, logs io.Writer) (*RunResult, error) {
+	keep := firstSet(opts.Keep, KeepNever)
+	if keep != KeepNever && keep != KeepAlways && keep != KeepOnFailure {
+		return nil, fmt.Errorf("invalid keep %q: must be %s, %s or %s", keep, KeepNever, KeepAlways, KeepOnFailure)
+	}
+	if err := validateQuotas(runCPURequest, firstSet(opts.CPU, DefaultRunCPU), runMemoryRequest, firstSet(opts.Memory, DefaultRunMemory)); err != nil {
+		return nil, err
+	}
+	info, err := Find(r.GitOpsPath, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	workload, err := readWorkload(r.GitOpsPath, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	place, err := readPlacement(r.GitOpsPath, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	image := firstSet(opts.Image, workload.Image)
+	if image == "" {
+		return nil, fmt.Errorf("agent %s has no workload image from a template; pass an image", opts.Name)
+	}
+	if rec, err := (&Freezer{Kube: r.Kube}).Frozen(ctx, opts.Name); err != nil {
+		return nil, err
+	} else if rec != nil {
+		return nil, fmt.Errorf("agent %s is frozen since %s by %s; unfreeze it first", opts.Name, rec.At, rec.By)
+	}
+
+	job := runJob(info.Namespace, r.serviceAccount(ctx, info.Namespace), image, opts, workload.Env, place)
+	jobs := r.Kube.BatchV1().Jobs(info.Namespace)
+	if job, err = jobs.Create(ctx, job, metav1.CreateOptions{}); err != nil {
+		return nil, fmt.Errorf("creating job: %w", err)
+	}
+
+	res := &RunResult{Job: job.Name, ExitCode: -1}
+	defer func() {
+		res.Kept = keep == KeepAlways || (keep == KeepOnFailure && res.ExitCode != 0)
+		if !res.Kept {
+			// The run context may be cancelled; clean up regardless.
+			background := metav1.DeletePropagationBackground
+			_ = jobs.Delete(context.Background(), job.Name, metav1.DeleteOptions{PropagationPolicy: &background})
+		}
+	}()
+
+	pod, err := r.waitStarted(ctx, info.Namespace, job.Name)
+	if err != nil {
+		return res, err
+	}
+	if err := r.streamLogs(ctx, info.Namespace, pod, logs); err != nil {
+		return res, err
+	}
+	if res.ExitCode, err = r.waitExit(ctx, info.Namespace, pod); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// readWorkload returns the agent's agent.workload values, which are empty
+// for agents created without a template.
+func readWorkload(gitOpsPath, name string) (Workload, error) {
+	var w Workload
+	if err := readAgentKey(gitOpsPath, name, "workload", &w); err != nil {
+		return Workload{}, err
+	}
+	return w, nil
+}
+
+// readPlacement returns the agent's node pool placement, which is empty
+// for agents created on a cluster without a sandbox pool.
+func readPlacement(gitOpsPath, name string) (placement, error) {
+	var p placement
+	if err := readAgentKey(gitOpsPath, name, "", &p); err != nil {
+		return placement{}, err
+	}
+	return p, nil
+}
+
+// readAgentKey decodes the agent.<key> value of the agent's values file
+// into dst, or the whole agent section when key is empty. dst is left
+// unchanged when the key is not set.
+func readAgentKey(gitOpsPath, name, key string, dst interface{}) error {
+	doc, err := readAgentValues(gitOpsPath, name)
+	if err != nil {
+		return err
+	}
+	agentDoc, _ := doc["agent"].(map[string]interface{})
+	var raw interface{} = agentDoc
+	if key != "" {
+		var ok bool
+		if raw, ok = agentDoc[key]; !ok {
+			return nil
+		}
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("encoding agent values: %w", err)
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("parsing agent values of %s: %w", name, err)
+	}
+	return nil
+}
+
+// serviceAccount returns the ServiceAccount the agent-template chart created
+// in ns, or "default" when there is none.
+func (r *Runner) serviceAccount(ctx context.Context, ns string) string {
+	list, err := r.Kube.CoreV1().ServiceAccounts(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "default"
+	}
+	for _, sa := range list.Items {
+		if sa.Name != "default" {
+			return sa.Name
+		}
+	}
+	return "default"
+}
+
+// runJob builds the Job for opts. It never retries: a run's exit code is
+// the result. The pod is placed like the agent's own workload.
+func runJob(ns, serviceAccount, image string, opts RunOpts, env []EnvVar, place placement) *batchv1.Job {
+	backoff := int32(0)
+	labels := map[string]string{
+		"app.kubernetes.io/managed-by": "sikifanso",
+		runLabel:                       opts.Name,
+	}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "run-" + rand.String(5),
+			Namespace: ns,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoff,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: serviceAccount,
+					Affinity:           place.Affinity,
+					Tolerations:        place.Tolerations,
+					Containers: []corev1.Container{{
+						Name:    "run",
+						Image:   image,
+						Command: opts.Command,
+						Env:     podEnv(env),
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse(runCPURequest),
+								corev1.ResourceMemory: resource.MustParse(runMemoryRequest),
+							},
+							Limits: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse(firstSet(opts.CPU, DefaultRunCPU)),
+								corev1.ResourceMemory: resource.MustParse(firstSet(opts.Memory, DefaultRunMemory)),
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// podEnv converts workload env to container env, resolving secret
+// references against the agent namespace.
+func podEnv(env []EnvVar) []corev1.EnvVar {
+	out := make([]corev1.EnvVar, 0, len(env))
+	for _, e := range env {
+		if e.Secret == "" {
+			out = append(out, corev1.EnvVar{Name: e.Name, Value: e.Value})
+			continue
+		}
+		out = append(out, corev1.EnvVar{Name: e.Name, ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: e.Secret},
+				Key:                  e.Key,
+			},
+		}})
+	}
+	return out
+}
+
+// waitStarted waits for the Job's pod to run or finish and returns its name.
+// It fails early when the quota rejects the pod or its image cannot be
+// pulled, instead of waiting for ctx to expire.
+func (r *Runner) waitStarted(ctx context.Context, ns, job string) (string, error) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		pods, err := r.Kube.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: "job-name=" + job})
+		if err != nil {
+			return "", fmt.Errorf("listing pods of job %s: %w", job, err)
+		}
+		if len(pods.Items) == 0 {
+			if err := r.quotaRejection(ctx, ns, job); err != nil {
+				return "", err
+			}
+		}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase != corev1.PodPending {
+				return pod.Name, nil
+			}
+			if err := pendingError(&pod); err != nil {
+				return "", err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("waiting for job %s to start: %w", job, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// quotaRejection returns ErrQuotaExceeded when the Job controller reported
+// that the namespace quota forbids its pod.
+func (r *Runner) quotaRejection(ctx context.Context, ns, job string) error {
+	events, err := r.Kube.CoreV1().Events(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil // best-effort; the wait continues until ctx expires
+	}
+	for _, ev := range events.Items {
+		if ev.InvolvedObject.Kind == "Job" && ev.InvolvedObject.Name == job &&
+			ev.Reason == "FailedCreate" && strings.Contains(ev.Message, "exceeded quota") {
+			return fmt.Errorf("%w in %s: %s", ErrQuotaExceeded, ns, ev.Message)
+		}
+	}
+	return nil
+}
+
+// pendingError reports a pending pod that will not start on its own.
+func pendingError(pod *corev1.Pod) error {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if w := cs.State.Waiting; w != nil {
+			switch w.Reason {
+			case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerConfigError":
+				return fmt.Errorf("run pod %s cannot start: %s: %s", pod.Name, w.Reason, w.Message)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Runner) streamLogs(ctx context.Context, ns, pod string, w io.Writer) error {
+	stream, err := r.Kube.CoreV1().Pods(ns).GetLogs(pod, &corev1.PodLogOptions{Follow: true}).Stream(ctx)
+	if err != nil {
+		return fmt.Errorf("streaming logs of %s: %w", pod, err)
+	}
+	defer func() { _ = stream.Close() }()
+	if _, err := io.Copy(w, stream); err != nil {
+		return fmt.Errorf("streaming logs of %s: %w", pod, err)
+	}
+	return nil
+}
+
+// waitExit waits for the run container to terminate and returns its exit
+// code.
+func (r *Runner) waitExit(ctx context.Context, ns, pod string) (int, error) {
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+	for {
+		got, err := r.Kube.CoreV1().Pods(ns).Get(ctx, pod, metav1.GetOptions{})
+		if err != nil {
+			return -1, fmt.Errorf("getting run pod: %w", err)
+		}
+		for _, cs := range got.Status.ContainerStatuses {
+			if t := cs.State.Terminated; t != nil {
+				return int(t.ExitCode), nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return -1, fmt.Errorf("waiting for run pod %s: %w", pod, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
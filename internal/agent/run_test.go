@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeRunCluster returns a clientset for agent "crew" whose Job controller is
+// simulated by onJob, called with each created Job.
+func fakeRunCluster(t *testing.T, onJob func(cs *fake.Clientset, job *batchv1.Job)) *fake.Clientset {
+	t.Helper()
+	cs := fake.NewClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "agent-crew"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "agent-crew"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "crew", Namespace: "agent-crew"}},
+	)
+	cs.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		onJob(cs, action.(k8stesting.CreateAction).GetObject().(*batchv1.Job))
+		return false, nil, nil
+	})
+	return cs
+}
+
+func finishedPod(job *batchv1.Job, exitCode int32) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      job.Name + "-x1",
+			Namespace: job.Namespace,
+			Labels:    map[string]string{"job-name": job.Name},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodFailed,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "run",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}},
+			}},
+		},
+	}
+}
+
+func TestRunner_Run(t *testing.T) {
+	t.Parallel()
+	dir := setupTemplateCatalog(t)
+	if err := Create(dir, CreateOpts{Name: "crew", Template: "crewai", NodePool: "sandbox"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var created *batchv1.Job
+	cs := fakeRunCluster(t, func(cs *fake.Clientset, job *batchv1.Job) {
+		created = job
+		_ = cs.Tracker().Add(finishedPod(job, 3))
+	})
+	r := &Runner{Kube: cs, GitOpsPath: dir, PollInterval: 10 * time.Millisecond}
+
+	var logs bytes.Buffer
+	res, err := r.Run(context.Background(), RunOpts{Name: "crew", Command: []string{"python", "task.py"}, Keep: KeepOnFailure}, &logs)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.ExitCode != 3 || !res.Kept {
+		t.Errorf("result = %+v, want exit code 3 and the job kept", res)
+	}
+	if logs.Len() == 0 {
+		t.Error("no logs streamed")
+	}
+
+	pod := created.Spec.Template.Spec
+	if pod.ServiceAccountName != "crew" {
+		t.Errorf("service account = %q, want crew", pod.ServiceAccountName)
+	}
+	if len(pod.Tolerations) != 1 || pod.Tolerations[0].Value != "sandbox" || pod.Affinity == nil {
+		t.Errorf("run pod is not placed on the sandbox pool: tolerations %+v, affinity %+v", pod.Tolerations, pod.Affinity)
+	}
+	c := pod.Containers[0]
+	if c.Image != "python:3.12-slim" {
+		t.Errorf("image = %q, want the template's workload image", c.Image)
+	}
+	env := map[string]corev1.EnvVar{}
+	for _, e := range c.Env {
+		env[e.Name] = e
+	}
+	if ref := env["OPENAI_API_KEY"].ValueFrom; ref == nil || ref.SecretKeyRef.Name != KeySecretName {
+		t.Errorf("OPENAI_API_KEY = %+v, want the LLM key secret", env["OPENAI_API_KEY"])
+	}
+	if c.Resources.Limits.Cpu().String() != DefaultRunCPU {
+		t.Errorf("cpu limit = %s, want %s", c.Resources.Limits.Cpu(), DefaultRunCPU)
+	}
+
+	if _, err := cs.BatchV1().Jobs("agent-crew").Get(context.Background(), res.Job, metav1.GetOptions{}); err != nil {
+		t.Errorf("failed job not kept: %v", err)
+	}
+}
+
+func TestRunner_RunQuotaExceeded(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	if err := Create(dir, CreateOpts{Name: "crew"}); err != nil {
+		t.Fatal(err)
+	}
+	cs := fakeRunCluster(t, func(cs *fake.Clientset, job *batchv1.Job) {
+		_ = cs.Tracker().Add(&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: job.Name + ".1", Namespace: job.Namespace},
+			InvolvedObject: corev1.ObjectReference{Kind: "Job", Name: job.Name},
+			Reason:         "FailedCreate",
+			Message:        `Error creating: pods "x" is forbidden: exceeded quota: agent-quota, requested: pods=1, used: pods=10, limited: pods=10`,
+		})
+	})
+	r := &Runner{Kube: cs, GitOpsPath: dir, PollInterval: 10 * time.Millisecond}
+
+	res, err := r.Run(context.Background(), RunOpts{Name: "crew", Image: "busybox", Command: []string{"true"}}, &bytes.Buffer{})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("error = %v, want ErrQuotaExceeded", err)
+	}
+	if res.Kept {
+		t.Error("job kept with keep=never")
+	}
+	jobs, _ := cs.BatchV1().Jobs("agent-crew").List(context.Background(), metav1.ListOptions{})
+	if len(jobs.Items) != 0 {
+		t.Errorf("job not cleaned up: %d left", len(jobs.Items))
+	}
+}
+
+func TestRunner_RunNeedsImage(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	if err := Create(dir, CreateOpts{Name: "crew"}); err != nil {
+		t.Fatal(err)
+	}
+	r := &Runner{Kube: fake.NewClientset(), GitOpsPath: dir}
+	if _, err := r.Run(context.Background(), RunOpts{Name: "crew", Command: []string{"true"}}, &bytes.Buffer{}); err == nil {
+		t.Error("expected an error for an agent without a workload image")
+	}
+}
@@ -0,0 +1,322 @@
+package agent
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/litellm"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed templates/*.yaml
+var builtinTemplates embed.FS
+
+// Template sources reported in Template.Source.
+const (
+	TemplateBuiltin = "built-in"
+	TemplateGitOps  = "gitops"
+)
+
+// Template is a starting point for an agent sandbox. It is a YAML file
+// rendered with text/template against TemplateData, so it can wire its
+// workload to the in-cluster addresses of enabled catalog apps. Files in
+// templates/ of the gitops repo override built-in templates of the same name.
+type Template struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Requires lists catalog apps that must be enabled to use the template.
+	Requires []string `json:"requires,omitempty"`
+	// Uses lists catalog apps the template wires in when they are enabled.
+	Uses []string `json:"uses,omitempty"`
+	// Workload is rendered by the agent-template chart under agent.workload.
+	Workload Workload `json:"workload"`
+	// Egress is added to the agent's allowlist, along with every required
+	// or used app that is enabled.
+	Egress []EgressRule `json:"egress,omitempty"`
+	// Values are extra chart values merged into the agent's values file.
+	// Quotas under values.agent replace the defaults but not explicit flags.
+	Values map[string]interface{} `json:"values,omitempty"`
+	// Source is TemplateBuiltin or TemplateGitOps.
+	Source string `json:"source"`
+}
+
+// Workload is the long-running container a template adds to the sandbox.
+type Workload struct {
+	Image   string   `json:"image"`
+	Command []string `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	Port    int      `json:"port,omitempty"`
+	Env     []EnvVar `json:"env,omitempty"`
+}
+
+// EnvVar is a workload environment variable. It holds either a literal
+// Value or a reference to Key of Secret in the agent namespace.
+type EnvVar struct {
+	Name   string `json:"name"`
+	Value  string `json:"value,omitempty"`
+	Secret string `json:"secret,omitempty"`
+	Key    string `json:"key,omitempty"`
+}
+
+// TemplateData is what templates are rendered with. Service addresses are
+// empty when their catalog app is not enabled.
+type TemplateData struct {
+	Name      string
+	Namespace string
+	// LiteLLM is the LiteLLM Proxy base URL.
+	LiteLLM string
+	// Qdrant is the Qdrant HTTP API URL.
+	Qdrant string
+	// Langfuse is the Langfuse web URL, used as LANGFUSE_HOST.
+	Langfuse string
+}
+
+// templateServices are the catalog apps templates can address, with the
+// service and port their charts expose.
+var templateServices = map[string]struct {
+	service string
+	port    int
+}{
+	litellm.AppName: {litellm.ServiceName, litellm.ServicePort},
+	"qdrant":        {"qdrant", 6333},
+	"langfuse":      {"langfuse-web", 3000},
+}
+
+// reservedValues are agent.* keys owned by sikifanso that a template's
+// values may not set.
+var reservedValues = []string{"name", "workload", "egress"}
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TemplatesDir returns the path to the agent templates directory within
+// gitOpsPath.
+func TemplatesDir(gitOpsPath string) string {
+	return filepath.Join(gitOpsPath, "templates")
+}
+
+// ListTemplates returns the built-in and gitops templates sorted by name.
+// Each is rendered for a placeholder agent with every service address set,
+// so a template that does not render or validate is reported here.
+func ListTemplates(gitOpsPath string) ([]Template, error) {
+	sources, err := templateSources(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	data := TemplateData{Name: "example", Namespace: namespaceFor("example")}
+	for app, svc := range templateServices {
+		setServiceURL(&data, app, fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", svc.service, app, svc.port))
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	templates := make([]Template, 0, len(names))
+	for _, name := range names {
+		t, err := renderTemplate(sources[name], data)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+	return templates, nil
+}
+
+// RenderTemplate renders the named template for agent name against the
+// cluster's catalog. It fails when a required app is not enabled.
+func RenderTemplate(gitOpsPath, templateName, name string) (*Template, error) {
+	sources, err := templateSources(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	src, ok := sources[templateName]
+	if !ok {
+		names := make([]string, 0, len(sources))
+		for n := range sources {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("template %q not found; available: %s", templateName, strings.Join(names, ", "))
+	}
+
+	data := TemplateData{Name: name, Namespace: namespaceFor(name)}
+	for app, svc := range templateServices {
+		if e, err := catalog.Find(gitOpsPath, app); err == nil && e.Enabled {
+			setServiceURL(&data, app, fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", svc.service, e.Namespace, svc.port))
+		}
+	}
+	t, err := renderTemplate(src, data)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range t.Requires {
+		e, err := catalog.Find(gitOpsPath, app)
+		if err != nil {
+			return nil, fmt.Errorf("template %s requires %s: %w", t.Name, app, err)
+		}
+		if !e.Enabled {
+			return nil, fmt.Errorf("template %s requires catalog app %s; enable it first: sikifanso app enable %s", t.Name, app, app)
+		}
+	}
+	return t, nil
+}
+
+// appEgress returns an egress rule for each required or used app that is
+// enabled, so the workload can reach the services it is wired to.
+func (t *Template) appEgress(gitOpsPath string) []EgressRule {
+	var rules []EgressRule
+	for _, app := range slices.Concat(t.Requires, t.Uses) {
+		e, err := catalog.Find(gitOpsPath, app)
+		if err != nil || !e.Enabled {
+			continue
+		}
+		rules = append(rules, EgressRule{App: app, Namespace: e.Namespace})
+	}
+	return rules
+}
+
+// templateSource is an unrendered template file.
+type templateSource struct {
+	file   string
+	body   []byte
+	source string
+}
+
+// templateSources reads the built-in templates, then those in the gitops
+// repo, keyed by file name without extension.
+func templateSources(gitOpsPath string) (map[string]templateSource, error) {
+	sources := map[string]templateSource{}
+	builtins, err := builtinTemplates.ReadDir("templates")
+	if err != nil {
+		return nil, fmt.Errorf("reading built-in templates: %w", err)
+	}
+	for _, f := range builtins {
+		body, err := builtinTemplates.ReadFile("templates/" + f.Name())
+		if err != nil {
+			return nil, fmt.Errorf("reading built-in template %s: %w", f.Name(), err)
+		}
+		sources[strings.TrimSuffix(f.Name(), ".yaml")] = templateSource{file: f.Name(), body: body, source: TemplateBuiltin}
+	}
+
+	dir := TemplatesDir(gitOpsPath)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return sources, nil
+		}
+		return nil, fmt.Errorf("reading templates directory: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".yaml") {
+			continue
+		}
+		body, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading template file %s: %w", e.Name(), err)
+		}
+		sources[strings.TrimSuffix(e.Name(), ".yaml")] = templateSource{file: e.Name(), body: body, source: TemplateGitOps}
+	}
+	return sources, nil
+}
+
+// renderTemplate executes src against data and validates the result.
+func renderTemplate(src templateSource, data TemplateData) (*Template, error) {
+	tmpl, err := template.New(src.file).Option("missingkey=error").Parse(string(src.body))
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", src.file, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("rendering template %s: %w", src.file, err)
+	}
+
+	var t Template
+	if err := yaml.UnmarshalStrict(buf.Bytes(), &t); err != nil {
+		return nil, fmt.Errorf("parsing rendered template %s: %w", src.file, err)
+	}
+	t.Source = src.source
+	if want := strings.TrimSuffix(src.file, ".yaml"); t.Name != want {
+		return nil, fmt.Errorf("template %s: name %q must match the file name", src.file, t.Name)
+	}
+	if err := t.validate(); err != nil {
+		return nil, fmt.Errorf("template %s: %w", t.Name, err)
+	}
+	return &t, nil
+}
+
+// validate checks a rendered template before anything is written from it.
+func (t *Template) validate() error {
+	if err := validateName(t.Name); err != nil {
+		return err
+	}
+	w := t.Workload
+	if w.Image == "" {
+		return fmt.Errorf("workload.image is required")
+	}
+	if w.Port < 0 || w.Port > 65535 {
+		return fmt.Errorf("invalid workload.port %d: must be 1-65535", w.Port)
+	}
+	if err := validateEnv(w.Env); err != nil {
+		return err
+	}
+	for i := range t.Egress {
+		if t.Egress[i].App != "" {
+			return fmt.Errorf("egress to app %s: list it under requires or uses instead", t.Egress[i].App)
+		}
+		if err := t.Egress[i].validate(); err != nil {
+			return err
+		}
+	}
+	if agentDoc, ok := t.Values["agent"].(map[string]interface{}); ok {
+		for _, key := range reservedValues {
+			if _, set := agentDoc[key]; set {
+				return fmt.Errorf("values.agent.%s is managed by sikifanso and cannot be set", key)
+			}
+		}
+	} else if _, set := t.Values["agent"]; set {
+		return fmt.Errorf("values.agent must be a mapping")
+	}
+	return nil
+}
+
+func validateEnv(env []EnvVar) error {
+	seen := map[string]bool{}
+	for _, e := range env {
+		if !envNameRe.MatchString(e.Name) {
+			return fmt.Errorf("invalid env name %q", e.Name)
+		}
+		if seen[e.Name] {
+			return fmt.Errorf("env %s is set twice", e.Name)
+		}
+		seen[e.Name] = true
+		if (e.Secret == "") != (e.Key == "") {
+			return fmt.Errorf("env %s: secret and key must be set together", e.Name)
+		}
+		if e.Secret != "" && e.Value != "" {
+			return fmt.Errorf("env %s: set either value or secret, not both", e.Name)
+		}
+	}
+	return nil
+}
+
+func setServiceURL(data *TemplateData, app, url string) {
+	switch app {
+	case litellm.AppName:
+		data.LiteLLM = url
+	case "qdrant":
+		data.Qdrant = url
+	case "langfuse":
+		data.Langfuse = url
+	}
+}
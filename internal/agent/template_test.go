@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+// setupTemplateCatalog writes a catalog with litellm-proxy enabled and
+// qdrant and langfuse disabled.
+func setupTemplateCatalog(t *testing.T) string {
+	t.Helper()
+	dir := setupGitOps(t)
+	catalogDir := filepath.Join(dir, "catalog")
+	if err := os.MkdirAll(catalogDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, app := range []struct{ name, ns, enabled string }{
+		{"litellm-proxy", "gateway", "true"},
+		{"qdrant", "rag", "false"},
+		{"langfuse", "observability", "false"},
+	} {
+		content := "name: " + app.name + "\nnamespace: " + app.ns + "\nenabled: " + app.enabled + "\n"
+		if err := os.WriteFile(filepath.Join(catalogDir, app.name+".yaml"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(TemplatesDir(dir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(TemplatesDir(dir), name+".yaml"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListTemplates_BuiltinsAndGitOpsOverride(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	writeTemplate(t, dir, "langgraph", "name: langgraph\ndescription: team build\nworkload:\n  image: registry.local/langgraph:dev\n")
+	writeTemplate(t, dir, "worker", "name: worker\ndescription: plain worker\nworkload:\n  image: busybox\n")
+
+	templates, err := ListTemplates(dir)
+	if err != nil {
+		t.Fatalf("ListTemplates: %v", err)
+	}
+	sources := map[string]string{}
+	for _, tmpl := range templates {
+		sources[tmpl.Name] = tmpl.Source
+	}
+	want := map[string]string{
+		"crewai":    TemplateBuiltin,
+		"langgraph": TemplateGitOps,
+		"rag":       TemplateBuiltin,
+		"worker":    TemplateGitOps,
+	}
+	for name, source := range want {
+		if sources[name] != source {
+			t.Errorf("template %s source = %q, want %q", name, sources[name], source)
+		}
+	}
+}
+
+func TestCreate_FromTemplate(t *testing.T) {
+	t.Parallel()
+	dir := setupTemplateCatalog(t)
+
+	if err := Create(dir, CreateOpts{Name: "crew", Template: "crewai", MemoryRequest: "512Mi"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	info, err := Find(dir, "crew")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Template != "crewai" {
+		t.Errorf("Template = %q, want crewai", info.Template)
+	}
+	// The template's memory limit replaces the default; the explicit request
+	// is kept.
+	if info.MemoryLimit != "2Gi" || info.MemoryRequest != "512Mi" {
+		t.Errorf("memory = %s/%s, want 512Mi/2Gi", info.MemoryRequest, info.MemoryLimit)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, agentValuesPath("crew")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Agent struct {
+			Workload Workload     `json:"workload"`
+			Egress   []EgressRule `json:"egress"`
+		} `json:"agent"`
+	}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]EnvVar{}
+	for _, e := range v.Agent.Workload.Env {
+		env[e.Name] = e
+	}
+	if got := env["OPENAI_API_BASE"].Value; got != "http://litellm-proxy.gateway.svc.cluster.local:4000" {
+		t.Errorf("OPENAI_API_BASE = %q", got)
+	}
+	if e := env["OPENAI_API_KEY"]; e.Secret != KeySecretName || e.Key != "LITELLM_API_KEY" {
+		t.Errorf("OPENAI_API_KEY = %+v, want a reference to the LLM key secret", e)
+	}
+	if _, ok := env["LANGFUSE_HOST"]; ok {
+		t.Error("LANGFUSE_HOST set although langfuse is disabled")
+	}
+
+	var targets []string
+	for _, r := range v.Agent.Egress {
+		targets = append(targets, r.Target())
+	}
+	if got := strings.Join(targets, ","); got != "pypi.org,files.pythonhosted.org,app:litellm-proxy" {
+		t.Errorf("egress = %s", got)
+	}
+}
+
+func TestCreate_FromTemplateImageOverride(t *testing.T) {
+	t.Parallel()
+	dir := setupTemplateCatalog(t)
+
+	if err := Create(dir, CreateOpts{Name: "graph", Template: "langgraph", Image: "registry.local/graph:1"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, agentValuesPath("graph")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	if !strings.Contains(content, "image: registry.local/graph:1") {
+		t.Errorf("values missing image override:\n%s", content)
+	}
+	if strings.Contains(content, "command:") {
+		t.Errorf("template command kept with an image override:\n%s", content)
+	}
+
+	if err := Create(dir, CreateOpts{Name: "bare", Image: "busybox"}); err == nil {
+		t.Error("expected an error for an image without a template")
+	}
+}
+
+func TestCreate_TemplateValidation(t *testing.T) {
+	t.Parallel()
+	dir := setupTemplateCatalog(t)
+	writeTemplate(t, dir, "half-secret", "name: half-secret\nworkload:\n  image: busybox\n  env:\n    - name: TOKEN\n      secret: creds\n")
+	writeTemplate(t, dir, "takeover", "name: takeover\nworkload:\n  image: busybox\nvalues:\n  agent:\n    egress: []\n")
+	writeTemplate(t, dir, "typo", "name: typo\nworkload:\n  imgae: busybox\n")
+	writeTemplate(t, dir, "unknown-field", "name: unknown-field\nworkload:\n  image: \"{{ .Postgres }}\"\n")
+
+	tests := []struct {
+		template string
+		wantErr  string
+	}{
+		{"rag", "requires catalog app qdrant"},
+		{"half-secret", "secret and key must be set together"},
+		{"takeover", "values.agent.egress is managed by sikifanso"},
+		{"typo", "unknown field"},
+		{"unknown-field", "Postgres"},
+		{"missing", `template "missing" not found`},
+	}
+	for _, tt := range tests {
+		err := Create(dir, CreateOpts{Name: "bot", Template: tt.template})
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("template %s: error = %v, want %q", tt.template, err, tt.wantErr)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "agents", "bot.yaml")); !os.IsNotExist(err) {
+		t.Error("agent entry written for an invalid template")
+	}
+}
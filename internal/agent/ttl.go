@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/argocd/appsetreconcile"
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/kube"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"go.uber.org/zap"
+)
+
+// DefaultReapInterval is how often long-running commands reap expired agents.
+const DefaultReapInterval = time.Minute
+
+// Expired reports whether the agent has a TTL that elapsed before t.
+func (i Info) Expired(t time.Time) bool {
+	return i.ExpiresAt != nil && !t.Before(*i.ExpiresAt)
+}
+
+// Remaining returns the lifetime left at t, negative once expired. It is
+// zero for agents without a TTL.
+func (i Info) Remaining(t time.Time) time.Duration {
+	if i.ExpiresAt == nil {
+		return 0
+	}
+	return i.ExpiresAt.Sub(t)
+}
+
+// ListExpired returns the agents whose TTL elapsed before t.
+func ListExpired(gitOpsPath string, t time.Time) ([]Info, error) {
+	agents, err := List(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	var expired []Info
+	for _, a := range agents {
+		if a.Expired(t) {
+			expired = append(expired, a)
+		}
+	}
+	return expired, nil
+}
+
+// Reap deletes every agent whose TTL elapsed before t in a single commit and
+// returns their names. Nothing is committed when none has expired, and the
+// removed files are put back if the commit fails.
+func Reap(gitOpsPath string, t time.Time) ([]string, error) {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+	expired, err := ListExpired(gitOpsPath, t)
+	if err != nil || len(expired) == 0 {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(expired))
+	paths := make([]string, 0, 2*len(expired))
+	removed := map[string][]byte{}
+	for _, a := range expired {
+		for _, rel := range []string{filepath.Join("agents", a.Name+".yaml"), agentValuesPath(a.Name)} {
+			abs := filepath.Join(gitOpsPath, rel)
+			data, err := os.ReadFile(abs)
+			if os.IsNotExist(err) {
+				continue // values files are optional, as in Delete
+			}
+			if err == nil {
+				err = os.Remove(abs)
+			}
+			if err != nil {
+				restoreFiles(removed)
+				return nil, fmt.Errorf("removing %s: %w", rel, err)
+			}
+			removed[abs] = data
+			paths = append(paths, rel)
+		}
+		names = append(names, a.Name)
+	}
+
+	msg := fmt.Sprintf("agent: reap expired %s", strings.Join(names, ", "))
+	if err := gitops.Commit(gitOpsPath, msg, paths...); err != nil {
+		restoreFiles(removed)
+		return nil, err
+	}
+	return names, nil
+}
+
+// restoreFiles writes back files removed ahead of a commit that failed.
+func restoreFiles(files map[string][]byte) {
+	for abs, data := range files {
+		_ = os.WriteFile(abs, data, 0o644)
+	}
+}
+
+// ReapCluster reaps the cluster's expired agents: they are removed in one
+// commit, the LLM keys of the reaped agents are revoked, and the agents
+// ApplicationSet is refreshed so ArgoCD deletes their namespaces. Key and
+// refresh failures are logged.
+func ReapCluster(ctx context.Context, log *zap.Logger, sess *session.Session) ([]string, error) {
+	names, err := Reap(sess.GitOpsPath, time.Now())
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+
+	// Keys live in the agent namespaces, which remain until the sync below.
+	gw, err := ConnectGateway(ctx, sess.GitOpsPath, sess.ClusterName)
+	if err != nil {
+		log.Warn("reaper: LLM keys of reaped agents not revoked", zap.Error(err))
+	}
+	if gw != nil {
+		for _, name := range names {
+			if _, err := gw.RevokeKey(ctx, name); err != nil {
+				log.Warn("reaper: revoking LLM key", zap.String("agent", name), zap.Error(err))
+			}
+		}
+	}
+
+	restCfg, err := kube.RESTConfigForCluster(sess.ClusterName)
+	if err == nil {
+		var r *appsetreconcile.Reconciler
+		if r, err = appsetreconcile.NewReconciler(restCfg, "argocd"); err == nil {
+			err = r.Trigger(ctx, "agents")
+		}
+	}
+	if err != nil {
+		log.Warn("reaper: triggering agents sync", zap.Error(err))
+	}
+	return names, nil
+}
+
+// RunReaper calls ReapCluster for each session returned by sessions every
+// interval until ctx is cancelled.
+func RunReaper(ctx context.Context, log *zap.Logger, interval time.Duration, sessions func() ([]*session.Session, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		list, err := sessions()
+		if err != nil {
+			log.Warn("reaper: loading sessions", zap.Error(err))
+		}
+		for _, sess := range list {
+			names, err := ReapCluster(ctx, log, sess)
+			if err != nil {
+				log.Warn("reaper: reaping agents", zap.String("cluster", sess.ClusterName), zap.Error(err))
+			}
+			if len(names) > 0 {
+				log.Info("reaped expired agents", zap.String("cluster", sess.ClusterName), zap.Strings("agents", names))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
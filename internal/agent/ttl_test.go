@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreate_TTLRecordsExpiry(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+
+	before := time.Now()
+	if err := Create(dir, CreateOpts{Name: "scratch", TTL: 4 * time.Hour}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	info, err := Find(dir, "scratch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ExpiresAt == nil {
+		t.Fatal("ExpiresAt not recorded")
+	}
+	if d := info.ExpiresAt.Sub(before); d < 4*time.Hour-time.Second || d > 4*time.Hour+time.Minute {
+		t.Errorf("ExpiresAt is %s after creation, want ~4h", d)
+	}
+	if info.Expired(before) || !info.Expired(before.Add(5*time.Hour)) {
+		t.Error("Expired does not honour ExpiresAt")
+	}
+
+	if err := Create(dir, CreateOpts{Name: "bad", TTL: -time.Hour}); err == nil {
+		t.Error("negative TTL accepted")
+	}
+}
+
+func TestReap_RemovesOnlyExpiredInOneCommit(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	for _, o := range []CreateOpts{
+		{Name: "short", TTL: time.Hour},
+		{Name: "shorter", TTL: time.Minute},
+		{Name: "long", TTL: 24 * time.Hour},
+		{Name: "forever"},
+	} {
+		if err := Create(dir, o); err != nil {
+			t.Fatalf("Create %s: %v", o.Name, err)
+		}
+	}
+
+	reaped, err := Reap(dir, time.Now().Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Reap: %v", err)
+	}
+	if strings.Join(reaped, ",") != "short,shorter" {
+		t.Errorf("reaped = %v, want [short shorter]", reaped)
+	}
+	for _, name := range reaped {
+		if _, err := os.Stat(filepath.Join(dir, "agents", name+".yaml")); !os.IsNotExist(err) {
+			t.Errorf("%s entry still exists", name)
+		}
+	}
+	agents, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(agents) != 2 {
+		t.Errorf("%d agents left, want 2", len(agents))
+	}
+
+	out := gitOutput(t, dir, "log", "-1", "--name-status", "--format=%s")
+	if !strings.HasPrefix(out, "agent: reap expired short, shorter") || strings.Count(out, "\nD\t") != 4 {
+		t.Errorf("last commit:\n%s", out)
+	}
+
+	reaped, err = Reap(dir, time.Now().Add(2*time.Hour))
+	if err != nil || len(reaped) != 0 {
+		t.Errorf("second Reap = %v, %v; want nothing", reaped, err)
+	}
+}
+
+func TestReap_RestoresFilesWhenCommitFails(t *testing.T) {
+	t.Parallel()
+	dir := setupGitOps(t)
+	if err := Create(dir, CreateOpts{Name: "short", TTL: time.Hour}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, ".git")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Reap(dir, time.Now().Add(2*time.Hour)); err == nil {
+		t.Fatal("Reap succeeded without a git repo")
+	}
+	if _, err := Find(dir, "short"); err != nil {
+		t.Errorf("agent entry not restored: %v", err)
+	}
+}
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %s: %v", args, out, err)
+	}
+	return string(out)
+}
@@ -0,0 +1,202 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/kube"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// QuotaWarnPercent is the share of a quota at which an agent is reported as
+// near its limit.
+const QuotaWarnPercent = 80
+
+// quotaResources are the ResourceQuota entries reported, in display order.
+var quotaResources = []corev1.ResourceName{
+	corev1.ResourceRequestsCPU,
+	corev1.ResourceLimitsCPU,
+	corev1.ResourceRequestsMemory,
+	corev1.ResourceLimitsMemory,
+	corev1.ResourcePods,
+}
+
+var podMetricsGVR = schema.GroupVersionResource{Group: "metrics.k8s.io", Version: "v1beta1", Resource: "pods"}
+
+// QuotaUsage is how much of one ResourceQuota entry an agent uses.
+type QuotaUsage struct {
+	// Resource is the quota key, e.g. requests.cpu or pods.
+	Resource string `json:"resource"`
+	Used     string `json:"used"`
+	Hard     string `json:"hard"`
+	Percent  int    `json:"percent"`
+}
+
+// String renders the entry as used/hard (percent%).
+func (q QuotaUsage) String() string {
+	return fmt.Sprintf("%s/%s (%d%%)", q.Used, q.Hard, q.Percent)
+}
+
+// Usage is an agent namespace's live consumption of its quota.
+type Usage struct {
+	Quota []QuotaUsage `json:"quota"`
+	// CPU and Memory are what the agent's pods consume right now, from
+	// metrics-server. They are empty when metrics are unavailable.
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+}
+
+// Highest returns the quota entry closest to its limit.
+func (u Usage) Highest() (QuotaUsage, bool) {
+	if len(u.Quota) == 0 {
+		return QuotaUsage{}, false
+	}
+	top := u.Quota[0]
+	for _, q := range u.Quota[1:] {
+		if q.Percent > top.Percent {
+			top = q
+		}
+	}
+	return top, true
+}
+
+// AtLimit returns the entries used at or above percent of their quota.
+func (u Usage) AtLimit(percent int) []QuotaUsage {
+	var out []QuotaUsage
+	for _, q := range u.Quota {
+		if q.Percent >= percent {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// UsageReader reads the quota usage of agent namespaces from the cluster.
+type UsageReader struct {
+	Kube kubernetes.Interface
+	// Metrics reads pod metrics; nil skips live CPU and memory.
+	Metrics dynamic.Interface
+}
+
+// NewUsageReader returns a UsageReader for the cluster.
+func NewUsageReader(clusterName string) (*UsageReader, error) {
+	restCfg, err := kube.RESTConfigForCluster(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating clientset: %w", err)
+	}
+	dyn, err := dynamic.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating dynamic client: %w", err)
+	}
+	return &UsageReader{Kube: cs, Metrics: dyn}, nil
+}
+
+// Read returns the usage of the agent namespace. Quota entries come from the
+// status of its ResourceQuotas; when several set the same resource the
+// tightest wins. Pod metrics are best-effort, since metrics-server may be
+// missing or still starting.
+func (r *UsageReader) Read(ctx context.Context, namespace string) (*Usage, error) {
+	quotas, err := r.Kube.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing resource quotas in %s: %w", namespace, err)
+	}
+	if len(quotas.Items) == 0 {
+		return nil, fmt.Errorf("no ResourceQuota in %s; the agent may not be synced yet", namespace)
+	}
+
+	u := &Usage{}
+	for _, res := range quotaResources {
+		var best *QuotaUsage
+		for _, rq := range quotas.Items {
+			hard, ok := rq.Status.Hard[res]
+			if !ok {
+				continue
+			}
+			used := rq.Status.Used[res]
+			q := QuotaUsage{Resource: string(res), Used: used.String(), Hard: hard.String(), Percent: percentOf(used, hard)}
+			if best == nil || q.Percent > best.Percent {
+				best = &q
+			}
+		}
+		if best != nil {
+			u.Quota = append(u.Quota, *best)
+		}
+	}
+
+	if r.Metrics != nil {
+		u.CPU, u.Memory = r.podMetrics(ctx, namespace)
+	}
+	return u, nil
+}
+
+// podMetrics sums the CPU and memory of every container in namespace. It
+// returns empty strings when metrics cannot be read.
+func (r *UsageReader) podMetrics(ctx context.Context, namespace string) (cpu, memory string) {
+	list, err := r.Metrics.Resource(podMetricsGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", ""
+	}
+	var cpuSum, memSum resource.Quantity
+	for _, pod := range list.Items {
+		containers, _, _ := unstructured.NestedSlice(pod.Object, "containers")
+		for _, c := range containers {
+			usage, _, _ := unstructured.NestedStringMap(asMap(c), "usage")
+			if q, err := resource.ParseQuantity(usage["cpu"]); err == nil {
+				cpuSum.Add(q)
+			}
+			if q, err := resource.ParseQuantity(usage["memory"]); err == nil {
+				memSum.Add(q)
+			}
+		}
+	}
+	return formatCPU(cpuSum), formatMemory(memSum)
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+// percentOf returns used as a whole percentage of hard. A zero quota that
+// is used at all counts as full.
+func percentOf(used, hard resource.Quantity) int {
+	if hard.IsZero() {
+		if used.IsZero() {
+			return 0
+		}
+		return 100
+	}
+	return int(used.MilliValue() * 100 / hard.MilliValue())
+}
+
+// formatCPU renders CPU in millicores, as quotas are usually written.
+func formatCPU(q resource.Quantity) string {
+	return fmt.Sprintf("%dm", q.MilliValue())
+}
+
+// formatMemory renders memory in Mi, rounded up.
+func formatMemory(q resource.Quantity) string {
+	const mi = 1 << 20
+	return fmt.Sprintf("%dMi", (q.Value()+mi-1)/mi)
+}
+
+// Summary renders entries as a list such as "pods 10/10 (100%), limits.cpu
+// 900m/1 (90%)".
+func Summary(entries []QuotaUsage) string {
+	parts := make([]string, len(entries))
+	for i, q := range entries {
+		parts[i] = q.Resource + " " + q.String()
+	}
+	return strings.Join(parts, ", ")
+}
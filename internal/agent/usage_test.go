@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func fakeQuota(namespace string, hard, used map[corev1.ResourceName]string) *corev1.ResourceQuota {
+	rq := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent-quota", Namespace: namespace},
+		Status:     corev1.ResourceQuotaStatus{Hard: corev1.ResourceList{}, Used: corev1.ResourceList{}},
+	}
+	for k, v := range hard {
+		rq.Status.Hard[k] = resource.MustParse(v)
+	}
+	for k, v := range used {
+		rq.Status.Used[k] = resource.MustParse(v)
+	}
+	return rq
+}
+
+func fakePodMetrics(namespace, name string, usage ...map[string]interface{}) *unstructured.Unstructured {
+	containers := make([]interface{}, len(usage))
+	for i, u := range usage {
+		containers[i] = map[string]interface{}{"name": "c", "usage": u}
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "metrics.k8s.io/v1beta1",
+		"kind":       "PodMetrics",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+		"containers": containers,
+	}}
+}
+
+func TestUsageReader_Read(t *testing.T) {
+	t.Parallel()
+	cs := fake.NewClientset(fakeQuota("agent-bot",
+		map[corev1.ResourceName]string{"requests.cpu": "250m", "limits.cpu": "1", "requests.memory": "256Mi", "limits.memory": "1Gi", "pods": "10"},
+		map[corev1.ResourceName]string{"requests.cpu": "200m", "limits.cpu": "500m", "requests.memory": "128Mi", "limits.memory": "512Mi", "pods": "10"},
+	))
+	// PodMetrics are served as "pods", which the fake tracker cannot guess
+	// from the kind, so they are created through the resource client.
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{podMetricsGVR: "PodMetricsList"})
+	for _, m := range []*unstructured.Unstructured{
+		fakePodMetrics("agent-bot", "a", map[string]interface{}{"cpu": "120m", "memory": "100Mi"}, map[string]interface{}{"cpu": "5m", "memory": "20Mi"}),
+		fakePodMetrics("agent-other", "b", map[string]interface{}{"cpu": "900m", "memory": "1Gi"}),
+	} {
+		if _, err := dyn.Resource(podMetricsGVR).Namespace(m.GetNamespace()).Create(context.Background(), m, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u, err := (&UsageReader{Kube: cs, Metrics: dyn}).Read(context.Background(), "agent-bot")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	want := []QuotaUsage{
+		{Resource: "requests.cpu", Used: "200m", Hard: "250m", Percent: 80},
+		{Resource: "limits.cpu", Used: "500m", Hard: "1", Percent: 50},
+		{Resource: "requests.memory", Used: "128Mi", Hard: "256Mi", Percent: 50},
+		{Resource: "limits.memory", Used: "512Mi", Hard: "1Gi", Percent: 50},
+		{Resource: "pods", Used: "10", Hard: "10", Percent: 100},
+	}
+	if len(u.Quota) != len(want) {
+		t.Fatalf("quota = %+v, want %+v", u.Quota, want)
+	}
+	for i := range want {
+		if u.Quota[i] != want[i] {
+			t.Errorf("quota[%d] = %+v, want %+v", i, u.Quota[i], want[i])
+		}
+	}
+	if u.CPU != "125m" || u.Memory != "120Mi" {
+		t.Errorf("live = %s CPU, %s memory, want 125m, 120Mi", u.CPU, u.Memory)
+	}
+
+	if top, _ := u.Highest(); top.Resource != "pods" {
+		t.Errorf("Highest = %s, want pods", top.Resource)
+	}
+	if got := Summary(u.AtLimit(QuotaWarnPercent)); got != "requests.cpu 200m/250m (80%), pods 10/10 (100%)" {
+		t.Errorf("Summary = %q", got)
+	}
+}
+
+func TestUsageReader_NoQuota(t *testing.T) {
+	t.Parallel()
+	r := &UsageReader{Kube: fake.NewClientset()}
+	if _, err := r.Read(context.Background(), "agent-new"); err == nil {
+		t.Error("expected an error for a namespace without a ResourceQuota")
+	}
+}
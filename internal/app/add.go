@@ -7,6 +7,7 @@ import (
 	"regexp"
 
 	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
 	"sigs.k8s.io/yaml"
 )
 
@@ -33,6 +34,11 @@ var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
 
 // Add writes the coordinate and values files, then commits to the gitops repo.
 func Add(opts AddOpts) error {
+	l, err := lock.GitOps(opts.GitOpsPath)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
 	if opts.Name == "" {
 		return fmt.Errorf("app name is required")
 	}
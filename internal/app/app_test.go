@@ -386,3 +386,66 @@ func TestRemove_NonexistentApp(t *testing.T) {
 		t.Errorf("error = %q, want it to mention 'not found'", err.Error())
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Update and values tests
+// ---------------------------------------------------------------------------
+
+func TestUpdate_RewritesChangedCoordinates(t *testing.T) {
+	t.Parallel()
+	dir := initGitRepo(t)
+
+	opts := AddOpts{
+		GitOpsPath: dir,
+		Name:       "my-app",
+		RepoURL:    "https://charts.example.com",
+		Chart:      "my-app",
+		Version:    "1.0.0",
+		Namespace:  "apps",
+	}
+	if err := Add(opts); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+
+	changed, err := Update(opts)
+	if err != nil || changed {
+		t.Fatalf("Update(same) = %v, %v; want false, nil", changed, err)
+	}
+
+	opts.Version = "2.0.0"
+	changed, err = Update(opts)
+	if err != nil || !changed {
+		t.Fatalf("Update(new version) = %v, %v; want true, nil", changed, err)
+	}
+	apps, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 1 || apps[0].Version != "2.0.0" {
+		t.Errorf("apps = %+v, want my-app at 2.0.0", apps)
+	}
+
+	opts.Name = "ghost"
+	if _, err := Update(opts); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Update(ghost) error = %v, want not found", err)
+	}
+}
+
+func TestSaveValues(t *testing.T) {
+	t.Parallel()
+	dir := initGitRepo(t)
+
+	if err := SaveValues(dir, "ghost", []byte("a: 1\n")); err == nil {
+		t.Fatal("expected error for nonexistent app")
+	}
+	if err := Add(AddOpts{GitOpsPath: dir, Name: "my-app", RepoURL: "https://x", Chart: "my-app", Version: "1.0.0", Namespace: "apps"}); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	if err := SaveValues(dir, "my-app", []byte("replicas: 2\n")); err != nil {
+		t.Fatalf("SaveValues error: %v", err)
+	}
+	data, err := ReadValues(dir, "my-app")
+	if err != nil || string(data) != "replicas: 2\n" {
+		t.Errorf("ReadValues = %q, %v; want replicas: 2", data, err)
+	}
+}
@@ -6,10 +6,16 @@ import (
 	"path/filepath"
 
 	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
 )
 
 // Remove deletes the coordinate and values files, then commits.
 func Remove(gitOpsPath, name string) error {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
 	coordPath := filepath.Join("apps", "coordinates", name+".yaml")
 	absCoord := filepath.Join(gitOpsPath, coordPath)
 
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
+	"sigs.k8s.io/yaml"
+)
+
+// Update rewrites the coordinates of an existing app from opts and commits.
+// It reports false when they already match and nothing was written.
+func Update(opts AddOpts) (bool, error) {
+	l, err := lock.GitOps(opts.GitOpsPath)
+	if err != nil {
+		return false, err
+	}
+	defer l.Release()
+	coordPath := filepath.Join("apps", "coordinates", opts.Name+".yaml")
+	absCoord := filepath.Join(opts.GitOpsPath, coordPath)
+
+	data, err := os.ReadFile(absCoord)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, fmt.Errorf("app %q not found", opts.Name)
+		}
+		return false, fmt.Errorf("reading coordinates file: %w", err)
+	}
+	var current coordinates
+	if err := yaml.Unmarshal(data, &current); err != nil {
+		return false, fmt.Errorf("parsing coordinates file: %w", err)
+	}
+
+	coord := coordinates{
+		Name:           opts.Name,
+		RepoURL:        opts.RepoURL,
+		Chart:          opts.Chart,
+		TargetRevision: opts.Version,
+		Namespace:      opts.Namespace,
+	}
+	if coord == current {
+		return false, nil
+	}
+
+	coordData, err := yaml.Marshal(coord)
+	if err != nil {
+		return false, fmt.Errorf("marshaling coordinates: %w", err)
+	}
+	if err := os.WriteFile(absCoord, coordData, 0o644); err != nil {
+		return false, fmt.Errorf("writing coordinates file: %w", err)
+	}
+	if err := gitops.Commit(opts.GitOpsPath, fmt.Sprintf("update app %s", opts.Name), coordPath); err != nil {
+		return false, fmt.Errorf("committing app files: %w", err)
+	}
+	return true, nil
+}
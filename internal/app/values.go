@@ -0,0 +1,55 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
+)
+
+// ValuesFile returns the gitops-relative path of the Helm values file for a
+// custom app, e.g. apps/values/my-app.yaml.
+func ValuesFile(name string) string {
+	return filepath.Join("apps", "values", name+".yaml")
+}
+
+// ReadValues returns the raw contents of the named app's values file, or nil
+// when it has none.
+func ReadValues(gitOpsPath, name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(gitOpsPath, ValuesFile(name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading values for %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// SaveValues writes the named app's values file and commits it.
+func SaveValues(gitOpsPath, name string, data []byte) error {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	coordFile := filepath.Join(gitOpsPath, "apps", "coordinates", name+".yaml")
+	if _, err := os.Stat(coordFile); os.IsNotExist(err) {
+		return fmt.Errorf("app %q not found", name)
+	}
+
+	rel := ValuesFile(name)
+	abs := filepath.Join(gitOpsPath, rel)
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return fmt.Errorf("creating values directory: %w", err)
+	}
+	if err := os.WriteFile(abs, data, 0o644); err != nil {
+		return fmt.Errorf("writing values for %s: %w", name, err)
+	}
+	if err := gitops.Commit(gitOpsPath, fmt.Sprintf("update values for app %s", name), rel); err != nil {
+		return fmt.Errorf("committing values for %s: %w", name, err)
+	}
+	return nil
+}
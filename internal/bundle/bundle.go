@@ -0,0 +1,316 @@
+// Package bundle packs everything a cluster needs into one archive — the
+// k3s and k3d images, the infrastructure and catalog charts with their
+// images, and the bootstrap tree — so clusters can be created without
+// network access.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
+)
+
+// FormatVersion is the bundle layout version written to the manifest.
+const FormatVersion = 1
+
+// Layout of a bundle, relative to its root.
+const (
+	manifestFile  = "manifest.json"
+	bootstrapDir  = "bootstrap"
+	chartsDir     = "charts"
+	hostImagesTar = "images/host.tar"
+	// clusterImagesTar is mounted into every node's k3s agent images
+	// directory, which k3s imports on startup before any pod is scheduled.
+	clusterImagesTar = "images/cluster.tar"
+)
+
+// NodeChartsDir is where the bundled charts are mounted on every node.
+const NodeChartsDir = "/sikifanso-charts"
+
+// NodeImagesTar is where the cluster image archive is mounted on every node.
+const NodeImagesTar = "/var/lib/rancher/k3s/agent/images/sikifanso-bundle.tar"
+
+// Manifest describes a bundle's contents.
+type Manifest struct {
+	FormatVersion int       `json:"formatVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	// CLIVersion is the sikifanso version that created the bundle.
+	CLIVersion       string `json:"cliVersion"`
+	BootstrapURL     string `json:"bootstrapURL"`
+	BootstrapVersion string `json:"bootstrapVersion,omitempty"`
+	K3sImage         string `json:"k3sImage"`
+	// HostImages are loaded into the host's Docker before the cluster is
+	// created: the k3s node image and the k3d helpers.
+	HostImages []string `json:"hostImages"`
+	// Images are preloaded into every node.
+	Images []string `json:"images"`
+	Charts []Chart  `json:"charts"`
+}
+
+// Chart is a bundled Helm chart.
+type Chart struct {
+	// Name is the component the chart is for: cilium, argocd,
+	// sikifanso-agent-template, or a catalog app name.
+	Name    string `json:"name"`
+	RepoURL string `json:"repoURL"`
+	Chart   string `json:"chart"`
+	Version string `json:"version"`
+	// File is the chart archive, relative to the charts directory.
+	File string `json:"file"`
+	// NoDigest lists the dotted values keys set to false so the chart
+	// references its images by tag, which is how they were preloaded.
+	NoDigest []string `json:"noDigest,omitempty"`
+}
+
+// Bundle is an unpacked bundle.
+type Bundle struct {
+	// Path is the archive the bundle was unpacked from.
+	Path     string
+	Dir      string
+	Manifest Manifest
+}
+
+// Open unpacks the bundle archive at path into dir, replacing anything
+// there, and reads its manifest.
+func Open(path, dir string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening bundle: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("clearing %s: %w", dir, err)
+	}
+	if err := extract(f, dir); err != nil {
+		return nil, fmt.Errorf("unpacking bundle %s: %w", filepath.Base(path), err)
+	}
+	b := &Bundle{Path: path, Dir: dir}
+	if err := readManifest(filepath.Join(dir, manifestFile), &b.Manifest); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Inspect reads the manifest of the bundle archive at path without
+// unpacking the rest.
+func Inspect(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening bundle: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading bundle %s: %w", filepath.Base(path), err)
+	}
+	defer func() { _ = gr.Close() }()
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s is not a sikifanso bundle: no %s", filepath.Base(path), manifestFile)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading bundle %s: %w", filepath.Base(path), err)
+		}
+		if hdr.Name != manifestFile {
+			continue
+		}
+		var m Manifest
+		if err := json.NewDecoder(tr).Decode(&m); err != nil {
+			return nil, fmt.Errorf("parsing bundle manifest: %w", err)
+		}
+		return &m, checkFormat(m)
+	}
+}
+
+// BootstrapDir returns the bundled bootstrap tree.
+func (b *Bundle) BootstrapDir() string {
+	return filepath.Join(b.Dir, bootstrapDir)
+}
+
+// ChartsDir returns the directory holding the chart archives and their
+// index.yaml, which the in-cluster chart repository serves.
+func (b *Bundle) ChartsDir() string {
+	return filepath.Join(b.Dir, chartsDir)
+}
+
+// ImagesTar returns the archive of images preloaded into every node.
+func (b *Bundle) ImagesTar() string {
+	return filepath.Join(b.Dir, filepath.FromSlash(clusterImagesTar))
+}
+
+// Chart returns the bundled chart for the named component.
+func (b *Bundle) Chart(name string) (*Chart, bool) {
+	for i := range b.Manifest.Charts {
+		if b.Manifest.Charts[i].Name == name {
+			return &b.Manifest.Charts[i], true
+		}
+	}
+	return nil, false
+}
+
+// LocalChart returns c pointed at its bundled archive, for installing it
+// from the host with Helm. Components without a bundled chart are returned
+// unchanged.
+func (b *Bundle) LocalChart(name string, c infraconfig.ChartConfig) infraconfig.ChartConfig {
+	ch, ok := b.Chart(name)
+	if !ok {
+		return c
+	}
+	c.RepoURL = ""
+	c.Chart = filepath.Join(b.ChartsDir(), ch.File)
+	c.TargetRevision = ""
+	return c
+}
+
+func readManifest(path string, m *Manifest) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("not a sikifanso bundle: no %s", manifestFile)
+		}
+		return fmt.Errorf("reading bundle manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("parsing bundle manifest: %w", err)
+	}
+	return checkFormat(*m)
+}
+
+func checkFormat(m Manifest) error {
+	if m.FormatVersion != FormatVersion {
+		return fmt.Errorf("bundle format %d is not supported (want %d); recreate it with this sikifanso version", m.FormatVersion, FormatVersion)
+	}
+	return nil
+}
+
+// archive writes the contents of dir to w as a gzipped tar, with the
+// manifest first so Inspect finds it without reading the images.
+func archive(w io.Writer, dir string) (retErr error) {
+	gw := gzip.NewWriter(w)
+	defer func() {
+		if err := gw.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	tw := tar.NewWriter(gw)
+	defer func() {
+		if err := tw.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+
+	if err := addFile(tw, filepath.Join(dir, manifestFile), manifestFile); err != nil {
+		return err
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		switch {
+		case rel == "." || name == manifestFile:
+			return nil
+		case info.IsDir():
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     name + "/",
+				Mode:     0o755,
+				ModTime:  info.ModTime(),
+			})
+		case info.Mode().IsRegular():
+			return addFile(tw, path, name)
+		}
+		return nil
+	})
+}
+
+func addFile(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// extract unpacks a gzipped tar into dir, rejecting entries that would
+// land outside it.
+func extract(r io.Reader, dir string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = gr.Close() }()
+
+	root := filepath.Clean(dir)
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(root, filepath.FromSlash(hdr.Name))
+		if dest != root && !strings.HasPrefix(dest, root+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal path in archive: %s", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+				return err
+			}
+			if err := extractFile(tr, dest); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractFile(r io.Reader, dest string) error {
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("writing %s: %w", dest, err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,301 @@
+package bundle
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/helm"
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
+	"helm.sh/helm/v3/pkg/chart"
+	"sigs.k8s.io/yaml"
+)
+
+func TestManifestImages(t *testing.T) {
+	t.Parallel()
+	manifests := `
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox:1.36
+      containers:
+        - name: app
+          image: ghcr.io/org/app:1.0
+---
+# empty document
+---
+apiVersion: example.com/v1
+kind: Envoy
+spec:
+  image: quay.io/cilium/envoy:v1.30
+  config:
+    image:
+      repository: not-a-string-image
+---
+apiVersion: batch/v1
+kind: Job
+spec:
+  template:
+    spec:
+      containers:
+        - image: ghcr.io/org/app:1.0
+`
+	got, err := manifestImages(manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"busybox:1.36", "ghcr.io/org/app:1.0", "quay.io/cilium/envoy:v1.30"}
+	if !slices.Equal(got, want) {
+		t.Errorf("images = %v, want %v", got, want)
+	}
+}
+
+func TestRenderedChartImages(t *testing.T) {
+	t.Parallel()
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: "v2", Name: "demo", Version: "0.1.0", KubeVersion: ">=1.30.0-0"},
+		Values: map[string]interface{}{
+			"image": map[string]interface{}{"repository": "ghcr.io/org/demo", "tag": "2.0", "digest": "sha256:abc", "useDigest": true},
+		},
+		Templates: []*chart.File{{
+			Name: "templates/pod.yaml",
+			Data: []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: demo
+spec:
+  containers:
+    - name: demo
+      image: "{{ .Values.image.repository }}:{{ .Values.image.tag }}{{ if .Values.image.useDigest }}@{{ .Values.image.digest }}{{ end }}"
+`),
+		}},
+	}
+
+	keys := noDigestKeys(ch.Values)
+	if !slices.Equal(keys, []string{"image.useDigest"}) {
+		t.Fatalf("noDigestKeys = %v", keys)
+	}
+	vals := infraconfig.MergeValues(nil, noDigestValues(keys))
+	manifests, err := helm.Render(ch, vals, "demo", "default", "v1.31.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := manifestImages(manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []string{"ghcr.io/org/demo:2.0"}) {
+		t.Errorf("images = %v, want the tag without digest", got)
+	}
+
+	// Helm's default kube version is too old for the chart's constraint.
+	if _, err := helm.Render(ch, vals, "demo", "default", ""); err == nil {
+		t.Error("expected the kubeVersion constraint to fail without a version")
+	}
+}
+
+func TestStripDigest(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"quay.io/cilium/cilium:v1.16.5@sha256:abc": "quay.io/cilium/cilium:v1.16.5",
+		"localhost:5000/app@sha256:abc":            "localhost:5000/app@sha256:abc",
+		"busybox:1.36":                             "busybox:1.36",
+	}
+	for in, want := range cases {
+		if got := stripDigest(in); got != want {
+			t.Errorf("stripDigest(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestK3sRelease(t *testing.T) {
+	t.Parallel()
+	got, err := k3sRelease("docker.io/rancher/k3s:v1.31.5-k3s1")
+	if err != nil || got != "v1.31.5+k3s1" {
+		t.Errorf("k3sRelease = %q, %v; want v1.31.5+k3s1", got, err)
+	}
+	if kubeVersion(got) != "v1.31.5" {
+		t.Errorf("kubeVersion = %q", kubeVersion(got))
+	}
+	if _, err := k3sRelease("rancher/k3s:latest"); err == nil {
+		t.Error("expected an error for a tag without a k3s release")
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	t.Parallel()
+	src := t.TempDir()
+	m := Manifest{FormatVersion: FormatVersion, BootstrapURL: "https://example.com/b.git", K3sImage: "rancher/k3s:v1.31.5-k3s1"}
+	writeJSON(t, filepath.Join(src, manifestFile), m)
+	writeFile(t, filepath.Join(src, "bootstrap", "infra", "platform.yaml"), "servers: 1\n")
+	writeFile(t, filepath.Join(src, "charts", "index.yaml"), "apiVersion: v1\n")
+
+	archivePath := filepath.Join(t.TempDir(), "offline.tar.gz")
+	if err := writeArchive(archivePath, src); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(archivePath + ".partial"); !os.IsNotExist(err) {
+		t.Errorf("partial file left behind: %v", err)
+	}
+
+	got, err := Inspect(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.K3sImage != m.K3sImage {
+		t.Errorf("Inspect k3s image = %q", got.K3sImage)
+	}
+
+	b, err := Open(archivePath, filepath.Join(t.TempDir(), "unpacked"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Manifest.BootstrapURL != m.BootstrapURL || b.Path != archivePath {
+		t.Errorf("bundle = %+v", b)
+	}
+	if data, err := os.ReadFile(filepath.Join(b.BootstrapDir(), "infra", "platform.yaml")); err != nil || string(data) != "servers: 1\n" {
+		t.Errorf("platform.yaml = %q, %v", data, err)
+	}
+}
+
+func TestInspect_RejectsOtherFormats(t *testing.T) {
+	t.Parallel()
+	src := t.TempDir()
+	writeJSON(t, filepath.Join(src, manifestFile), Manifest{FormatVersion: FormatVersion + 1})
+	archivePath := filepath.Join(t.TempDir(), "future.tar.gz")
+	if err := writeArchive(archivePath, src); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Inspect(archivePath); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("err = %v, want a format error", err)
+	}
+}
+
+func TestLocalChart(t *testing.T) {
+	t.Parallel()
+	b := &Bundle{Dir: "/b", Manifest: Manifest{Charts: []Chart{{Name: "cilium", File: "cilium-1.16.5.tgz"}}}}
+	c := b.LocalChart("cilium", infraconfig.ChartConfig{RepoURL: "https://helm.cilium.io", Chart: "cilium", TargetRevision: "1.16.x", ReleaseName: "cilium"})
+	if c.RepoURL != "" || c.Chart != filepath.Join("/b", "charts", "cilium-1.16.5.tgz") || c.ReleaseName != "cilium" {
+		t.Errorf("LocalChart = %+v", c)
+	}
+	unchanged := infraconfig.ChartConfig{RepoURL: "https://argoproj.github.io/argo-helm", Chart: "argo-cd"}
+	if got := b.LocalChart("argocd", unchanged); got != unchanged {
+		t.Errorf("LocalChart without a bundled chart = %+v", got)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "infra", "cilium.yaml"), "# Cilium chart\nrepoURL: https://helm.cilium.io\nchart: cilium\ntargetRevision: 1.16.5\n")
+	writeFile(t, filepath.Join(dir, "catalog", "grafana.yaml"), "name: grafana\nrepoURL: https://grafana.github.io/helm-charts\nchart: grafana\ntargetRevision: 8.0.0\nnamespace: monitoring\nenabled: false\n")
+	writeFile(t, filepath.Join(dir, "catalog", "qdrant.yaml"), "name: qdrant\nrepoURL: https://qdrant.github.io/qdrant-helm\nchart: qdrant\ntargetRevision: 1.0.0\nnamespace: qdrant\nenabled: false\n")
+	gitRun(t, dir, "init")
+	gitRun(t, dir, "-c", "user.email=t@t", "-c", "user.name=t", "add", ".")
+	gitRun(t, dir, "-c", "user.email=t@t", "-c", "user.name=t", "commit", "-m", "init")
+
+	b := &Bundle{Manifest: Manifest{Charts: []Chart{
+		{Name: "cilium", File: "cilium-1.16.5.tgz", NoDigest: []string{"image.useDigest", "operator.image.useDigest"}},
+		{Name: "argocd", File: "argo-cd-7.0.0.tgz"},
+		{Name: "grafana", File: "grafana-8.0.0.tgz", NoDigest: []string{"image.useDigest"}},
+	}}}
+	missing, err := b.Localize(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(missing, []string{"qdrant"}) {
+		t.Errorf("missing = %v, want [qdrant]", missing)
+	}
+
+	cfg, err := infraconfig.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Cilium.RepoURL != ChartRepoURL || cfg.Cilium.Chart != "cilium" || cfg.ArgoCD.RepoURL != ChartRepoURL {
+		t.Errorf("infra charts = %+v / %+v", cfg.Cilium, cfg.ArgoCD)
+	}
+	op, _ := cfg.CiliumValues["operator"].(map[string]interface{})
+	img, _ := op["image"].(map[string]interface{})
+	if img["useDigest"] != false {
+		t.Errorf("cilium operator.image.useDigest = %v, want false", img["useDigest"])
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "infra", "cilium.yaml")); !strings.Contains(string(data), "# Cilium chart") {
+		t.Errorf("cilium.yaml lost its comment:\n%s", data)
+	}
+
+	grafana, err := catalog.Find(dir, "grafana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grafana.RepoURL != ChartRepoURL {
+		t.Errorf("grafana repoURL = %q", grafana.RepoURL)
+	}
+	data, err := catalog.ReadValues(dir, "grafana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vals map[string]map[string]bool
+	if err := yaml.Unmarshal(data, &vals); err != nil || vals["image"]["useDigest"] {
+		t.Errorf("grafana values = %s, %v", data, err)
+	}
+	if qdrant, _ := catalog.Find(dir, "qdrant"); qdrant.RepoURL == ChartRepoURL {
+		t.Error("qdrant is not bundled and must keep its repoURL")
+	}
+
+	out, err := exec.Command("git", "-C", dir, "status", "--porcelain").Output()
+	if err != nil || len(out) != 0 {
+		t.Errorf("uncommitted changes after Localize: %s %v", out, err)
+	}
+}
+
+func TestChartServer(t *testing.T) {
+	t.Parallel()
+	dep, svc := chartServer()
+	pod := dep.Spec.Template.Spec
+	if pod.Volumes[0].HostPath.Path != NodeChartsDir {
+		t.Errorf("hostPath = %q, want %q", pod.Volumes[0].HostPath.Path, NodeChartsDir)
+	}
+	if pod.Containers[0].ImagePullPolicy != "Never" {
+		t.Error("the chart server must only use the preloaded image")
+	}
+	if !strings.HasPrefix(ChartRepoURL, "http://"+svc.Name+"."+svc.Namespace+".svc") {
+		t.Errorf("ChartRepoURL %q does not match service %s/%s", ChartRepoURL, svc.Namespace, svc.Name)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, string(data))
+}
+
+func gitRun(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %s: %v", args, out, err)
+	}
+}
@@ -0,0 +1,277 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/helm"
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
+	k3d "github.com/k3d-io/k3d/v5/pkg/types"
+	"go.uber.org/zap"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// CreateOptions configures bundle creation.
+type CreateOptions struct {
+	// Output is the archive to write.
+	Output           string
+	BootstrapURL     string
+	BootstrapVersion string // tag to clone; "" means HEAD
+	// Apps limits the bundled catalog charts to these apps and their
+	// dependencies; empty bundles the whole catalog.
+	Apps []string
+	// Images are extra images to preload, e.g. agent workloads.
+	Images     []string
+	CLIVersion string
+}
+
+// component is a chart to bundle, with the values it is rendered with to
+// find its images.
+type component struct {
+	name, repoURL, chart, version, namespace string
+	values                                   map[string]interface{}
+}
+
+// Create builds a bundle: it clones the bootstrap repo, downloads every
+// infrastructure, catalog and agent chart, renders them to find their
+// images, and pulls and saves those images together with the k3s and k3d
+// ones. Docker's pull progress is written to out.
+func Create(ctx context.Context, log *zap.Logger, opts CreateOptions, out io.Writer) (*Manifest, error) {
+	work, err := os.MkdirTemp("", "sikifanso-bundle-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(work) }()
+
+	b := &Bundle{Dir: work, Manifest: Manifest{
+		FormatVersion:    FormatVersion,
+		CreatedAt:        time.Now().UTC(),
+		CLIVersion:       opts.CLIVersion,
+		BootstrapURL:     opts.BootstrapURL,
+		BootstrapVersion: opts.BootstrapVersion,
+	}}
+
+	if err := gitops.Scaffold(ctx, log, b.BootstrapDir(), gitops.ScaffoldOptions{
+		RepoURL: opts.BootstrapURL,
+		Version: opts.BootstrapVersion,
+	}); err != nil {
+		return nil, err
+	}
+	// Clusters get a fresh history on scaffold; only the tree is bundled.
+	if err := os.RemoveAll(filepath.Join(b.BootstrapDir(), ".git")); err != nil {
+		return nil, err
+	}
+
+	cfg, err := infraconfig.Load(b.BootstrapDir())
+	if err != nil {
+		return nil, fmt.Errorf("loading infrastructure config: %w", err)
+	}
+	b.Manifest.K3sImage = cfg.Platform.K3sImage
+	release, err := k3sRelease(cfg.Platform.K3sImage)
+	if err != nil {
+		return nil, err
+	}
+
+	comps, err := components(b.BootstrapDir(), cfg, opts.Apps)
+	if err != nil {
+		return nil, err
+	}
+
+	images := map[string]bool{agent.DefaultProbeImage: true}
+	for _, img := range opts.Images {
+		images[img] = true
+	}
+	system, err := k3sImages(ctx, release)
+	if err != nil {
+		return nil, err
+	}
+	for _, img := range system {
+		images[img] = true
+	}
+
+	if err := os.MkdirAll(b.ChartsDir(), 0o755); err != nil {
+		return nil, err
+	}
+	settings := cli.New()
+	for _, c := range comps {
+		ch, chartImages, err := bundleChart(log, settings, b.ChartsDir(), c, kubeVersion(release))
+		if err != nil {
+			return nil, err
+		}
+		b.Manifest.Charts = append(b.Manifest.Charts, *ch)
+		for _, img := range chartImages {
+			images[img] = true
+		}
+	}
+	index, err := repo.IndexDirectory(b.ChartsDir(), "")
+	if err != nil {
+		return nil, fmt.Errorf("indexing charts: %w", err)
+	}
+	index.SortEntries()
+	if err := index.WriteFile(filepath.Join(b.ChartsDir(), "index.yaml"), 0o644); err != nil {
+		return nil, fmt.Errorf("writing chart index: %w", err)
+	}
+
+	b.Manifest.HostImages = []string{
+		cfg.Platform.K3sImage,
+		k3d.GetLoadbalancerImage(),
+		k3d.GetToolsImage(),
+		k3d.DefaultRegistryImageRepo + ":" + k3d.DefaultRegistryImageTag,
+	}
+	b.Manifest.Images = sortedKeys(images)
+
+	d, err := newDocker(out)
+	if err != nil {
+		return nil, err
+	}
+	defer d.close()
+	log.Info("pulling images", zap.Int("host", len(b.Manifest.HostImages)), zap.Int("cluster", len(b.Manifest.Images)))
+	if err := d.pull(ctx, append(append([]string{}, b.Manifest.HostImages...), b.Manifest.Images...)); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.ImagesTar()), 0o755); err != nil {
+		return nil, err
+	}
+	log.Info("saving images")
+	if err := d.save(ctx, b.Manifest.HostImages, filepath.Join(work, filepath.FromSlash(hostImagesTar))); err != nil {
+		return nil, err
+	}
+	if err := d.save(ctx, b.Manifest.Images, b.ImagesTar()); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(b.Manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(work, manifestFile), data, 0o644); err != nil {
+		return nil, err
+	}
+
+	log.Info("writing bundle", zap.String("path", opts.Output))
+	if err := writeArchive(opts.Output, work); err != nil {
+		return nil, err
+	}
+	return &b.Manifest, nil
+}
+
+// components lists the charts a bundle carries: Cilium, ArgoCD, the agent
+// template, and the selected catalog apps with their dependencies.
+func components(bootstrapDir string, cfg *infraconfig.InfraConfig, apps []string) ([]component, error) {
+	comps := []component{
+		{name: "cilium", repoURL: cfg.Cilium.RepoURL, chart: cfg.Cilium.Chart, version: cfg.Cilium.TargetRevision, namespace: cfg.Cilium.Namespace, values: cfg.CiliumValues},
+		{name: "argocd", repoURL: cfg.ArgoCD.RepoURL, chart: cfg.ArgoCD.Chart, version: cfg.ArgoCD.TargetRevision, namespace: cfg.ArgoCD.Namespace, values: cfg.ArgoCDValues},
+		{name: agent.ChartName, repoURL: agent.DefaultChartRepoURL, chart: agent.ChartName, version: agent.DefaultChartVersion, namespace: "default"},
+	}
+
+	entries, err := catalog.List(bootstrapDir)
+	if err != nil {
+		return nil, fmt.Errorf("listing catalog: %w", err)
+	}
+	selected := map[string]bool{}
+	if len(apps) > 0 {
+		resolved, _, err := catalog.ResolveDeps(apps, entries)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range resolved {
+			selected[name] = true
+		}
+	}
+	for _, e := range entries {
+		if len(apps) > 0 && !selected[e.Name] {
+			continue
+		}
+		data, err := catalog.ReadValues(bootstrapDir, e.Name)
+		if err != nil {
+			return nil, err
+		}
+		vals, err := catalog.ParseValues(data)
+		if err != nil {
+			return nil, fmt.Errorf("values for %s: %w", e.Name, err)
+		}
+		comps = append(comps, component{name: e.Name, repoURL: e.RepoURL, chart: e.Chart, version: e.TargetRevision, namespace: e.Namespace, values: vals})
+	}
+	return comps, nil
+}
+
+// bundleChart downloads c's chart into chartsDir and renders it to list
+// its images. A chart that cannot be rendered offline, e.g. because it
+// requires values, is still bundled; its images must then be added with
+// CreateOptions.Images.
+func bundleChart(log *zap.Logger, settings *cli.EnvSettings, chartsDir string, c component, kubeVer string) (*Chart, []string, error) {
+	log.Info("bundling chart", zap.String("component", c.name), zap.String("chart", c.chart), zap.String("version", c.version))
+	path, err := helm.Fetch(settings, c.repoURL, c.chart, c.version)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", c.name, err)
+	}
+	ch, err := loader.Load(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: loading chart: %w", c.name, err)
+	}
+	file := filepath.Base(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(filepath.Join(chartsDir, file), data, 0o644); err != nil {
+		return nil, nil, err
+	}
+
+	bundled := &Chart{
+		Name:     c.name,
+		RepoURL:  c.repoURL,
+		Chart:    c.chart,
+		Version:  ch.Metadata.Version,
+		File:     file,
+		NoDigest: noDigestKeys(ch.Values),
+	}
+	vals := infraconfig.MergeValues(c.values, noDigestValues(bundled.NoDigest))
+	manifests, err := helm.Render(ch, vals, c.name, c.namespace, kubeVer)
+	if err != nil {
+		log.Warn("could not render chart, its images are not bundled", zap.String("component", c.name), zap.Error(err))
+		return bundled, nil, nil
+	}
+	found, err := manifestImages(manifests)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", c.name, err)
+	}
+	images := make([]string, 0, len(found))
+	for _, img := range found {
+		images = append(images, stripDigest(img))
+	}
+	return bundled, images, nil
+}
+
+// writeArchive archives dir to path through a temporary file, so a failed
+// run never leaves a truncated bundle behind.
+func writeArchive(path, dir string) (retErr error) {
+	tmp := path + ".partial"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", path, err)
+	}
+	defer func() {
+		if retErr != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+	if err := archive(f, dir); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
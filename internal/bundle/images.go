@@ -0,0 +1,250 @@
+package bundle
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	dockerimage "github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// k3sImagesURL lists the images k3s runs itself (pause, CoreDNS,
+// local-path-provisioner, metrics-server) for a release.
+const k3sImagesURL = "https://github.com/k3s-io/k3s/releases/download/%s/k3s-images.txt"
+
+// manifestImages returns the container images referenced by rendered
+// manifests: every string value of an "image" key, which covers pod specs
+// and the custom resources that embed one.
+func manifestImages(manifests string) ([]string, error) {
+	seen := map[string]bool{}
+	dec := yamlv3.NewDecoder(strings.NewReader(manifests))
+	for {
+		var doc interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing rendered manifests: %w", err)
+		}
+		collectImages(doc, seen)
+	}
+	return sortedKeys(seen), nil
+}
+
+func collectImages(v interface{}, seen map[string]bool) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if s, ok := child.(string); ok && k == "image" && s != "" {
+				seen[s] = true
+				continue
+			}
+			collectImages(child, seen)
+		}
+	case []interface{}:
+		for _, child := range t {
+			collectImages(child, seen)
+		}
+	}
+}
+
+// noDigestKeys returns the dotted paths of every useDigest: true in a
+// chart's default values. Images pulled and saved by Docker no longer match
+// their registry digest, so bundled charts must reference them by tag.
+func noDigestKeys(values map[string]interface{}) []string {
+	var keys []string
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			switch t := v.(type) {
+			case map[string]interface{}:
+				walk(path, t)
+			case bool:
+				if k == "useDigest" && t {
+					keys = append(keys, path)
+				}
+			}
+		}
+	}
+	walk("", values)
+	sort.Strings(keys)
+	return keys
+}
+
+// noDigestValues turns noDigestKeys output into a values overlay.
+func noDigestValues(keys []string) map[string]interface{} {
+	vals := map[string]interface{}{}
+	for _, k := range keys {
+		m := vals
+		parts := strings.Split(k, ".")
+		for _, p := range parts[:len(parts)-1] {
+			next, ok := m[p].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				m[p] = next
+			}
+			m = next
+		}
+		m[parts[len(parts)-1]] = false
+	}
+	return vals
+}
+
+// stripDigest drops the digest from a reference that also has a tag, so it
+// is pulled and saved under the tag pods reference once digests are off.
+func stripDigest(ref string) string {
+	name, _, ok := strings.Cut(ref, "@")
+	if !ok {
+		return ref
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		return name
+	}
+	return ref
+}
+
+// k3sRelease turns a k3s image tag into its release name:
+// "rancher/k3s:v1.31.5-k3s1" becomes "v1.31.5+k3s1".
+func k3sRelease(k3sImage string) (string, error) {
+	_, tag, ok := strings.Cut(k3sImage[strings.LastIndex(k3sImage, "/")+1:], ":")
+	i := strings.LastIndex(tag, "-k3s")
+	if !ok || i < 0 {
+		return "", fmt.Errorf("cannot tell the k3s release of image %q", k3sImage)
+	}
+	return tag[:i] + "+" + tag[i+1:], nil
+}
+
+// kubeVersion returns the Kubernetes version of a k3s release, for
+// rendering charts that constrain it.
+func kubeVersion(release string) string {
+	v, _, _ := strings.Cut(release, "+")
+	return v
+}
+
+// k3sImages fetches the list of images k3s itself runs for release.
+func k3sImages(ctx context.Context, release string) ([]string, error) {
+	url := fmt.Sprintf(k3sImagesURL, strings.ReplaceAll(release, "+", "%2B"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching k3s image list: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	var images []string
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			images = append(images, line)
+		}
+	}
+	return images, sc.Err()
+}
+
+// docker wraps the Docker calls a bundle needs.
+type docker struct {
+	cli *client.Client
+	out io.Writer
+}
+
+func newDocker(out io.Writer) (*docker, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("connecting to docker: %w", err)
+	}
+	return &docker{cli: cli, out: out}, nil
+}
+
+func (d *docker) close() { _ = d.cli.Close() }
+
+// pull pulls each image, writing Docker's progress to d.out.
+func (d *docker) pull(ctx context.Context, images []string) error {
+	for _, img := range images {
+		progress, err := d.cli.ImagePull(ctx, img, dockerimage.PullOptions{})
+		if err != nil {
+			return fmt.Errorf("pulling %s: %w", img, err)
+		}
+		err = jsonmessage.DisplayJSONMessagesStream(progress, d.out, 0, false, nil)
+		_ = progress.Close()
+		if err != nil {
+			return fmt.Errorf("pulling %s: %w", img, err)
+		}
+	}
+	return nil
+}
+
+// save writes images to a docker save archive at path.
+func (d *docker) save(ctx context.Context, images []string, path string) error {
+	rc, err := d.cli.ImageSave(ctx, images)
+	if err != nil {
+		return fmt.Errorf("saving images: %w", err)
+	}
+	defer func() { _ = rc.Close() }()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("saving images: %w", err)
+	}
+	return f.Close()
+}
+
+// load loads a docker save archive into the host's Docker.
+func (d *docker) load(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	resp, err := d.cli.ImageLoad(ctx, f, true)
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if err := jsonmessage.DisplayJSONMessagesStream(resp.Body, io.Discard, 0, false, nil); err != nil {
+		return fmt.Errorf("loading %s: %w", path, err)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LoadHostImages loads the k3s and k3d images into the host's Docker, so
+// k3d creates the cluster without pulling.
+func (b *Bundle) LoadHostImages(ctx context.Context) error {
+	d, err := newDocker(io.Discard)
+	if err != nil {
+		return err
+	}
+	defer d.close()
+	return d.load(ctx, filepath.Join(b.Dir, filepath.FromSlash(hostImagesTar)))
+}
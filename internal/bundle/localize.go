@@ -0,0 +1,111 @@
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
+)
+
+// infraCharts maps the bundled infrastructure components to their chart
+// config and values files under infra/.
+var infraCharts = map[string][2]string{
+	"cilium": {"cilium.yaml", "cilium-values.yaml"},
+	"argocd": {"argocd.yaml", "argocd-values.yaml"},
+}
+
+// Localize points the gitops repo at the bundled charts: the Cilium and
+// ArgoCD chart configs and every bundled catalog entry get ChartRepoURL as
+// their repoURL, and charts that pin images by digest get their NoDigest
+// overrides in their values files. The changes are committed together.
+// It returns the catalog apps without a bundled chart, which cannot be
+// enabled offline.
+func (b *Bundle) Localize(gitopsDir string) ([]string, error) {
+	l, err := lock.GitOps(gitopsDir)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+	var paths []string
+	for _, name := range []string{"cilium", "argocd"} {
+		ch, ok := b.Chart(name)
+		if !ok {
+			continue
+		}
+		files := infraCharts[name]
+		chartFile := filepath.Join("infra", files[0])
+		if err := editYAML(gitopsDir, chartFile, map[string]string{"repoURL": ChartRepoURL}); err != nil {
+			return nil, err
+		}
+		paths = append(paths, chartFile)
+		if len(ch.NoDigest) > 0 {
+			valuesFile := filepath.Join("infra", files[1])
+			if err := editYAML(gitopsDir, valuesFile, noDigestSet(ch.NoDigest)); err != nil {
+				return nil, err
+			}
+			paths = append(paths, valuesFile)
+		}
+	}
+
+	entries, err := catalog.List(gitopsDir)
+	if err != nil {
+		return nil, fmt.Errorf("listing catalog: %w", err)
+	}
+	var missing []string
+	for _, e := range entries {
+		ch, ok := b.Chart(e.Name)
+		if !ok {
+			missing = append(missing, e.Name)
+			continue
+		}
+		if err := catalog.SetRepoURL(gitopsDir, e.Name, ChartRepoURL); err != nil {
+			return nil, err
+		}
+		paths = append(paths, filepath.Join("catalog", e.Name+".yaml"))
+		if len(ch.NoDigest) > 0 {
+			if err := editYAML(gitopsDir, catalog.ValuesFile(e.Name), noDigestSet(ch.NoDigest)); err != nil {
+				return nil, err
+			}
+			paths = append(paths, catalog.ValuesFile(e.Name))
+		}
+	}
+
+	if len(paths) > 0 {
+		if err := gitops.Commit(gitopsDir, "bundle: serve charts from the offline bundle", paths...); err != nil {
+			return nil, fmt.Errorf("committing bundle charts: %w", err)
+		}
+	}
+	return missing, nil
+}
+
+// editYAML applies dotted-path assignments to a gitops-relative YAML file,
+// creating it when absent.
+func editYAML(gitopsDir, rel string, set map[string]string) error {
+	path := filepath.Join(gitopsDir, rel)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading %s: %w", rel, err)
+	}
+	out, err := catalog.EditValues(data, set, nil)
+	if err != nil {
+		return fmt.Errorf("editing %s: %w", rel, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		return fmt.Errorf("writing %s: %w", rel, err)
+	}
+	return nil
+}
+
+func noDigestSet(keys []string) map[string]string {
+	set := make(map[string]string, len(keys))
+	for _, k := range keys {
+		set[k] = "false"
+	}
+	return set
+}
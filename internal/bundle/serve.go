@@ -0,0 +1,102 @@
+package bundle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	chartServerName      = "sikifanso-charts"
+	chartServerNamespace = "kube-system"
+	chartServerPort      = 8080
+
+	serveTimeout = 2 * time.Minute
+	pollInterval = 2 * time.Second
+)
+
+// ChartRepoURL is the in-cluster Helm repository serving a bundle's charts
+// to ArgoCD.
+var ChartRepoURL = fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", chartServerName, chartServerNamespace, chartServerPort)
+
+// ServeCharts runs the in-cluster chart repository: a static HTTP server
+// over NodeChartsDir, which every node mounts from the unpacked bundle. It
+// uses the preloaded busybox image, so it needs no registry, and waits
+// until the server is available.
+func ServeCharts(ctx context.Context, log *zap.Logger, client kubernetes.Interface) error {
+	dep, svc := chartServer()
+	log.Info("starting chart repository", zap.String("url", ChartRepoURL))
+	if _, err := client.AppsV1().Deployments(chartServerNamespace).Create(ctx, dep, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating chart repository deployment: %w", err)
+	}
+	if _, err := client.CoreV1().Services(chartServerNamespace).Create(ctx, svc, metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating chart repository service: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, serveTimeout)
+	defer cancel()
+	for {
+		d, err := client.AppsV1().Deployments(chartServerNamespace).Get(ctx, chartServerName, metav1.GetOptions{})
+		if err == nil && d.Status.AvailableReplicas > 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for the chart repository; check kubectl -n %s get pods -l app=%s", chartServerNamespace, chartServerName)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// chartServer returns the chart repository's Deployment and Service.
+func chartServer() (*appsv1.Deployment, *corev1.Service) {
+	labels := map[string]string{"app": chartServerName, "app.kubernetes.io/managed-by": "sikifanso"}
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: chartServerName, Namespace: chartServerNamespace, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(int32(1)),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": chartServerName}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:            "httpd",
+						Image:           agent.DefaultProbeImage,
+						ImagePullPolicy: corev1.PullNever,
+						Command:         []string{"httpd", "-f", "-p", fmt.Sprint(chartServerPort), "-h", "/charts"},
+						Ports:           []corev1.ContainerPort{{ContainerPort: chartServerPort}},
+						VolumeMounts:    []corev1.VolumeMount{{Name: "charts", MountPath: "/charts", ReadOnly: true}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "charts",
+						VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{
+							Path: NodeChartsDir,
+							Type: ptr.To(corev1.HostPathDirectory),
+						}},
+					}},
+				},
+			},
+		},
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: chartServerName, Namespace: chartServerNamespace, Labels: labels},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": chartServerName},
+			Ports: []corev1.ServicePort{{
+				Port:       chartServerPort,
+				TargetPort: intstr.FromInt32(chartServerPort),
+			}},
+		},
+	}
+	return dep, svc
+}
@@ -2,6 +2,7 @@ package catalog
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -24,6 +25,36 @@ type Entry struct {
 	Enabled        bool     `json:"enabled"`
 	Tier           string   `json:"tier,omitempty"`
 	DependsOn      []string `json:"dependsOn,omitempty"`
+	// Resources is the app's approximate footprint, used to check that the
+	// enabled set fits the Docker host.
+	Resources *Resources `json:"resources,omitempty"`
+	// AutoEnabledBy lists the apps whose enablement pulled this entry in as
+	// a dependency. Empty means the entry was enabled explicitly (or predates
+	// provenance tracking); such entries are never pruned.
+	AutoEnabledBy []string `json:"autoEnabledBy,omitempty"`
+	// Source names the third-party catalog source the entry was vendored
+	// from; empty for entries that belong to the gitops repo itself.
+	Source string `json:"source,omitempty"`
+}
+
+// Provenance values reported by Entry.Provenance.
+const (
+	ProvenanceExplicit = "explicit"
+	ProvenanceAuto     = "auto"
+)
+
+// Provenance returns ProvenanceAuto for enabled entries that were only pulled
+// in as a dependency, ProvenanceExplicit for other enabled entries, and ""
+// for disabled ones.
+func (e Entry) Provenance() string {
+	switch {
+	case !e.Enabled:
+		return ""
+	case len(e.AutoEnabledBy) > 0:
+		return ProvenanceAuto
+	default:
+		return ProvenanceExplicit
+	}
 }
 
 // CatalogDir returns the path to the catalog directory within gitOpsPath.
@@ -73,6 +104,10 @@ func List(gitOpsPath string) ([]Entry, error) {
 	return apps, nil
 }
 
+// ErrNotFound is returned (wrapped) by Find when the catalog has no entry
+// with the given name.
+var ErrNotFound = errors.New("not found")
+
 // Find returns the catalog entry with the given name.
 // It reads only the target file in the happy path; on miss, it lists all
 // available names in the error message.
@@ -100,13 +135,52 @@ func Find(gitOpsPath, name string) (*Entry, error) {
 	for _, e := range entries {
 		names = append(names, e.Name)
 	}
-	return nil, fmt.Errorf("app %q not found in catalog; available: %s", name, strings.Join(names, ", "))
+	return nil, fmt.Errorf("app %q %w in catalog; available: %s", name, ErrNotFound, strings.Join(names, ", "))
 }
 
 // SetEnabled flips the enabled field of the named catalog entry and writes the
-// updated file back to disk, preserving comments and field order.
+// updated file back to disk, preserving comments and field order. Disabling
+// also drops any autoEnabledBy provenance.
 // It does not commit; the caller is responsible for committing the change.
 func SetEnabled(gitOpsPath, name string, enabled bool) error {
+	return editEntry(gitOpsPath, name, func(doc *yamlv3.Node) error {
+		if err := setEnabledInNode(doc, enabled); err != nil {
+			return fmt.Errorf("updating enabled field: %w", err)
+		}
+		if !enabled {
+			return setAutoEnabledByInNode(doc, nil)
+		}
+		return nil
+	})
+}
+
+// SetAutoEnabledBy records the apps that caused the named entry to be
+// auto-enabled as a dependency. An empty list marks the entry as explicitly
+// enabled by removing the field. Comments and field order are preserved.
+// It does not commit; the caller is responsible for committing the change.
+func SetAutoEnabledBy(gitOpsPath, name string, by []string) error {
+	return editEntry(gitOpsPath, name, func(doc *yamlv3.Node) error {
+		return setAutoEnabledByInNode(doc, by)
+	})
+}
+
+// SetRepoURL points the named entry at another chart repository, e.g. one
+// serving the same charts offline. Comments and field order are preserved.
+// It does not commit; the caller is responsible for committing the change.
+func SetRepoURL(gitOpsPath, name, repoURL string) error {
+	return editEntry(gitOpsPath, name, func(doc *yamlv3.Node) error {
+		mapping, err := entryMapping(doc)
+		if err != nil {
+			return err
+		}
+		setMappingValue(mapping, "repoURL", strScalar(repoURL), "")
+		return nil
+	})
+}
+
+// editEntry reads the named catalog file as a yaml.Node, applies edit, and
+// writes the result back with the repo's two-space indentation.
+func editEntry(gitOpsPath, name string, edit func(doc *yamlv3.Node) error) error {
 	fileName := name + ".yaml"
 	filePath := filepath.Join(CatalogDir(gitOpsPath), fileName)
 
@@ -123,37 +197,56 @@ func SetEnabled(gitOpsPath, name string, enabled bool) error {
 		return fmt.Errorf("parsing catalog file %s: %w", fileName, err)
 	}
 
-	if err := setEnabledInNode(&doc, enabled); err != nil {
-		return fmt.Errorf("updating enabled field in %s: %w", fileName, err)
+	if err := edit(&doc); err != nil {
+		return fmt.Errorf("editing %s: %w", fileName, err)
 	}
 
-	var buf bytes.Buffer
-	enc := yamlv3.NewEncoder(&buf)
-	enc.SetIndent(2)
-	if err := enc.Encode(&doc); err != nil {
+	out, err := encodeNode(&doc)
+	if err != nil {
 		return fmt.Errorf("encoding catalog entry %s: %w", name, err)
 	}
-	if err := enc.Close(); err != nil {
-		return fmt.Errorf("closing encoder for %s: %w", name, err)
-	}
 
-	if err := os.WriteFile(filePath, buf.Bytes(), 0o644); err != nil {
+	if err := os.WriteFile(filePath, out, 0o644); err != nil {
 		return fmt.Errorf("writing catalog file %s: %w", fileName, err)
 	}
 
 	return nil
 }
 
-// setEnabledInNode walks the yaml.Node AST to find the "enabled" key and
-// updates its value in place, preserving comments, field order, and whitespace.
-func setEnabledInNode(doc *yamlv3.Node, enabled bool) error {
+// encodeNode marshals a catalog document with the repo's two-space
+// indentation.
+func encodeNode(doc *yamlv3.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yamlv3.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// entryMapping returns the top-level mapping node of a catalog document.
+func entryMapping(doc *yamlv3.Node) (*yamlv3.Node, error) {
 	if doc.Kind != yamlv3.DocumentNode || len(doc.Content) == 0 {
-		return fmt.Errorf("unexpected YAML structure")
+		return nil, fmt.Errorf("unexpected YAML structure")
 	}
 
 	mapping := doc.Content[0]
 	if mapping.Kind != yamlv3.MappingNode {
-		return fmt.Errorf("expected mapping node, got %d", mapping.Kind)
+		return nil, fmt.Errorf("expected mapping node, got %d", mapping.Kind)
+	}
+	return mapping, nil
+}
+
+// setEnabledInNode walks the yaml.Node AST to find the "enabled" key and
+// updates its value in place, preserving comments, field order, and whitespace.
+func setEnabledInNode(doc *yamlv3.Node, enabled bool) error {
+	mapping, err := entryMapping(doc)
+	if err != nil {
+		return err
 	}
 
 	for i := 0; i < len(mapping.Content)-1; i += 2 {
@@ -170,3 +263,39 @@ func setEnabledInNode(doc *yamlv3.Node, enabled bool) error {
 
 	return fmt.Errorf("enabled field not found")
 }
+
+// setAutoEnabledByInNode replaces the "autoEnabledBy" sequence, inserting it
+// right after "enabled" when absent, or removes the key when by is empty.
+func setAutoEnabledByInNode(doc *yamlv3.Node, by []string) error {
+	mapping, err := entryMapping(doc)
+	if err != nil {
+		return err
+	}
+
+	seq := &yamlv3.Node{Kind: yamlv3.SequenceNode, Tag: "!!seq", Style: yamlv3.FlowStyle}
+	for _, b := range by {
+		seq.Content = append(seq.Content, &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: b})
+	}
+
+	insertAt := len(mapping.Content)
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		switch mapping.Content[i].Value {
+		case "autoEnabledBy":
+			if len(by) == 0 {
+				mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			} else {
+				mapping.Content[i+1] = seq
+			}
+			return nil
+		case "enabled":
+			insertAt = i + 2
+		}
+	}
+	if len(by) == 0 {
+		return nil
+	}
+
+	key := &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: "autoEnabledBy"}
+	mapping.Content = append(mapping.Content[:insertAt], append([]*yamlv3.Node{key, seq}, mapping.Content[insertAt:]...)...)
+	return nil
+}
@@ -280,6 +280,36 @@ enabled: false
 	}
 }
 
+func TestSetRepoURL(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	writeEntry(t, dir, `
+name: grafana
+category: monitoring
+repoURL: https://grafana.github.io/helm-charts # upstream
+chart: grafana
+targetRevision: ">=8.0.0 <9.0.0"
+namespace: monitoring
+enabled: true
+`, "grafana")
+
+	if err := SetRepoURL(dir, "grafana", "http://charts.local:8080"); err != nil {
+		t.Fatalf("SetRepoURL: %v", err)
+	}
+	entry, err := Find(dir, "grafana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.RepoURL != "http://charts.local:8080" || entry.Chart != "grafana" || !entry.Enabled {
+		t.Errorf("entry = %+v, want only repoURL changed", entry)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "catalog", "grafana.yaml"))
+	if !strings.Contains(string(data), "# upstream") {
+		t.Errorf("comment lost:\n%s", data)
+	}
+}
+
 func TestCatalogDir(t *testing.T) {
 	t.Parallel()
 	got := CatalogDir("/some/gitops/path")
@@ -288,3 +318,66 @@ func TestCatalogDir(t *testing.T) {
 		t.Errorf("CatalogDir = %q, want %q", got, want)
 	}
 }
+
+func TestSetAutoEnabledBy_RoundTripPreservesComments(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	writeEntry(t, dir, `# Operator for CloudNativePG clusters
+name: cnpg-operator
+category: storage
+enabled: true # toggled by sikifanso
+tier: 0-operators
+`, "cnpg-operator")
+
+	if err := SetAutoEnabledBy(dir, "cnpg-operator", []string{"langfuse", "temporal"}); err != nil {
+		t.Fatalf("SetAutoEnabledBy: %v", err)
+	}
+	entry, err := Find(dir, "cnpg-operator")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if strings.Join(entry.AutoEnabledBy, ",") != "langfuse,temporal" {
+		t.Errorf("AutoEnabledBy = %v, want [langfuse temporal]", entry.AutoEnabledBy)
+	}
+	if entry.Provenance() != ProvenanceAuto {
+		t.Errorf("Provenance = %q, want %q", entry.Provenance(), ProvenanceAuto)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "catalog", "cnpg-operator.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# Operator for CloudNativePG clusters", "# toggled by sikifanso"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("comment %q lost:\n%s", want, data)
+		}
+	}
+
+	// Clearing marks the entry explicit and removes the key.
+	if err := SetAutoEnabledBy(dir, "cnpg-operator", nil); err != nil {
+		t.Fatalf("SetAutoEnabledBy(nil): %v", err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "catalog", "cnpg-operator.yaml"))
+	if strings.Contains(string(data), "autoEnabledBy") {
+		t.Errorf("autoEnabledBy not removed:\n%s", data)
+	}
+}
+
+func TestSetEnabled_DisableDropsProvenance(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	writeEntry(t, dir, "name: valkey\nenabled: true\nautoEnabledBy: [langfuse]\n", "valkey")
+
+	if err := SetEnabled(dir, "valkey", false); err != nil {
+		t.Fatalf("SetEnabled(false): %v", err)
+	}
+	entry, err := Find(dir, "valkey")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(entry.AutoEnabledBy) != 0 {
+		t.Errorf("AutoEnabledBy = %v after disable, want empty", entry.AutoEnabledBy)
+	}
+}
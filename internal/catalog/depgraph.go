@@ -92,3 +92,81 @@ func Dependents(name string, all []Entry) []string {
 	sort.Strings(deps)
 	return deps
 }
+
+// TransitiveDependents walks the reverse dependency graph from name and
+// returns every enabled entry that depends on it directly or indirectly.
+// The result is in teardown order: highest tier first, then by name.
+func TransitiveDependents(name string, all []Entry) []string {
+	reverse := make(map[string][]Entry, len(all))
+	for _, e := range all {
+		for _, d := range e.DependsOn {
+			reverse[d] = append(reverse[d], e)
+		}
+	}
+
+	seen := map[string]bool{name: true}
+	var found []Entry
+	queue := []string{name}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, e := range reverse[cur] {
+			if seen[e.Name] {
+				continue
+			}
+			seen[e.Name] = true
+			// Walk through disabled entries too: an enabled app two hops away
+			// still breaks when its root dependency goes.
+			queue = append(queue, e.Name)
+			if e.Enabled {
+				found = append(found, e)
+			}
+		}
+	}
+
+	return TeardownOrder(found)
+}
+
+// Orphans returns enabled, auto-enabled entries that no explicitly enabled
+// entry requires any more, in teardown order (highest tier first, then name).
+func Orphans(all []Entry) ([]string, error) {
+	var explicit []string
+	for _, e := range all {
+		if e.Provenance() == ProvenanceExplicit {
+			explicit = append(explicit, e.Name)
+		}
+	}
+	required, _, err := ResolveDeps(explicit, all)
+	if err != nil {
+		return nil, fmt.Errorf("resolving dependencies of explicitly enabled apps: %w", err)
+	}
+	requiredSet := make(map[string]bool, len(required))
+	for _, name := range required {
+		requiredSet[name] = true
+	}
+
+	var orphans []Entry
+	for _, e := range all {
+		if e.Provenance() == ProvenanceAuto && !requiredSet[e.Name] {
+			orphans = append(orphans, e)
+		}
+	}
+	return TeardownOrder(orphans), nil
+}
+
+// TeardownOrder returns the names of entries sorted for disabling: highest
+// tier first so dependents go before what they use, then by name.
+func TeardownOrder(entries []Entry) []string {
+	sorted := append([]Entry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Tier != sorted[j].Tier {
+			return sorted[i].Tier > sorted[j].Tier
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	names := make([]string, 0, len(sorted))
+	for _, e := range sorted {
+		names = append(names, e.Name)
+	}
+	return names
+}
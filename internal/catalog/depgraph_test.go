@@ -145,3 +145,36 @@ func TestDependents_NoDependents(t *testing.T) {
 		t.Errorf("Dependents = %v, want empty", deps)
 	}
 }
+
+func TestTransitiveDependents_WalksReverseGraph(t *testing.T) {
+	t.Parallel()
+	all := []Entry{
+		{Name: "cnpg-operator", Tier: "0-operators", Enabled: true},
+		{Name: "postgresql", Tier: "1-data", Enabled: true, DependsOn: []string{"cnpg-operator"}},
+		{Name: "langfuse", Tier: "2-services", Enabled: true, DependsOn: []string{"postgresql"}},
+		{Name: "ollama", Tier: "2-services", Enabled: true},
+	}
+	got := TransitiveDependents("cnpg-operator", all)
+	want := []string{"langfuse", "postgresql"}
+	if len(got) != len(want) {
+		t.Fatalf("TransitiveDependents = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("TransitiveDependents[%d] = %q, want %q (teardown order)", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTransitiveDependents_SkipsDisabledButWalksThrough(t *testing.T) {
+	t.Parallel()
+	all := []Entry{
+		{Name: "a", Enabled: true},
+		{Name: "b", Enabled: false, DependsOn: []string{"a"}},
+		{Name: "c", Enabled: true, DependsOn: []string{"b"}},
+	}
+	got := TransitiveDependents("a", all)
+	if len(got) != 1 || got[0] != "c" {
+		t.Errorf("TransitiveDependents = %v, want [c]", got)
+	}
+}
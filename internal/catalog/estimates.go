@@ -0,0 +1,41 @@
+package catalog
+
+// estimates approximate the footprint of the default bootstrap catalog's
+// apps with their default values: summed pod requests and persistent volume
+// sizes, rounded up. They stand in for entries that declare no resources so
+// the capacity check counts every well-known app; a declared resources block
+// always wins.
+var estimates = map[string]Resources{
+	"alloy":                     {CPU: "100m", Memory: "256Mi"},
+	"cnpg-operator":             {CPU: "100m", Memory: "256Mi"},
+	"external-secrets":          {CPU: "100m", Memory: "256Mi"},
+	"guardrails-ai":             {CPU: "250m", Memory: "512Mi"},
+	"langfuse":                  {CPU: "1", Memory: "2Gi", Disk: "10Gi"},
+	"litellm-proxy":             {CPU: "250m", Memory: "512Mi"},
+	"loki":                      {CPU: "250m", Memory: "512Mi", Disk: "10Gi"},
+	"nemo-guardrails":           {CPU: "500m", Memory: "1Gi"},
+	"ollama":                    {CPU: "2", Memory: "4Gi", Disk: "20Gi"},
+	"opa":                       {CPU: "100m", Memory: "128Mi"},
+	"postgresql":                {CPU: "250m", Memory: "512Mi", Disk: "8Gi"},
+	"presidio":                  {CPU: "500m", Memory: "1Gi"},
+	"prometheus-stack":          {CPU: "500m", Memory: "2Gi", Disk: "10Gi"},
+	"qdrant":                    {CPU: "250m", Memory: "512Mi", Disk: "5Gi"},
+	"tempo":                     {CPU: "250m", Memory: "512Mi", Disk: "10Gi"},
+	"temporal":                  {CPU: "500m", Memory: "1Gi"},
+	"text-embeddings-inference": {CPU: "1", Memory: "2Gi"},
+	"unstructured":              {CPU: "500m", Memory: "2Gi"},
+	"valkey":                    {CPU: "100m", Memory: "256Mi", Disk: "1Gi"},
+}
+
+// ResourcesFor returns the resources e declares or, failing that, the
+// built-in estimate for its name. estimated reports the latter; r is nil
+// when neither exists.
+func ResourcesFor(e Entry) (r *Resources, estimated bool) {
+	if e.Resources != nil {
+		return e.Resources, false
+	}
+	if est, ok := estimates[e.Name]; ok {
+		return &est, true
+	}
+	return nil, false
+}
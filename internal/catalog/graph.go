@@ -0,0 +1,221 @@
+package catalog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Graph is a renderable view of the catalog dependency graph. Edges point
+// from an app to the app it depends on.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a catalog app in a Graph. Sync and Health are filled from
+// ArgoCD by the caller when a cluster is reachable and are empty otherwise.
+type GraphNode struct {
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+	Tier     string `json:"tier,omitempty"`
+	Enabled  bool   `json:"enabled"`
+	Sync     string `json:"sync,omitempty"`
+	Health   string `json:"health,omitempty"`
+}
+
+// GraphEdge records that From depends on To.
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// BuildGraph returns the dependency graph of the whole catalog, or of the
+// transitive dependency closure of roots when roots is non-empty. Nodes are
+// sorted by tier then name, edges by From then To.
+func BuildGraph(all []Entry, roots []string) (Graph, error) {
+	byName := make(map[string]Entry, len(all))
+	for _, e := range all {
+		byName[e.Name] = e
+	}
+
+	selected := all
+	if len(roots) > 0 {
+		for _, r := range roots {
+			if _, ok := byName[r]; !ok {
+				return Graph{}, fmt.Errorf("app %q not found in catalog", r)
+			}
+		}
+		closure, _, err := ResolveDeps(roots, all)
+		if err != nil {
+			return Graph{}, err
+		}
+		selected = make([]Entry, 0, len(closure))
+		for _, name := range closure {
+			selected = append(selected, byName[name])
+		}
+	}
+
+	var g Graph
+	for _, e := range selected {
+		g.Nodes = append(g.Nodes, GraphNode{
+			Name:     e.Name,
+			Category: e.Category,
+			Tier:     e.Tier,
+			Enabled:  e.Enabled,
+		})
+		for _, d := range e.DependsOn {
+			g.Edges = append(g.Edges, GraphEdge{From: e.Name, To: d})
+		}
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		if g.Nodes[i].Tier != g.Nodes[j].Tier {
+			return g.Nodes[i].Tier < g.Nodes[j].Tier
+		}
+		return g.Nodes[i].Name < g.Nodes[j].Name
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g, nil
+}
+
+// Node states used to colour rendered graphs.
+const (
+	stateDisabled    = "disabled"
+	stateEnabled     = "enabled"
+	stateHealthy     = "healthy"
+	stateProgressing = "progressing"
+	stateDegraded    = "degraded"
+)
+
+// state classifies a node for colouring: disabled apps are grey, enabled
+// apps without live status are blue, and live health maps to green, yellow
+// or red.
+func (n GraphNode) state() string {
+	if !n.Enabled {
+		return stateDisabled
+	}
+	switch n.Health {
+	case "":
+		return stateEnabled
+	case "Healthy":
+		return stateHealthy
+	case "Degraded", "Missing":
+		return stateDegraded
+	default:
+		return stateProgressing
+	}
+}
+
+var dotColors = map[string]string{
+	stateDisabled:    "#e0e0e0",
+	stateEnabled:     "#bbdefb",
+	stateHealthy:     "#c8e6c9",
+	stateProgressing: "#fff9c4",
+	stateDegraded:    "#ffcdd2",
+}
+
+// DOT renders the graph in Graphviz DOT format, one cluster per tier.
+func (g Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph catalog {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\"];\n")
+
+	for i, group := range g.tierGroups() {
+		indent := "  "
+		if group.tier != "" {
+			fmt.Fprintf(&b, "  subgraph cluster_%d {\n", i)
+			fmt.Fprintf(&b, "    label=%q;\n", group.tier)
+			b.WriteString("    style=dashed;\n")
+			indent = "    "
+		}
+		for _, n := range group.nodes {
+			// The label is not %q-quoted so the \n line break reaches Graphviz.
+			fmt.Fprintf(&b, "%s%q [label=\"%s\", fillcolor=%q", indent, n.Name, n.label(`\n`), dotColors[n.state()])
+			if !n.Enabled {
+				b.WriteString(", fontcolor=\"#757575\"")
+			}
+			b.WriteString("];\n")
+		}
+		if group.tier != "" {
+			b.WriteString("  }\n")
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %q -> %q;\n", e.From, e.To)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart, one subgraph per tier.
+// Node and subgraph IDs are positional (n0, t0, ...) so that names differing
+// only in punctuation, like foo-bar and foo_bar, stay separate nodes; the
+// names themselves are the labels.
+func (g Graph) Mermaid() string {
+	ids := make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[n.Name] = fmt.Sprintf("n%d", i)
+	}
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, group := range g.tierGroups() {
+		indent := "  "
+		if group.tier != "" {
+			fmt.Fprintf(&b, "  subgraph t%d[\"%s\"]\n", i, group.tier)
+			indent = "    "
+		}
+		for _, n := range group.nodes {
+			fmt.Fprintf(&b, "%s%s[\"%s\"]:::%s\n", indent, ids[n.Name], n.label("<br/>"), n.state())
+		}
+		if group.tier != "" {
+			b.WriteString("  end\n")
+		}
+	}
+	for _, e := range g.Edges {
+		// Unknown dependencies are not nodes; they get a bare, labelled ID.
+		if _, ok := ids[e.To]; !ok {
+			ids[e.To] = fmt.Sprintf("n%d", len(ids))
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[e.To], e.To)
+		}
+		fmt.Fprintf(&b, "  %s --> %s\n", ids[e.From], ids[e.To])
+	}
+	for _, s := range []string{stateDisabled, stateEnabled, stateHealthy, stateProgressing, stateDegraded} {
+		fmt.Fprintf(&b, "  classDef %s fill:%s,stroke:#616161\n", s, dotColors[s])
+	}
+	return b.String()
+}
+
+// label is the node text: the name plus its live status when known.
+func (n GraphNode) label(sep string) string {
+	switch {
+	case n.Health != "":
+		return n.Name + sep + n.Health
+	case !n.Enabled:
+		return n.Name + sep + stateDisabled
+	default:
+		return n.Name
+	}
+}
+
+type tierGroup struct {
+	tier  string
+	nodes []GraphNode
+}
+
+// tierGroups splits the (tier-sorted) nodes into consecutive groups by tier.
+func (g Graph) tierGroups() []tierGroup {
+	var groups []tierGroup
+	for _, n := range g.Nodes {
+		if len(groups) == 0 || groups[len(groups)-1].tier != n.Tier {
+			groups = append(groups, tierGroup{tier: n.Tier})
+		}
+		groups[len(groups)-1].nodes = append(groups[len(groups)-1].nodes, n)
+	}
+	return groups
+}
@@ -0,0 +1,141 @@
+package catalog
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func graphCatalog() []Entry {
+	return []Entry{
+		{Name: "cnpg-operator", Tier: "0-operators", Enabled: true},
+		{Name: "postgresql", Tier: "1-data", Enabled: true, DependsOn: []string{"cnpg-operator"}},
+		{Name: "valkey", Tier: "1-data", Enabled: false},
+		{Name: "langfuse", Tier: "2-services", Enabled: true, DependsOn: []string{"postgresql", "valkey"}},
+		{Name: "ollama", Tier: "2-services", Enabled: false},
+	}
+}
+
+func TestBuildGraph_WholeCatalog(t *testing.T) {
+	t.Parallel()
+	g, err := BuildGraph(graphCatalog(), nil)
+	if err != nil {
+		t.Fatalf("BuildGraph: %v", err)
+	}
+	var names []string
+	for _, n := range g.Nodes {
+		names = append(names, n.Name)
+	}
+	want := "cnpg-operator,postgresql,valkey,langfuse,ollama"
+	if strings.Join(names, ",") != want {
+		t.Errorf("nodes = %v, want %s (tier, then name)", names, want)
+	}
+	if len(g.Edges) != 3 {
+		t.Errorf("edges = %v, want 3", g.Edges)
+	}
+}
+
+func TestBuildGraph_ClosureOfRoots(t *testing.T) {
+	t.Parallel()
+	g, err := BuildGraph(graphCatalog(), []string{"postgresql"})
+	if err != nil {
+		t.Fatalf("BuildGraph: %v", err)
+	}
+	if len(g.Nodes) != 2 || g.Nodes[0].Name != "cnpg-operator" || g.Nodes[1].Name != "postgresql" {
+		t.Errorf("nodes = %+v, want cnpg-operator and postgresql", g.Nodes)
+	}
+	if len(g.Edges) != 1 || g.Edges[0] != (GraphEdge{From: "postgresql", To: "cnpg-operator"}) {
+		t.Errorf("edges = %+v, want postgresql -> cnpg-operator", g.Edges)
+	}
+}
+
+func TestBuildGraph_UnknownRoot(t *testing.T) {
+	t.Parallel()
+	if _, err := BuildGraph(graphCatalog(), []string{"nope"}); err == nil {
+		t.Fatal("expected error for unknown app, got nil")
+	}
+}
+
+func TestGraph_DOT(t *testing.T) {
+	t.Parallel()
+	g, err := BuildGraph(graphCatalog(), []string{"langfuse"})
+	if err != nil {
+		t.Fatalf("BuildGraph: %v", err)
+	}
+	g.Nodes[0].Health = "Healthy"
+
+	out := g.DOT()
+	for _, want := range []string{
+		"digraph catalog {",
+		`label="0-operators";`,
+		`"cnpg-operator" [label="cnpg-operator\nHealthy", fillcolor="#c8e6c9"]`,
+		`"valkey" [label="valkey\ndisabled", fillcolor="#e0e0e0"`,
+		`"langfuse" -> "postgresql";`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestGraph_Mermaid(t *testing.T) {
+	t.Parallel()
+	g, err := BuildGraph(graphCatalog(), []string{"langfuse"})
+	if err != nil {
+		t.Fatalf("BuildGraph: %v", err)
+	}
+	g.Nodes[0].Health = "Degraded"
+
+	out := g.Mermaid()
+	for _, want := range []string{
+		"flowchart LR",
+		`subgraph t0["0-operators"]`,
+		`n0["cnpg-operator<br/>Degraded"]:::degraded`,
+		`n3["langfuse"]:::enabled`,
+		"n3 --> n1",
+		"classDef healthy",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Mermaid output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestGraph_MermaidIDsAreDistinct(t *testing.T) {
+	t.Parallel()
+	g, err := BuildGraph([]Entry{
+		{Name: "foo-bar", Enabled: true, DependsOn: []string{"missing"}},
+		{Name: "foo_bar", Enabled: true, DependsOn: []string{"foo-bar"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("BuildGraph: %v", err)
+	}
+
+	out := g.Mermaid()
+	for _, want := range []string{
+		`n0["foo-bar"]:::enabled`,
+		`n1["foo_bar"]:::enabled`,
+		`n2["missing"]`,
+		"n0 --> n2",
+		"n1 --> n0",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Mermaid output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestGraph_JSONOmitsUnknownStatus(t *testing.T) {
+	t.Parallel()
+	g, err := BuildGraph([]Entry{{Name: "ollama", Enabled: true}}, nil)
+	if err != nil {
+		t.Fatalf("BuildGraph: %v", err)
+	}
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"nodes":[{"name":"ollama","enabled":true}],"edges":null}`; got != want {
+		t.Errorf("JSON = %s, want %s", got, want)
+	}
+}
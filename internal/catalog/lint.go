@@ -0,0 +1,366 @@
+package catalog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// KnownTiers are the rollout tiers understood by the catalog ApplicationSet's
+// RollingSync strategy, in sync order. Entries without a tier sync last.
+var KnownTiers = []string{"0-operators", "1-data", "2-services"}
+
+// reservedNamespaces are owned by the platform and must not host catalog apps.
+var reservedNamespaces = []string{"argocd", "default", "kube-node-lease", "kube-public", "kube-system"}
+
+// agentNamespacePrefix is reserved for agent sandbox namespaces.
+const agentNamespacePrefix = "agent-"
+
+// Severity grades a lint Issue.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Lint check identifiers reported in Issue.Check.
+const (
+	CheckParse        = "parse"
+	CheckUnknownField = "unknown-field"
+	CheckRequired     = "required-field"
+	CheckDependsOn    = "unknown-dependency"
+	CheckCycle        = "cycle"
+	CheckTier         = "unknown-tier"
+	CheckTierOrder    = "tier-order"
+	CheckDuplicate    = "duplicate-name"
+	CheckFilename     = "filename-mismatch"
+	CheckNamespace    = "namespace"
+	CheckCollision    = "namespace-collision"
+	CheckResources    = "resources"
+)
+
+// Issue is a single problem found by Lint.
+type Issue struct {
+	Severity Severity `json:"severity"`
+	Check    string   `json:"check"`
+	File     string   `json:"file"`
+	App      string   `json:"app,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// HasErrors reports whether any issue has SeverityError.
+func HasErrors(issues []Issue) bool {
+	for _, i := range issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// lintFile is a parsed catalog file together with its path relative to the
+// gitops repo, as reported in Issue.File.
+type lintFile struct {
+	path  string
+	entry Entry
+}
+
+// Lint validates every catalog entry under gitOpsPath and returns the issues
+// found, sorted by file. Unlike List, a malformed file does not abort the run;
+// it is reported and the remaining files are still checked.
+func Lint(gitOpsPath string) ([]Issue, error) {
+	dir := CatalogDir(gitOpsPath)
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading catalog directory: %w", err)
+	}
+
+	var issues []Issue
+	var files []lintFile
+	for _, de := range dirEntries {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), ".yaml") {
+			continue
+		}
+		rel := filepath.Join("catalog", de.Name())
+		data, err := os.ReadFile(filepath.Join(dir, de.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading catalog file %s: %w", de.Name(), err)
+		}
+		var e Entry
+		if err := yaml.Unmarshal(data, &e); err != nil {
+			issues = append(issues, Issue{Severity: SeverityError, Check: CheckParse, File: rel, Message: err.Error()})
+			continue
+		}
+		// A strict pass catches typos like "dependOn" that the lax parse ignores.
+		var strict Entry
+		if err := yaml.UnmarshalStrict(data, &strict); err != nil {
+			issues = append(issues, Issue{Severity: SeverityWarning, Check: CheckUnknownField, File: rel, App: e.Name, Message: err.Error()})
+		}
+		files = append(files, lintFile{path: rel, entry: e})
+	}
+
+	issues = append(issues, lintFields(files)...)
+	issues = append(issues, lintNames(files)...)
+	issues = append(issues, lintGraph(files)...)
+	issues = append(issues, lintNamespaces(files)...)
+	issues = append(issues, lintCollisions(files)...)
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].File < issues[j].File })
+	return issues, nil
+}
+
+func lintFields(files []lintFile) []Issue {
+	var issues []Issue
+	for _, f := range files {
+		e := f.entry
+		required := []struct{ field, value string }{
+			{"name", e.Name},
+			{"category", e.Category},
+			{"description", e.Description},
+			{"repoURL", e.RepoURL},
+			{"chart", e.Chart},
+			{"targetRevision", e.TargetRevision},
+			{"namespace", e.Namespace},
+		}
+		for _, r := range required {
+			if strings.TrimSpace(r.value) == "" {
+				issues = append(issues, Issue{
+					Severity: SeverityError, Check: CheckRequired, File: f.path, App: e.Name,
+					Message: fmt.Sprintf("missing required field %q", r.field),
+				})
+			}
+		}
+		if e.Tier != "" && !slices.Contains(KnownTiers, e.Tier) {
+			issues = append(issues, Issue{
+				Severity: SeverityError, Check: CheckTier, File: f.path, App: e.Name,
+				Message: fmt.Sprintf("unknown tier %q; known tiers: %s", e.Tier, strings.Join(KnownTiers, ", ")),
+			})
+		}
+		if e.Resources != nil {
+			if _, err := e.Resources.Footprint(); err != nil {
+				issues = append(issues, Issue{
+					Severity: SeverityError, Check: CheckResources, File: f.path, App: e.Name,
+					Message: err.Error(),
+				})
+			}
+		}
+	}
+	return issues
+}
+
+func lintNames(files []lintFile) []Issue {
+	var issues []Issue
+	firstFile := make(map[string]string, len(files))
+	for _, f := range files {
+		name := f.entry.Name
+		if name == "" {
+			continue
+		}
+		if stem := strings.TrimSuffix(filepath.Base(f.path), ".yaml"); stem != name {
+			issues = append(issues, Issue{
+				Severity: SeverityError, Check: CheckFilename, File: f.path, App: name,
+				Message: fmt.Sprintf("name %q does not match filename; expected catalog/%s.yaml", name, name),
+			})
+		}
+		if prev, ok := firstFile[name]; ok {
+			issues = append(issues, Issue{
+				Severity: SeverityError, Check: CheckDuplicate, File: f.path, App: name,
+				Message: fmt.Sprintf("name %q is already used by %s", name, prev),
+			})
+			continue
+		}
+		firstFile[name] = f.path
+	}
+	return issues
+}
+
+// lintGraph checks dependsOn targets, tier ordering along dependency edges,
+// and cycles across the whole graph (not only the closure of one app).
+func lintGraph(files []lintFile) []Issue {
+	var issues []Issue
+	byName := make(map[string]lintFile, len(files))
+	for _, f := range files {
+		if _, dup := byName[f.entry.Name]; !dup && f.entry.Name != "" {
+			byName[f.entry.Name] = f
+		}
+	}
+
+	for _, f := range files {
+		e := f.entry
+		for _, dep := range e.DependsOn {
+			target, ok := byName[dep]
+			if !ok {
+				issues = append(issues, Issue{
+					Severity: SeverityError, Check: CheckDependsOn, File: f.path, App: e.Name,
+					Message: fmt.Sprintf("dependsOn %q: no such catalog entry", dep),
+				})
+				continue
+			}
+			if msg := tierOrderProblem(e, target.entry); msg != "" {
+				issues = append(issues, Issue{
+					Severity: SeverityWarning, Check: CheckTierOrder, File: f.path, App: e.Name, Message: msg,
+				})
+			}
+		}
+	}
+
+	for _, cycle := range findCycles(byName) {
+		f := byName[cycle[0]]
+		issues = append(issues, Issue{
+			Severity: SeverityError, Check: CheckCycle, File: f.path, App: cycle[0],
+			Message: "dependency cycle: " + strings.Join(append(cycle, cycle[0]), " -> "),
+		})
+	}
+	return issues
+}
+
+// tierOrderProblem explains why a dependency edge would sync in the wrong
+// order under RollingSync, or returns "" when the tiers are consistent.
+func tierOrderProblem(e, dep Entry) string {
+	if e.Tier == "" {
+		return "" // untiered apps sync last, after every dependency
+	}
+	if dep.Tier == "" {
+		return fmt.Sprintf("depends on untiered %s, which syncs after tier %s", dep.Name, e.Tier)
+	}
+	ei, di := slices.Index(KnownTiers, e.Tier), slices.Index(KnownTiers, dep.Tier)
+	if ei >= 0 && di > ei {
+		return fmt.Sprintf("tier %s depends on %s in later tier %s", e.Tier, dep.Name, dep.Tier)
+	}
+	return ""
+}
+
+// findCycles returns each distinct dependency cycle once, rotated so the
+// lexically smallest member comes first.
+func findCycles(byName map[string]lintFile) [][]string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(byName))
+	seen := make(map[string]bool)
+	var cycles [][]string
+	var stack []string
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, dep := range byName[name].entry.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				continue
+			}
+			switch state[dep] {
+			case unvisited:
+				visit(dep)
+			case visiting:
+				start := slices.Index(stack, dep)
+				cycle := canonicalCycle(stack[start:])
+				if key := strings.Join(cycle, ","); !seen[key] {
+					seen[key] = true
+					cycles = append(cycles, cycle)
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+	}
+
+	names := make([]string, 0, len(byName))
+	for n := range byName {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if state[n] == unvisited {
+			visit(n)
+		}
+	}
+	return cycles
+}
+
+func canonicalCycle(path []string) []string {
+	minIdx := 0
+	for i, n := range path {
+		if n < path[minIdx] {
+			minIdx = i
+		}
+	}
+	out := make([]string, 0, len(path))
+	out = append(out, path[minIdx:]...)
+	return append(out, path[:minIdx]...)
+}
+
+func lintNamespaces(files []lintFile) []Issue {
+	var issues []Issue
+	for _, f := range files {
+		ns := f.entry.Namespace
+		switch {
+		case ns == "":
+			continue
+		case slices.Contains(reservedNamespaces, ns):
+			issues = append(issues, Issue{
+				Severity: SeverityError, Check: CheckNamespace, File: f.path, App: f.entry.Name,
+				Message: fmt.Sprintf("namespace %q is reserved for the platform", ns),
+			})
+		case strings.HasPrefix(ns, agentNamespacePrefix):
+			issues = append(issues, Issue{
+				Severity: SeverityError, Check: CheckNamespace, File: f.path, App: f.entry.Name,
+				Message: fmt.Sprintf("namespace %q collides with the %s* prefix reserved for agent sandboxes", ns, agentNamespacePrefix),
+			})
+		}
+	}
+	return issues
+}
+
+// lintCollisions reports apps that deploy to the same namespace, where their
+// resources can overwrite each other. It is an error for apps enabled
+// together and a warning while at most one of them is enabled.
+func lintCollisions(files []lintFile) []Issue {
+	byNS := map[string][]lintFile{}
+	for _, f := range files {
+		if ns := f.entry.Namespace; ns != "" {
+			byNS[ns] = append(byNS[ns], f)
+		}
+	}
+	var issues []Issue
+	for ns, group := range byNS {
+		if len(group) < 2 {
+			continue
+		}
+		enabled := 0
+		for _, f := range group {
+			if f.entry.Enabled {
+				enabled++
+			}
+		}
+		for _, f := range group {
+			var others []string
+			for _, o := range group {
+				if o.path != f.path {
+					others = append(others, o.entry.Name)
+				}
+			}
+			issue := Issue{
+				Severity: SeverityWarning, Check: CheckCollision, File: f.path, App: f.entry.Name,
+				Message: fmt.Sprintf("namespace %q is also used by %s", ns, strings.Join(others, ", ")),
+			}
+			if f.entry.Enabled && enabled > 1 {
+				issue.Severity = SeverityError
+				issue.Message += ", enabled at the same time"
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
@@ -0,0 +1,193 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+)
+
+// validEntry returns a catalog file body that passes every lint check.
+func validEntry(name, extra string) string {
+	return "name: " + name + "\ncategory: test\ndescription: test\nrepoURL: https://example.com\nchart: " + name +
+		"\ntargetRevision: \"1.0.0\"\nnamespace: " + name + "\nenabled: false\n" + extra
+}
+
+// issuesFor returns the issues with the given check identifier.
+func issuesFor(issues []Issue, check string) []Issue {
+	var out []Issue
+	for _, i := range issues {
+		if i.Check == check {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func TestLint_CleanCatalog(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, validEntry("cnpg-operator", "tier: 0-operators\n"), "cnpg-operator")
+	writeEntry(t, dir, validEntry("postgresql", "tier: 1-data\ndependsOn: [cnpg-operator]\n"), "postgresql")
+
+	issues, err := Lint(dir)
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("Lint = %+v, want no issues", issues)
+	}
+}
+
+func TestLint_RequiredFields(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, "name: bare\nenabled: false\n", "bare")
+
+	issues, err := Lint(dir)
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	// category, description, repoURL, chart, targetRevision, namespace
+	if got := len(issuesFor(issues, CheckRequired)); got != 6 {
+		t.Errorf("got %d required-field issues, want 6: %+v", got, issues)
+	}
+	if !HasErrors(issues) {
+		t.Error("HasErrors = false, want true")
+	}
+}
+
+func TestLint_ParseErrorDoesNotAbort(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, "name: [unterminated\n", "broken")
+	writeEntry(t, dir, validEntry("ok", "dependsOn: [missing]\n"), "ok")
+
+	issues, err := Lint(dir)
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	if len(issuesFor(issues, CheckParse)) != 1 {
+		t.Errorf("want 1 parse issue, got %+v", issues)
+	}
+	if len(issuesFor(issues, CheckDependsOn)) != 1 {
+		t.Errorf("want 1 unknown-dependency issue for ok.yaml, got %+v", issues)
+	}
+}
+
+func TestLint_CycleAnywhereInGraph(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, validEntry("a", "dependsOn: [b]\n"), "a")
+	writeEntry(t, dir, validEntry("b", "dependsOn: [c]\n"), "b")
+	writeEntry(t, dir, validEntry("c", "dependsOn: [a]\n"), "c")
+	writeEntry(t, dir, validEntry("d", "dependsOn: [a]\n"), "d")
+
+	issues, err := Lint(dir)
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	cycles := issuesFor(issues, CheckCycle)
+	if len(cycles) != 1 {
+		t.Fatalf("want exactly 1 cycle issue, got %+v", cycles)
+	}
+	if !strings.Contains(cycles[0].Message, "a -> b -> c -> a") {
+		t.Errorf("cycle message = %q", cycles[0].Message)
+	}
+}
+
+func TestLint_Tiers(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, validEntry("op", "tier: 9-bogus\n"), "op")
+	writeEntry(t, dir, validEntry("db", "tier: 2-services\n"), "db")
+	writeEntry(t, dir, validEntry("svc", "tier: 1-data\ndependsOn: [db]\n"), "svc")
+
+	issues, err := Lint(dir)
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	if len(issuesFor(issues, CheckTier)) != 1 {
+		t.Errorf("want 1 unknown-tier issue, got %+v", issues)
+	}
+	order := issuesFor(issues, CheckTierOrder)
+	if len(order) != 1 || order[0].App != "svc" {
+		t.Errorf("want 1 tier-order issue for svc, got %+v", order)
+	}
+}
+
+func TestLint_NamesAndNamespaces(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, validEntry("grafana", ""), "grafana")
+	writeEntry(t, dir, validEntry("grafana", ""), "grafana-copy")
+	writeEntry(t, dir, strings.Replace(validEntry("sys", ""), "namespace: sys", "namespace: kube-system", 1), "sys")
+	writeEntry(t, dir, strings.Replace(validEntry("sneaky", ""), "namespace: sneaky", "namespace: agent-sneaky", 1), "sneaky")
+	writeEntry(t, dir, validEntry("typo", "dependOn: [grafana]\n"), "typo")
+
+	issues, err := Lint(dir)
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	if len(issuesFor(issues, CheckDuplicate)) != 1 {
+		t.Errorf("want 1 duplicate-name issue, got %+v", issues)
+	}
+	if len(issuesFor(issues, CheckFilename)) != 1 {
+		t.Errorf("want 1 filename-mismatch issue, got %+v", issues)
+	}
+	if len(issuesFor(issues, CheckNamespace)) != 2 {
+		t.Errorf("want 2 namespace issues, got %+v", issues)
+	}
+	unknown := issuesFor(issues, CheckUnknownField)
+	if len(unknown) != 1 || unknown[0].Severity != SeverityWarning {
+		t.Errorf("want 1 unknown-field warning, got %+v", unknown)
+	}
+}
+
+func TestLint_Resources(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, validEntry("ok", "resources:\n  cpu: 500m\n  memory: 1Gi\n  disk: 10Gi\n"), "ok")
+	writeEntry(t, dir, validEntry("bad", "resources:\n  memory: lots\n"), "bad")
+
+	issues, err := Lint(dir)
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	got := issuesFor(issues, CheckResources)
+	if len(got) != 1 || got[0].App != "bad" {
+		t.Errorf("resources issues = %+v, want one for bad", got)
+	}
+}
+
+func TestLint_NamespaceCollisions(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	shared := func(name string, enabled bool) string {
+		body := strings.Replace(validEntry(name, ""), "namespace: "+name, "namespace: shared", 1)
+		if enabled {
+			body = strings.Replace(body, "enabled: false", "enabled: true", 1)
+		}
+		return body
+	}
+	writeEntry(t, dir, shared("alpha", true), "alpha")
+	writeEntry(t, dir, shared("beta", true), "beta")
+	writeEntry(t, dir, shared("gamma", false), "gamma")
+	writeEntry(t, dir, validEntry("delta", ""), "delta")
+
+	issues, err := Lint(dir)
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	severity := map[string]Severity{}
+	for _, i := range issuesFor(issues, CheckCollision) {
+		severity[i.App] = i.Severity
+	}
+	want := map[string]Severity{"alpha": SeverityError, "beta": SeverityError, "gamma": SeverityWarning}
+	if len(severity) != len(want) {
+		t.Fatalf("collision issues = %+v, want alpha, beta and gamma", issues)
+	}
+	for app, sev := range want {
+		if severity[app] != sev {
+			t.Errorf("%s collision severity = %q, want %q", app, severity[app], sev)
+		}
+	}
+}
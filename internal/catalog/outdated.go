@@ -0,0 +1,207 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// VersionLister returns the published versions of a chart, e.g.
+// helm.ChartVersions.
+type VersionLister func(ctx context.Context, repoURL, chart string) ([]string, error)
+
+// VersionReport compares an entry's targetRevision with the chart versions
+// published upstream.
+type VersionReport struct {
+	Name           string `json:"name"`
+	Chart          string `json:"chart"`
+	TargetRevision string `json:"targetRevision"`
+	// Current is the newest published version targetRevision selects.
+	Current string `json:"current,omitempty"`
+	// Latest is the newest published stable version.
+	Latest    string `json:"latest,omitempty"`
+	Outdated  bool   `json:"outdated"`
+	MajorBump bool   `json:"majorBump"`
+	Error     string `json:"error,omitempty"`
+}
+
+// versionCheckConcurrency bounds parallel repository lookups.
+const versionCheckConcurrency = 8
+
+// CheckVersions reports, for each entry, which version its targetRevision
+// selects and whether a newer one is published. Lookup failures are recorded
+// in VersionReport.Error rather than failing the whole report. Each chart is
+// looked up once even when several entries share it.
+func CheckVersions(ctx context.Context, entries []Entry, list VersionLister) []VersionReport {
+	type result struct {
+		versions []string
+		err      error
+	}
+	var (
+		lookups = map[string]*result{}
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, versionCheckConcurrency)
+	)
+	key := func(e Entry) string { return e.RepoURL + "\x00" + e.Chart }
+	for _, e := range entries {
+		k := key(e)
+		if _, ok := lookups[k]; ok {
+			continue
+		}
+		r := &result{}
+		lookups[k] = r
+		wg.Add(1)
+		go func(repoURL, chart string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			r.versions, r.err = list(ctx, repoURL, chart)
+		}(e.RepoURL, e.Chart)
+	}
+	wg.Wait()
+
+	reports := make([]VersionReport, 0, len(entries))
+	for _, e := range entries {
+		r := lookups[key(e)]
+		report := VersionReport{Name: e.Name, Chart: e.Chart, TargetRevision: e.TargetRevision}
+		if r.err != nil {
+			report.Error = r.err.Error()
+		} else {
+			compareVersions(&report, r.versions)
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	return reports
+}
+
+// compareVersions fills Current, Latest, Outdated and MajorBump from the
+// published versions. Unparseable versions are ignored.
+func compareVersions(report *VersionReport, published []string) {
+	var stable, all []*semver.Version
+	for _, v := range published {
+		sv, err := semver.NewVersion(v)
+		if err != nil {
+			continue
+		}
+		all = append(all, sv)
+		if sv.Prerelease() == "" {
+			stable = append(stable, sv)
+		}
+	}
+	if len(stable) == 0 {
+		report.Error = "no stable versions published"
+		return
+	}
+	sort.Sort(semver.Collection(stable))
+	latest := stable[len(stable)-1]
+	report.Latest = latest.Original()
+
+	rev := strings.TrimSpace(report.TargetRevision)
+	if rev == "" || rev == "*" {
+		// Unpinned entries always track the newest chart.
+		report.Current = report.Latest
+		return
+	}
+
+	var current *semver.Version
+	if pinned, err := semver.StrictNewVersion(strings.TrimPrefix(rev, "v")); err == nil {
+		current = pinned
+	} else {
+		constraint, err := semver.NewConstraint(rev)
+		if err != nil {
+			report.Error = fmt.Sprintf("invalid targetRevision %q: %v", rev, err)
+			return
+		}
+		sort.Sort(semver.Collection(all))
+		for i := len(all) - 1; i >= 0; i-- {
+			if constraint.Check(all[i]) {
+				current = all[i]
+				break
+			}
+		}
+		if current == nil {
+			report.Error = fmt.Sprintf("no published version matches %q", rev)
+			return
+		}
+	}
+	report.Current = current.Original()
+	report.Outdated = latest.GreaterThan(current)
+	report.MajorBump = report.Outdated && latest.Major() > current.Major()
+}
+
+// BumpedRevision returns a targetRevision that selects latest while keeping
+// the style of old: exact pins stay exact, and ranges become a window over
+// latest's major version (">=85.0.0 <86.0.0") so patch and minor releases
+// keep flowing in.
+func BumpedRevision(old, latest string) (string, error) {
+	v, err := semver.NewVersion(latest)
+	if err != nil {
+		return "", fmt.Errorf("parsing version %q: %w", latest, err)
+	}
+	if _, err := semver.StrictNewVersion(strings.TrimPrefix(strings.TrimSpace(old), "v")); err == nil {
+		return v.Original(), nil
+	}
+	return fmt.Sprintf(">=%d.0.0 <%d.0.0", v.Major(), v.Major()+1), nil
+}
+
+// RevisionBump changes an entry's targetRevision.
+type RevisionBump struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ApplyRevisionBumps rewrites targetRevision of each named entry in place,
+// preserving comments and field order, and commits all changes together.
+func ApplyRevisionBumps(gitOpsPath string, bumps []RevisionBump) error {
+	if len(bumps) == 0 {
+		return nil
+	}
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	paths := make([]string, 0, len(bumps))
+	summary := make([]string, 0, len(bumps))
+	for _, b := range bumps {
+		if err := editEntry(gitOpsPath, b.Name, func(doc *yamlv3.Node) error {
+			return setTargetRevisionInNode(doc, b.To)
+		}); err != nil {
+			return err
+		}
+		paths = append(paths, fmt.Sprintf("catalog/%s.yaml", b.Name))
+		summary = append(summary, fmt.Sprintf("%s to %s", b.Name, b.To))
+	}
+	msg := "catalog: upgrade " + strings.Join(summary, ", ")
+	if err := gitops.Commit(gitOpsPath, msg, paths...); err != nil {
+		return fmt.Errorf("committing upgrades: %w", err)
+	}
+	return nil
+}
+
+// setTargetRevisionInNode replaces the "targetRevision" value, quoting it
+// since ranges like ">=1.0.0 <2.0.0" are not plain YAML scalars.
+func setTargetRevisionInNode(doc *yamlv3.Node, rev string) error {
+	mapping, err := entryMapping(doc)
+	if err != nil {
+		return err
+	}
+	node := mappingValue(mapping, "targetRevision")
+	if node == nil {
+		return fmt.Errorf("targetRevision field not found")
+	}
+	node.Value = rev
+	node.Tag = "!!str"
+	node.Style = yamlv3.DoubleQuotedStyle
+	return nil
+}
@@ -0,0 +1,146 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicanalbayrak/sikifanso/internal/helm"
+)
+
+const testIndex = `apiVersion: v1
+entries:
+  grafana:
+    - version: 9.1.0
+    - version: 8.5.2
+    - version: 8.4.0
+  loki:
+    - version: 6.2.0
+    - version: 6.3.0-rc.1
+  tempo:
+    - version: 1.10.0
+`
+
+// serveIndex starts an HTTP Helm repository serving testIndex.
+func serveIndex(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, testIndex)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestCheckVersions_AgainstHTTPIndex(t *testing.T) {
+	t.Parallel()
+	repo := serveIndex(t)
+	entries := []Entry{
+		{Name: "grafana", RepoURL: repo, Chart: "grafana", TargetRevision: ">=8.0.0 <9.0.0"},
+		{Name: "loki", RepoURL: repo, Chart: "loki", TargetRevision: "6.2.0"},
+		{Name: "tempo", RepoURL: repo, Chart: "tempo", TargetRevision: "1.9.0"},
+		{Name: "missing", RepoURL: repo, Chart: "missing", TargetRevision: "1.0.0"},
+	}
+
+	reports := CheckVersions(context.Background(), entries, helm.ChartVersions)
+	byName := make(map[string]VersionReport, len(reports))
+	for _, r := range reports {
+		byName[r.Name] = r
+	}
+
+	g := byName["grafana"]
+	if g.Current != "8.5.2" || g.Latest != "9.1.0" || !g.Outdated || !g.MajorBump {
+		t.Errorf("grafana = %+v, want current 8.5.2, latest 9.1.0, outdated major bump", g)
+	}
+	if l := byName["loki"]; l.Outdated || l.Latest != "6.2.0" {
+		t.Errorf("loki = %+v, want up to date (prereleases ignored)", l)
+	}
+	if tm := byName["tempo"]; !tm.Outdated || tm.MajorBump {
+		t.Errorf("tempo = %+v, want outdated minor bump", tm)
+	}
+	if m := byName["missing"]; m.Error == "" {
+		t.Errorf("missing = %+v, want lookup error", m)
+	}
+}
+
+func TestCheckVersions_LooksUpEachChartOnce(t *testing.T) {
+	t.Parallel()
+	calls := 0
+	list := func(_ context.Context, _, _ string) ([]string, error) {
+		calls++
+		return []string{"1.0.0"}, nil
+	}
+	entries := []Entry{
+		{Name: "a", RepoURL: "https://x", Chart: "c", TargetRevision: "1.0.0"},
+		{Name: "b", RepoURL: "https://x", Chart: "c", TargetRevision: "*"},
+	}
+	reports := CheckVersions(context.Background(), entries, list)
+	if calls != 1 {
+		t.Errorf("lister called %d times, want 1", calls)
+	}
+	for _, r := range reports {
+		if r.Outdated || r.Current != "1.0.0" {
+			t.Errorf("%s = %+v, want current 1.0.0 and up to date", r.Name, r)
+		}
+	}
+}
+
+func TestBumpedRevision(t *testing.T) {
+	t.Parallel()
+	tests := []struct{ old, latest, want string }{
+		{"1.9.0", "1.10.0", "1.10.0"},
+		{">=8.0.0 <9.0.0", "9.1.0", ">=9.0.0 <10.0.0"},
+		{"~8.4.0", "8.5.2", ">=8.0.0 <9.0.0"},
+	}
+	for _, tt := range tests {
+		got, err := BumpedRevision(tt.old, tt.latest)
+		if err != nil {
+			t.Fatalf("BumpedRevision(%q, %q): %v", tt.old, tt.latest, err)
+		}
+		if got != tt.want {
+			t.Errorf("BumpedRevision(%q, %q) = %q, want %q", tt.old, tt.latest, got, tt.want)
+		}
+	}
+}
+
+func TestApplyRevisionBumps_RewritesInPlaceAndCommits(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, `name: grafana
+chart: grafana
+# pinned to the 8.x line
+targetRevision: ">=8.0.0 <9.0.0"
+enabled: true
+`, "grafana")
+	initGitRepo(t, dir)
+
+	err := ApplyRevisionBumps(dir, []RevisionBump{{Name: "grafana", From: ">=8.0.0 <9.0.0", To: ">=9.0.0 <10.0.0"}})
+	if err != nil {
+		t.Fatalf("ApplyRevisionBumps: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "catalog", "grafana.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# pinned to the 8.x line\ntargetRevision: \">=9.0.0 <10.0.0\"") {
+		t.Errorf("targetRevision not rewritten in place:\n%s", data)
+	}
+
+	out, err := exec.Command("git", "-C", dir, "log", "-1", "--format=%s").Output()
+	if err != nil {
+		t.Fatalf("git log: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "catalog: upgrade grafana to >=9.0.0 <10.0.0" {
+		t.Errorf("commit subject = %q", got)
+	}
+}
@@ -0,0 +1,132 @@
+package catalog
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Resources is the approximate footprint of an app once it is running: the
+// summed requests of its pods and the size of its persistent volumes, as
+// Kubernetes quantities ("500m", "2Gi"). It is metadata for capacity
+// preflight checks and does not change what the chart deploys.
+type Resources struct {
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+	Disk   string `json:"disk,omitempty"`
+}
+
+// Footprint is a parsed resource amount.
+type Footprint struct {
+	CPUMillis   int64 `json:"cpuMillis"`
+	MemoryBytes int64 `json:"memoryBytes"`
+	DiskBytes   int64 `json:"diskBytes"`
+}
+
+// Add returns the sum of f and o.
+func (f Footprint) Add(o Footprint) Footprint {
+	return Footprint{
+		CPUMillis:   f.CPUMillis + o.CPUMillis,
+		MemoryBytes: f.MemoryBytes + o.MemoryBytes,
+		DiskBytes:   f.DiskBytes + o.DiskBytes,
+	}
+}
+
+// Footprint parses the declared quantities. Missing fields count as zero.
+func (r Resources) Footprint() (Footprint, error) {
+	var f Footprint
+	for _, q := range []struct {
+		field, value string
+		set          func(resource.Quantity)
+	}{
+		{"cpu", r.CPU, func(q resource.Quantity) { f.CPUMillis = q.MilliValue() }},
+		{"memory", r.Memory, func(q resource.Quantity) { f.MemoryBytes = q.Value() }},
+		{"disk", r.Disk, func(q resource.Quantity) { f.DiskBytes = q.Value() }},
+	} {
+		if q.value == "" {
+			continue
+		}
+		parsed, err := resource.ParseQuantity(q.value)
+		if err != nil {
+			return Footprint{}, fmt.Errorf("invalid resources.%s %q: %w", q.field, q.value, err)
+		}
+		q.set(parsed)
+	}
+	return f, nil
+}
+
+// AppFootprint is the footprint of a single catalog entry.
+type AppFootprint struct {
+	Name string `json:"name"`
+	// Estimated is set when the entry declares no resources and the
+	// built-in estimate was used instead.
+	Estimated bool `json:"estimated,omitempty"`
+	Footprint
+}
+
+// FootprintSummary totals the footprint of a set of entries. Entries with
+// neither declared resources nor a built-in estimate are listed in Unknown
+// and contribute nothing.
+type FootprintSummary struct {
+	Apps    []AppFootprint `json:"apps"`
+	Total   Footprint      `json:"total"`
+	Unknown []string       `json:"unknown,omitempty"`
+}
+
+// SumFootprint adds up the resources of entries (see ResourcesFor), largest
+// memory consumers first.
+func SumFootprint(entries []Entry) (FootprintSummary, error) {
+	var s FootprintSummary
+	for _, e := range entries {
+		r, estimated := ResourcesFor(e)
+		if r == nil {
+			s.Unknown = append(s.Unknown, e.Name)
+			continue
+		}
+		f, err := r.Footprint()
+		if err != nil {
+			return FootprintSummary{}, fmt.Errorf("%s: %w", e.Name, err)
+		}
+		s.Apps = append(s.Apps, AppFootprint{Name: e.Name, Estimated: estimated, Footprint: f})
+		s.Total = s.Total.Add(f)
+	}
+	sort.SliceStable(s.Apps, func(i, j int) bool {
+		if s.Apps[i].MemoryBytes != s.Apps[j].MemoryBytes {
+			return s.Apps[i].MemoryBytes > s.Apps[j].MemoryBytes
+		}
+		return s.Apps[i].Name < s.Apps[j].Name
+	})
+	sort.Strings(s.Unknown)
+	return s, nil
+}
+
+// EnabledAfter returns the entries of all that are enabled once enable are
+// turned on and disable turned off, without touching the gitops repo.
+func EnabledAfter(all []Entry, enable, disable []string) []Entry {
+	on := make(map[string]bool, len(enable))
+	for _, n := range enable {
+		on[n] = true
+	}
+	off := make(map[string]bool, len(disable))
+	for _, n := range disable {
+		off[n] = true
+	}
+	var out []Entry
+	for _, e := range all {
+		if (e.Enabled || on[e.Name]) && !off[e.Name] {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// FormatCPU renders millicores as cores, e.g. 2500 -> "2.5".
+func FormatCPU(millis int64) string {
+	return fmt.Sprintf("%.1f", float64(millis)/1000)
+}
+
+// FormatBytes renders a byte count in GiB, e.g. "6.5Gi".
+func FormatBytes(b int64) string {
+	return fmt.Sprintf("%.1fGi", float64(b)/(1<<30))
+}
@@ -0,0 +1,84 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSumFootprint(t *testing.T) {
+	t.Parallel()
+	entries := []Entry{
+		{Name: "ollama", Resources: &Resources{CPU: "2", Memory: "4Gi", Disk: "20Gi"}},
+		{Name: "valkey", Resources: &Resources{CPU: "100m", Memory: "128Mi"}},
+		{Name: "my-app"},
+	}
+	s, err := SumFootprint(entries)
+	if err != nil {
+		t.Fatalf("SumFootprint: %v", err)
+	}
+	want := Footprint{CPUMillis: 2100, MemoryBytes: 4<<30 + 128<<20, DiskBytes: 20 << 30}
+	if s.Total != want {
+		t.Errorf("Total = %+v, want %+v", s.Total, want)
+	}
+	if len(s.Apps) != 2 || s.Apps[0].Name != "ollama" {
+		t.Errorf("Apps = %+v, want ollama first", s.Apps)
+	}
+	if strings.Join(s.Unknown, ",") != "my-app" {
+		t.Errorf("Unknown = %v, want [my-app]", s.Unknown)
+	}
+
+	if _, err := SumFootprint([]Entry{{Name: "bad", Resources: &Resources{CPU: "two"}}}); err == nil {
+		t.Error("expected error for invalid quantity")
+	}
+}
+
+func TestSumFootprint_UsesEstimates(t *testing.T) {
+	t.Parallel()
+	entries := []Entry{
+		{Name: "opa"},
+		{Name: "valkey", Resources: &Resources{CPU: "1", Memory: "1Gi"}},
+	}
+	s, err := SumFootprint(entries)
+	if err != nil {
+		t.Fatalf("SumFootprint: %v", err)
+	}
+	if len(s.Unknown) != 0 {
+		t.Errorf("Unknown = %v, want none", s.Unknown)
+	}
+	got := map[string]AppFootprint{}
+	for _, a := range s.Apps {
+		got[a.Name] = a
+	}
+	if a := got["opa"]; !a.Estimated || a.CPUMillis != 100 || a.MemoryBytes != 128<<20 {
+		t.Errorf("opa = %+v, want the built-in estimate", a)
+	}
+	if a := got["valkey"]; a.Estimated || a.CPUMillis != 1000 {
+		t.Errorf("valkey = %+v, want the declared resources", a)
+	}
+}
+
+func TestEstimatesParse(t *testing.T) {
+	t.Parallel()
+	for name, r := range estimates {
+		if _, err := r.Footprint(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestEnabledAfter(t *testing.T) {
+	t.Parallel()
+	all := []Entry{
+		{Name: "a", Enabled: true},
+		{Name: "b", Enabled: true},
+		{Name: "c"},
+		{Name: "d"},
+	}
+	var names []string
+	for _, e := range EnabledAfter(all, []string{"c"}, []string{"b"}) {
+		names = append(names, e.Name)
+	}
+	if got := strings.Join(names, ","); got != "a,c" {
+		t.Errorf("EnabledAfter = %s, want a,c", got)
+	}
+}
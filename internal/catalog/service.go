@@ -2,9 +2,11 @@ package catalog
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
 )
 
 // ToggleResult describes the outcome of a Toggle operation.
@@ -22,6 +24,15 @@ type ToggleResult struct {
 // Callers are responsible for triggering ArgoCD sync after a successful toggle,
 // as each surface (CLI, MCP, Dashboard) has different sync UX requirements.
 func Toggle(gitOpsPath, name string, enable bool) (*ToggleResult, error) {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+	return toggle(gitOpsPath, name, enable)
+}
+
+func toggle(gitOpsPath, name string, enable bool) (*ToggleResult, error) {
 	entry, err := Find(gitOpsPath, name)
 	if err != nil {
 		return nil, err
@@ -52,11 +63,16 @@ func Toggle(gitOpsPath, name string, enable bool) (*ToggleResult, error) {
 // This is a convenience for callers that don't know (or care about) the
 // current state — e.g., the dashboard toggle button.
 func Flip(gitOpsPath, name string) (*ToggleResult, error) {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
 	entry, err := Find(gitOpsPath, name)
 	if err != nil {
 		return nil, err
 	}
-	return Toggle(gitOpsPath, name, !entry.Enabled)
+	return toggle(gitOpsPath, name, !entry.Enabled)
 }
 
 // ToggleWithDepsResult describes the outcome of a ToggleWithDeps operation.
@@ -65,14 +81,33 @@ type ToggleWithDepsResult struct {
 	Enabled  bool
 	NoChange bool
 	AutoDeps []string // dep names that were auto-enabled (enable path only)
+	Cascaded []string // dependents disabled alongside Name (DisableCascade only), in teardown order
 }
 
+// DisableMode controls how ToggleWithDeps treats enabled dependents when
+// disabling an app. It has no effect on the enable path.
+type DisableMode int
+
+const (
+	// DisableSafe refuses to disable an app that enabled apps depend on.
+	DisableSafe DisableMode = iota
+	// DisableForce disables the app anyway, leaving dependents broken.
+	DisableForce
+	// DisableCascade disables every transitive dependent in the same commit.
+	DisableCascade
+)
+
 // ToggleWithDeps is like Toggle but resolves transitive dependencies.
 //
 // Enable path: auto-enables missing dependencies, commits all changes together.
-// Disable path: returns error listing dependents unless force is true.
-// Force bypasses the dependent check but does NOT cascade-disable dependents.
-func ToggleWithDeps(gitOpsPath, name string, enable, force bool) (*ToggleWithDepsResult, error) {
+// Disable path: behaviour on enabled dependents is chosen by mode — refuse
+// (DisableSafe), ignore them (DisableForce), or disable them too (DisableCascade).
+func ToggleWithDeps(gitOpsPath, name string, enable bool, mode DisableMode) (*ToggleWithDepsResult, error) {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
 	entry, err := Find(gitOpsPath, name)
 	if err != nil {
 		return nil, err
@@ -85,7 +120,7 @@ func ToggleWithDeps(gitOpsPath, name string, enable, force bool) (*ToggleWithDep
 	if enable {
 		return toggleWithDepsEnable(gitOpsPath, name)
 	}
-	return toggleWithDepsDisable(gitOpsPath, name, force)
+	return toggleWithDepsDisable(gitOpsPath, name, mode)
 }
 
 func toggleWithDepsEnable(gitOpsPath, name string) (*ToggleWithDepsResult, error) {
@@ -99,27 +134,49 @@ func toggleWithDepsEnable(gitOpsPath, name string) (*ToggleWithDepsResult, error
 		return nil, fmt.Errorf("resolving dependencies: %w", err)
 	}
 
-	// Build a map of currently enabled entries to skip no-ops.
-	enabledSet := make(map[string]bool, len(all))
+	byName := make(map[string]Entry, len(all))
 	for _, e := range all {
-		if e.Enabled {
-			enabledSet[e.Name] = true
-		}
+		byName[e.Name] = e
 	}
 
 	var commitPaths []string
 	var actualAutoAdded []string
+	promoted := false
 	for _, app := range resolved {
-		if enabledSet[app] {
-			continue // already enabled
-		}
-		if err := SetEnabled(gitOpsPath, app, true); err != nil {
-			return nil, fmt.Errorf("enabling %s: %w", app, err)
-		}
-		commitPaths = append(commitPaths, fmt.Sprintf("catalog/%s.yaml", app))
-		if app != name {
+		e := byName[app]
+		switch {
+		case app == name && e.Enabled:
+			// Explicitly enabling an auto-enabled app promotes it so prune keeps it.
+			if len(e.AutoEnabledBy) == 0 {
+				continue
+			}
+			if err := SetAutoEnabledBy(gitOpsPath, app, nil); err != nil {
+				return nil, fmt.Errorf("marking %s explicit: %w", app, err)
+			}
+			promoted = true
+		case app == name:
+			if err := SetEnabled(gitOpsPath, app, true); err != nil {
+				return nil, fmt.Errorf("enabling %s: %w", app, err)
+			}
+		case e.Enabled:
+			// Already-enabled explicit deps keep their provenance; auto ones
+			// record that name now relies on them too.
+			if len(e.AutoEnabledBy) == 0 || slices.Contains(e.AutoEnabledBy, name) {
+				continue
+			}
+			if err := SetAutoEnabledBy(gitOpsPath, app, append(e.AutoEnabledBy, name)); err != nil {
+				return nil, fmt.Errorf("recording provenance for %s: %w", app, err)
+			}
+		default:
+			if err := SetEnabled(gitOpsPath, app, true); err != nil {
+				return nil, fmt.Errorf("enabling %s: %w", app, err)
+			}
+			if err := SetAutoEnabledBy(gitOpsPath, app, []string{name}); err != nil {
+				return nil, fmt.Errorf("recording provenance for %s: %w", app, err)
+			}
 			actualAutoAdded = append(actualAutoAdded, app)
 		}
+		commitPaths = append(commitPaths, fmt.Sprintf("catalog/%s.yaml", app))
 	}
 
 	if len(commitPaths) == 0 {
@@ -127,6 +184,9 @@ func toggleWithDepsEnable(gitOpsPath, name string) (*ToggleWithDepsResult, error
 	}
 
 	commitMsg := fmt.Sprintf("catalog: enable %s", name)
+	if promoted {
+		commitMsg = fmt.Sprintf("catalog: mark %s as explicitly enabled", name)
+	}
 	if len(actualAutoAdded) > 0 {
 		commitMsg += fmt.Sprintf(" (auto-deps: %s)", strings.Join(actualAutoAdded, ", "))
 	}
@@ -141,27 +201,75 @@ func toggleWithDepsEnable(gitOpsPath, name string) (*ToggleWithDepsResult, error
 	}, nil
 }
 
-func toggleWithDepsDisable(gitOpsPath, name string, force bool) (*ToggleWithDepsResult, error) {
-	if !force {
+func toggleWithDepsDisable(gitOpsPath, name string, mode DisableMode) (*ToggleWithDepsResult, error) {
+	var cascaded []string
+	if mode != DisableForce {
 		all, err := List(gitOpsPath)
 		if err != nil {
 			return nil, fmt.Errorf("listing catalog: %w", err)
 		}
-		deps := Dependents(name, all)
-		if len(deps) > 0 {
-			return nil, fmt.Errorf("cannot disable %s: required by %s (use --force to override)", name, strings.Join(deps, ", "))
+		switch mode {
+		case DisableCascade:
+			cascaded = TransitiveDependents(name, all)
+		default:
+			deps := Dependents(name, all)
+			if len(deps) > 0 {
+				return nil, fmt.Errorf("cannot disable %s: required by %s (use --cascade to disable them too, or --force to override)", name, strings.Join(deps, ", "))
+			}
 		}
 	}
 
-	if err := SetEnabled(gitOpsPath, name, false); err != nil {
-		return nil, fmt.Errorf("disabling %s: %w", name, err)
+	commitPaths := make([]string, 0, len(cascaded)+1)
+	for _, app := range append(append([]string{}, cascaded...), name) {
+		if err := SetEnabled(gitOpsPath, app, false); err != nil {
+			return nil, fmt.Errorf("disabling %s: %w", app, err)
+		}
+		commitPaths = append(commitPaths, fmt.Sprintf("catalog/%s.yaml", app))
 	}
 
 	commitMsg := fmt.Sprintf("catalog: disable %s", name)
-	commitPath := fmt.Sprintf("catalog/%s.yaml", name)
-	if err := gitops.Commit(gitOpsPath, commitMsg, commitPath); err != nil {
+	if len(cascaded) > 0 {
+		commitMsg += fmt.Sprintf(" (cascade: %s)", strings.Join(cascaded, ", "))
+	}
+	if err := gitops.Commit(gitOpsPath, commitMsg, commitPaths...); err != nil {
 		return nil, fmt.Errorf("committing change: %w", err)
 	}
 
-	return &ToggleWithDepsResult{Name: name, Enabled: false}, nil
+	return &ToggleWithDepsResult{Name: name, Enabled: false, Cascaded: cascaded}, nil
+}
+
+// Prune disables every auto-enabled entry that is no longer required by any
+// explicitly enabled entry, committing all changes together. It returns the
+// pruned names in teardown order; nil means there was nothing to prune.
+func Prune(gitOpsPath string) ([]string, error) {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+	all, err := List(gitOpsPath)
+	if err != nil {
+		return nil, fmt.Errorf("listing catalog: %w", err)
+	}
+	orphans, err := Orphans(all)
+	if err != nil {
+		return nil, err
+	}
+	if len(orphans) == 0 {
+		return nil, nil
+	}
+
+	commitPaths := make([]string, 0, len(orphans))
+	for _, app := range orphans {
+		if err := SetEnabled(gitOpsPath, app, false); err != nil {
+			return nil, fmt.Errorf("disabling %s: %w", app, err)
+		}
+		commitPaths = append(commitPaths, fmt.Sprintf("catalog/%s.yaml", app))
+	}
+
+	commitMsg := fmt.Sprintf("catalog: prune orphaned dependencies (%s)", strings.Join(orphans, ", "))
+	if err := gitops.Commit(gitOpsPath, commitMsg, commitPaths...); err != nil {
+		return nil, fmt.Errorf("committing changes: %w", err)
+	}
+	return orphans, nil
 }
@@ -0,0 +1,248 @@
+package catalog
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// initGitRepo commits the current contents of dir so gitops.Commit works.
+func initGitRepo(t *testing.T, dir string) {
+	t.Helper()
+	for _, args := range [][]string{
+		{"init"},
+		{"config", "user.email", "test@test.com"},
+		{"config", "user.name", "test"},
+		{"add", "."},
+		{"commit", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %v", args, out, err)
+		}
+	}
+}
+
+func writeDepChain(t *testing.T, dir string) {
+	t.Helper()
+	writeEntry(t, dir, "name: cnpg-operator\ntier: 0-operators\nenabled: true\n", "cnpg-operator")
+	writeEntry(t, dir, "name: postgresql\ntier: 1-data\nenabled: true\ndependsOn: [cnpg-operator]\n", "postgresql")
+	writeEntry(t, dir, "name: langfuse\ntier: 2-services\nenabled: true\ndependsOn: [postgresql]\n", "langfuse")
+	writeEntry(t, dir, "name: ollama\ntier: 2-services\nenabled: true\n", "ollama")
+	initGitRepo(t, dir)
+}
+
+func TestToggleWithDeps_DisableSafeRefusesWithDependents(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeDepChain(t, dir)
+
+	_, err := ToggleWithDeps(dir, "cnpg-operator", false, DisableSafe)
+	if err == nil {
+		t.Fatal("expected error when dependents are enabled")
+	}
+	if !strings.Contains(err.Error(), "postgresql") {
+		t.Errorf("error %q does not name the dependent", err)
+	}
+}
+
+func TestToggleWithDeps_DisableCascade(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeDepChain(t, dir)
+
+	result, err := ToggleWithDeps(dir, "cnpg-operator", false, DisableCascade)
+	if err != nil {
+		t.Fatalf("ToggleWithDeps: %v", err)
+	}
+	want := []string{"langfuse", "postgresql"}
+	if strings.Join(result.Cascaded, ",") != strings.Join(want, ",") {
+		t.Errorf("Cascaded = %v, want %v", result.Cascaded, want)
+	}
+
+	for name, enabled := range map[string]bool{"cnpg-operator": false, "postgresql": false, "langfuse": false, "ollama": true} {
+		e, err := Find(dir, name)
+		if err != nil {
+			t.Fatalf("Find(%s): %v", name, err)
+		}
+		if e.Enabled != enabled {
+			t.Errorf("%s enabled = %v, want %v", name, e.Enabled, enabled)
+		}
+	}
+
+	out, err := exec.Command("git", "-C", dir, "log", "--format=%s").Output()
+	if err != nil {
+		t.Fatalf("git log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d commits, want 2 (init + cascade): %v", len(lines), lines)
+	}
+	if lines[0] != "catalog: disable cnpg-operator (cascade: langfuse, postgresql)" {
+		t.Errorf("commit message = %q", lines[0])
+	}
+}
+
+func TestToggleWithDeps_DisableForceLeavesDependents(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeDepChain(t, dir)
+
+	result, err := ToggleWithDeps(dir, "cnpg-operator", false, DisableForce)
+	if err != nil {
+		t.Fatalf("ToggleWithDeps: %v", err)
+	}
+	if len(result.Cascaded) != 0 {
+		t.Errorf("Cascaded = %v, want empty", result.Cascaded)
+	}
+	e, _ := Find(dir, "postgresql")
+	if !e.Enabled {
+		t.Error("postgresql should stay enabled with DisableForce")
+	}
+}
+
+func TestToggleWithDeps_EnableRecordsProvenance(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, "name: cnpg-operator\ntier: 0-operators\nenabled: false\n", "cnpg-operator")
+	writeEntry(t, dir, "name: postgresql\ntier: 1-data\nenabled: false\ndependsOn: [cnpg-operator]\n", "postgresql")
+	writeEntry(t, dir, "name: langfuse\ntier: 2-services\nenabled: false\ndependsOn: [postgresql]\n", "langfuse")
+	initGitRepo(t, dir)
+
+	if _, err := ToggleWithDeps(dir, "langfuse", true, DisableSafe); err != nil {
+		t.Fatalf("ToggleWithDeps: %v", err)
+	}
+
+	want := map[string]string{"langfuse": ProvenanceExplicit, "postgresql": ProvenanceAuto, "cnpg-operator": ProvenanceAuto}
+	for name, prov := range want {
+		e, err := Find(dir, name)
+		if err != nil {
+			t.Fatalf("Find(%s): %v", name, err)
+		}
+		if e.Provenance() != prov {
+			t.Errorf("%s provenance = %q, want %q", name, e.Provenance(), prov)
+		}
+	}
+
+	// Explicitly enabling an auto-enabled dependency promotes it.
+	result, err := ToggleWithDeps(dir, "postgresql", true, DisableSafe)
+	if err != nil {
+		t.Fatalf("ToggleWithDeps(promote): %v", err)
+	}
+	if result.NoChange {
+		t.Error("promoting an auto-enabled app should not be a no-op")
+	}
+	e, _ := Find(dir, "postgresql")
+	if e.Provenance() != ProvenanceExplicit {
+		t.Errorf("postgresql provenance = %q after explicit enable, want explicit", e.Provenance())
+	}
+}
+
+func TestPrune_DisablesOrphanedDependencies(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, "name: cnpg-operator\ntier: 0-operators\nenabled: true\nautoEnabledBy: [langfuse]\n", "cnpg-operator")
+	writeEntry(t, dir, "name: postgresql\ntier: 1-data\nenabled: true\nautoEnabledBy: [langfuse]\ndependsOn: [cnpg-operator]\n", "postgresql")
+	writeEntry(t, dir, "name: valkey\ntier: 1-data\nenabled: true\nautoEnabledBy: [langfuse]\n", "valkey")
+	writeEntry(t, dir, "name: temporal\ntier: 2-services\nenabled: true\ndependsOn: [postgresql]\n", "temporal")
+	writeEntry(t, dir, "name: langfuse\ntier: 2-services\nenabled: false\ndependsOn: [postgresql, valkey]\n", "langfuse")
+	initGitRepo(t, dir)
+
+	pruned, err := Prune(dir)
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	// postgresql and cnpg-operator are still required by explicit temporal.
+	if strings.Join(pruned, ",") != "valkey" {
+		t.Errorf("pruned = %v, want [valkey]", pruned)
+	}
+
+	again, err := Prune(dir)
+	if err != nil {
+		t.Fatalf("second Prune: %v", err)
+	}
+	if len(again) != 0 {
+		t.Errorf("second Prune = %v, want nothing", again)
+	}
+}
+
+// TestToggle_ConcurrentSurfacesSerialize races dashboard-style flips against
+// CLI-style enables on one repo: every toggle must land as its own commit
+// and leave a clean tree.
+func TestToggle_ConcurrentSurfacesSerialize(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, "name: ollama\ntier: 2-services\nenabled: false\n", "ollama")
+	writeEntry(t, dir, "name: qdrant\ntier: 1-data\nenabled: false\n", "qdrant")
+	writeEntry(t, dir, "name: postgresql\ntier: 1-data\nenabled: false\n", "postgresql")
+	writeEntry(t, dir, "name: langfuse\ntier: 2-services\nenabled: false\ndependsOn: [postgresql]\n", "langfuse")
+	initGitRepo(t, dir)
+
+	const rounds = 10
+	var (
+		wg      sync.WaitGroup
+		changes atomic.Int32
+		errs    = make(chan error, 4*rounds)
+	)
+	record := func(noChange bool, err error) {
+		switch {
+		case err != nil:
+			errs <- err
+		case !noChange:
+			changes.Add(1)
+		}
+	}
+	wg.Add(2)
+	go func() { // dashboard
+		defer wg.Done()
+		for range rounds {
+			for _, name := range []string{"ollama", "qdrant"} {
+				r, err := Flip(dir, name)
+				record(err == nil && r.NoChange, err)
+			}
+		}
+	}()
+	go func() { // cli
+		defer wg.Done()
+		for i := range rounds {
+			r, err := ToggleWithDeps(dir, "langfuse", i%2 == 1, DisableForce)
+			record(err == nil && r.NoChange, err)
+			r2, err := Toggle(dir, "qdrant", true)
+			record(err == nil && r2.NoChange, err)
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("toggle failed: %v", err)
+	}
+
+	status, err := exec.Command("git", "-C", dir, "status", "--porcelain").Output()
+	if err != nil {
+		t.Fatalf("git status: %v", err)
+	}
+	if len(status) != 0 {
+		t.Errorf("tree not clean after concurrent toggles:\n%s", status)
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-list", "--count", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("git rev-list: %v", err)
+	}
+	if got, want := strings.TrimSpace(string(out)), fmt.Sprint(1+changes.Load()); got != want {
+		t.Errorf("got %s commits, want %s (init + one per change)", got, want)
+	}
+
+	for name, enabled := range map[string]bool{"ollama": false, "langfuse": true, "postgresql": true} {
+		e, err := Find(dir, name)
+		if err != nil {
+			t.Fatalf("Find(%s): %v", name, err)
+		}
+		if e.Enabled != enabled {
+			t.Errorf("%s enabled = %v, want %v", name, e.Enabled, enabled)
+		}
+	}
+}
@@ -0,0 +1,540 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	yamlv3 "gopkg.in/yaml.v3"
+	"sigs.k8s.io/yaml"
+)
+
+// Source is a third-party catalog vendored into the gitops repo. Its entries
+// are copied to catalog/<name>-<app>.yaml so the catalog ApplicationSet,
+// List, Find and dependency resolution see them like built-in entries.
+type Source struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Ref is the branch or tag to track; empty means the remote HEAD.
+	Ref string `json:"ref,omitempty"`
+	// Path is the catalog directory within the source repo.
+	Path      string    `json:"path"`
+	Commit    string    `json:"commit"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Apps lists the vendored (prefixed) entry names.
+	Apps []string `json:"apps"`
+}
+
+// SourceOptions describes a catalog source to add.
+type SourceOptions struct {
+	Name string
+	URL  string
+	Ref  string
+	Path string
+}
+
+// SourceResult reports the entries an add or update vendored.
+type SourceResult struct {
+	Source  Source   `json:"source"`
+	Added   []string `json:"added,omitempty"`
+	Updated []string `json:"updated,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// DefaultSourcePath is the catalog directory read from a source repo when
+// SourceOptions.Path is empty, matching the bootstrap repo layout.
+const DefaultSourcePath = "catalog"
+
+var sourceNameRe = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// entryNameRe is the rule for upstream entry names, the same DNS label rule
+// agent names follow.
+var entryNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// SourcesDir returns the directory holding source metadata. It is a
+// subdirectory of the catalog so List skips it.
+func SourcesDir(gitOpsPath string) string {
+	return filepath.Join(CatalogDir(gitOpsPath), "sources")
+}
+
+func sourceFile(name string) string {
+	return filepath.Join("catalog", "sources", name+".yaml")
+}
+
+// SourceNameFromURL derives a default source name from a git URL, e.g.
+// https://github.com/acme/ai-catalog.git -> ai-catalog.
+func SourceNameFromURL(url string) string {
+	base := strings.TrimSuffix(strings.TrimRight(url, "/"), ".git")
+	if i := strings.LastIndexAny(base, "/:"); i >= 0 {
+		base = base[i+1:]
+	}
+	return strings.ToLower(base)
+}
+
+// ListSources returns the catalog sources vendored into the gitops repo,
+// sorted by name.
+func ListSources(gitOpsPath string) ([]Source, error) {
+	files, err := os.ReadDir(SourcesDir(gitOpsPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading sources directory: %w", err)
+	}
+	var sources []Source
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".yaml") {
+			continue
+		}
+		src, err := readSource(gitOpsPath, strings.TrimSuffix(f.Name(), ".yaml"))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, *src)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+	return sources, nil
+}
+
+func readSource(gitOpsPath, name string) (*Source, error) {
+	data, err := os.ReadFile(filepath.Join(gitOpsPath, sourceFile(name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("catalog source %q not found", name)
+		}
+		return nil, fmt.Errorf("reading catalog source %s: %w", name, err)
+	}
+	var src Source
+	if err := yaml.Unmarshal(data, &src); err != nil {
+		return nil, fmt.Errorf("parsing catalog source %s: %w", name, err)
+	}
+	return &src, nil
+}
+
+// AddSource clones opts.URL, vendors the entries of its catalog directory
+// under the "<name>-" prefix (all disabled), and commits. It fails without
+// writing anything when a prefixed name collides with an existing entry.
+func AddSource(ctx context.Context, gitOpsPath string, opts SourceOptions) (*SourceResult, error) {
+	if opts.Name == "" {
+		opts.Name = SourceNameFromURL(opts.URL)
+	}
+	if !sourceNameRe.MatchString(opts.Name) {
+		return nil, fmt.Errorf("invalid source name %q: use lowercase letters, digits and '-' (set one with --name)", opts.Name)
+	}
+	if opts.Path == "" {
+		opts.Path = DefaultSourcePath
+	}
+	if _, err := os.Stat(filepath.Join(gitOpsPath, sourceFile(opts.Name))); err == nil {
+		return nil, fmt.Errorf("catalog source %q already exists; use update to refresh it", opts.Name)
+	}
+	src := Source{Name: opts.Name, URL: opts.URL, Ref: opts.Ref, Path: opts.Path}
+	return syncSource(ctx, gitOpsPath, src, "add")
+}
+
+// UpdateSource re-fetches a source and re-vendors its entries. Local enabled
+// state, provenance and values files are kept. Entries dropped upstream are
+// removed, unless they are enabled, in which case nothing is written.
+func UpdateSource(ctx context.Context, gitOpsPath, name string) (*SourceResult, error) {
+	src, err := readSource(gitOpsPath, name)
+	if err != nil {
+		return nil, err
+	}
+	return syncSource(ctx, gitOpsPath, *src, "update")
+}
+
+// RemoveSource deletes a source and its vendored entries and values, and
+// commits. It refuses while any of its entries is enabled.
+func RemoveSource(gitOpsPath, name string) ([]string, error) {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+	src, err := readSource(gitOpsPath, name)
+	if err != nil {
+		return nil, err
+	}
+	all, err := List(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	if enabled := enabledFromSource(all, name, src.Apps); len(enabled) > 0 {
+		return nil, fmt.Errorf("cannot remove source %s: %s still enabled; disable first", name, strings.Join(enabled, ", "))
+	}
+
+	var paths []string
+	for _, app := range src.Apps {
+		removed, err := removeEntryFiles(gitOpsPath, app)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, removed...)
+	}
+	if err := os.Remove(filepath.Join(gitOpsPath, sourceFile(name))); err != nil {
+		return nil, fmt.Errorf("removing catalog source %s: %w", name, err)
+	}
+	paths = append(paths, sourceFile(name))
+
+	if err := gitops.Commit(gitOpsPath, fmt.Sprintf("catalog: remove source %s", name), paths...); err != nil {
+		return nil, fmt.Errorf("committing source removal: %w", err)
+	}
+	return src.Apps, nil
+}
+
+// vendoredEntry is an entry read from a source checkout.
+type vendoredEntry struct {
+	entry  Entry
+	data   []byte
+	values []byte
+}
+
+func syncSource(ctx context.Context, gitOpsPath string, src Source, verb string) (*SourceResult, error) {
+	checkout, err := os.MkdirTemp("", "sikifanso-source-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(checkout)
+
+	commit, err := cloneSource(ctx, checkout, src.URL, src.Ref)
+	if err != nil {
+		return nil, err
+	}
+	upstream, err := readSourceEntries(filepath.Join(checkout, src.Path))
+	if err != nil {
+		return nil, fmt.Errorf("reading catalog of source %s: %w", src.Name, err)
+	}
+	if len(upstream) == 0 {
+		return nil, fmt.Errorf("source %s has no catalog entries in %s/", src.Name, src.Path)
+	}
+
+	// The clone runs unlocked; the checks and writes below see a settled repo.
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	all, err := List(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	local := make(map[string]Entry, len(all))
+	for _, e := range all {
+		local[e.Name] = e
+	}
+	// Validate everything before writing anything.
+	stale, err := checkSource(src, upstream, all, local)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := src.Name + "-"
+	inSource := make(map[string]bool, len(upstream))
+	for _, v := range upstream {
+		inSource[v.entry.Name] = true
+	}
+
+	result := &SourceResult{}
+	var paths []string
+	src.Apps = src.Apps[:0]
+	for _, v := range upstream {
+		name := prefix + v.entry.Name
+		existing, exists := local[name]
+		written, err := writeVendoredEntry(gitOpsPath, v, src.Name, inSource, existing)
+		if err != nil {
+			return nil, fmt.Errorf("vendoring %s: %w", v.entry.Name, err)
+		}
+		paths = append(paths, written...)
+		if exists {
+			result.Updated = append(result.Updated, name)
+		} else {
+			result.Added = append(result.Added, name)
+		}
+		src.Apps = append(src.Apps, name)
+	}
+	for _, app := range stale {
+		removed, err := removeEntryFiles(gitOpsPath, app)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, removed...)
+		result.Removed = append(result.Removed, app)
+	}
+	sort.Strings(src.Apps)
+
+	src.Commit = commit
+	src.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	if err := writeSource(gitOpsPath, src); err != nil {
+		return nil, err
+	}
+	paths = append(paths, sourceFile(src.Name))
+
+	msg := fmt.Sprintf("catalog: %s source %s at %s", verb, src.Name, shortHash(commit))
+	if err := gitops.Commit(gitOpsPath, msg, paths...); err != nil {
+		return nil, fmt.Errorf("committing source %s: %w", src.Name, err)
+	}
+
+	result.Source = src
+	return result, nil
+}
+
+// checkSource rejects name conflicts with entries not owned by src,
+// dependencies that resolve nowhere, and dropping entries that are enabled.
+// It returns the previously vendored entries that upstream no longer has.
+func checkSource(src Source, upstream []vendoredEntry, all []Entry, local map[string]Entry) ([]string, error) {
+	prefix := src.Name + "-"
+	wanted := make(map[string]bool, len(upstream))
+	inSource := make(map[string]bool, len(upstream))
+	for _, v := range upstream {
+		wanted[prefix+v.entry.Name] = true
+		inSource[v.entry.Name] = true
+	}
+
+	var conflicts []string
+	for _, v := range upstream {
+		name := prefix + v.entry.Name
+		if existing, ok := local[name]; ok && existing.Source != src.Name {
+			conflicts = append(conflicts, name)
+		}
+		for _, d := range v.entry.DependsOn {
+			if _, ok := local[d]; !ok && !inSource[d] {
+				return nil, fmt.Errorf("source %s: %s depends on %q, which is neither in the source nor the local catalog", src.Name, v.entry.Name, d)
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("source %s conflicts with existing catalog entries: %s", src.Name, strings.Join(conflicts, ", "))
+	}
+
+	var stale []string
+	for _, app := range src.Apps {
+		if !wanted[app] {
+			stale = append(stale, app)
+		}
+	}
+	if enabled := enabledFromSource(all, src.Name, stale); len(enabled) > 0 {
+		return nil, fmt.Errorf("source %s no longer provides %s, which is enabled; disable it before updating", src.Name, strings.Join(enabled, ", "))
+	}
+	return stale, nil
+}
+
+// writeVendoredEntry writes the prefixed entry file and, when the app has no
+// local values file yet, the upstream values. Values are copied once so later
+// local edits win over upstream changes. It returns the paths written.
+func writeVendoredEntry(gitOpsPath string, v vendoredEntry, source string, inSource map[string]bool, existing Entry) ([]string, error) {
+	name := source + "-" + v.entry.Name
+	data, err := vendorEntry(v, source, source+"-", inSource, existing)
+	if err != nil {
+		return nil, err
+	}
+	rel := filepath.Join("catalog", name+".yaml")
+	if err := os.WriteFile(filepath.Join(gitOpsPath, rel), data, 0o644); err != nil {
+		return nil, fmt.Errorf("writing %s: %w", rel, err)
+	}
+	paths := []string{rel}
+
+	valuesRel := ValuesFile(name)
+	if _, err := os.Stat(filepath.Join(gitOpsPath, valuesRel)); v.values == nil || !os.IsNotExist(err) {
+		return paths, nil
+	}
+	if err := os.MkdirAll(filepath.Join(gitOpsPath, "catalog", "values"), 0o755); err != nil {
+		return nil, fmt.Errorf("creating values directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(gitOpsPath, valuesRel), v.values, 0o644); err != nil {
+		return nil, fmt.Errorf("writing %s: %w", valuesRel, err)
+	}
+	return append(paths, valuesRel), nil
+}
+
+func writeSource(gitOpsPath string, src Source) error {
+	data, err := yaml.Marshal(src)
+	if err != nil {
+		return fmt.Errorf("encoding catalog source %s: %w", src.Name, err)
+	}
+	if err := os.MkdirAll(SourcesDir(gitOpsPath), 0o755); err != nil {
+		return fmt.Errorf("creating sources directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(gitOpsPath, sourceFile(src.Name)), data, 0o644); err != nil {
+		return fmt.Errorf("writing catalog source %s: %w", src.Name, err)
+	}
+	return nil
+}
+
+// shortHash abbreviates a commit hash for messages.
+func shortHash(h string) string {
+	if len(h) > 7 {
+		return h[:7]
+	}
+	return h
+}
+
+// cloneSource shallow-clones url at ref (a branch or tag; empty for HEAD)
+// into dir and returns the checked-out commit hash.
+func cloneSource(ctx context.Context, dir, url, ref string) (string, error) {
+	opts := &git.CloneOptions{URL: url, Depth: 1}
+	var repo *git.Repository
+	var err error
+	if ref == "" {
+		repo, err = git.PlainCloneContext(ctx, dir, false, opts)
+	} else {
+		// Try the ref as a branch first, then as a tag.
+		opts.SingleBranch = true
+		opts.ReferenceName = plumbing.NewBranchReferenceName(ref)
+		repo, err = git.PlainCloneContext(ctx, dir, false, opts)
+		if err != nil {
+			if rmErr := os.RemoveAll(dir); rmErr != nil {
+				return "", fmt.Errorf("cleaning checkout: %w", rmErr)
+			}
+			opts.ReferenceName = plumbing.NewTagReferenceName(ref)
+			var tagErr error
+			if repo, tagErr = git.PlainCloneContext(ctx, dir, false, opts); tagErr == nil {
+				err = nil
+			}
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("cloning %s: %w", url, err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("resolving HEAD of %s: %w", url, err)
+	}
+	return head.Hash().String(), nil
+}
+
+// readSourceEntries reads the *.yaml entries of a source catalog directory
+// along with their values/<name>.yaml files. Names become local file paths,
+// so each must be a DNS label matching its file name.
+func readSourceEntries(dir string) ([]vendoredEntry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []vendoredEntry
+	seen := make(map[string]bool)
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".yaml") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var e Entry
+		if err := yaml.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", f.Name(), err)
+		}
+		if e.Name == "" {
+			return nil, fmt.Errorf("%s: missing name", f.Name())
+		}
+		if !entryNameRe.MatchString(e.Name) {
+			return nil, fmt.Errorf("%s: invalid name %q: use lowercase letters, digits and '-'", f.Name(), e.Name)
+		}
+		if stem := strings.TrimSuffix(f.Name(), ".yaml"); stem != e.Name {
+			return nil, fmt.Errorf("%s: name %q does not match the file name", f.Name(), e.Name)
+		}
+		if seen[e.Name] {
+			return nil, fmt.Errorf("%s: duplicate name %q", f.Name(), e.Name)
+		}
+		seen[e.Name] = true
+		v := vendoredEntry{entry: e, data: data}
+		if values, err := os.ReadFile(filepath.Join(dir, "values", e.Name+".yaml")); err == nil {
+			v.values = values
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// vendorEntry rewrites an upstream entry file for the local catalog: the name
+// and in-source dependencies get the prefix, source is recorded, and enabled
+// state and provenance come from the existing local entry (new entries start
+// disabled). Comments and field order are preserved.
+func vendorEntry(v vendoredEntry, source, prefix string, inSource map[string]bool, existing Entry) ([]byte, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(v.data, &doc); err != nil {
+		return nil, err
+	}
+	mapping, err := entryMapping(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	setMappingValue(mapping, "name", strScalar(prefix+v.entry.Name), "")
+	setMappingValue(mapping, "source", strScalar(source), "name")
+	if deps := mappingValue(mapping, "dependsOn"); deps != nil && deps.Kind == yamlv3.SequenceNode {
+		for _, d := range deps.Content {
+			if inSource[d.Value] {
+				d.Value = prefix + d.Value
+			}
+		}
+	}
+	enabled := &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(existing.Enabled)}
+	setMappingValue(mapping, "enabled", enabled, "")
+	if err := setAutoEnabledByInNode(&doc, existing.AutoEnabledBy); err != nil {
+		return nil, err
+	}
+	return encodeNode(&doc)
+}
+
+// setMappingValue replaces key's value in mapping, or inserts the pair after
+// the key named after (at the end when after is "" or absent).
+func setMappingValue(mapping *yamlv3.Node, key string, value *yamlv3.Node, after string) {
+	insertAt := len(mapping.Content)
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		switch mapping.Content[i].Value {
+		case key:
+			value.LineComment = mapping.Content[i+1].LineComment
+			mapping.Content[i+1] = value
+			return
+		case after:
+			insertAt = i + 2
+		}
+	}
+	mapping.Content = append(mapping.Content[:insertAt], append([]*yamlv3.Node{strScalar(key), value}, mapping.Content[insertAt:]...)...)
+}
+
+func strScalar(s string) *yamlv3.Node {
+	return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// enabledFromSource returns the names among apps that are enabled entries of
+// the given source.
+func enabledFromSource(all []Entry, source string, apps []string) []string {
+	want := make(map[string]bool, len(apps))
+	for _, a := range apps {
+		want[a] = true
+	}
+	var enabled []string
+	for _, e := range all {
+		if want[e.Name] && e.Source == source && e.Enabled {
+			enabled = append(enabled, e.Name)
+		}
+	}
+	return enabled
+}
+
+// removeEntryFiles deletes an entry file and its values file, returning the
+// gitops-relative paths that were removed.
+func removeEntryFiles(gitOpsPath, name string) ([]string, error) {
+	var removed []string
+	for _, rel := range []string{filepath.Join("catalog", name+".yaml"), ValuesFile(name)} {
+		err := os.Remove(filepath.Join(gitOpsPath, rel))
+		switch {
+		case err == nil:
+			removed = append(removed, rel)
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("removing %s: %w", rel, err)
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,236 @@
+package catalog
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSourceRepo creates a git repo laid out like a third-party catalog.
+func writeSourceRepo(t *testing.T, entries map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range entries {
+		writeEntry(t, dir, content, name)
+	}
+	initGitRepo(t, dir)
+	return dir
+}
+
+func commitSourceRepo(t *testing.T, dir string) {
+	t.Helper()
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-m", "update"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %v", args, out, err)
+		}
+	}
+}
+
+func TestAddSource_VendorsPrefixedEntries(t *testing.T) {
+	t.Parallel()
+	src := writeSourceRepo(t, map[string]string{
+		"vectordb": "# upstream comment\nname: vectordb\ncategory: storage\nenabled: true\n",
+		"rag":      "name: rag\ncategory: ai\nenabled: false\ndependsOn: [vectordb, cnpg-operator]\n",
+	})
+	if err := os.MkdirAll(filepath.Join(src, "catalog", "values"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "catalog", "values", "rag.yaml"), []byte("replicas: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	commitSourceRepo(t, src)
+
+	gitOps := t.TempDir()
+	writeEntry(t, gitOps, "name: cnpg-operator\nenabled: false\n", "cnpg-operator")
+	initGitRepo(t, gitOps)
+
+	res, err := AddSource(context.Background(), gitOps, SourceOptions{Name: "acme", URL: src})
+	if err != nil {
+		t.Fatalf("AddSource: %v", err)
+	}
+	if got := strings.Join(res.Added, ","); got != "acme-rag,acme-vectordb" {
+		t.Errorf("Added = %q", got)
+	}
+
+	rag, err := Find(gitOps, "acme-rag")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if rag.Source != "acme" || rag.Enabled {
+		t.Errorf("acme-rag source=%q enabled=%v, want acme, false", rag.Source, rag.Enabled)
+	}
+	if got := strings.Join(rag.DependsOn, ","); got != "acme-vectordb,cnpg-operator" {
+		t.Errorf("DependsOn = %q", got)
+	}
+	vectordb, err := Find(gitOps, "acme-vectordb")
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if vectordb.Enabled {
+		t.Error("vendored entries should start disabled")
+	}
+	data, _ := os.ReadFile(filepath.Join(gitOps, "catalog", "acme-vectordb.yaml"))
+	if !strings.Contains(string(data), "# upstream comment") {
+		t.Errorf("upstream comment lost:\n%s", data)
+	}
+	if values, err := ReadValues(gitOps, "acme-rag"); err != nil || string(values) != "replicas: 2\n" {
+		t.Errorf("values = %q, %v", values, err)
+	}
+
+	if _, _, err := ResolveDeps([]string{"acme-rag"}, mustList(t, gitOps)); err != nil {
+		t.Errorf("ResolveDeps over vendored entries: %v", err)
+	}
+
+	sources, err := ListSources(gitOps)
+	if err != nil {
+		t.Fatalf("ListSources: %v", err)
+	}
+	if len(sources) != 1 || sources[0].Name != "acme" || sources[0].Commit == "" || sources[0].Path != DefaultSourcePath {
+		t.Errorf("sources = %+v", sources)
+	}
+}
+
+func TestAddSource_Conflict(t *testing.T) {
+	t.Parallel()
+	src := writeSourceRepo(t, map[string]string{"rag": "name: rag\nenabled: false\n"})
+
+	gitOps := t.TempDir()
+	writeEntry(t, gitOps, "name: acme-rag\nenabled: false\n", "acme-rag")
+	initGitRepo(t, gitOps)
+
+	_, err := AddSource(context.Background(), gitOps, SourceOptions{Name: "acme", URL: src})
+	if err == nil || !strings.Contains(err.Error(), "acme-rag") {
+		t.Fatalf("expected conflict on acme-rag, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(SourcesDir(gitOps), "acme.yaml")); !os.IsNotExist(err) {
+		t.Error("conflicting add should not write source metadata")
+	}
+}
+
+func TestAddSource_RejectsUnsafeNames(t *testing.T) {
+	t.Parallel()
+	cases := map[string]map[string]string{
+		"traversal":     {"evil": "name: ../../../evil\nenabled: false\n"},
+		"stem mismatch": {"rag": "name: vectordb\nenabled: false\n"},
+		"uppercase":     {"Rag": "name: Rag\nenabled: false\n"},
+	}
+	for name, entries := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			src := writeSourceRepo(t, entries)
+			root := t.TempDir()
+			gitOps := filepath.Join(root, "gitops")
+			writeEntry(t, gitOps, "name: ollama\nenabled: false\n", "ollama")
+			initGitRepo(t, gitOps)
+
+			if _, err := AddSource(context.Background(), gitOps, SourceOptions{Name: "acme", URL: src}); err == nil {
+				t.Fatal("expected error for unsafe entry name")
+			}
+			if _, err := os.Stat(filepath.Join(SourcesDir(gitOps), "acme.yaml")); !os.IsNotExist(err) {
+				t.Error("rejected add should not write source metadata")
+			}
+			files, err := os.ReadDir(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 {
+				t.Errorf("files written outside the gitops repo: %v", files)
+			}
+		})
+	}
+}
+
+func TestAddSource_UnknownDependency(t *testing.T) {
+	t.Parallel()
+	src := writeSourceRepo(t, map[string]string{"rag": "name: rag\nenabled: false\ndependsOn: [missing]\n"})
+	gitOps := t.TempDir()
+	writeEntry(t, gitOps, "name: ollama\nenabled: false\n", "ollama")
+	initGitRepo(t, gitOps)
+
+	if _, err := AddSource(context.Background(), gitOps, SourceOptions{Name: "acme", URL: src}); err == nil {
+		t.Fatal("expected error for unresolvable dependency")
+	}
+}
+
+func TestUpdateSource_KeepsLocalStateAndPrunes(t *testing.T) {
+	t.Parallel()
+	src := writeSourceRepo(t, map[string]string{
+		"rag":    "name: rag\ndescription: v1\nenabled: false\n",
+		"legacy": "name: legacy\nenabled: false\n",
+	})
+	gitOps := t.TempDir()
+	writeEntry(t, gitOps, "name: ollama\nenabled: false\n", "ollama")
+	initGitRepo(t, gitOps)
+	ctx := context.Background()
+
+	if _, err := AddSource(ctx, gitOps, SourceOptions{Name: "acme", URL: src}); err != nil {
+		t.Fatalf("AddSource: %v", err)
+	}
+	if err := SetEnabled(gitOps, "acme-rag", true); err != nil {
+		t.Fatal(err)
+	}
+
+	writeEntry(t, src, "name: rag\ndescription: v2\nenabled: false\n", "rag")
+	if err := os.Remove(filepath.Join(src, "catalog", "legacy.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	commitSourceRepo(t, src)
+
+	res, err := UpdateSource(ctx, gitOps, "acme")
+	if err != nil {
+		t.Fatalf("UpdateSource: %v", err)
+	}
+	if strings.Join(res.Updated, ",") != "acme-rag" || strings.Join(res.Removed, ",") != "acme-legacy" {
+		t.Errorf("result = %+v", res)
+	}
+	rag, err := Find(gitOps, "acme-rag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rag.Description != "v2" || !rag.Enabled {
+		t.Errorf("acme-rag description=%q enabled=%v, want v2, true", rag.Description, rag.Enabled)
+	}
+	if _, err := os.Stat(filepath.Join(gitOps, "catalog", "acme-legacy.yaml")); !os.IsNotExist(err) {
+		t.Error("entry dropped upstream should be removed")
+	}
+
+	if _, err := RemoveSource(gitOps, "acme"); err == nil {
+		t.Fatal("RemoveSource should refuse while acme-rag is enabled")
+	}
+	if err := SetEnabled(gitOps, "acme-rag", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RemoveSource(gitOps, "acme"); err != nil {
+		t.Fatalf("RemoveSource: %v", err)
+	}
+	if entries := mustList(t, gitOps); len(entries) != 1 || entries[0].Name != "ollama" {
+		t.Errorf("entries after remove = %+v", entries)
+	}
+}
+
+func TestSourceNameFromURL(t *testing.T) {
+	t.Parallel()
+	for url, want := range map[string]string{
+		"https://github.com/acme/AI-Catalog.git": "ai-catalog",
+		"git@github.com:acme/models.git":         "models",
+		"/srv/catalogs/team/":                    "team",
+	} {
+		if got := SourceNameFromURL(url); got != want {
+			t.Errorf("SourceNameFromURL(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func mustList(t *testing.T, gitOps string) []Entry {
+	t.Helper()
+	entries, err := List(gitOps)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	return entries
+}
@@ -0,0 +1,362 @@
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/helm"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
+	"go.uber.org/zap"
+	yamlv3 "gopkg.in/yaml.v3"
+	"sigs.k8s.io/yaml"
+)
+
+// ValuesFile returns the gitops-relative path of the Helm values override
+// file for a catalog app, e.g. catalog/values/langfuse.yaml.
+func ValuesFile(name string) string {
+	return filepath.Join("catalog", "values", name+".yaml")
+}
+
+// ReadValues returns the raw contents of the named app's values file, or nil
+// when the app has no overrides yet. It fails if the app is not in the catalog.
+func ReadValues(gitOpsPath, name string) ([]byte, error) {
+	if _, err := Find(gitOpsPath, name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(gitOpsPath, ValuesFile(name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading values for %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// ParseValues decodes a values document into a map. An empty document yields
+// an empty map.
+func ParseValues(data []byte) (map[string]interface{}, error) {
+	vals := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &vals); err != nil {
+		return nil, fmt.Errorf("parsing values: %w", err)
+	}
+	if vals == nil {
+		vals = map[string]interface{}{}
+	}
+	return vals, nil
+}
+
+// ParseSet splits a Helm-style "a.b.c=value" assignment into its key and value.
+func ParseSet(s string) (key, value string, err error) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return "", "", fmt.Errorf("invalid assignment %q: expected KEY=VALUE", s)
+	}
+	return strings.TrimSpace(key), value, nil
+}
+
+// EditValues applies dotted-path assignments and removals to a values
+// document and returns the result, preserving comments and key order.
+// Values are typed as Helm's --set does: "true" is a bool, "3" an int;
+// wrapping a value in quotes makes it a string. Dots inside a key segment can be escaped as "\.".
+func EditValues(data []byte, set map[string]string, unset []string) ([]byte, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing values: %w", err)
+	}
+	if doc.Kind == 0 {
+		// Empty or comment-only file: start a fresh mapping but keep the comment.
+		doc = yamlv3.Node{Kind: yamlv3.DocumentNode, HeadComment: strings.TrimSpace(string(data))}
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = []*yamlv3.Node{{Kind: yamlv3.MappingNode, Tag: "!!map"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yamlv3.MappingNode {
+		return nil, fmt.Errorf("values file must be a YAML mapping")
+	}
+
+	// Assignments are applied in sorted key order so the output is stable.
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		path, err := splitValuesKey(k)
+		if err != nil {
+			return nil, err
+		}
+		if err := setValueInNode(root, path, set[k]); err != nil {
+			return nil, fmt.Errorf("setting %s: %w", k, err)
+		}
+	}
+	for _, k := range unset {
+		path, err := splitValuesKey(k)
+		if err != nil {
+			return nil, err
+		}
+		if !unsetValueInNode(root, path) {
+			return nil, fmt.Errorf("unsetting %s: key is not set", k)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yamlv3.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("encoding values: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("closing encoder: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SaveValues writes the named app's values file and commits it. The data
+// must be a YAML mapping (or empty).
+func SaveValues(gitOpsPath, name string, data []byte) error {
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	return saveValues(gitOpsPath, name, data)
+}
+
+func saveValues(gitOpsPath, name string, data []byte) error {
+	if _, err := Find(gitOpsPath, name); err != nil {
+		return err
+	}
+	if _, err := ParseValues(data); err != nil {
+		return err
+	}
+
+	rel := ValuesFile(name)
+	abs := filepath.Join(gitOpsPath, rel)
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return fmt.Errorf("creating values directory: %w", err)
+	}
+	if err := os.WriteFile(abs, data, 0o644); err != nil {
+		return fmt.Errorf("writing values for %s: %w", name, err)
+	}
+	if err := gitops.Commit(gitOpsPath, fmt.Sprintf("catalog: update values for %s", name), rel); err != nil {
+		return fmt.Errorf("committing values for %s: %w", name, err)
+	}
+	return nil
+}
+
+// UpdateValues replaces the named app's values with data and commits,
+// validating against the chart schema first unless skipSchema is set.
+// It reports false when data matches the current file and nothing was written.
+// The schema check fetches the chart, so it runs before the gitops lock is
+// taken; the comparison and the write happen under it.
+func UpdateValues(log *zap.Logger, gitOpsPath, name string, data []byte, skipSchema bool) (bool, error) {
+	if !skipSchema {
+		entry, err := Find(gitOpsPath, name)
+		if err != nil {
+			return false, err
+		}
+		if err := ValidateValues(log, *entry, data); err != nil {
+			return false, err
+		}
+	}
+
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return false, err
+	}
+	defer l.Release()
+	current, err := ReadValues(gitOpsPath, name)
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(current, data) {
+		return false, nil
+	}
+	if err := saveValues(gitOpsPath, name, data); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ErrValuesChanged is returned (wrapped) by EditAndSaveValues when the values
+// file changed while the edit was being validated.
+var ErrValuesChanged = errors.New("values changed")
+
+// validateValues is ValidateValues; tests replace it.
+var validateValues = ValidateValues
+
+// EditAndSaveValues applies set and unset to the named app's values as
+// EditValues does, commits the result and returns it. It reports false when
+// the edit leaves the file as it is. The file is read, edited and written
+// under the gitops lock, so concurrent edits of other keys are not lost.
+// The schema check fetches the chart and runs before the lock on the edit
+// of the file as it was then; if the file has changed once the lock is
+// held, nothing is written and the error wraps ErrValuesChanged.
+func EditAndSaveValues(log *zap.Logger, gitOpsPath, name string, set map[string]string, unset []string, skipSchema bool) ([]byte, bool, error) {
+	var base []byte
+	if !skipSchema {
+		entry, err := Find(gitOpsPath, name)
+		if err != nil {
+			return nil, false, err
+		}
+		if base, err = ReadValues(gitOpsPath, name); err != nil {
+			return nil, false, err
+		}
+		edited, err := EditValues(base, set, unset)
+		if err != nil {
+			return nil, false, err
+		}
+		if err := validateValues(log, *entry, edited); err != nil {
+			return nil, false, err
+		}
+	}
+
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return nil, false, err
+	}
+	defer l.Release()
+	current, err := ReadValues(gitOpsPath, name)
+	if err != nil {
+		return nil, false, err
+	}
+	if !skipSchema && !bytes.Equal(current, base) {
+		return nil, false, fmt.Errorf("%w: %s values were modified while the edit was validated; re-run", ErrValuesChanged, name)
+	}
+	updated, err := EditValues(current, set, unset)
+	if err != nil {
+		return nil, false, err
+	}
+	if bytes.Equal(current, updated) {
+		return updated, false, nil
+	}
+	if err := saveValues(gitOpsPath, name, updated); err != nil {
+		return nil, false, err
+	}
+	return updated, true, nil
+}
+
+// ValidateValues fetches the entry's chart at its targetRevision and checks
+// data, merged over the chart defaults, against the chart's
+// values.schema.json. Charts without a schema always pass.
+func ValidateValues(log *zap.Logger, entry Entry, data []byte) error {
+	vals, err := ParseValues(data)
+	if err != nil {
+		return err
+	}
+	cfg, settings, err := helm.Setup(log, entry.Namespace)
+	if err != nil {
+		return err
+	}
+	ch, err := helm.LocateChart(cfg, settings, helm.InstallParams{
+		Namespace:   entry.Namespace,
+		RepoURL:     entry.RepoURL,
+		ChartName:   entry.Chart,
+		ReleaseName: entry.Name,
+		Version:     entry.TargetRevision,
+	})
+	if err != nil {
+		return fmt.Errorf("fetching chart for schema validation: %w", err)
+	}
+	return helm.ValidateValues(ch, vals)
+}
+
+// splitValuesKey splits a dotted key into its segments, honouring "\." escapes.
+func splitValuesKey(key string) ([]string, error) {
+	var (
+		parts []string
+		cur   strings.Builder
+	)
+	for i := 0; i < len(key); i++ {
+		switch {
+		case key[i] == '\\' && i+1 < len(key) && key[i+1] == '.':
+			cur.WriteByte('.')
+			i++
+		case key[i] == '.':
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(key[i])
+		}
+	}
+	parts = append(parts, cur.String())
+	for _, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("invalid key %q: empty path segment", key)
+		}
+	}
+	return parts, nil
+}
+
+// mappingValue returns the value node for key in mapping, or nil.
+func mappingValue(mapping *yamlv3.Node, key string) *yamlv3.Node {
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// valueScalar builds the node for a --set value. Quoted values are strings;
+// anything else is left untagged so the encoder resolves its type.
+func valueScalar(value string) *yamlv3.Node {
+	if n := len(value); n >= 2 && (value[0] == '"' && value[n-1] == '"' || value[0] == '\'' && value[n-1] == '\'') {
+		return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Style: yamlv3.DoubleQuotedStyle, Value: value[1 : n-1]}
+	}
+	return &yamlv3.Node{Kind: yamlv3.ScalarNode, Value: value}
+}
+
+// setValueInNode sets path to value, creating intermediate mappings.
+func setValueInNode(mapping *yamlv3.Node, path []string, value string) error {
+	for i, seg := range path {
+		last := i == len(path)-1
+		existing := mappingValue(mapping, seg)
+		if last {
+			scalar := valueScalar(value)
+			if existing == nil {
+				mapping.Content = append(mapping.Content, &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: seg}, scalar)
+				return nil
+			}
+			scalar.LineComment = existing.LineComment
+			*existing = *scalar
+			return nil
+		}
+		if existing == nil {
+			existing = &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map"}
+			mapping.Content = append(mapping.Content, &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: seg}, existing)
+		}
+		if existing.Kind != yamlv3.MappingNode {
+			return fmt.Errorf("%s is not a map", strings.Join(path[:i+1], "."))
+		}
+		mapping = existing
+	}
+	return nil
+}
+
+// unsetValueInNode removes path and reports whether it was present.
+func unsetValueInNode(mapping *yamlv3.Node, path []string) bool {
+	for _, seg := range path[:len(path)-1] {
+		next := mappingValue(mapping, seg)
+		if next == nil || next.Kind != yamlv3.MappingNode {
+			return false
+		}
+		mapping = next
+	}
+	leaf := path[len(path)-1]
+	for i := 0; i < len(mapping.Content)-1; i += 2 {
+		if mapping.Content[i].Value == leaf {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return true
+		}
+	}
+	return false
+}
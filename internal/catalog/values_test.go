@@ -0,0 +1,228 @@
+package catalog
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
+	"go.uber.org/zap"
+)
+
+func TestEditValues_SetPreservesComments(t *testing.T) {
+	t.Parallel()
+	in := `# Helm values for langfuse
+langfuse:
+  # public URL
+  nextauth:
+    url: http://localhost # default
+replicas: 1
+`
+	out, err := EditValues([]byte(in), map[string]string{
+		"langfuse.nextauth.url": "http://langfuse.local",
+		"langfuse.salt.value":   "s3cret",
+		"replicas":              "2",
+	}, nil)
+	if err != nil {
+		t.Fatalf("EditValues: %v", err)
+	}
+	got := string(out)
+	for _, want := range []string{
+		"# Helm values for langfuse",
+		"# public URL",
+		"url: http://langfuse.local # default",
+		"salt:\n    value: s3cret",
+		"replicas: 2",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+
+	vals, err := ParseValues(out)
+	if err != nil {
+		t.Fatalf("ParseValues: %v", err)
+	}
+	if vals["replicas"] != float64(2) {
+		t.Errorf("replicas = %#v, want number 2", vals["replicas"])
+	}
+}
+
+func TestEditValues_CommentOnlyFile(t *testing.T) {
+	t.Parallel()
+	out, err := EditValues([]byte("# Helm values for ollama\n"), map[string]string{"ollama.gpu.enabled": "true"}, nil)
+	if err != nil {
+		t.Fatalf("EditValues: %v", err)
+	}
+	vals, err := ParseValues(out)
+	if err != nil {
+		t.Fatalf("ParseValues: %v", err)
+	}
+	gpu := vals["ollama"].(map[string]interface{})["gpu"].(map[string]interface{})
+	if gpu["enabled"] != true {
+		t.Errorf("ollama.gpu.enabled = %#v, want true:\n%s", gpu["enabled"], out)
+	}
+	if !strings.Contains(string(out), "# Helm values for ollama") {
+		t.Errorf("header comment lost:\n%s", out)
+	}
+}
+
+func TestEditValues_EscapedDot(t *testing.T) {
+	t.Parallel()
+	out, err := EditValues(nil, map[string]string{`podAnnotations.prometheus\.io/scrape`: `"true"`}, nil)
+	if err != nil {
+		t.Fatalf("EditValues: %v", err)
+	}
+	vals, err := ParseValues(out)
+	if err != nil {
+		t.Fatalf("ParseValues: %v", err)
+	}
+	ann := vals["podAnnotations"].(map[string]interface{})
+	if got := ann["prometheus.io/scrape"]; got != "true" {
+		t.Errorf("prometheus.io/scrape = %#v, want string \"true\":\n%s", got, out)
+	}
+}
+
+func TestEditValues_Unset(t *testing.T) {
+	t.Parallel()
+	in := "a:\n  b: 1\n  c: 2\n"
+	out, err := EditValues([]byte(in), nil, []string{"a.b"})
+	if err != nil {
+		t.Fatalf("EditValues: %v", err)
+	}
+	if strings.Contains(string(out), "b:") || !strings.Contains(string(out), "c: 2") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	if _, err := EditValues([]byte(in), nil, []string{"a.missing"}); err == nil {
+		t.Error("expected error unsetting a missing key, got nil")
+	}
+}
+
+func TestEditValues_SetThroughScalarFails(t *testing.T) {
+	t.Parallel()
+	if _, err := EditValues([]byte("image: nginx\n"), map[string]string{"image.tag": "1.0"}, nil); err == nil {
+		t.Fatal("expected error setting a key below a scalar, got nil")
+	}
+}
+
+func TestParseSet(t *testing.T) {
+	t.Parallel()
+	key, value, err := ParseSet("a.b=c=d")
+	if err != nil || key != "a.b" || value != "c=d" {
+		t.Errorf("ParseSet = %q, %q, %v; want a.b, c=d, nil", key, value, err)
+	}
+	for _, bad := range []string{"novalue", "=x"} {
+		if _, _, err := ParseSet(bad); err == nil {
+			t.Errorf("ParseSet(%q): expected error", bad)
+		}
+	}
+}
+
+func TestSaveValues_WritesAndCommits(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, "name: ollama\nenabled: true\n", "ollama")
+	initGitRepo(t, dir)
+
+	if err := SaveValues(dir, "ollama", []byte("replicas: 2\n")); err != nil {
+		t.Fatalf("SaveValues: %v", err)
+	}
+	data, err := ReadValues(dir, "ollama")
+	if err != nil {
+		t.Fatalf("ReadValues: %v", err)
+	}
+	if string(data) != "replicas: 2\n" {
+		t.Errorf("values = %q", data)
+	}
+
+	out, err := exec.Command("git", "-C", dir, "log", "-1", "--format=%s").Output()
+	if err != nil {
+		t.Fatalf("git log: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "catalog: update values for ollama" {
+		t.Errorf("commit subject = %q", got)
+	}
+
+	if err := SaveValues(dir, "ollama", []byte("- not\n- a map\n")); err == nil {
+		t.Error("expected error saving a non-mapping document, got nil")
+	}
+	if err := SaveValues(dir, "missing", []byte("a: 1\n")); err == nil {
+		t.Error("expected error for an app not in the catalog, got nil")
+	}
+}
+
+func TestUpdateValues_ComparesUnderLock(t *testing.T) {
+	t.Setenv("SIKIFANSO_LOCK_TIMEOUT", "100ms")
+	dir := t.TempDir()
+	writeEntry(t, dir, "name: ollama\nenabled: true\n", "ollama")
+	initGitRepo(t, dir)
+
+	changed, err := UpdateValues(nil, dir, "ollama", []byte("replicas: 2\n"), true)
+	if err != nil || !changed {
+		t.Fatalf("UpdateValues = %v, %v; want true, nil", changed, err)
+	}
+	changed, err = UpdateValues(nil, dir, "ollama", []byte("replicas: 2\n"), true)
+	if err != nil || changed {
+		t.Fatalf("UpdateValues(unchanged) = %v, %v; want false, nil", changed, err)
+	}
+
+	// Even a no-op waits for the lock: the file may be mid-write.
+	l, err := lock.GitOps(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+	if _, err := UpdateValues(nil, dir, "ollama", []byte("replicas: 2\n"), true); !errors.Is(err, lock.ErrBusy) {
+		t.Errorf("UpdateValues while locked = %v, want ErrBusy", err)
+	}
+}
+
+func TestEditAndSaveValues_KeepsConcurrentEdits(t *testing.T) {
+	dir := t.TempDir()
+	writeEntry(t, dir, "name: ollama\nenabled: true\n", "ollama")
+	initGitRepo(t, dir)
+
+	if _, _, err := EditAndSaveValues(nil, dir, "ollama", map[string]string{"a": "1"}, nil, true); err != nil {
+		t.Fatalf("EditAndSaveValues(a): %v", err)
+	}
+	updated, changed, err := EditAndSaveValues(nil, dir, "ollama", map[string]string{"b": "2"}, nil, true)
+	if err != nil || !changed {
+		t.Fatalf("EditAndSaveValues(b) = %v, %v; want true, nil", changed, err)
+	}
+	if string(updated) != "a: 1\nb: 2\n" {
+		t.Errorf("values = %q, want both edits", updated)
+	}
+
+	// A write that lands while the schema check runs aborts the edit.
+	validateValues = func(*zap.Logger, Entry, []byte) error {
+		return os.WriteFile(filepath.Join(dir, ValuesFile("ollama")), []byte("c: 3\n"), 0o644)
+	}
+	defer func() { validateValues = ValidateValues }()
+	if _, _, err := EditAndSaveValues(nil, dir, "ollama", map[string]string{"d": "4"}, nil, false); !errors.Is(err, ErrValuesChanged) {
+		t.Errorf("EditAndSaveValues during a concurrent write = %v, want ErrValuesChanged", err)
+	}
+	if data, _ := ReadValues(dir, "ollama"); string(data) != "c: 3\n" {
+		t.Errorf("values = %q, want the concurrent write kept", data)
+	}
+}
+
+func TestReadValues_MissingFileIsEmpty(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeEntry(t, dir, "name: ollama\nenabled: true\n", "ollama")
+	if err := os.MkdirAll(filepath.Join(dir, "catalog", "values"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ReadValues(dir, "ollama")
+	if err != nil {
+		t.Fatalf("ReadValues: %v", err)
+	}
+	if data != nil {
+		t.Errorf("ReadValues = %q, want nil", data)
+	}
+}
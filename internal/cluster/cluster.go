@@ -10,6 +10,7 @@ import (
 
 	"github.com/alicanalbayrak/sikifanso/internal/argocd"
 	"github.com/alicanalbayrak/sikifanso/internal/argocd/grpcclient"
+	"github.com/alicanalbayrak/sikifanso/internal/bundle"
 	"github.com/alicanalbayrak/sikifanso/internal/cilium"
 	"github.com/alicanalbayrak/sikifanso/internal/gitops"
 	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
@@ -21,6 +22,7 @@ import (
 	conf "github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
 	k3drt "github.com/k3d-io/k3d/v5/pkg/runtimes"
 	k3d "github.com/k3d-io/k3d/v5/pkg/types"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
 
@@ -28,9 +30,27 @@ import (
 type Options struct {
 	BootstrapURL     string
 	BootstrapVersion string // tag to clone; "" means HEAD
+	// Servers, Agents and AgentPools override the topology from
+	// infra/platform.yaml when non-nil.
+	Servers    *int
+	Agents     *int
+	AgentPools []infraconfig.NodePool
+	// Registry creates a local image registry for the cluster; see
+	// session.RegistryHost.
+	Registry bool
+	// Bundle is an offline bundle archive (see bundle.Create). The cluster
+	// is then created from its bootstrap tree, images and charts, and the
+	// bootstrap options are taken from the bundle.
+	Bundle string
+	// Preflight, when set, runs once the gitops repo is scaffolded and before
+	// any container is created, so checks that need the catalog can still
+	// abort the creation.
+	Preflight func(gitOpsPath string) error
 }
 
-// Create creates a new single-server k3d cluster using the SimpleConfig pipeline.
+// Create creates a new k3d cluster using the SimpleConfig pipeline. Agent
+// pool nodes are labelled and tainted so only workloads that opt in, such
+// as agent sandboxes, are scheduled there.
 func Create(ctx context.Context, log *zap.Logger, name string, opts Options) (*session.Session, error) {
 	exists, err := Exists(ctx, name)
 	if err != nil {
@@ -53,41 +73,96 @@ func Create(ctx context.Context, log *zap.Logger, name string, opts Options) (*s
 		zap.Int("hubbleUI", hp.HubbleUI),
 	)
 
+	var registry *session.RegistryInfo
+	if opts.Registry {
+		ports, err := findFreePorts(1)
+		if err != nil {
+			return nil, fmt.Errorf("resolving registry port: %w", err)
+		}
+		registry = &session.RegistryInfo{Name: registryName(name), HostPort: ports[0]}
+		log.Info("creating local registry", zap.String("registry", registry.Name), zap.Int("hostPort", registry.HostPort))
+	}
+
 	// Scaffold gitops repo before cluster creation so the directory exists for the volume mount.
 	// Remove any stale session directory left over from a previous failed creation.
 	if err := session.Remove(name); err != nil {
 		log.Warn("failed to clean up stale session directory", zap.Error(err))
 	}
 
+	var b *bundle.Bundle
+	scaffoldOpts := gitops.ScaffoldOptions{
+		RepoURL: opts.BootstrapURL,
+		Version: opts.BootstrapVersion,
+	}
+	if opts.Bundle != "" {
+		if b, err = openBundle(log, name, opts.Bundle); err != nil {
+			return nil, err
+		}
+		scaffoldOpts = gitops.ScaffoldOptions{
+			RepoURL:   b.Manifest.BootstrapURL,
+			Version:   b.Manifest.BootstrapVersion,
+			SourceDir: b.BootstrapDir(),
+		}
+	}
+
 	gitopsDir, err := session.GitOpsDir(name)
 	if err != nil {
 		return nil, fmt.Errorf("resolving gitops directory: %w", err)
 	}
-	if err := gitops.Scaffold(ctx, log, gitopsDir, gitops.ScaffoldOptions{
-		RepoURL: opts.BootstrapURL,
-		Version: opts.BootstrapVersion,
-	}); err != nil {
+	if err := gitops.Scaffold(ctx, log, gitopsDir, scaffoldOpts); err != nil {
 		return nil, fmt.Errorf("scaffolding gitops repo: %w", err)
 	}
+	if b != nil {
+		missing, err := b.Localize(gitopsDir)
+		if err != nil {
+			return nil, fmt.Errorf("pointing gitops repo at the bundled charts: %w", err)
+		}
+		if len(missing) > 0 {
+			log.Warn("catalog apps not in the bundle cannot be enabled offline", zap.Strings("apps", missing))
+		}
+	}
 
 	// Load infrastructure config from the bootstrap repo, falling back to defaults.
 	cfg, err := infraconfig.Load(gitopsDir)
 	if err != nil {
 		return nil, fmt.Errorf("loading infrastructure config: %w", err)
 	}
+	if err := applyTopology(&cfg.Platform, opts); err != nil {
+		return nil, err
+	}
+	if opts.Preflight != nil {
+		if err := opts.Preflight(gitopsDir); err != nil {
+			if rmErr := session.Remove(name); rmErr != nil {
+				log.Warn("failed to clean up session directory", zap.Error(rmErr))
+			}
+			return nil, err
+		}
+	}
 
 	// Prevent k3d DNS fix that breaks Docker Desktop.
 	// See: https://github.com/k3d-io/k3d/issues/1515
 	_ = os.Setenv("K3D_FIX_DNS", "0")
 
+	if b != nil {
+		log.Info("loading bundled k3s and k3d images")
+		if err := b.LoadHostImages(ctx); err != nil {
+			return nil, fmt.Errorf("loading bundled images: %w", err)
+		}
+	}
+
 	// The scaffold above deleted and recreated gitopsDir. On Docker Desktop that
 	// leaves the VM serving stale virtiofs dentries for it, which breaks the bind
 	// mount k3d is about to make. Heal the cache before creating the cluster.
 	prewarmGitOpsMount(ctx, log, gitopsDir, cfg.Platform.K3sImage)
 
-	log.Info("creating k3d cluster", zap.String("cluster", name))
+	log.Info("creating k3d cluster",
+		zap.String("cluster", name),
+		zap.Int("servers", cfg.Platform.Servers),
+		zap.Int("agents", totalAgents(cfg.Platform)),
+	)
 
 	np := cfg.Platform.NodePorts
+	poolArgs, poolLabels := poolOptions(cfg.Platform)
 	simpleCfg := conf.SimpleConfig{
 		TypeMeta: configtypes.TypeMeta{
 			Kind:       "Simple",
@@ -97,7 +172,7 @@ func Create(ctx context.Context, log *zap.Logger, name string, opts Options) (*s
 			Name: name,
 		},
 		Servers: cfg.Platform.Servers,
-		Agents:  cfg.Platform.Agents,
+		Agents:  totalAgents(cfg.Platform),
 		Image:   cfg.Platform.K3sImage,
 		ExposeAPI: conf.SimpleExposureOpts{
 			HostPort: fmt.Sprintf("%d", hp.APIServer),
@@ -116,16 +191,24 @@ func Create(ctx context.Context, log *zap.Logger, name string, opts Options) (*s
 		},
 		Options: conf.SimpleConfigOptions{
 			K3sOptions: conf.SimpleConfigOptionsK3s{
-				ExtraArgs: []conf.K3sArgWithNodeFilters{
+				ExtraArgs: append([]conf.K3sArgWithNodeFilters{
 					{Arg: "--flannel-backend=none", NodeFilters: []string{"server:*"}},
 					{Arg: "--disable-network-policy", NodeFilters: []string{"server:*"}},
 					{Arg: "--disable=traefik", NodeFilters: []string{"server:*"}},
 					{Arg: "--disable=servicelb", NodeFilters: []string{"server:*"}},
-				},
+				}, poolArgs...),
+				NodeLabels: poolLabels,
 			},
 		},
 	}
 
+	if registry != nil {
+		simpleCfg.Registries = registryOptions(name, registry.HostPort)
+	}
+	if b != nil {
+		simpleCfg.Volumes = append(simpleCfg.Volumes, bundleVolumes(b)...)
+	}
+
 	if err := k3dconfig.ProcessSimpleConfig(&simpleCfg); err != nil {
 		return nil, fmt.Errorf("processing simple config: %w", err)
 	}
@@ -165,7 +248,7 @@ func Create(ctx context.Context, log *zap.Logger, name string, opts Options) (*s
 		return nil, fmt.Errorf("building rest config: %w", err)
 	}
 
-	argocdResult, err := installInfra(ctx, log, restCfg, name, hp, cfg, gitopsDir)
+	argocdResult, err := installInfra(ctx, log, restCfg, name, hp, cfg, gitopsDir, b)
 	if err != nil {
 		return nil, err
 	}
@@ -175,8 +258,8 @@ func Create(ctx context.Context, log *zap.Logger, name string, opts Options) (*s
 		ClusterName:      name,
 		State:            "running",
 		CreatedAt:        time.Now(),
-		BootstrapURL:     opts.BootstrapURL,
-		BootstrapVersion: opts.BootstrapVersion,
+		BootstrapURL:     scaffoldOpts.RepoURL,
+		BootstrapVersion: scaffoldOpts.Version,
 		GitOpsPath:       gitopsDir,
 		Services: session.ServiceInfo{
 			ArgoCD: session.ArgoCDInfo{
@@ -192,10 +275,15 @@ func Create(ctx context.Context, log *zap.Logger, name string, opts Options) (*s
 			},
 		},
 		K3dConfig: session.K3dConfigInfo{
-			Image:   cfg.Platform.K3sImage,
-			Servers: cfg.Platform.Servers,
-			Agents:  cfg.Platform.Agents,
+			Image:      cfg.Platform.K3sImage,
+			Servers:    cfg.Platform.Servers,
+			Agents:     cfg.Platform.Agents,
+			AgentPools: cfg.Platform.AgentPools,
 		},
+		Registry: registry,
+	}
+	if b != nil {
+		sess.Bundle = &session.BundleInfo{File: b.Path, ChartRepo: bundle.ChartRepoURL}
 	}
 
 	if err := session.Save(sess); err != nil {
@@ -207,25 +295,42 @@ func Create(ctx context.Context, log *zap.Logger, name string, opts Options) (*s
 }
 
 // installInfra installs Cilium + ArgoCD, waits for gRPC readiness, creates
-// the initial Application CRDs, and applies the root ApplicationSet.
-func installInfra(ctx context.Context, log *zap.Logger, restCfg *rest.Config, name string, hp HostPorts, cfg *infraconfig.InfraConfig, gitopsDir string) (*argocd.InstallResult, error) {
+// the initial Application CRDs, and applies the root ApplicationSet. With a
+// bundle, both charts are installed from its archives and the in-cluster
+// chart repository is started once Cilium provides pod networking.
+func installInfra(ctx context.Context, log *zap.Logger, restCfg *rest.Config, name string, hp HostPorts, cfg *infraconfig.InfraConfig, gitopsDir string, b *bundle.Bundle) (*argocd.InstallResult, error) {
 	np := cfg.Platform.NodePorts
+	ciliumChart, argocdChart := cfg.Cilium, cfg.ArgoCD
+	if b != nil {
+		ciliumChart = b.LocalChart("cilium", cfg.Cilium)
+		argocdChart = b.LocalChart("argocd", cfg.ArgoCD)
+	}
 
 	// Cilium values: base config + runtime overrides (node ports).
 	ciliumValues := infraconfig.MergeValues(cfg.CiliumValues, infraconfig.CiliumRuntimeOverrides(np, ""))
 
-	ciliumResult, err := cilium.Install(ctx, log, restCfg, name, cfg.Cilium, ciliumValues)
+	ciliumResult, err := cilium.Install(ctx, log, restCfg, name, ciliumChart, ciliumValues)
 	if err != nil {
 		return nil, fmt.Errorf("installing cilium: %w", err)
 	}
 
+	if b != nil {
+		cs, err := kubernetes.NewForConfig(restCfg)
+		if err != nil {
+			return nil, fmt.Errorf("creating kubernetes client: %w", err)
+		}
+		if err := bundle.ServeCharts(ctx, log, cs); err != nil {
+			return nil, err
+		}
+	}
+
 	// Update cilium values with the actual API server IP for the ArgoCD Application CRD.
 	ciliumValues = infraconfig.MergeValues(cfg.CiliumValues, infraconfig.CiliumRuntimeOverrides(np, ciliumResult.APIServerIP))
 
 	// ArgoCD values: base config + runtime overrides (node port).
 	argocdValues := infraconfig.MergeValues(cfg.ArgoCDValues, infraconfig.ArgoCDRuntimeOverrides(np))
 
-	argocdResult, err := argocd.Install(ctx, log, restCfg, cfg.ArgoCD, argocdValues)
+	argocdResult, err := argocd.Install(ctx, log, restCfg, argocdChart, argocdValues)
 	if err != nil {
 		return nil, fmt.Errorf("installing argocd: %w", err)
 	}
@@ -0,0 +1,45 @@
+package cluster
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/alicanalbayrak/sikifanso/internal/bundle"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	conf "github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+	"go.uber.org/zap"
+)
+
+// openBundle unpacks the bundle archive at path into the cluster's session
+// directory, where the node mounts from bundleVolumes point.
+func openBundle(log *zap.Logger, clusterName, path string) (*bundle.Bundle, error) {
+	dir, err := session.BundleDir(clusterName)
+	if err != nil {
+		return nil, fmt.Errorf("resolving bundle directory: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("unpacking offline bundle", zap.String("bundle", abs), zap.String("target", dir))
+	b, err := bundle.Open(abs, dir)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("offline bundle ready",
+		zap.String("bootstrap", b.Manifest.BootstrapURL),
+		zap.Int("charts", len(b.Manifest.Charts)),
+		zap.Int("images", len(b.Manifest.Images)),
+	)
+	return b, nil
+}
+
+// bundleVolumes mounts the bundled charts and cluster images into every
+// node: the charts for the in-cluster chart repository, the images where
+// k3s imports them at startup.
+func bundleVolumes(b *bundle.Bundle) []conf.VolumeWithNodeFilters {
+	return []conf.VolumeWithNodeFilters{
+		{Volume: b.ChartsDir() + ":" + bundle.NodeChartsDir, NodeFilters: []string{"all"}},
+		{Volume: b.ImagesTar() + ":" + bundle.NodeImagesTar, NodeFilters: []string{"all"}},
+	}
+}
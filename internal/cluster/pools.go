@@ -0,0 +1,63 @@
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
+	conf "github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+)
+
+// applyTopology overrides the node counts and pools of p with those set in
+// opts and validates the result.
+func applyTopology(p *infraconfig.PlatformConfig, opts Options) error {
+	if opts.Servers != nil {
+		p.Servers = *opts.Servers
+	}
+	if opts.Agents != nil {
+		p.Agents = *opts.Agents
+	}
+	if opts.AgentPools != nil {
+		p.AgentPools = opts.AgentPools
+	}
+	return p.ValidateTopology()
+}
+
+// totalAgents returns the number of k3d agent nodes p needs: the
+// general-purpose ones plus every pool's.
+func totalAgents(p infraconfig.PlatformConfig) int {
+	n := p.Agents
+	for _, pool := range p.AgentPools {
+		n += pool.Count
+	}
+	return n
+}
+
+// poolOptions returns the k3s node labels and taints that turn k3d agent
+// nodes into pools. General-purpose agents take the first indices, then
+// each pool takes the next Count agents in order.
+func poolOptions(p infraconfig.PlatformConfig) ([]conf.K3sArgWithNodeFilters, []conf.LabelWithNodeFilters) {
+	var (
+		args   []conf.K3sArgWithNodeFilters
+		labels []conf.LabelWithNodeFilters
+	)
+	next := p.Agents
+	for _, pool := range p.AgentPools {
+		indices := make([]string, 0, pool.Count)
+		for i := 0; i < pool.Count; i++ {
+			indices = append(indices, strconv.Itoa(next+i))
+		}
+		next += pool.Count
+		filter := []string{"agent:" + strings.Join(indices, ",")}
+		args = append(args, conf.K3sArgWithNodeFilters{
+			Arg:         "--node-taint=" + pool.Taint(),
+			NodeFilters: filter,
+		})
+		labels = append(labels, conf.LabelWithNodeFilters{
+			Label:       fmt.Sprintf("%s=%s", infraconfig.PoolLabel, pool.Name),
+			NodeFilters: filter,
+		})
+	}
+	return args, labels
+}
@@ -0,0 +1,56 @@
+package cluster
+
+import (
+	"testing"
+
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
+)
+
+func TestApplyTopology(t *testing.T) {
+	t.Parallel()
+	p := infraconfig.PlatformConfig{Servers: 1, Agents: 2, AgentPools: []infraconfig.NodePool{{Name: "gpu", Count: 1}}}
+	servers := 3
+	if err := applyTopology(&p, Options{Servers: &servers, AgentPools: []infraconfig.NodePool{{Name: "sandbox", Count: 2}}}); err != nil {
+		t.Fatal(err)
+	}
+	if p.Servers != 3 || p.Agents != 2 {
+		t.Errorf("servers/agents = %d/%d, want 3/2 (agents from platform.yaml)", p.Servers, p.Agents)
+	}
+	if len(p.AgentPools) != 1 || p.AgentPools[0].Name != "sandbox" {
+		t.Errorf("pools = %v, want the sandbox pool only", p.AgentPools)
+	}
+	if got := totalAgents(p); got != 4 {
+		t.Errorf("totalAgents = %d, want 4", got)
+	}
+
+	zero := 0
+	if err := applyTopology(&p, Options{Servers: &zero}); err == nil {
+		t.Error("expected an error for zero servers")
+	}
+}
+
+func TestPoolOptions(t *testing.T) {
+	t.Parallel()
+	args, labels := poolOptions(infraconfig.PlatformConfig{
+		Servers: 1,
+		Agents:  1,
+		AgentPools: []infraconfig.NodePool{
+			{Name: "sandbox", Count: 2},
+			{Name: "gpu", Count: 1},
+		},
+	})
+	if len(args) != 2 || len(labels) != 2 {
+		t.Fatalf("got %d taints and %d labels, want 2 each", len(args), len(labels))
+	}
+
+	// Agent 0 stays general-purpose; the pools take agents 1-2 and 3.
+	if args[0].Arg != "--node-taint=sikifanso.io/pool=sandbox:NoSchedule" || args[0].NodeFilters[0] != "agent:1,2" {
+		t.Errorf("sandbox taint = %+v", args[0])
+	}
+	if labels[0].Label != "sikifanso.io/pool=sandbox" || labels[0].NodeFilters[0] != "agent:1,2" {
+		t.Errorf("sandbox label = %+v", labels[0])
+	}
+	if labels[1].Label != "sikifanso.io/pool=gpu" || labels[1].NodeFilters[0] != "agent:3" {
+		t.Errorf("gpu label = %+v", labels[1])
+	}
+}
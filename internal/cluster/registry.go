@@ -0,0 +1,37 @@
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	conf "github.com/k3d-io/k3d/v5/pkg/config/v1alpha5"
+)
+
+// registryPort is the port the registry listens on inside the cluster
+// network.
+const registryPort = 5000
+
+// registryName returns the container name of the cluster's registry, which
+// is also its hostname on the cluster network.
+func registryName(clusterName string) string {
+	return "k3d-" + clusterName + "-registry"
+}
+
+// registryOptions returns the k3d config that creates a registry attached to
+// the cluster network, published on hostPort, with a k3s registries.yaml
+// mirror so nodes pull session.RegistryHost images from it.
+func registryOptions(clusterName string, hostPort int) conf.SimpleConfigRegistries {
+	name := registryName(clusterName)
+	return conf.SimpleConfigRegistries{
+		Create: &conf.SimpleConfigRegistryCreateConfig{
+			Name:     name,
+			Host:     "127.0.0.1",
+			HostPort: strconv.Itoa(hostPort),
+		},
+		// Multi-line, so k3d reads it as registries.yaml content rather
+		// than a path.
+		Config: fmt.Sprintf("mirrors:\n  %q:\n    endpoint:\n      - http://%s:%d\n",
+			session.RegistryHost, name, registryPort),
+	}
+}
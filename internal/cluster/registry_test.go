@@ -0,0 +1,21 @@
+package cluster
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryOptions(t *testing.T) {
+	t.Parallel()
+	reg := registryOptions("dev", 41234)
+	if reg.Create == nil || reg.Create.Name != "k3d-dev-registry" || reg.Create.HostPort != "41234" {
+		t.Fatalf("create = %+v", reg.Create)
+	}
+	// k3d reads a single-line Config as a file path.
+	if !strings.Contains(reg.Config, "\n") {
+		t.Fatal("registries config must be multi-line")
+	}
+	if !strings.Contains(reg.Config, `"registry.local"`) || !strings.Contains(reg.Config, "http://k3d-dev-registry:5000") {
+		t.Errorf("registries config does not mirror registry.local to the registry:\n%s", reg.Config)
+	}
+}
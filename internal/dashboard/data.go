@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
 	"github.com/alicanalbayrak/sikifanso/internal/catalog"
 	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
 	"github.com/alicanalbayrak/sikifanso/internal/kube"
@@ -31,11 +32,21 @@ type AppStatus struct {
 	SyncStatus string `json:"syncStatus"` // Synced, OutOfSync, Unknown, etc.
 }
 
+// AgentStatus is an agent sandbox and whether it is frozen.
+type AgentStatus struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Frozen    bool   `json:"frozen"`
+	FrozenBy  string `json:"frozenBy,omitempty"`
+	FrozenAt  string `json:"frozenAt,omitempty"`
+}
+
 // ClusterData aggregates cluster information for dashboard rendering.
 type ClusterData struct {
 	ClusterName string           `json:"clusterName"`
 	Session     *session.Session `json:"-"`
 	CatalogApps []AppStatus      `json:"catalogApps"`
+	Agents      []AgentStatus    `json:"agents"`
 	NodeCount   int              `json:"nodeCount"`
 	NodesReady  int              `json:"nodesReady"`
 	ArgoCDURL   string           `json:"argocdURL"`
@@ -70,6 +81,8 @@ func Gather(ctx context.Context, clusterName string) (*ClusterData, error) {
 		}
 	}
 
+	data.Agents = gatherAgents(ctx, sess)
+
 	// Gather catalog app statuses.
 	entries, err := catalog.List(sess.GitOpsPath)
 	if err != nil {
@@ -133,6 +146,27 @@ func Gather(ctx context.Context, clusterName string) (*ClusterData, error) {
 	return data, nil
 }
 
+// gatherAgents lists the cluster's agents with their freeze state. Agents
+// are reported unfrozen when the cluster cannot be reached.
+func gatherAgents(ctx context.Context, sess *session.Session) []AgentStatus {
+	agents, err := agent.List(sess.GitOpsPath)
+	if err != nil {
+		return nil
+	}
+	f, ferr := agent.NewFreezer(sess)
+	out := make([]AgentStatus, 0, len(agents))
+	for _, a := range agents {
+		as := AgentStatus{Name: a.Name, Namespace: a.Namespace}
+		if ferr == nil {
+			if rec, err := f.Frozen(ctx, a.Name); err == nil && rec != nil {
+				as.Frozen, as.FrozenBy, as.FrozenAt = true, rec.By+" via "+rec.Via, rec.At
+			}
+		}
+		out = append(out, as)
+	}
+	return out
+}
+
 func queryAppStatus(ctx context.Context, dynClient dynamic.Interface, appName, namespace string) (health, syncStatus string) {
 	app, err := dynClient.Resource(applicationGVR).Namespace(namespace).Get(ctx, appName, metav1.GetOptions{})
 	if err != nil {
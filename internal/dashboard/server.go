@@ -4,14 +4,16 @@ import (
 	"context"
 	"embed"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"html/template"
 	"io/fs"
 	"net/http"
 
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
 	"github.com/alicanalbayrak/sikifanso/internal/argocd/appsetreconcile"
 	"github.com/alicanalbayrak/sikifanso/internal/catalog"
 	"github.com/alicanalbayrak/sikifanso/internal/kube"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
 	"github.com/alicanalbayrak/sikifanso/internal/session"
 	"go.uber.org/zap"
 )
@@ -74,6 +76,8 @@ func NewServer(opts ServerOpts) *http.Server {
 	mux.HandleFunc("GET /", handleIndex(opts))
 	mux.HandleFunc("GET /api/status", handleStatus(opts))
 	mux.HandleFunc("POST /api/catalog/{name}/toggle", handleToggle(opts))
+	mux.HandleFunc("POST /api/agents/{name}/freeze", handleFreeze(opts, true))
+	mux.HandleFunc("POST /api/agents/{name}/unfreeze", handleFreeze(opts, false))
 
 	return &http.Server{
 		Addr:    opts.Addr,
@@ -140,7 +144,35 @@ func handleToggle(opts ServerOpts) http.HandlerFunc {
 			return
 		}
 
-		result, err := catalog.Flip(sess.GitOpsPath, name)
+		entry, err := catalog.Find(sess.GitOpsPath, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		// Disabling an app that others depend on needs explicit consent: the
+		// client gets the dependents back and may retry with ?cascade=true.
+		cascade := r.URL.Query().Get("cascade") == "true"
+		mode := catalog.DisableSafe
+		if entry.Enabled {
+			if cascade {
+				mode = catalog.DisableCascade
+			} else if all, listErr := catalog.List(sess.GitOpsPath); listErr == nil {
+				if dependents := catalog.TransitiveDependents(name, all); len(dependents) > 0 {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusConflict)
+					_ = json.NewEncoder(w).Encode(toggleResponse{Name: name, Enabled: true, Dependents: dependents})
+					return
+				}
+			}
+		}
+
+		result, err := catalog.ToggleWithDeps(sess.GitOpsPath, name, !entry.Enabled, mode)
+		if errors.Is(err, lock.ErrBusy) {
+			// Another surface is mutating the cluster; the client may retry.
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
 		if err != nil {
 			opts.Log.Error("toggling app", zap.String("app", name), zap.Error(err))
 			http.Error(w, "failed to toggle app", http.StatusInternalServerError)
@@ -161,6 +193,59 @@ func handleToggle(opts ServerOpts) http.HandlerFunc {
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		_, _ = fmt.Fprintf(w, `{"name":%q,"enabled":%v}`, name, result.Enabled)
+		_ = json.NewEncoder(w).Encode(toggleResponse{
+			Name:     name,
+			Enabled:  result.Enabled,
+			AutoDeps: result.AutoDeps,
+			Cascaded: result.Cascaded,
+		})
+	}
+}
+
+// handleFreeze freezes or unfreezes an agent. A freeze reason may be passed
+// as ?reason=.
+func handleFreeze(opts ServerOpts, freeze bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		sess, err := session.Load(opts.ClusterName)
+		if err != nil {
+			http.Error(w, "failed to load session", http.StatusInternalServerError)
+			return
+		}
+		if _, err := agent.Find(sess.GitOpsPath, name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		f, err := agent.NewFreezer(sess)
+		if err != nil {
+			opts.Log.Error("connecting to cluster", zap.Error(err))
+			http.Error(w, "failed to connect to cluster", http.StatusInternalServerError)
+			return
+		}
+
+		var rec *agent.FreezeRecord
+		if freeze {
+			rec, err = f.Freeze(r.Context(), name, agent.CurrentActor("dashboard", r.URL.Query().Get("reason")))
+		} else {
+			rec, err = f.Unfreeze(r.Context(), name, agent.CurrentActor("dashboard", ""))
+		}
+		if err != nil {
+			opts.Log.Error("freezing agent", zap.String("agent", name), zap.Bool("freeze", freeze), zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(rec)
 	}
 }
+
+// toggleResponse is the JSON body returned by the toggle endpoint. On a 409
+// Conflict, Dependents lists the enabled apps that a cascade would disable.
+type toggleResponse struct {
+	Name       string   `json:"name"`
+	Enabled    bool     `json:"enabled"`
+	AutoDeps   []string `json:"autoDeps,omitempty"`
+	Cascaded   []string `json:"cascaded,omitempty"`
+	Dependents []string `json:"dependents,omitempty"`
+}
@@ -55,6 +55,12 @@
           }
         }
 
+        // Update agent rows.
+        var agents = data.agents || [];
+        for (var k = 0; k < agents.length; k++) {
+          updateAgentRow(agents[k]);
+        }
+
         // Update timestamp.
         var tsEl = document.getElementById('last-refresh');
         if (tsEl) {
@@ -66,6 +72,25 @@
       });
   }
 
+  function updateAgentRow(agent) {
+    var row = document.getElementById('agent-' + agent.name);
+    if (!row) return;
+
+    var badge = row.querySelector('.frozen-badge');
+    if (badge) {
+      badge.textContent = agent.frozen ? 'frozen' : 'running';
+      badge.className = 'badge frozen-badge ' + (agent.frozen ? 'degraded' : 'healthy');
+    }
+    var by = row.querySelector('.frozen-by');
+    if (by) {
+      by.textContent = agent.frozen ? agent.frozenBy + ' at ' + agent.frozenAt : '-';
+    }
+    var btn = row.querySelector('.freeze-btn');
+    if (btn && !btn.disabled) {
+      btn.textContent = agent.frozen ? 'Unfreeze' : 'Freeze';
+    }
+  }
+
   function badgeClass(status) {
     switch (status) {
       case 'Healthy': case 'Synced': return 'healthy';
@@ -75,15 +100,37 @@
     }
   }
 
+  function postToggle(name, cascade) {
+    var url = '/api/catalog/' + encodeURIComponent(name) + '/toggle';
+    if (cascade) url += '?cascade=true';
+    return fetch(url, { method: 'POST' }).then(function(resp) {
+      if (resp.status === 409) {
+        return resp.json().then(function(body) {
+          var msg = name + ' is required by ' + body.dependents.join(', ') +
+            '.\n\nDisable them too?';
+          if (!window.confirm(msg)) return null;
+          return postToggle(name, true);
+        });
+      }
+      if (resp.status === 503) {
+        return resp.text().then(function(msg) {
+          window.alert(name + ' not toggled: ' + msg.trim());
+          return null;
+        });
+      }
+      if (!resp.ok) throw new Error('toggle failed');
+      return resp.json();
+    });
+  }
+
   window.toggleApp = function(name, btn) {
     btn.disabled = true;
     btn.textContent = '...';
-    fetch('/api/catalog/' + encodeURIComponent(name) + '/toggle', { method: 'POST' })
-      .then(function(resp) {
-        if (!resp.ok) throw new Error('toggle failed');
-        return resp.json();
-      })
-      .then(function() {
+    postToggle(name, false)
+      .then(function(result) {
+        if (result && result.cascaded && result.cascaded.length) {
+          console.info('cascade-disabled:', result.cascaded.join(', '));
+        }
         updateStatus();
         btn.disabled = false;
         btn.textContent = 'Toggle';
@@ -95,5 +142,32 @@
       });
   };
 
+  window.freezeAgent = function(name, btn) {
+    var freeze = btn.textContent === 'Freeze';
+    var url = '/api/agents/' + encodeURIComponent(name) + (freeze ? '/freeze' : '/unfreeze');
+    if (freeze) {
+      var reason = window.prompt('Freeze ' + name + '? This denies all of its traffic and scales it to zero.\n\nReason:');
+      if (reason === null) return;
+      if (reason) url += '?reason=' + encodeURIComponent(reason);
+    }
+    var label = btn.textContent;
+    btn.disabled = true;
+    btn.textContent = '...';
+    fetch(url, { method: 'POST' })
+      .then(function(resp) {
+        if (!resp.ok) {
+          return resp.text().then(function(msg) { throw new Error(msg); });
+        }
+        btn.disabled = false;
+        updateStatus();
+      })
+      .catch(function(err) {
+        console.error('freeze failed:', err);
+        window.alert((freeze ? 'Freeze' : 'Unfreeze') + ' failed: ' + err.message);
+        btn.disabled = false;
+        btn.textContent = label;
+      });
+  };
+
   setInterval(updateStatus, POLL_INTERVAL);
 })();
@@ -0,0 +1,98 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"k8s.io/client-go/kubernetes"
+)
+
+const checkNameAgentPolicy = "Agent network policy"
+
+// PolicyProbeTimeout bounds the probe AgentPolicyCheck runs. It is shorter
+// than agent.DefaultProbeTimeout so a slow image pull cannot stall doctor.
+const PolicyProbeTimeout = 30 * time.Second
+
+// AgentPolicyCheck runs the network policy probe from one agent sandbox to
+// catch agent-template chart regressions. Every sandbox renders the same
+// policy, so the first agent that is running and not frozen stands in for
+// all of them. The check is skipped without a Client or agents.
+//
+// Unlike the other checks it creates a pod in the cluster, so it is not part
+// of AppChecks; doctor runs it only when asked to.
+type AgentPolicyCheck struct {
+	Client     kubernetes.Interface
+	GitOpsPath string
+	// Timeout bounds the probe; zero means PolicyProbeTimeout.
+	Timeout time.Duration
+}
+
+func (c AgentPolicyCheck) Run(ctx context.Context) []Result {
+	if c.Client == nil {
+		return nil
+	}
+	agents, err := agent.List(c.GitOpsPath)
+	if err != nil || len(agents) == 0 {
+		return nil // AgentsCheck reports listing errors
+	}
+
+	freezer := &agent.Freezer{Kube: c.Client}
+	now := time.Now()
+	for _, a := range agents {
+		if a.Expired(now) {
+			continue
+		}
+		// Frozen fails when the namespace does not exist yet.
+		if rec, err := freezer.Frozen(ctx, a.Name); err != nil || rec != nil {
+			continue
+		}
+		timeout := c.Timeout
+		if timeout == 0 {
+			timeout = PolicyProbeTimeout
+		}
+		probe := &agent.PolicyProbe{Kube: c.Client, Image: agent.DefaultProbeImage, Timeout: timeout}
+		results, err := probe.Test(ctx, c.GitOpsPath, a.Name, "")
+		if err != nil {
+			return []Result{{
+				Name:  checkNameAgentPolicy,
+				OK:    false,
+				Cause: fmt.Sprintf("running the policy probe in agent %s: %v", a.Name, err),
+				Fix:   fmt.Sprintf("sikifanso agent policy test %s", a.Name),
+			}}
+		}
+		return []Result{policyResult(a.Name, results)}
+	}
+	return nil
+}
+
+// policyResult summarizes a probe run from agent name.
+func policyResult(name string, results []agent.ProbeResult) Result {
+	var mismatches []string
+	for _, r := range results {
+		if r.Pass() {
+			continue
+		}
+		verdict := "denied"
+		if r.Allowed {
+			verdict = "allowed"
+		}
+		mismatches = append(mismatches, r.Name+" "+verdict)
+	}
+	if len(mismatches) == 0 {
+		return Result{
+			Name:    checkNameAgentPolicy,
+			OK:      true,
+			Message: fmt.Sprintf("%d targets match the sandbox policy (probed from %s)", len(results), name),
+		}
+	}
+	return Result{
+		Name:    checkNameAgentPolicy,
+		OK:      false,
+		Message: fmt.Sprintf("from %s: %s", name, strings.Join(mismatches, ", ")),
+		Cause:   "the sandbox network policy does not enforce the expected isolation; check the agent-template chart version",
+		Fix:     fmt.Sprintf("sikifanso agent policy test %s", name),
+	}
+}
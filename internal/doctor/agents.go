@@ -3,23 +3,36 @@ package doctor
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/alicanalbayrak/sikifanso/internal/agent"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 )
 
 const checkNameAgents = "Agents"
 
 // AgentsCheck verifies the health and sync status of each agent
-// by querying the ArgoCD Application CRD via the dynamic client.
+// by querying the ArgoCD Application CRD via the dynamic client. When Client
+// is set, agents whose namespace quota is exhausted are flagged too.
 type AgentsCheck struct {
 	DynClient       dynamic.Interface
+	Client          kubernetes.Interface
 	GitOpsPath      string
 	ArgoCDNamespace string
 }
 
+// quotaFlags maps ResourceQuota keys to the agent update flag that raises them.
+var quotaFlags = map[string]string{
+	"requests.cpu":    "--cpu-request",
+	"limits.cpu":      "--cpu-limit",
+	"requests.memory": "--memory-request",
+	"limits.memory":   "--memory-limit",
+	"pods":            "--pods",
+}
+
 func (c AgentsCheck) Run(ctx context.Context) []Result {
 	agents, err := agent.List(c.GitOpsPath)
 	if err != nil {
@@ -44,6 +57,16 @@ func (c AgentsCheck) Run(ctx context.Context) []Result {
 func (c AgentsCheck) checkAgent(ctx context.Context, a agent.Info) Result {
 	name := fmt.Sprintf("Agent: %s", a.Name)
 
+	if now := time.Now(); a.Expired(now) {
+		return Result{
+			Name:    name,
+			OK:      false,
+			Message: fmt.Sprintf("expired %s ago", now.Sub(*a.ExpiresAt).Round(time.Minute)),
+			Cause:   "the agent's TTL elapsed but it was not reaped",
+			Fix:     "sikifanso agent reap",
+		}
+	}
+
 	ns := c.ArgoCDNamespace
 	if ns == "" {
 		ns = "argocd"
@@ -72,11 +95,33 @@ func (c AgentsCheck) checkAgent(ctx context.Context, a agent.Info) Result {
 	healthStatus, _, _ := unstructured.NestedString(status, "health", "status")
 	syncStatus, _, _ := unstructured.NestedString(status, "sync", "status")
 
+	// An exhausted quota is checked first: it is often why the agent's
+	// workloads are degraded, and it has a direct fix.
+	var near []agent.QuotaUsage
+	if c.Client != nil {
+		if usage, err := (&agent.UsageReader{Kube: c.Client}).Read(ctx, a.Namespace); err == nil {
+			if full := usage.AtLimit(100); len(full) > 0 {
+				return Result{
+					Name:    name,
+					OK:      false,
+					Message: "at quota: " + agent.Summary(full),
+					Cause:   "the agent namespace's ResourceQuota is used up, so new pods are rejected",
+					Fix:     fmt.Sprintf("sikifanso agent update %s %s <higher>", a.Name, quotaFlags[full[0].Resource]),
+				}
+			}
+			near = usage.AtLimit(agent.QuotaWarnPercent)
+		}
+	}
+
 	if healthStatus == "Healthy" && syncStatus == "Synced" {
+		msg := "Healthy, Synced"
+		if len(near) > 0 {
+			msg += "; near quota: " + agent.Summary(near)
+		}
 		return Result{
 			Name:    name,
 			OK:      true,
-			Message: "Healthy, Synced",
+			Message: msg,
 		}
 	}
 
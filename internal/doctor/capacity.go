@@ -0,0 +1,62 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/preflight"
+)
+
+const checkNameCapacity = "Host capacity"
+
+// CapacityCheck compares the declared footprint of the enabled catalog apps
+// with the CPUs and memory available to Docker.
+type CapacityCheck struct {
+	GitOpsPath string
+}
+
+func (c CapacityCheck) Run(ctx context.Context) []Result {
+	entries, err := catalog.List(c.GitOpsPath)
+	if err != nil {
+		return []Result{{Name: checkNameCapacity, OK: false, Cause: fmt.Sprintf("listing catalog: %v", err)}}
+	}
+	report, err := preflight.CheckCapacity(ctx, catalog.EnabledAfter(entries, nil, nil))
+	if err != nil {
+		return []Result{{Name: checkNameCapacity, OK: false, Cause: err.Error()}}
+	}
+
+	msg := report.Summary()
+	if n := len(report.Apps.Unknown); n > 0 {
+		msg += fmt.Sprintf("; %d apps declare no resources", n)
+	}
+	switch report.Status {
+	case preflight.CapacityExceeded:
+		return []Result{{
+			Name:    checkNameCapacity,
+			OK:      false,
+			Message: msg,
+			Cause:   "enabled apps request more than Docker provides; " + topConsumers(report.Apps, 3),
+			Fix:     "disable apps or give Docker more CPUs/memory",
+		}}
+	case preflight.CapacityWarn:
+		msg += " — near capacity"
+	}
+	return []Result{{Name: checkNameCapacity, OK: true, Message: msg}}
+}
+
+// topConsumers names the n apps requesting the most memory.
+func topConsumers(s catalog.FootprintSummary, n int) string {
+	if len(s.Apps) == 0 {
+		return "the platform alone does not fit"
+	}
+	if len(s.Apps) < n {
+		n = len(s.Apps)
+	}
+	parts := make([]string, 0, n)
+	for _, a := range s.Apps[:n] {
+		parts = append(parts, fmt.Sprintf("%s %s", a.Name, catalog.FormatBytes(a.MemoryBytes)))
+	}
+	return "largest: " + strings.Join(parts, ", ")
+}
@@ -41,10 +41,11 @@ func InfraChecks() []Check {
 }
 
 // ClusterChecks returns checks that need a typed Kubernetes clientset.
-// Namespaces are read from the provided InfraConfig.
-func ClusterChecks(cs *kubernetes.Clientset, cfg *infraconfig.InfraConfig) []Check {
+// Namespaces are read from the provided InfraConfig; pools are the agent
+// pools the cluster was created with.
+func ClusterChecks(cs *kubernetes.Clientset, cfg *infraconfig.InfraConfig, pools []infraconfig.NodePool) []Check {
 	return []Check{
-		NodesCheck{Client: cs},
+		NodesCheck{Client: cs, Pools: pools},
 		CiliumCheck{Client: cs, Namespace: cfg.Cilium.Namespace},
 		HubbleCheck{Client: cs, Namespace: cfg.Cilium.Namespace},
 		ArgoCDCheck{Client: cs, Namespace: cfg.ArgoCD.Namespace},
@@ -53,11 +54,13 @@ func ClusterChecks(cs *kubernetes.Clientset, cfg *infraconfig.InfraConfig) []Che
 
 // AppChecks returns checks for enabled catalog apps and agent namespaces.
 // grpcClient is optional; when non-nil, unhealthy apps are enriched with
-// per-resource details fetched from the ArgoCD gRPC API.
-func AppChecks(dynClient dynamic.Interface, gitOpsPath string, cfg *infraconfig.InfraConfig, grpcClient *grpcclient.Client) []Check {
+// per-resource details fetched from the ArgoCD gRPC API. cs is optional too;
+// when non-nil, agent namespace quotas are checked. The checks only read
+// from the cluster; the network policy probe is opt-in, see AgentPolicyCheck.
+func AppChecks(dynClient dynamic.Interface, cs kubernetes.Interface, gitOpsPath string, cfg *infraconfig.InfraConfig, grpcClient *grpcclient.Client) []Check {
 	return []Check{
 		AppsCheck{DynClient: dynClient, GitOpsPath: gitOpsPath, ArgoCDNamespace: cfg.ArgoCD.Namespace, GRPCClient: grpcClient},
-		AgentsCheck{DynClient: dynClient, GitOpsPath: gitOpsPath, ArgoCDNamespace: cfg.ArgoCD.Namespace},
+		AgentsCheck{DynClient: dynClient, Client: cs, GitOpsPath: gitOpsPath, ArgoCDNamespace: cfg.ArgoCD.Namespace},
 	}
 }
 
@@ -3,9 +3,19 @@ package doctor
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 // mockCheck is a simple Check implementation that returns predetermined results.
@@ -301,3 +311,120 @@ func TestExtractDegradedCause(t *testing.T) {
 		})
 	}
 }
+
+func TestAgentsCheck_FlagsExpiredAgent(t *testing.T) {
+	t.Parallel()
+	expired := time.Now().Add(-2 * time.Hour)
+	// An expired agent is reported before its Application is looked up, so
+	// no dynamic client is needed.
+	r := AgentsCheck{}.checkAgent(context.Background(), agent.Info{Name: "scratch", ExpiresAt: &expired})
+	if r.OK {
+		t.Fatal("expired agent reported OK")
+	}
+	if r.Fix != "sikifanso agent reap" || r.Message != "expired 2h0m0s ago" {
+		t.Errorf("result = %+v", r)
+	}
+}
+
+func TestAgentsCheck_FlagsAgentAtQuota(t *testing.T) {
+	t.Parallel()
+	app := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Application",
+		"metadata":   map[string]interface{}{"name": "bot", "namespace": "argocd"},
+		"status": map[string]interface{}{
+			"sync":   map[string]interface{}{"status": "Synced"},
+			"health": map[string]interface{}{"status": "Healthy"},
+		},
+	}}
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{applicationGVR: "ApplicationList"}, app)
+	quota := func(podsUsed string) *corev1.ResourceQuota {
+		return &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "agent-quota", Namespace: "agent-bot"},
+			Status: corev1.ResourceQuotaStatus{
+				Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+				Used: corev1.ResourceList{corev1.ResourcePods: resource.MustParse(podsUsed)},
+			},
+		}
+	}
+	info := agent.Info{Name: "bot", Namespace: "agent-bot"}
+
+	r := AgentsCheck{DynClient: dyn, Client: fake.NewClientset(quota("10"))}.checkAgent(context.Background(), info)
+	if r.OK {
+		t.Fatal("agent at quota reported OK")
+	}
+	if r.Message != "at quota: pods 10/10 (100%)" || r.Fix != "sikifanso agent update bot --pods <higher>" {
+		t.Errorf("result = %+v", r)
+	}
+
+	r = AgentsCheck{DynClient: dyn, Client: fake.NewClientset(quota("9"))}.checkAgent(context.Background(), info)
+	if !r.OK || r.Message != "Healthy, Synced; near quota: pods 9/10 (90%)" {
+		t.Errorf("near-quota result = %+v", r)
+	}
+}
+
+func TestPolicyResult_ReportsMismatches(t *testing.T) {
+	t.Parallel()
+	results := []agent.ProbeResult{
+		{ProbeTarget: agent.ProbeTarget{Name: "app:qdrant", Expected: true}, Allowed: true},
+		{ProbeTarget: agent.ProbeTarget{Name: "kubernetes-api"}, Allowed: true},
+		{ProbeTarget: agent.ProbeTarget{Name: "app:litellm-proxy", Expected: true}},
+	}
+	r := policyResult("bot", results)
+	if r.OK {
+		t.Fatal("policy mismatch reported OK")
+	}
+	if r.Message != "from bot: kubernetes-api allowed, app:litellm-proxy denied" || r.Fix != "sikifanso agent policy test bot" {
+		t.Errorf("result = %+v", r)
+	}
+
+	r = policyResult("bot", results[:1])
+	if !r.OK {
+		t.Errorf("matching policy reported failing: %+v", r)
+	}
+}
+
+func TestAppChecks_SkipPolicyProbe(t *testing.T) {
+	t.Parallel()
+	for _, c := range AppChecks(nil, fake.NewClientset(), t.TempDir(), infraconfig.Defaults(), nil) {
+		if _, ok := c.(AgentPolicyCheck); ok {
+			t.Fatal("AppChecks includes the policy probe, which creates a pod")
+		}
+	}
+}
+
+func TestNodesCheck_ReportsPools(t *testing.T) {
+	t.Parallel()
+	node := func(name, pool string, ready corev1.ConditionStatus) *corev1.Node {
+		n := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+			},
+		}
+		if pool != "" {
+			n.Labels["sikifanso.io/pool"] = pool
+		}
+		return n
+	}
+	cs := fake.NewClientset(
+		node("k3d-dev-server-0", "", corev1.ConditionTrue),
+		node("k3d-dev-agent-0", "sandbox", corev1.ConditionTrue),
+		node("k3d-dev-agent-1", "sandbox", corev1.ConditionFalse),
+	)
+
+	results := NodesCheck{Client: cs, Pools: []infraconfig.NodePool{{Name: "sandbox", Count: 2}, {Name: "gpu", Count: 1}}}.Run(context.Background())
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want the cluster and two pools: %+v", len(results), results)
+	}
+	if results[0].OK {
+		t.Error("cluster with a NotReady node reported OK")
+	}
+	if r := results[1]; r.Name != "Node pool sandbox" || r.OK || r.Cause != "1/2 nodes ready" {
+		t.Errorf("sandbox pool = %+v", r)
+	}
+	if r := results[2]; r.Name != "Node pool gpu" || r.OK || r.Cause != "no nodes labelled sikifanso.io/pool=gpu" {
+		t.Errorf("gpu pool = %+v", r)
+	}
+}
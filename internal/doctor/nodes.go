@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
 	"github.com/alicanalbayrak/sikifanso/internal/kube"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -14,9 +16,12 @@ const (
 	fixNodes       = "sikifanso cluster start"
 )
 
-// NodesCheck verifies that all k3d nodes are in the Ready state.
+// NodesCheck verifies that all k3d nodes are in the Ready state, and that
+// each agent pool has its nodes and they are Ready.
 type NodesCheck struct {
-	Client *kubernetes.Clientset
+	Client kubernetes.Interface
+	// Pools are the agent pools the cluster was created with.
+	Pools []infraconfig.NodePool
 }
 
 func (c NodesCheck) Run(ctx context.Context) []Result {
@@ -47,18 +52,59 @@ func (c NodesCheck) Run(ctx context.Context) []Result {
 		}
 	}
 
+	results := []Result{{
+		Name:    checkNameNodes,
+		OK:      true,
+		Message: fmt.Sprintf("%d/%d nodes ready", ready, total),
+	}}
 	if ready < total {
-		return []Result{{
+		results[0] = Result{
 			Name:  checkNameNodes,
 			OK:    false,
 			Cause: fmt.Sprintf("%d/%d nodes ready", ready, total),
 			Fix:   fixNodes,
-		}}
+		}
 	}
+	return append(results, c.poolResults(nodes.Items)...)
+}
 
-	return []Result{{
-		Name:    checkNameNodes,
-		OK:      true,
-		Message: fmt.Sprintf("%d/%d nodes ready", ready, total),
-	}}
+// poolResults reports each agent pool: the expected ones and any others
+// found on the nodes.
+func (c NodesCheck) poolResults(nodes []corev1.Node) []Result {
+	found := kube.PoolStatuses(nodes)
+	statuses := make(map[string]kube.PoolStatus, len(found))
+	for _, s := range found {
+		statuses[s.Name] = s
+	}
+	expected := make(map[string]int, len(c.Pools))
+	names := make([]string, 0, len(c.Pools))
+	for _, p := range c.Pools {
+		expected[p.Name] = p.Count
+		names = append(names, p.Name)
+	}
+	for _, s := range found {
+		if _, ok := expected[s.Name]; !ok && s.Name != kube.ServerPool && s.Name != kube.GeneralPool {
+			expected[s.Name] = s.Nodes
+			names = append(names, s.Name)
+		}
+	}
+
+	results := make([]Result, 0, len(names))
+	for _, name := range names {
+		s, want := statuses[name], expected[name]
+		r := Result{Name: "Node pool " + name}
+		switch {
+		case s.Nodes == 0:
+			r.Cause = fmt.Sprintf("no nodes labelled %s=%s", infraconfig.PoolLabel, name)
+			r.Fix = fmt.Sprintf("recreate the cluster with: sikifanso cluster create --agent-pool %s=%d", name, want)
+		case s.Ready < want:
+			r.Cause = fmt.Sprintf("%d/%d nodes ready", s.Ready, want)
+			r.Fix = fixNodes
+		default:
+			r.OK = true
+			r.Message = fmt.Sprintf("%d/%d nodes ready", s.Ready, want)
+		}
+		results = append(results, r)
+	}
+	return results
 }
@@ -146,6 +146,117 @@ func TestCommit_ErrorWhenPathDoesNotExist(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// Stage tests
+// ---------------------------------------------------------------------------
+
+func TestStage_CommitsAllChangesOnce(t *testing.T) {
+	t.Parallel()
+	dir := initTestRepo(t)
+	for name, content := range map[string]string{"keep.txt": "keep", "edit.txt": "v1", "drop.txt": "drop"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitRun(t, dir, "add", ".")
+	gitRun(t, dir, "commit", "-m", "files")
+
+	stage, err := NewStage(dir)
+	if err != nil {
+		t.Fatalf("NewStage: %v", err)
+	}
+	defer stage.Discard()
+	if err := os.WriteFile(filepath.Join(stage.Dir(), "edit.txt"), []byte("v2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Commit(stage.Dir(), "edit a file", "edit.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(stage.Dir(), "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stage.Dir(), "sub", "new.txt"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(stage.Dir(), "drop.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Commit(stage.Dir(), "add and drop files", filepath.Join("sub", "new.txt"), "drop.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing reaches the repo before Commit.
+	if data, _ := os.ReadFile(filepath.Join(dir, "edit.txt")); string(data) != "v1" {
+		t.Errorf("edit.txt = %q before Commit, want v1", data)
+	}
+
+	paths, err := stage.Commit("apply staged changes")
+	if err != nil {
+		t.Fatalf("Stage.Commit: %v", err)
+	}
+	if got := strings.Join(paths, ","); got != "drop.txt,edit.txt,"+filepath.Join("sub", "new.txt") {
+		t.Errorf("paths = %s", got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "edit.txt")); string(data) != "v2" {
+		t.Errorf("edit.txt = %q, want v2", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "drop.txt")); !os.IsNotExist(err) {
+		t.Error("drop.txt not removed")
+	}
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "apply staged changes\n\n- edit a file\n- add and drop files"
+	if commit.Message != want {
+		t.Errorf("commit message = %q, want %q", commit.Message, want)
+	}
+	if parent, err := commit.Parent(0); err != nil || parent.Message != "files\n" {
+		t.Errorf("staged changes did not land as one commit on top of the repo")
+	}
+	if status, err := exec.Command("git", "-C", dir, "status", "--porcelain").Output(); err != nil || len(status) > 0 {
+		t.Errorf("uncommitted changes after Stage.Commit: %s (%v)", status, err)
+	}
+
+	// A stage that changed nothing commits nothing.
+	again, err := NewStage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Discard()
+	if paths, err := again.Commit("noop"); err != nil || len(paths) > 0 {
+		t.Errorf("empty stage Commit = %v, %v", paths, err)
+	}
+}
+
+func TestStage_DiscardLeavesRepoUntouched(t *testing.T) {
+	t.Parallel()
+	dir := initTestRepo(t)
+	stage, err := NewStage(dir)
+	if err != nil {
+		t.Fatalf("NewStage: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(stage.Dir(), "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stage.Discard()
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) {
+		t.Error("discarded change reached the repo")
+	}
+	if _, err := os.Stat(stage.Dir()); !os.IsNotExist(err) {
+		t.Error("stage directory not removed")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Scaffold tests
 // ---------------------------------------------------------------------------
@@ -305,6 +416,46 @@ func TestScaffold_PreservesFileContent(t *testing.T) {
 	}
 }
 
+func TestScaffold_FromSourceDir(t *testing.T) {
+	t.Parallel()
+	// The seed repo's .git must not be copied; Scaffold starts a fresh one.
+	seedDir := createSeedRepo(t)
+	targetDir := filepath.Join(t.TempDir(), "scaffolded")
+
+	log := zap.NewNop()
+	if err := Scaffold(context.Background(), log, targetDir, ScaffoldOptions{
+		RepoURL:   "https://example.com/bootstrap.git",
+		Version:   "v0.2.0",
+		SourceDir: seedDir,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(targetDir, "README.md"))
+	if err != nil || string(data) != "seed repo" {
+		t.Fatalf("README.md = %q, %v; want the seed content", data, err)
+	}
+	repo, err := git.PlainOpen(targetDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits, err := repo.Log(&git.LogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	_ = commits.ForEach(func(c *object.Commit) error {
+		n++
+		if !strings.Contains(c.Message, "https://example.com/bootstrap.git @ v0.2.0") {
+			t.Errorf("commit message = %q", c.Message)
+		}
+		return nil
+	})
+	if n != 1 {
+		t.Errorf("got %d commits, want only the initial scaffold", n)
+	}
+}
+
 func TestScaffold_ErrorWhenInvalidURL(t *testing.T) {
 	t.Parallel()
 	targetDir := filepath.Join(t.TempDir(), "scaffolded")
@@ -3,6 +3,7 @@ package gitops
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 
@@ -18,32 +19,26 @@ const DefaultBootstrapURL = "https://github.com/sikifanso/sikifanso-homelab-boot
 type ScaffoldOptions struct {
 	RepoURL string
 	Version string // tag to clone; "" means HEAD
+	// SourceDir, when set, is a local copy of the bootstrap tree (e.g. from
+	// an offline bundle) used instead of cloning; RepoURL and Version then
+	// only label the initial commit.
+	SourceDir string
 }
 
-// Scaffold clones the bootstrap template repo into targetDir, strips
-// upstream history, and creates a fresh initial commit.
+// Scaffold clones the bootstrap template repo (or copies opts.SourceDir)
+// into targetDir, strips upstream history, and creates a fresh initial
+// commit.
 func Scaffold(ctx context.Context, log *zap.Logger, targetDir string, opts ScaffoldOptions) error {
-	log.Info("cloning bootstrap repo",
-		zap.String("url", opts.RepoURL),
-		zap.String("version", opts.Version),
-		zap.String("target", targetDir),
-	)
-
-	cloneOpts := &git.CloneOptions{
-		URL:   opts.RepoURL,
-		Depth: 1,
-	}
-	if opts.Version != "" {
-		cloneOpts.ReferenceName = plumbing.NewTagReferenceName(opts.Version)
-		cloneOpts.SingleBranch = true
-	}
-
-	_, err := git.PlainCloneContext(ctx, targetDir, false, cloneOpts)
-	if err != nil {
-		if opts.Version != "" {
-			return fmt.Errorf("cloning bootstrap repo at tag %s: %w", opts.Version, err)
+	if opts.SourceDir != "" {
+		log.Info("copying bootstrap tree",
+			zap.String("source", opts.SourceDir),
+			zap.String("target", targetDir),
+		)
+		if err := copyTree(opts.SourceDir, targetDir); err != nil {
+			return fmt.Errorf("copying bootstrap tree: %w", err)
 		}
-		return fmt.Errorf("cloning bootstrap repo: %w", err)
+	} else if err := clone(ctx, log, targetDir, opts); err != nil {
+		return err
 	}
 
 	// Ensure app and agent directories exist (custom bootstrap repos may omit them).
@@ -94,3 +89,58 @@ func Scaffold(ctx context.Context, log *zap.Logger, targetDir string, opts Scaff
 	log.Info("gitops repo scaffolded", zap.String("path", targetDir))
 	return nil
 }
+
+// clone shallow-clones the bootstrap repo at opts.Version into targetDir.
+func clone(ctx context.Context, log *zap.Logger, targetDir string, opts ScaffoldOptions) error {
+	log.Info("cloning bootstrap repo",
+		zap.String("url", opts.RepoURL),
+		zap.String("version", opts.Version),
+		zap.String("target", targetDir),
+	)
+
+	cloneOpts := &git.CloneOptions{
+		URL:   opts.RepoURL,
+		Depth: 1,
+	}
+	if opts.Version != "" {
+		cloneOpts.ReferenceName = plumbing.NewTagReferenceName(opts.Version)
+		cloneOpts.SingleBranch = true
+	}
+
+	_, err := git.PlainCloneContext(ctx, targetDir, false, cloneOpts)
+	if err != nil {
+		if opts.Version != "" {
+			return fmt.Errorf("cloning bootstrap repo at tag %s: %w", opts.Version, err)
+		}
+		return fmt.Errorf("cloning bootstrap repo: %w", err)
+	}
+	return nil
+}
+
+// copyTree copies the regular files and directories under src into dst,
+// skipping any .git directory.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dst, rel), 0o755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0o644)
+	})
+}
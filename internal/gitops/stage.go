@@ -0,0 +1,214 @@
+package gitops
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Stage is a scratch copy of a gitops repo. Operations that commit on their
+// own run against Dir; Commit then carries everything they changed back to
+// the repo as one commit, and Discard drops it all. Callers hold the repo's
+// lock for the life of the stage so the copy stays current.
+type Stage struct {
+	repoDir string
+	tmp     string
+}
+
+// NewStage copies the working tree of the repo at repoDir, uncommitted
+// changes included, into a fresh repo in a temporary directory.
+func NewStage(repoDir string) (*Stage, error) {
+	tmp, err := os.MkdirTemp("", "sikifanso-stage-")
+	if err != nil {
+		return nil, fmt.Errorf("creating stage directory: %w", err)
+	}
+	s := &Stage{repoDir: repoDir, tmp: tmp}
+	if err := copyTree(repoDir, s.Dir()); err != nil {
+		s.Discard()
+		return nil, fmt.Errorf("copying gitops repo: %w", err)
+	}
+	if err := initStage(s.Dir()); err != nil {
+		s.Discard()
+		return nil, err
+	}
+	return s, nil
+}
+
+// initStage commits the copied tree in dir as the root of a new repo.
+func initStage(dir string) error {
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		return fmt.Errorf("initializing stage repo: %w", err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("getting worktree: %w", err)
+	}
+	if _, err := w.Add("."); err != nil {
+		return fmt.Errorf("staging files: %w", err)
+	}
+	_, err = w.Commit("stage", &git.CommitOptions{Author: botSignature(), AllowEmptyCommits: true})
+	if err != nil {
+		return fmt.Errorf("creating stage commit: %w", err)
+	}
+	return nil
+}
+
+// Dir returns the path of the staged copy. It has a directory of its own,
+// so the gitops lock taken on it does not contend with the repo's.
+func (s *Stage) Dir() string {
+	return filepath.Join(s.tmp, "gitops")
+}
+
+// Discard removes the staged copy.
+func (s *Stage) Discard() {
+	_ = os.RemoveAll(s.tmp)
+}
+
+// Commit writes the files that differ between the stage and the repo back to
+// the repo and commits them together. The commit message is message followed
+// by the subjects of the commits made in the stage. It returns the paths
+// committed; none means the stage changed nothing and no commit was made.
+// If the commit fails, the repo's files are restored.
+func (s *Stage) Commit(message string) ([]string, error) {
+	paths, err := changedFiles(s.Dir(), s.repoDir)
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+	subjects, err := s.subjects()
+	if err != nil {
+		return nil, err
+	}
+	if len(subjects) > 0 {
+		message += "\n\n- " + strings.Join(subjects, "\n- ")
+	}
+
+	// orig holds each path's previous content, nil when it did not exist.
+	orig := make(map[string][]byte, len(paths))
+	restore := func() {
+		for rel, data := range orig {
+			abs := filepath.Join(s.repoDir, rel)
+			if data == nil {
+				_ = os.Remove(abs)
+			} else {
+				_ = os.WriteFile(abs, data, 0o644)
+			}
+		}
+	}
+	for _, rel := range paths {
+		dst := filepath.Join(s.repoDir, rel)
+		prev, err := os.ReadFile(dst)
+		if err != nil && !os.IsNotExist(err) {
+			restore()
+			return nil, fmt.Errorf("reading %s: %w", rel, err)
+		}
+		orig[rel] = prev
+		data, err := os.ReadFile(filepath.Join(s.Dir(), rel))
+		switch {
+		case os.IsNotExist(err):
+			err = os.Remove(dst)
+		case err == nil:
+			if err = os.MkdirAll(filepath.Dir(dst), 0o755); err == nil {
+				err = os.WriteFile(dst, data, 0o644)
+			}
+		}
+		if err != nil {
+			restore()
+			return nil, fmt.Errorf("writing %s: %w", rel, err)
+		}
+	}
+	if err := Commit(s.repoDir, message, paths...); err != nil {
+		restore()
+		return nil, err
+	}
+	return paths, nil
+}
+
+// subjects returns the first lines of the commits made in the stage, oldest
+// first.
+func (s *Stage) subjects() ([]string, error) {
+	repo, err := git.PlainOpen(s.Dir())
+	if err != nil {
+		return nil, fmt.Errorf("opening stage repo: %w", err)
+	}
+	commits, err := repo.Log(&git.LogOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("reading stage history: %w", err)
+	}
+	var out []string
+	err = commits.ForEach(func(c *object.Commit) error {
+		if c.NumParents() > 0 {
+			subject, _, _ := strings.Cut(c.Message, "\n")
+			out = append(out, subject)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading stage history: %w", err)
+	}
+	slices.Reverse(out)
+	return out, nil
+}
+
+// changedFiles returns the paths, relative to the tree roots, of the regular
+// files that are in only one of the trees a and b or differ between them.
+func changedFiles(a, b string) ([]string, error) {
+	filesA, err := treeFiles(a)
+	if err != nil {
+		return nil, err
+	}
+	filesB, err := treeFiles(b)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for rel, data := range filesA {
+		if other, ok := filesB[rel]; !ok || !bytes.Equal(data, other) {
+			out = append(out, rel)
+		}
+	}
+	for rel := range filesB {
+		if _, ok := filesA[rel]; !ok {
+			out = append(out, rel)
+		}
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+// treeFiles reads the regular files under root, skipping any .git directory.
+func treeFiles(root string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[rel] = data
+		return nil
+	})
+	return files, err
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -12,7 +13,9 @@ import (
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/registry"
 )
 
 // InstallParams holds the configuration for a Helm chart installation.
@@ -59,6 +62,60 @@ func LocateChart(cfg *action.Configuration, settings *cli.EnvSettings, p Install
 	return ch, nil
 }
 
+// Fetch downloads a chart into Helm's repository cache and returns the path
+// of the archive. As with ChartVersions, a repoURL without an http(s) scheme
+// is an OCI registry.
+func Fetch(settings *cli.EnvSettings, repoURL, chartName, version string) (string, error) {
+	install := action.NewInstall(&action.Configuration{})
+	install.Version = version
+	ref := chartName
+	if strings.HasPrefix(repoURL, "http://") || strings.HasPrefix(repoURL, "https://") {
+		install.RepoURL = repoURL
+	} else {
+		client, err := registry.NewClient(registry.ClientOptEnableCache(true))
+		if err != nil {
+			return "", fmt.Errorf("creating registry client: %w", err)
+		}
+		install.SetRegistryClient(client)
+		ref = "oci://" + strings.TrimSuffix(strings.TrimPrefix(repoURL, "oci://"), "/") + "/" + chartName
+	}
+	path, err := install.LocateChart(ref, settings)
+	if err != nil {
+		return "", fmt.Errorf("fetching chart %s: %w", chartName, err)
+	}
+	return path, nil
+}
+
+// Render templates ch with vals without a cluster, as helm template does,
+// and returns the manifests including hooks. kubeVersion, e.g. "v1.31.5",
+// satisfies charts that constrain it; empty uses Helm's default.
+func Render(ch *chart.Chart, vals map[string]interface{}, releaseName, namespace, kubeVersion string) (string, error) {
+	install := action.NewInstall(&action.Configuration{Log: func(string, ...interface{}) {}})
+	install.ReleaseName = releaseName
+	install.Namespace = namespace
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	if kubeVersion != "" {
+		kv, err := chartutil.ParseKubeVersion(kubeVersion)
+		if err != nil {
+			return "", fmt.Errorf("parsing kube version %s: %w", kubeVersion, err)
+		}
+		install.KubeVersion = kv
+	}
+	rel, err := install.Run(ch, vals)
+	if err != nil {
+		return "", fmt.Errorf("rendering chart %s: %w", ch.Name(), err)
+	}
+	var out strings.Builder
+	out.WriteString(rel.Manifest)
+	for _, h := range rel.Hooks {
+		out.WriteString("\n---\n")
+		out.WriteString(h.Manifest)
+	}
+	return out.String(), nil
+}
+
 // Deploy runs the Helm install with a spinner for visual feedback.
 func Deploy(ctx context.Context, cfg *action.Configuration, ch *chart.Chart, vals map[string]interface{}, p InstallParams) error {
 	install := action.NewInstall(cfg)
@@ -134,3 +191,17 @@ func Rollback(cfg *action.Configuration, releaseName string) error {
 	}
 	return nil
 }
+
+// ValidateValues merges vals over the chart defaults and validates the result
+// against the chart's values.schema.json (and those of its subcharts). Charts
+// without a schema always pass.
+func ValidateValues(ch *chart.Chart, vals map[string]interface{}) error {
+	merged, err := chartutil.CoalesceValues(ch, vals)
+	if err != nil {
+		return fmt.Errorf("merging values with chart defaults: %w", err)
+	}
+	if err := chartutil.ValidateAgainstSchema(ch, merged); err != nil {
+		return fmt.Errorf("values do not match the %s chart schema: %w", ch.Name(), err)
+	}
+	return nil
+}
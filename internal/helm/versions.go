@@ -0,0 +1,78 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/registry"
+	"sigs.k8s.io/yaml"
+)
+
+// indexFile is the subset of a Helm repository index.yaml needed to list
+// chart versions.
+type indexFile struct {
+	Entries map[string][]struct {
+		Version string `json:"version"`
+	} `json:"entries"`
+}
+
+// ChartVersions lists the published versions of chartName. HTTP(S)
+// repositories are read from their index.yaml; any other repoURL (with or
+// without an oci:// prefix) is treated as an OCI registry and its tags are
+// listed.
+func ChartVersions(ctx context.Context, repoURL, chartName string) ([]string, error) {
+	if strings.HasPrefix(repoURL, "http://") || strings.HasPrefix(repoURL, "https://") {
+		return indexVersions(ctx, repoURL, chartName)
+	}
+	return ociVersions(repoURL, chartName)
+}
+
+func indexVersions(ctx context.Context, repoURL, chartName string) ([]string, error) {
+	url := strings.TrimSuffix(repoURL, "/") + "/index.yaml"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request for %s: %w", url, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", url, err)
+	}
+
+	var idx indexFile
+	if err := yaml.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", url, err)
+	}
+	entries, ok := idx.Entries[chartName]
+	if !ok {
+		return nil, fmt.Errorf("chart %s not found in %s", chartName, url)
+	}
+	versions := make([]string, 0, len(entries))
+	for _, e := range entries {
+		versions = append(versions, e.Version)
+	}
+	return versions, nil
+}
+
+func ociVersions(repoURL, chartName string) ([]string, error) {
+	client, err := registry.NewClient(registry.ClientOptEnableCache(true))
+	if err != nil {
+		return nil, fmt.Errorf("creating registry client: %w", err)
+	}
+	ref := strings.TrimSuffix(strings.TrimPrefix(repoURL, "oci://"), "/") + "/" + chartName
+	tags, err := client.Tags(ref)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags of %s: %w", ref, err)
+	}
+	return tags, nil
+}
@@ -0,0 +1,82 @@
+package hubble
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const getFlowsMethod = "/observer.Observer/GetFlows"
+
+// Client streams flows from a Hubble Relay or agent observer.
+type Client struct {
+	conn *grpc.ClientConn
+	// stop ends the port-forward the client was opened over, if any.
+	stop func()
+}
+
+// NewClient returns a Client for the plaintext observer at addr (host:port).
+// The relay sikifanso installs does not serve TLS.
+func NewClient(addr string) (*Client, error) {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodecV2(rawCodec{})),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to Hubble Relay at %s: %w", addr, err)
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Close releases the connection and any port-forward under it.
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	if c.stop != nil {
+		c.stop()
+	}
+	return err
+}
+
+// Flows streams the flows matching req to fn in the order Hubble returns
+// them. It returns when the stream ends, ctx is cancelled, or fn returns an
+// error. Cancellation is not reported as an error.
+func (c *Client) Flows(ctx context.Context, req Request, fn func(Flow) error) error {
+	body, err := req.marshal()
+	if err != nil {
+		return err
+	}
+	stream, err := c.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, getFlowsMethod)
+	if err != nil {
+		return fmt.Errorf("opening flow stream: %w", err)
+	}
+	if err := stream.SendMsg(&body); err != nil {
+		return fmt.Errorf("sending flow request: %w", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return fmt.Errorf("sending flow request: %w", err)
+	}
+
+	for {
+		var msg []byte
+		if err := stream.RecvMsg(&msg); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("receiving flows: %w", err)
+		}
+		flow, err := unmarshalResponse(msg)
+		if err != nil {
+			return err
+		}
+		if flow == nil {
+			continue
+		}
+		if err := fn(*flow); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package hubble
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+// TestClient_Flows runs GetFlows against a stub observer that checks the
+// method name, then streams a node status event and a flow.
+func TestClient_Flows(t *testing.T) {
+	t.Parallel()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(
+		grpc.ForceServerCodecV2(rawCodec{}),
+		grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			if method != getFlowsMethod {
+				t.Errorf("method = %q, want %q", method, getFlowsMethod)
+			}
+			var req []byte
+			if err := stream.RecvMsg(&req); err != nil {
+				return err
+			}
+			for _, m := range [][]byte{msg(sub(2, nil)), droppedFlow()} {
+				if err := stream.SendMsg(&m); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	c, err := NewClient(lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+
+	var flows []Flow
+	err = c.Flows(context.Background(), Request{Namespace: "agent-bot"}, func(f Flow) error {
+		flows = append(flows, f)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Flows: %v", err)
+	}
+	if len(flows) != 1 || flows[0].Verdict != VerdictDropped {
+		t.Errorf("flows = %+v, want one dropped flow", flows)
+	}
+}
@@ -0,0 +1,357 @@
+package hubble
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/mem"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers from cilium api/v1/observer/observer.proto and
+// api/v1/flow/flow.proto.
+const (
+	reqNumber    = 1
+	reqFollow    = 3
+	reqWhitelist = 6
+	reqSince     = 7
+
+	filterSourcePod      = 2
+	filterDestinationPod = 4
+	filterVerdict        = 5
+
+	respFlow = 1
+
+	flowTime             = 1
+	flowVerdict          = 2
+	flowDropReason       = 3
+	flowIP               = 5
+	flowL4               = 6
+	flowSource           = 8
+	flowDestination      = 9
+	flowNodeName         = 11
+	flowSourceNames      = 13
+	flowDestinationNames = 14
+	flowDirection        = 22
+	flowDropReasonDesc   = 25
+	flowEgressAllowedBy  = 21001
+	flowIngressAllowedBy = 21002
+	flowEgressDeniedBy   = 21004
+	flowIngressDeniedBy  = 21005
+
+	ipSource      = 1
+	ipDestination = 2
+
+	endpointNamespace = 3
+	endpointPod       = 5
+
+	policyName      = 1
+	policyNamespace = 2
+)
+
+// l4Protocols maps flow.Layer4 oneof fields to protocol names. TCP, UDP and
+// SCTP carry source_port = 1 and destination_port = 2.
+var l4Protocols = map[protowire.Number]string{1: "TCP", 2: "UDP", 3: "ICMPv4", 4: "ICMPv6", 5: "SCTP"}
+
+var directions = map[uint64]string{1: "INGRESS", 2: "EGRESS"}
+
+// Request selects the flows to stream.
+type Request struct {
+	// Namespace limits flows to those with a source or destination pod in
+	// it; empty means every namespace.
+	Namespace string
+	// Verdicts limits flows to these verdict names; empty means all.
+	Verdicts []string
+	// Since returns flows observed after this time.
+	Since time.Time
+	// Number returns at most the last Number flows; zero with a zero Since
+	// and Follow unset means 20, as in the hubble CLI.
+	Number uint64
+	// Follow keeps the stream open for new flows.
+	Follow bool
+}
+
+// defaultNumber is how many past flows a plain request returns.
+const defaultNumber = 20
+
+// marshal encodes r as an observer.GetFlowsRequest.
+func (r Request) marshal() ([]byte, error) {
+	var verdictNums []uint64
+	for _, v := range r.Verdicts {
+		n, err := ParseVerdict(v)
+		if err != nil {
+			return nil, err
+		}
+		verdictNums = append(verdictNums, n)
+	}
+
+	var b []byte
+	number := r.Number
+	if number == 0 && r.Since.IsZero() && !r.Follow {
+		number = defaultNumber
+	}
+	if number > 0 {
+		b = protowire.AppendTag(b, reqNumber, protowire.VarintType)
+		b = protowire.AppendVarint(b, number)
+	}
+	if r.Follow {
+		b = protowire.AppendTag(b, reqFollow, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+
+	// Filters in the whitelist are ORed, fields within one are ANDed.
+	switch {
+	case r.Namespace != "":
+		for _, field := range []protowire.Number{filterSourcePod, filterDestinationPod} {
+			b = protowire.AppendTag(b, reqWhitelist, protowire.BytesType)
+			b = protowire.AppendBytes(b, flowFilter(field, r.Namespace+"/", verdictNums))
+		}
+	case len(verdictNums) > 0:
+		b = protowire.AppendTag(b, reqWhitelist, protowire.BytesType)
+		b = protowire.AppendBytes(b, flowFilter(0, "", verdictNums))
+	}
+
+	if !r.Since.IsZero() {
+		var ts []byte
+		ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(r.Since.Unix()))
+		ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+		ts = protowire.AppendVarint(ts, uint64(r.Since.Nanosecond()))
+		b = protowire.AppendTag(b, reqSince, protowire.BytesType)
+		b = protowire.AppendBytes(b, ts)
+	}
+	return b, nil
+}
+
+// flowFilter encodes a flow.FlowFilter matching podPrefix in podField (when
+// set) and any of verdicts.
+func flowFilter(podField protowire.Number, podPrefix string, verdicts []uint64) []byte {
+	var f []byte
+	if podField != 0 {
+		f = protowire.AppendTag(f, podField, protowire.BytesType)
+		f = protowire.AppendString(f, podPrefix)
+	}
+	if len(verdicts) > 0 {
+		var packed []byte
+		for _, v := range verdicts {
+			packed = protowire.AppendVarint(packed, v)
+		}
+		f = protowire.AppendTag(f, filterVerdict, protowire.BytesType)
+		f = protowire.AppendBytes(f, packed)
+	}
+	return f
+}
+
+// field is one decoded protobuf field: Bytes for length-delimited fields,
+// Varint for varints.
+type field struct {
+	Num    protowire.Number
+	Bytes  []byte
+	Varint uint64
+}
+
+// walk calls fn for each field of a protobuf message, skipping fixed-width
+// and group fields.
+func walk(b []byte, fn func(field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		f := field{Num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.Varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.Bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n >= 0 {
+				b = b[n:]
+				continue
+			}
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalResponse decodes an observer.GetFlowsResponse. It returns nil for
+// responses that carry no flow, such as node status events.
+func unmarshalResponse(b []byte) (*Flow, error) {
+	var flow *Flow
+	err := walk(b, func(f field) error {
+		if f.Num != respFlow {
+			return nil
+		}
+		fl, err := unmarshalFlow(f.Bytes)
+		flow = fl
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding flow: %w", err)
+	}
+	return flow, nil
+}
+
+func unmarshalFlow(b []byte) (*Flow, error) {
+	fl := &Flow{}
+	var dropReason, dropReasonDesc uint64
+	err := walk(b, func(f field) error {
+		switch f.Num {
+		case flowTime:
+			fl.Time = unmarshalTimestamp(f.Bytes)
+		case flowVerdict:
+			fl.Verdict = verdicts[f.Varint]
+		case flowDropReason:
+			dropReason = f.Varint
+		case flowDropReasonDesc:
+			dropReasonDesc = f.Varint
+		case flowDirection:
+			fl.Direction = directions[f.Varint]
+		case flowNodeName:
+			fl.Node = string(f.Bytes)
+		case flowSourceNames:
+			fl.Source.Names = append(fl.Source.Names, string(f.Bytes))
+		case flowDestinationNames:
+			fl.Destination.Names = append(fl.Destination.Names, string(f.Bytes))
+		case flowSource:
+			return unmarshalEndpoint(f.Bytes, &fl.Source)
+		case flowDestination:
+			return unmarshalEndpoint(f.Bytes, &fl.Destination)
+		case flowIP:
+			return unmarshalIP(f.Bytes, fl)
+		case flowL4:
+			return unmarshalL4(f.Bytes, fl)
+		case flowEgressDeniedBy, flowIngressDeniedBy:
+			fl.DeniedBy = append(fl.DeniedBy, unmarshalPolicy(f.Bytes))
+		case flowEgressAllowedBy, flowIngressAllowedBy:
+			fl.AllowedBy = append(fl.AllowedBy, unmarshalPolicy(f.Bytes))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fl.Verdict == VerdictDropped {
+		if dropReasonDesc == 0 {
+			dropReasonDesc = dropReason
+		}
+		if dropReasonDesc != 0 {
+			fl.DropReason = dropReasonName(dropReasonDesc)
+		}
+	}
+	return fl, nil
+}
+
+func unmarshalTimestamp(b []byte) time.Time {
+	var sec, nsec uint64
+	_ = walk(b, func(f field) error {
+		switch f.Num {
+		case 1:
+			sec = f.Varint
+		case 2:
+			nsec = f.Varint
+		}
+		return nil
+	})
+	return time.Unix(int64(sec), int64(nsec)).UTC()
+}
+
+func unmarshalEndpoint(b []byte, e *Endpoint) error {
+	return walk(b, func(f field) error {
+		switch f.Num {
+		case endpointNamespace:
+			e.Namespace = string(f.Bytes)
+		case endpointPod:
+			e.Pod = string(f.Bytes)
+		}
+		return nil
+	})
+}
+
+func unmarshalIP(b []byte, fl *Flow) error {
+	return walk(b, func(f field) error {
+		switch f.Num {
+		case ipSource:
+			fl.Source.IP = string(f.Bytes)
+		case ipDestination:
+			fl.Destination.IP = string(f.Bytes)
+		}
+		return nil
+	})
+}
+
+func unmarshalL4(b []byte, fl *Flow) error {
+	return walk(b, func(f field) error {
+		proto, ok := l4Protocols[f.Num]
+		if !ok {
+			return nil
+		}
+		fl.Protocol = proto
+		if proto == "ICMPv4" || proto == "ICMPv6" {
+			return nil
+		}
+		return walk(f.Bytes, func(p field) error {
+			switch p.Num {
+			case 1:
+				fl.Source.Port = uint32(p.Varint)
+			case 2:
+				fl.Destination.Port = uint32(p.Varint)
+			}
+			return nil
+		})
+	})
+}
+
+// unmarshalPolicy renders a flow.Policy as namespace/name, or name for
+// cluster-wide policies.
+func unmarshalPolicy(b []byte) string {
+	var name, ns string
+	_ = walk(b, func(f field) error {
+		switch f.Num {
+		case policyName:
+			name = string(f.Bytes)
+		case policyNamespace:
+			ns = string(f.Bytes)
+		}
+		return nil
+	})
+	if ns == "" {
+		return name
+	}
+	return ns + "/" + name
+}
+
+// rawCodec passes pre-encoded messages through gRPC. It is named "proto" so
+// the wire content type matches what Hubble Relay expects.
+type rawCodec struct{}
+
+var _ encoding.CodecV2 = rawCodec{}
+
+func (rawCodec) Marshal(v any) (mem.BufferSlice, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("rawCodec: cannot marshal %T", v)
+	}
+	return mem.BufferSlice{mem.SliceBuffer(*b)}, nil
+}
+
+func (rawCodec) Unmarshal(data mem.BufferSlice, v any) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("rawCodec: cannot unmarshal into %T", v)
+	}
+	*b = data.Materialize()
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
@@ -0,0 +1,152 @@
+package hubble
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func msg(fields ...[]byte) []byte {
+	return slices.Concat(fields...)
+}
+
+func str(num protowire.Number, s string) []byte {
+	b := protowire.AppendTag(nil, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func sub(num protowire.Number, m []byte) []byte {
+	b := protowire.AppendTag(nil, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+func varint(num protowire.Number, v uint64) []byte {
+	b := protowire.AppendTag(nil, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// droppedFlow encodes a GetFlowsResponse for a pod in agent-bot whose TCP
+// connection to api.openai.com:443 was dropped by default deny.
+func droppedFlow() []byte {
+	flow := msg(
+		sub(flowTime, msg(varint(1, 1700000000), varint(2, 5))),
+		varint(flowVerdict, 2),
+		sub(flowIP, msg(str(ipSource, "10.42.0.7"), str(ipDestination, "162.159.140.245"))),
+		sub(flowL4, sub(1, msg(varint(1, 40312), varint(2, 443)))),
+		sub(flowSource, msg(str(endpointNamespace, "agent-bot"), str(endpointPod, "worker-0"))),
+		sub(flowDestination, nil),
+		str(flowNodeName, "k3d-dev-server-0"),
+		str(flowDestinationNames, "api.openai.com"),
+		varint(flowDirection, 2),
+		varint(flowDropReasonDesc, 133),
+	)
+	return msg(sub(respFlow, flow), str(1000, "k3d-dev-server-0"))
+}
+
+func TestUnmarshalResponse_DroppedFlow(t *testing.T) {
+	t.Parallel()
+	fl, err := unmarshalResponse(droppedFlow())
+	if err != nil {
+		t.Fatalf("unmarshalResponse: %v", err)
+	}
+	if fl == nil {
+		t.Fatal("no flow decoded")
+	}
+	if !fl.Time.Equal(time.Unix(1700000000, 5)) {
+		t.Errorf("Time = %v", fl.Time)
+	}
+	if fl.Verdict != VerdictDropped || fl.DropReason != "POLICY_DENIED" || fl.Direction != "EGRESS" || fl.Protocol != "TCP" {
+		t.Errorf("flow = %+v", fl)
+	}
+	if got := fl.Source.String(); got != "agent-bot/worker-0:40312" {
+		t.Errorf("source = %q", got)
+	}
+	if got := fl.Destination.String(); got != "api.openai.com:443" {
+		t.Errorf("destination = %q", got)
+	}
+	if got := fl.Explain(); got != "no policy allows it (default deny)" {
+		t.Errorf("Explain = %q", got)
+	}
+}
+
+func TestUnmarshalResponse_DeniedByPolicy(t *testing.T) {
+	t.Parallel()
+	flow := msg(
+		varint(flowVerdict, 2),
+		varint(flowDropReasonDesc, 181),
+		sub(flowEgressDeniedBy, msg(str(policyName, "block-metadata"), str(policyNamespace, "agent-bot"))),
+		sub(flowEgressDeniedBy, str(policyName, "cluster-deny")),
+	)
+	fl, err := unmarshalResponse(sub(respFlow, flow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fl.Explain(); got != "denied by agent-bot/block-metadata, cluster-deny" {
+		t.Errorf("Explain = %q", got)
+	}
+}
+
+func TestUnmarshalResponse_SkipsNonFlow(t *testing.T) {
+	t.Parallel()
+	fl, err := unmarshalResponse(msg(sub(2, str(1, "node")), str(1000, "n")))
+	if err != nil || fl != nil {
+		t.Errorf("node status = %+v, %v; want nil, nil", fl, err)
+	}
+}
+
+func TestRequestMarshal(t *testing.T) {
+	t.Parallel()
+	since := time.Unix(1700000000, 0)
+	b, err := Request{Namespace: "agent-bot", Verdicts: []string{"dropped"}, Since: since, Follow: true}.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var follow bool
+	var pods []string
+	var number uint64
+	var sinceSec uint64
+	err = walk(b, func(f field) error {
+		switch f.Num {
+		case reqNumber:
+			number = f.Varint
+		case reqFollow:
+			follow = f.Varint == 1
+		case reqSince:
+			return walk(f.Bytes, func(ts field) error {
+				if ts.Num == 1 {
+					sinceSec = ts.Varint
+				}
+				return nil
+			})
+		case reqWhitelist:
+			return walk(f.Bytes, func(ff field) error {
+				switch ff.Num {
+				case filterSourcePod, filterDestinationPod:
+					pods = append(pods, string(ff.Bytes))
+				case filterVerdict:
+					if v, _ := protowire.ConsumeVarint(ff.Bytes); v != 2 {
+						t.Errorf("verdict filter = %d, want 2", v)
+					}
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !follow || number != 0 || sinceSec != 1700000000 {
+		t.Errorf("follow=%v number=%d since=%d", follow, number, sinceSec)
+	}
+	if !slices.Equal(pods, []string{"agent-bot/", "agent-bot/"}) {
+		t.Errorf("pod filters = %v", pods)
+	}
+
+	if _, err := (Request{Verdicts: []string{"blocked"}}).marshal(); err == nil {
+		t.Error("unknown verdict accepted")
+	}
+}
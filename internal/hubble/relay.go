@@ -0,0 +1,105 @@
+package hubble
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+const (
+	// relayNamespace and relaySelector locate the Hubble Relay pod the Cilium
+	// chart deploys.
+	relayNamespace = "kube-system"
+	relaySelector  = "k8s-app=hubble-relay"
+	// relayPort is the relay container's gRPC port.
+	relayPort = 4245
+)
+
+// ForwardRelay port-forwards a local port to a running Hubble Relay pod and
+// returns its address. The forward lasts until stop is called or ctx ends.
+func ForwardRelay(ctx context.Context, restCfg *rest.Config, cs kubernetes.Interface) (addr string, stop func(), err error) {
+	pods, err := cs.CoreV1().Pods(relayNamespace).List(ctx, metav1.ListOptions{LabelSelector: relaySelector})
+	if err != nil {
+		return "", nil, fmt.Errorf("finding Hubble Relay: %w", err)
+	}
+	var pod string
+	for _, p := range pods.Items {
+		if p.Status.Phase == corev1.PodRunning {
+			pod = p.Name
+			break
+		}
+	}
+	if pod == "" {
+		return "", nil, fmt.Errorf("no running Hubble Relay pod in %s (selector %s); check: sikifanso doctor", relayNamespace, relaySelector)
+	}
+
+	transport, upgrader, err := spdy.RoundTripperFor(restCfg)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating port-forward transport: %w", err)
+	}
+	u, err := url.Parse(restCfg.Host)
+	if err != nil {
+		return "", nil, fmt.Errorf("parsing API server URL: %w", err)
+	}
+	u.Path += fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/portforward", relayNamespace, pod)
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, u)
+
+	stopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	fw, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"},
+		[]string{fmt.Sprintf("0:%d", relayPort)}, stopCh, readyCh, io.Discard, io.Discard)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating port-forward: %w", err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- fw.ForwardPorts() }()
+
+	select {
+	case <-readyCh:
+	case err := <-errCh:
+		return "", nil, fmt.Errorf("port-forwarding to %s/%s: %w", relayNamespace, pod, err)
+	case <-ctx.Done():
+		close(stopCh)
+		return "", nil, ctx.Err()
+	}
+
+	ports, err := fw.GetPorts()
+	if err != nil {
+		close(stopCh)
+		return "", nil, fmt.Errorf("reading forwarded port: %w", err)
+	}
+	var once sync.Once
+	stop = func() { once.Do(func() { close(stopCh) }) }
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return fmt.Sprintf("127.0.0.1:%d", ports[0].Local), stop, nil
+}
+
+// Connect port-forwards to Hubble Relay and returns a Client over the
+// forward. Closing the client stops the forward.
+func Connect(ctx context.Context, restCfg *rest.Config, cs kubernetes.Interface) (*Client, error) {
+	addr, stop, err := ForwardRelay(ctx, restCfg, cs)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient(addr)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	c.stop = stop
+	return c, nil
+}
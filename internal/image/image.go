@@ -0,0 +1,105 @@
+// Package image gets locally built images into a cluster: pushed to its
+// local registry, or imported straight into the nodes.
+package image
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	dockerimage "github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	k3dclient "github.com/k3d-io/k3d/v5/pkg/client"
+	k3drt "github.com/k3d-io/k3d/v5/pkg/runtimes"
+	k3d "github.com/k3d-io/k3d/v5/pkg/types"
+)
+
+// Pushed is the result of a push.
+type Pushed struct {
+	// Source is the local image that was pushed.
+	Source string `json:"source"`
+	// Pushed is the reference it was pushed to from the host.
+	Pushed string `json:"pushed"`
+	// Ref is the reference pods in the cluster use.
+	Ref string `json:"ref"`
+}
+
+// Push tags the local Docker image src for the cluster's registry, pushes
+// it and writes the push progress to out. The returned Ref is the
+// session.RegistryHost reference to use in pod specs.
+func Push(ctx context.Context, sess *session.Session, src string, out io.Writer) (*Pushed, error) {
+	if sess.Registry == nil {
+		return nil, fmt.Errorf("cluster %s has no local registry; recreate it with: sikifanso cluster create --registry, or use: sikifanso image load %s", sess.ClusterName, src)
+	}
+	path, err := repoPath(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pushed{
+		Source: src,
+		Pushed: sess.Registry.PushHost() + "/" + path,
+		Ref:    session.RegistryHost + "/" + path,
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("connecting to docker: %w", err)
+	}
+	defer func() { _ = cli.Close() }()
+
+	if err := cli.ImageTag(ctx, src, p.Pushed); err != nil {
+		return nil, fmt.Errorf("tagging %s: %w", src, err)
+	}
+	// The registry has no auth, but the daemon requires the header.
+	auth, err := registry.EncodeAuthConfig(registry.AuthConfig{})
+	if err != nil {
+		return nil, err
+	}
+	progress, err := cli.ImagePush(ctx, p.Pushed, dockerimage.PushOptions{RegistryAuth: auth})
+	if err != nil {
+		return nil, fmt.Errorf("pushing %s: %w", p.Pushed, err)
+	}
+	defer func() { _ = progress.Close() }()
+	if err := jsonmessage.DisplayJSONMessagesStream(progress, out, 0, false, nil); err != nil {
+		return nil, fmt.Errorf("pushing %s: %w", p.Pushed, err)
+	}
+	return p, nil
+}
+
+// Load imports local Docker images, or image tarballs, into every node of
+// the cluster. Pods use them by their original references; the pull policy
+// must not be Always, which rules out :latest tags without one.
+func Load(ctx context.Context, clusterName string, images []string) error {
+	cluster, err := k3dclient.ClusterGet(ctx, k3drt.Docker, &k3d.Cluster{Name: clusterName})
+	if err != nil {
+		return fmt.Errorf("cluster %q not found: %w", clusterName, err)
+	}
+	if err := k3dclient.ImageImportIntoClusterMulti(ctx, k3drt.Docker, images, cluster, k3d.ImageImportOpts{
+		Mode: k3d.ImportModeAutoDetect,
+	}); err != nil {
+		return fmt.Errorf("importing images into %s: %w", clusterName, err)
+	}
+	return nil
+}
+
+// repoPath strips the registry host from an image reference, so it can be
+// re-rooted on the local registry: "ghcr.io/org/app:1" becomes "org/app:1"
+// and "app:dev" stays as it is. Like Docker, the first path component is a
+// host when it contains a dot or a port, or is localhost.
+func repoPath(ref string) (string, error) {
+	if ref == "" || strings.ContainsAny(ref, " \t") {
+		return "", fmt.Errorf("invalid image reference %q", ref)
+	}
+	first, rest, ok := strings.Cut(ref, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref = rest
+	}
+	if ref == "" || strings.HasPrefix(ref, "/") {
+		return "", fmt.Errorf("invalid image reference %q", ref)
+	}
+	return ref, nil
+}
@@ -0,0 +1,48 @@
+package image
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+)
+
+func TestRepoPath(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{ref: "my-agent:dev", want: "my-agent:dev"},
+		{ref: "org/app", want: "org/app"},
+		{ref: "ghcr.io/org/app:1.2", want: "org/app:1.2"},
+		{ref: "localhost:5000/app:dev", want: "app:dev"},
+		{ref: "localhost/app", want: "app"},
+		{ref: "registry.local/app@sha256:abc", want: "app@sha256:abc"},
+		{ref: "", wantErr: true},
+		{ref: "ghcr.io/", wantErr: true},
+		{ref: "my app", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			t.Parallel()
+			got, err := repoPath(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("repoPath(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("repoPath(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPush_NeedsRegistry(t *testing.T) {
+	t.Parallel()
+	_, err := Push(context.Background(), &session.Session{ClusterName: "dev"}, "app:dev", nil)
+	if err == nil || !strings.Contains(err.Error(), "--registry") {
+		t.Errorf("error = %v, want a hint to create the cluster with --registry", err)
+	}
+}
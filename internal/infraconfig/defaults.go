@@ -2,6 +2,7 @@ package infraconfig
 
 import (
 	"embed"
+	"slices"
 	"sync"
 
 	"sigs.k8s.io/yaml"
@@ -29,6 +30,7 @@ func Defaults() *InfraConfig {
 	})
 
 	cp := *defaultCfg
+	cp.Platform.AgentPools = slices.Clone(defaultCfg.Platform.AgentPools)
 	cp.CiliumValues = copyMap(defaultCfg.CiliumValues)
 	cp.ArgoCDValues = copyMap(defaultCfg.ArgoCDValues)
 	return &cp
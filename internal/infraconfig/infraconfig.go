@@ -4,16 +4,82 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"sigs.k8s.io/yaml"
 )
 
 // PlatformConfig holds K3s image and cluster topology settings.
 type PlatformConfig struct {
-	K3sImage  string         `yaml:"k3sImage"`
-	Servers   int            `yaml:"servers"`
-	Agents    int            `yaml:"agents"`
-	NodePorts NodePortConfig `yaml:"nodePorts"`
+	K3sImage string `yaml:"k3sImage"`
+	Servers  int    `yaml:"servers"`
+	// Agents is the number of general-purpose k3d agent nodes, not counting
+	// the nodes of AgentPools.
+	Agents     int            `yaml:"agents"`
+	AgentPools []NodePool     `yaml:"agentPools"`
+	NodePorts  NodePortConfig `yaml:"nodePorts"`
+}
+
+// PoolLabel is the node label, and the key of the NoSchedule taint, that
+// marks the k3d agent nodes of a NodePool.
+const PoolLabel = "sikifanso.io/pool"
+
+// SandboxPool is the pool agent sandboxes are scheduled on when the cluster
+// has one.
+const SandboxPool = "sandbox"
+
+// NodePool is a group of k3d agent nodes reserved for one kind of workload.
+// Its nodes carry the PoolLabel label and a matching NoSchedule taint, so
+// only pods that tolerate the taint run there.
+type NodePool struct {
+	Name  string `yaml:"name" json:"name"`
+	Count int    `yaml:"count" json:"count"`
+}
+
+// Taint returns the pool's node taint in k3s --node-taint form.
+func (p NodePool) Taint() string {
+	return PoolLabel + "=" + p.Name + ":NoSchedule"
+}
+
+var validPoolName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// ParseNodePool parses a NAME=COUNT pool spec such as "sandbox=2".
+func ParseNodePool(spec string) (NodePool, error) {
+	name, count, ok := strings.Cut(spec, "=")
+	if !ok {
+		return NodePool{}, fmt.Errorf("invalid agent pool %q: want NAME=COUNT", spec)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return NodePool{}, fmt.Errorf("invalid agent pool %q: count must be a number", spec)
+	}
+	return NodePool{Name: name, Count: n}, nil
+}
+
+// ValidateTopology checks the node counts and pools of p.
+func (p PlatformConfig) ValidateTopology() error {
+	if p.Servers < 1 {
+		return fmt.Errorf("invalid servers %d: a cluster needs at least one server", p.Servers)
+	}
+	if p.Agents < 0 {
+		return fmt.Errorf("invalid agents %d: must not be negative", p.Agents)
+	}
+	seen := make(map[string]bool, len(p.AgentPools))
+	for _, pool := range p.AgentPools {
+		if !validPoolName.MatchString(pool.Name) {
+			return fmt.Errorf("invalid agent pool name %q: must match [a-z0-9-]+", pool.Name)
+		}
+		if pool.Count < 1 {
+			return fmt.Errorf("invalid agent pool %s: needs at least one node", pool.Name)
+		}
+		if seen[pool.Name] {
+			return fmt.Errorf("agent pool %s is defined twice", pool.Name)
+		}
+		seen[pool.Name] = true
+	}
+	return nil
 }
 
 // NodePortConfig holds container-side NodePort assignments.
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"testing"
 )
 
@@ -97,6 +98,9 @@ func TestLoadPartialFiles(t *testing.T) {
 k3sImage: "rancher/k3s:v1.30.0-k3s1"
 servers: 3
 agents: 5
+agentPools:
+  - name: sandbox
+    count: 2
 nodePorts:
   http: 30082
   https: 30083
@@ -121,6 +125,9 @@ nodePorts:
 	if cfg.Platform.Agents != 5 {
 		t.Errorf("Agents = %d, want 5", cfg.Platform.Agents)
 	}
+	if want := []NodePool{{Name: "sandbox", Count: 2}}; !slices.Equal(cfg.Platform.AgentPools, want) {
+		t.Errorf("AgentPools = %v, want %v", cfg.Platform.AgentPools, want)
+	}
 
 	// Other configs should retain defaults
 	if cfg.Cilium.RepoURL != "https://helm.cilium.io/" {
@@ -319,3 +326,49 @@ func TestArgoCDRuntimeOverridesUsesOnlyRealChartKeys(t *testing.T) {
 		t.Errorf("nodePortHttp = %v, want 30080", got)
 	}
 }
+
+func TestParseNodePool(t *testing.T) {
+	t.Parallel()
+	pool, err := ParseNodePool("sandbox=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool != (NodePool{Name: "sandbox", Count: 2}) {
+		t.Errorf("pool = %+v", pool)
+	}
+	if got := pool.Taint(); got != "sikifanso.io/pool=sandbox:NoSchedule" {
+		t.Errorf("Taint() = %q", got)
+	}
+	for _, spec := range []string{"sandbox", "sandbox=two", "=2"} {
+		if p, err := ParseNodePool(spec); err == nil {
+			if err := (PlatformConfig{Servers: 1, AgentPools: []NodePool{p}}).ValidateTopology(); err == nil {
+				t.Errorf("%q: expected an error", spec)
+			}
+		}
+	}
+}
+
+func TestValidateTopology(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		cfg     PlatformConfig
+		wantErr bool
+	}{
+		{"single server", PlatformConfig{Servers: 1}, false},
+		{"pools", PlatformConfig{Servers: 1, Agents: 1, AgentPools: []NodePool{{Name: "sandbox", Count: 2}, {Name: "gpu", Count: 1}}}, false},
+		{"no server", PlatformConfig{Servers: 0}, true},
+		{"negative agents", PlatformConfig{Servers: 1, Agents: -1}, true},
+		{"empty pool", PlatformConfig{Servers: 1, AgentPools: []NodePool{{Name: "sandbox"}}}, true},
+		{"bad pool name", PlatformConfig{Servers: 1, AgentPools: []NodePool{{Name: "Sandbox", Count: 1}}}, true},
+		{"duplicate pool", PlatformConfig{Servers: 1, AgentPools: []NodePool{{Name: "sandbox", Count: 1}, {Name: "sandbox", Count: 2}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tt.cfg.ValidateTopology(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTopology() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package kube
 import (
 	"fmt"
 
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -49,3 +50,49 @@ func NodeReady(node corev1.Node) bool {
 	}
 	return false
 }
+
+// Node pools that are not agent pools: k3d servers, and agents without a
+// pool label.
+const (
+	ServerPool  = "server"
+	GeneralPool = "general"
+)
+
+// NodePool returns the pool a k3d node belongs to: its infraconfig.PoolLabel
+// value, or ServerPool or GeneralPool for nodes without one.
+func NodePool(node corev1.Node) string {
+	if pool := node.Labels[infraconfig.PoolLabel]; pool != "" {
+		return pool
+	}
+	if _, ok := node.Labels["node-role.kubernetes.io/control-plane"]; ok {
+		return ServerPool
+	}
+	return GeneralPool
+}
+
+// PoolStatus counts the nodes of one pool and how many of them are Ready.
+type PoolStatus struct {
+	Name  string `json:"name"`
+	Nodes int    `json:"nodes"`
+	Ready int    `json:"ready"`
+}
+
+// PoolStatuses groups nodes by NodePool, in order of first appearance.
+func PoolStatuses(nodes []corev1.Node) []PoolStatus {
+	var pools []PoolStatus
+	index := map[string]int{}
+	for _, n := range nodes {
+		name := NodePool(n)
+		i, ok := index[name]
+		if !ok {
+			i = len(pools)
+			index[name] = i
+			pools = append(pools, PoolStatus{Name: name})
+		}
+		pools[i].Nodes++
+		if NodeReady(n) {
+			pools[i].Ready++
+		}
+	}
+	return pools
+}
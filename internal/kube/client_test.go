@@ -56,3 +56,36 @@ func TestNodeReady_NoCondition(t *testing.T) {
 		t.Error("expected NodeReady to return false when no conditions present")
 	}
 }
+
+func TestPoolStatuses(t *testing.T) {
+	t.Parallel()
+	node := func(name string, labels map[string]string, ready corev1.ConditionStatus) corev1.Node {
+		return corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+			},
+		}
+	}
+	nodes := []corev1.Node{
+		node("k3d-dev-server-0", map[string]string{"node-role.kubernetes.io/control-plane": "true"}, corev1.ConditionTrue),
+		node("k3d-dev-agent-0", nil, corev1.ConditionTrue),
+		node("k3d-dev-agent-1", map[string]string{"sikifanso.io/pool": "sandbox"}, corev1.ConditionTrue),
+		node("k3d-dev-agent-2", map[string]string{"sikifanso.io/pool": "sandbox"}, corev1.ConditionFalse),
+	}
+
+	got := PoolStatuses(nodes)
+	want := []PoolStatus{
+		{Name: ServerPool, Nodes: 1, Ready: 1},
+		{Name: GeneralPool, Nodes: 1, Ready: 1},
+		{Name: "sandbox", Nodes: 2, Ready: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d pools, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("pool %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
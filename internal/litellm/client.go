@@ -0,0 +1,130 @@
+// Package litellm talks to the admin API of the LiteLLM Proxy catalog app to
+// manage the virtual keys handed to agent sandboxes.
+package litellm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls the LiteLLM admin API with the proxy's master key.
+type Client struct {
+	baseURL   string
+	masterKey string
+	http      *http.Client
+}
+
+// NewClient returns a Client for the proxy at baseURL. A nil httpClient uses
+// http.DefaultClient.
+func NewClient(baseURL, masterKey string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		masterKey: masterKey,
+		http:      httpClient,
+	}
+}
+
+// KeyRequest describes a virtual key to generate. Zero MaxBudget and RPMLimit
+// mean unlimited; empty Models allows every model the proxy serves.
+type KeyRequest struct {
+	Alias     string            `json:"key_alias"`
+	MaxBudget float64           `json:"max_budget,omitempty"`
+	RPMLimit  int               `json:"rpm_limit,omitempty"`
+	Models    []string          `json:"models,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// Key is a virtual key and its usage. Spend and MaxBudget are in USD.
+type Key struct {
+	Key       string   `json:"key,omitempty"`
+	Alias     string   `json:"key_alias,omitempty"`
+	Spend     float64  `json:"spend"`
+	MaxBudget *float64 `json:"max_budget,omitempty"`
+	RPMLimit  *int     `json:"rpm_limit,omitempty"`
+	Models    []string `json:"models,omitempty"`
+}
+
+// GenerateKey creates a virtual key.
+func (c *Client) GenerateKey(ctx context.Context, req KeyRequest) (*Key, error) {
+	var key Key
+	if err := c.do(ctx, http.MethodPost, "/key/generate", req, &key); err != nil {
+		return nil, fmt.Errorf("generating key %s: %w", req.Alias, err)
+	}
+	if key.Key == "" {
+		return nil, fmt.Errorf("generating key %s: response has no key", req.Alias)
+	}
+	return &key, nil
+}
+
+// DeleteKey revokes a virtual key.
+func (c *Client) DeleteKey(ctx context.Context, key string) error {
+	body := map[string][]string{"keys": {key}}
+	if err := c.do(ctx, http.MethodPost, "/key/delete", body, nil); err != nil {
+		return fmt.Errorf("deleting key: %w", err)
+	}
+	return nil
+}
+
+// KeyInfo returns the limits and current spend of a virtual key.
+func (c *Client) KeyInfo(ctx context.Context, key string) (*Key, error) {
+	var resp struct {
+		Info Key `json:"info"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/key/info?key="+url.QueryEscape(key), nil, &resp); err != nil {
+		return nil, fmt.Errorf("reading key info: %w", err)
+	}
+	resp.Info.Key = ""
+	return &resp.Info, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("building request: %w", err)
+	}
+	// LiteLLM also accepts the master key in x-litellm-api-key. Authorization
+	// would not survive the Kubernetes API server proxy, which strips it after
+	// authenticating the caller.
+	req.Header.Set("x-litellm-api-key", "Bearer "+c.masterKey)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
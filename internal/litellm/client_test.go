@@ -0,0 +1,103 @@
+package litellm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubProxy serves /key/generate, /key/info and /key/delete from memory,
+// rejecting requests without the master key.
+func stubProxy(t *testing.T) (*httptest.Server, map[string]*Key) {
+	t.Helper()
+	keys := map[string]*Key{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /key/generate", func(w http.ResponseWriter, r *http.Request) {
+		var req KeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		k := &Key{Key: "sk-" + req.Alias, Alias: req.Alias, Models: req.Models}
+		if req.MaxBudget > 0 {
+			k.MaxBudget = &req.MaxBudget
+		}
+		if req.RPMLimit > 0 {
+			k.RPMLimit = &req.RPMLimit
+		}
+		keys[k.Key] = k
+		_ = json.NewEncoder(w).Encode(k)
+	})
+	mux.HandleFunc("GET /key/info", func(w http.ResponseWriter, r *http.Request) {
+		k, ok := keys[r.URL.Query().Get("key")]
+		if !ok {
+			http.Error(w, `{"error":"key not found"}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"key": k.Key, "info": k})
+	})
+	mux.HandleFunc("POST /key/delete", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Keys []string `json:"keys"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		for _, k := range req.Keys {
+			delete(keys, k)
+		}
+		_, _ = w.Write([]byte(`{"deleted_keys":[]}`))
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-litellm-api-key") != "Bearer sk-master" {
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, keys
+}
+
+func TestClient_KeyLifecycle(t *testing.T) {
+	t.Parallel()
+	srv, keys := stubProxy(t)
+	c := NewClient(srv.URL+"/", "sk-master", nil)
+	ctx := context.Background()
+
+	key, err := c.GenerateKey(ctx, KeyRequest{Alias: "bot", MaxBudget: 5, RPMLimit: 60, Models: []string{"gpt-4o"}})
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if key.Key != "sk-bot" {
+		t.Errorf("key = %q, want sk-bot", key.Key)
+	}
+	keys["sk-bot"].Spend = 1.25
+
+	info, err := c.KeyInfo(ctx, key.Key)
+	if err != nil {
+		t.Fatalf("KeyInfo: %v", err)
+	}
+	if info.Spend != 1.25 || info.MaxBudget == nil || *info.MaxBudget != 5 || *info.RPMLimit != 60 {
+		t.Errorf("info = %+v, want spend 1.25, budget 5, rpm 60", info)
+	}
+	if info.Key != "" {
+		t.Error("KeyInfo leaked the key value")
+	}
+
+	if err := c.DeleteKey(ctx, key.Key); err != nil {
+		t.Fatalf("DeleteKey: %v", err)
+	}
+	if _, err := c.KeyInfo(ctx, key.Key); err == nil {
+		t.Error("KeyInfo after delete succeeded, want error")
+	}
+}
+
+func TestClient_RejectsWrongMasterKey(t *testing.T) {
+	t.Parallel()
+	srv, _ := stubProxy(t)
+	c := NewClient(srv.URL, "sk-wrong", nil)
+	if _, err := c.GenerateKey(context.Background(), KeyRequest{Alias: "bot"}); err == nil {
+		t.Fatal("GenerateKey with wrong master key succeeded")
+	}
+}
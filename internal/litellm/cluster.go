@@ -0,0 +1,50 @@
+package litellm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// AppName is the catalog entry that deploys the proxy.
+	AppName = "litellm-proxy"
+	// ServiceName and ServicePort address the proxy inside the cluster. The
+	// chart names its resources after the release since it contains "litellm".
+	ServiceName = "litellm-proxy"
+	ServicePort = 4000
+	// masterKeySecret holds the chart-generated master key under masterKeyField.
+	masterKeySecret = "litellm-proxy-masterkey"
+	masterKeyField  = "masterkey"
+)
+
+// InClusterURL is the address pods use to reach the proxy in namespace.
+func InClusterURL(namespace string) string {
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", ServiceName, namespace, ServicePort)
+}
+
+// NewClusterClient returns a Client that reaches the proxy in namespace
+// through the Kubernetes API server's service proxy, so no port is exposed
+// on the host. The master key is read from the chart's secret.
+func NewClusterClient(ctx context.Context, restCfg *rest.Config, cs kubernetes.Interface, namespace string) (*Client, error) {
+	secret, err := cs.CoreV1().Secrets(namespace).Get(ctx, masterKeySecret, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("reading LiteLLM master key: %w", err)
+	}
+	masterKey := string(secret.Data[masterKeyField])
+	if masterKey == "" {
+		return nil, fmt.Errorf("secret %s/%s has no %s", namespace, masterKeySecret, masterKeyField)
+	}
+
+	httpClient, err := rest.HTTPClientFor(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating API server HTTP client: %w", err)
+	}
+	base := fmt.Sprintf("%s/api/v1/namespaces/%s/services/http:%s:%d/proxy",
+		strings.TrimSuffix(restCfg.Host, "/"), namespace, ServiceName, ServicePort)
+	return NewClient(base, masterKey, httpClient), nil
+}
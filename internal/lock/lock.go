@@ -0,0 +1,176 @@
+// Package lock serializes writes to a cluster's gitops repo and session
+// file across the CLI, the MCP server and the dashboard. It is an advisory
+// file lock in the cluster's session directory that records who holds it,
+// so a caller that times out can say which surface and process to wait for.
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileName is the lock file created in the cluster's session directory.
+const FileName = "mutation.lock"
+
+// DefaultTimeout is how long Acquire waits for a busy lock. The
+// SIKIFANSO_LOCK_TIMEOUT environment variable overrides it, e.g. "30s".
+const DefaultTimeout = 10 * time.Second
+
+// pollInterval is how often Acquire retries a busy lock.
+const pollInterval = 50 * time.Millisecond
+
+// ErrBusy is matched by the error Acquire returns when the lock stays held
+// past the timeout.
+var ErrBusy = errors.New("cluster busy")
+
+var (
+	mu      sync.Mutex
+	surface = "cli"
+)
+
+// SetSurface names the interface this process mutates clusters through:
+// cli (the default), mcp or dashboard. It is recorded as the holder.
+func SetSurface(s string) {
+	mu.Lock()
+	defer mu.Unlock()
+	surface = s
+}
+
+func settings() (string, time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	d := DefaultTimeout
+	if v := os.Getenv("SIKIFANSO_LOCK_TIMEOUT"); v != "" {
+		if parsed, err := time.ParseDuration(v); err == nil && parsed >= 0 {
+			d = parsed
+		}
+	}
+	return surface, d
+}
+
+// Holder describes the process holding a lock.
+type Holder struct {
+	Surface string    `json:"surface"`
+	PID     int       `json:"pid"`
+	Since   time.Time `json:"since"`
+}
+
+// BusyError reports a lock that stayed held past the timeout. Holder is nil
+// when the holder had not recorded itself yet.
+type BusyError struct {
+	Holder *Holder
+}
+
+func (e *BusyError) Error() string {
+	if e.Holder == nil {
+		return ErrBusy.Error()
+	}
+	return fmt.Sprintf("%s, held by %s pid %d", ErrBusy, e.Holder.Surface, e.Holder.PID)
+}
+
+// Unwrap makes errors.Is(err, ErrBusy) hold.
+func (e *BusyError) Unwrap() error { return ErrBusy }
+
+// Lock is a held lock. Release it when the mutation is done.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire takes the lock in dir, creating dir and the lock file as needed,
+// and waits up to the timeout while another process or goroutine holds it.
+// The lock is not reentrant: acquiring it again before Release waits for
+// the timeout and fails.
+func Acquire(dir string) (*Lock, error) {
+	surface, wait := settings()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating lock directory: %w", err)
+	}
+	path := filepath.Join(dir, FileName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("locking %s: %w", path, err)
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			holder := readHolder(f)
+			f.Close()
+			return nil, &BusyError{Holder: holder}
+		}
+		time.Sleep(pollInterval)
+	}
+
+	if err := writeHolder(f, Holder{Surface: surface, PID: os.Getpid(), Since: time.Now().UTC()}); err != nil {
+		_ = unlock(f)
+		f.Close()
+		return nil, fmt.Errorf("recording lock holder: %w", err)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Release clears the holder record and frees the lock.
+func (l *Lock) Release() {
+	_ = l.f.Truncate(0)
+	_ = unlock(l.f)
+	_ = l.f.Close()
+}
+
+// Do runs fn while holding the lock in dir.
+func Do(dir string, fn func() error) error {
+	l, err := Acquire(dir)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	return fn()
+}
+
+// GitOps takes the lock guarding the gitops repo at gitOpsPath. It lives in
+// the repo's parent, the cluster's session directory, so it is shared with
+// session writes and stays out of the repo itself.
+func GitOps(gitOpsPath string) (*Lock, error) {
+	abs, err := filepath.Abs(gitOpsPath)
+	if err != nil {
+		return nil, fmt.Errorf("resolving gitops path: %w", err)
+	}
+	return Acquire(filepath.Dir(abs))
+}
+
+func writeHolder(f *os.File, h Holder) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(data, 0)
+	return err
+}
+
+func readHolder(f *os.File) *Holder {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 4096))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	var h Holder
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil
+	}
+	return &h
+}
@@ -0,0 +1,154 @@
+package lock
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHelperHolder is not a real test: it is the child process of
+// TestAcquire_BusyAcrossProcesses, holding the lock until stdin closes.
+func TestHelperHolder(t *testing.T) {
+	dir := os.Getenv("LOCK_HELPER_DIR")
+	if dir == "" {
+		t.Skip("helper process only")
+	}
+	SetSurface("dashboard")
+	l, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer l.Release()
+	os.Stdout.WriteString("locked\n")
+	_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+}
+
+func TestAcquire_BusyAcrossProcesses(t *testing.T) {
+	t.Setenv("SIKIFANSO_LOCK_TIMEOUT", "200ms")
+	dir := t.TempDir()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperHolder$")
+	cmd.Env = append(os.Environ(), "LOCK_HELPER_DIR="+dir)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		stdin.Close()
+		_ = cmd.Wait()
+	}()
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "locked" {
+		t.Fatalf("helper did not lock: %q, %v", line, err)
+	}
+
+	_, err = Acquire(dir)
+	var busy *BusyError
+	if !errors.As(err, &busy) || !errors.Is(err, ErrBusy) {
+		t.Fatalf("Acquire = %v, want a BusyError", err)
+	}
+	if busy.Holder == nil || busy.Holder.Surface != "dashboard" || busy.Holder.PID != cmd.Process.Pid {
+		t.Errorf("Holder = %+v, want dashboard pid %d", busy.Holder, cmd.Process.Pid)
+	}
+	want := "cluster busy, held by dashboard pid "
+	if !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("error = %q, want prefix %q", err, want)
+	}
+
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("helper: %v", err)
+	}
+	l, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire after the holder exited: %v", err)
+	}
+	l.Release()
+}
+
+func TestAcquire_WaitsForRelease(t *testing.T) {
+	dir := t.TempDir()
+	first, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		second, err := Acquire(dir)
+		if err == nil {
+			second.Release()
+		}
+		acquired <- err
+	}()
+
+	select {
+	case err := <-acquired:
+		t.Fatalf("second Acquire returned while the lock was held: %v", err)
+	case <-time.After(3 * pollInterval):
+	}
+	first.Release()
+	if err := <-acquired; err != nil {
+		t.Fatalf("second Acquire: %v", err)
+	}
+}
+
+func TestAcquire_BusyNamesHolder(t *testing.T) {
+	t.Setenv("SIKIFANSO_LOCK_TIMEOUT", "100ms")
+	dir := t.TempDir()
+	l, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer l.Release()
+
+	err = Do(dir, func() error {
+		t.Fatal("fn ran without the lock")
+		return nil
+	})
+	var busy *BusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("Do = %v, want a BusyError", err)
+	}
+	if busy.Holder == nil || busy.Holder.Surface != "cli" || busy.Holder.PID != os.Getpid() {
+		t.Errorf("Holder = %+v, want cli pid %d", busy.Holder, os.Getpid())
+	}
+}
+
+func TestRelease_ClearsHolder(t *testing.T) {
+	dir := t.TempDir()
+	if err := Do(dir, func() error { return nil }); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Errorf("lock file holds %q after release, want it empty", data)
+	}
+}
+
+func TestGitOps_LocksSessionDir(t *testing.T) {
+	sessDir := t.TempDir()
+	l, err := GitOps(filepath.Join(sessDir, "gitops"))
+	if err != nil {
+		t.Fatalf("GitOps: %v", err)
+	}
+	defer l.Release()
+	if _, err := os.Stat(filepath.Join(sessDir, FileName)); err != nil {
+		t.Errorf("lock file not in the session directory: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package lock
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// The locked byte range lies past the holder record, which Windows would
+// otherwise refuse to let waiters read.
+const rangeOffsetHigh = 1
+
+// tryLock takes an exclusive lock on f without blocking. It reports false
+// when another handle holds it.
+func tryLock(f *os.File) (bool, error) {
+	ol := windows.Overlapped{OffsetHigh: rangeOffsetHigh}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlock(f *os.File) error {
+	ol := windows.Overlapped{OffsetHigh: rangeOffsetHigh}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
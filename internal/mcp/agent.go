@@ -1,14 +1,21 @@
 package mcp
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/litellm"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
+// defaultRunTimeout bounds agent_run when no timeout is given.
+const defaultRunTimeout = 5 * time.Minute
+
 type agentListInput struct {
 	Cluster string `json:"cluster" jsonschema:"Name of the cluster"`
 }
@@ -19,13 +26,55 @@ type agentInfoInput struct {
 }
 
 type agentCreateInput struct {
+	Cluster       string   `json:"cluster" jsonschema:"Name of the cluster"`
+	Name          string   `json:"name" jsonschema:"Name for the new agent"`
+	CPURequest    string   `json:"cpuRequest,omitempty" jsonschema:"CPU request quota (guaranteed), e.g. 250m"`
+	CPULimit      string   `json:"cpuLimit,omitempty" jsonschema:"CPU limit quota (burst ceiling), e.g. 1000m"`
+	MemoryRequest string   `json:"memoryRequest,omitempty" jsonschema:"Memory request quota (guaranteed), e.g. 256Mi"`
+	MemoryLimit   string   `json:"memoryLimit,omitempty" jsonschema:"Memory limit quota (burst ceiling), e.g. 1Gi"`
+	Pods          string   `json:"pods,omitempty" jsonschema:"Max pods, e.g. 10"`
+	LLMBudget     float64  `json:"llmBudget,omitempty" jsonschema:"Max LLM spend in USD for the agent's LiteLLM key; omit for unlimited"`
+	LLMRPM        int      `json:"llmRpm,omitempty" jsonschema:"Max LLM requests per minute for the agent's LiteLLM key; omit for unlimited"`
+	LLMModels     []string `json:"llmModels,omitempty" jsonschema:"Models the agent's LiteLLM key may use; omit for all"`
+	TTL           string   `json:"ttl,omitempty" jsonschema:"Lifetime of an ephemeral agent, e.g. 4h; it is reaped once elapsed. Omit to keep it until deleted"`
+	Template      string   `json:"template,omitempty" jsonschema:"Agent template providing a workload wired to LiteLLM, Qdrant and Langfuse; see agent_templates"`
+	Image         string   `json:"image,omitempty" jsonschema:"Replace the template's workload image and command"`
+}
+
+type agentTemplatesInput struct {
+	Cluster string `json:"cluster" jsonschema:"Name of the cluster"`
+}
+
+type agentUpdateInput struct {
 	Cluster       string `json:"cluster" jsonschema:"Name of the cluster"`
-	Name          string `json:"name" jsonschema:"Name for the new agent"`
-	CPURequest    string `json:"cpuRequest,omitempty" jsonschema:"CPU request quota (guaranteed), e.g. 250m"`
-	CPULimit      string `json:"cpuLimit,omitempty" jsonschema:"CPU limit quota (burst ceiling), e.g. 1000m"`
-	MemoryRequest string `json:"memoryRequest,omitempty" jsonschema:"Memory request quota (guaranteed), e.g. 256Mi"`
-	MemoryLimit   string `json:"memoryLimit,omitempty" jsonschema:"Memory limit quota (burst ceiling), e.g. 1Gi"`
-	Pods          string `json:"pods,omitempty" jsonschema:"Max pods, e.g. 10"`
+	Name          string `json:"name" jsonschema:"Name of the agent to update"`
+	CPURequest    string `json:"cpuRequest,omitempty" jsonschema:"New CPU request quota, e.g. 500m; omit to keep"`
+	CPULimit      string `json:"cpuLimit,omitempty" jsonschema:"New CPU limit quota, e.g. 2000m; omit to keep"`
+	MemoryRequest string `json:"memoryRequest,omitempty" jsonschema:"New memory request quota, e.g. 512Mi; omit to keep"`
+	MemoryLimit   string `json:"memoryLimit,omitempty" jsonschema:"New memory limit quota, e.g. 2Gi; omit to keep"`
+	Pods          string `json:"pods,omitempty" jsonschema:"New max pods; omit to keep"`
+	ChartVersion  string `json:"chartVersion,omitempty" jsonschema:"New agent template chart version; omit to keep"`
+	DryRun        bool   `json:"dryRun,omitempty" jsonschema:"Report the changes without applying them"`
+}
+
+type agentFreezeInput struct {
+	Cluster string `json:"cluster" jsonschema:"Name of the cluster"`
+	Name    string `json:"name" jsonschema:"Name of the agent to freeze"`
+	Reason  string `json:"reason,omitempty" jsonschema:"Why the agent is frozen, kept in the audit log"`
+}
+
+type agentUnfreezeInput struct {
+	Cluster string `json:"cluster" jsonschema:"Name of the cluster"`
+	Name    string `json:"name" jsonschema:"Name of the agent to unfreeze"`
+}
+
+type agentRunInput struct {
+	Cluster        string   `json:"cluster" jsonschema:"Name of the cluster"`
+	Name           string   `json:"name" jsonschema:"Name of the agent to run in"`
+	Command        []string `json:"command,omitempty" jsonschema:"Command and arguments to run, e.g. [\"python\", \"-c\", \"print(1)\"]; omit for the image's entrypoint"`
+	Image          string   `json:"image,omitempty" jsonschema:"Image to run; defaults to the agent's template workload image"`
+	Keep           string   `json:"keep,omitempty" jsonschema:"Keep the job afterwards: never (default), always or on-failure"`
+	TimeoutSeconds int      `json:"timeoutSeconds,omitempty" jsonschema:"Give up waiting after this many seconds; default 300"`
 }
 
 type agentDeleteInput struct {
@@ -51,17 +100,25 @@ func registerAgentTools(s *mcp.Server, deps *Deps) {
 		}
 		var sb strings.Builder
 		sb.WriteString("Agents:\n")
+		now := time.Now()
 		for _, a := range agents {
-			fmt.Fprintf(&sb, "  - %s (namespace: %s, cpu: %s/%s, memory: %s/%s, pods: %s)\n",
+			fmt.Fprintf(&sb, "  - %s (namespace: %s, cpu: %s/%s, memory: %s/%s, pods: %s",
 				a.Name, a.Namespace, a.CPURequest, a.CPULimit, a.MemoryRequest, a.MemoryLimit, a.Pods)
+			switch {
+			case a.Expired(now):
+				sb.WriteString(", expired")
+			case a.ExpiresAt != nil:
+				fmt.Fprintf(&sb, ", expires in %s", a.Remaining(now).Round(time.Minute))
+			}
+			sb.WriteString(")\n")
 		}
 		return textResult(sb.String())
 	})
 
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "agent_info",
-		Description: "Get detailed information about a specific agent",
-	}, func(_ context.Context, _ *mcp.CallToolRequest, input agentInfoInput) (*mcp.CallToolResult, any, error) {
+		Description: "Get detailed information about a specific agent, including live quota usage and LLM key spend when LiteLLM Proxy is enabled",
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, input agentInfoInput) (*mcp.CallToolResult, any, error) {
 		sess, r, sv, e := loadSession(input.Cluster)
 		if sess == nil {
 			return r, sv, e
@@ -76,18 +133,88 @@ func registerAgentTools(s *mcp.Server, deps *Deps) {
 		fmt.Fprintf(&sb, "CPU: %s request / %s limit\n", info.CPURequest, info.CPULimit)
 		fmt.Fprintf(&sb, "Memory: %s request / %s limit\n", info.MemoryRequest, info.MemoryLimit)
 		fmt.Fprintf(&sb, "Max Pods: %s\n", info.Pods)
+		if info.ExpiresAt != nil {
+			fmt.Fprintf(&sb, "Expires: %s\n", info.ExpiresAt.Format(time.RFC3339))
+		}
+		if info.Template != "" {
+			fmt.Fprintf(&sb, "Template: %s\n", info.Template)
+		}
+		if f, err := agent.NewFreezer(sess); err == nil {
+			if rec, err := f.Frozen(ctx, input.Name); err == nil && rec != nil {
+				fmt.Fprintf(&sb, "Frozen: since %s by %s via %s", rec.At, rec.By, rec.Via)
+				if rec.Reason != "" {
+					fmt.Fprintf(&sb, " (%s)", rec.Reason)
+				}
+				sb.WriteString("\n")
+			}
+		}
+		writeAgentUsage(ctx, &sb, sess.ClusterName, info.Namespace)
+
+		gw, err := agent.ConnectGateway(ctx, sess.GitOpsPath, sess.ClusterName)
+		var key *litellm.Key
+		if err == nil && gw != nil {
+			key, err = gw.KeyUsage(ctx, input.Name)
+		}
+		switch {
+		case err != nil:
+			fmt.Fprintf(&sb, "LLM key: unavailable (%v)\n", err)
+		case key != nil:
+			fmt.Fprintf(&sb, "LLM key: %s\n", key.Alias)
+			fmt.Fprintf(&sb, "LLM spend: $%.2f\n", key.Spend)
+			if key.MaxBudget != nil {
+				fmt.Fprintf(&sb, "LLM budget: $%.2f\n", *key.MaxBudget)
+			}
+			if key.RPMLimit != nil {
+				fmt.Fprintf(&sb, "LLM rate limit: %d rpm\n", *key.RPMLimit)
+			}
+			if len(key.Models) > 0 {
+				fmt.Fprintf(&sb, "LLM models: %s\n", strings.Join(key.Models, ", "))
+			}
+		}
+		return textResult(sb.String())
+	})
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "agent_templates",
+		Description: "List agent templates usable with agent_create, with the catalog apps each requires",
+	}, func(_ context.Context, _ *mcp.CallToolRequest, input agentTemplatesInput) (*mcp.CallToolResult, any, error) {
+		sess, r, sv, e := loadSession(input.Cluster)
+		if sess == nil {
+			return r, sv, e
+		}
+		templates, err := agent.ListTemplates(sess.GitOpsPath)
+		if err != nil {
+			return errResult(fmt.Errorf("listing templates: %w", err))
+		}
+		var sb strings.Builder
+		sb.WriteString("Agent templates:\n")
+		for _, t := range templates {
+			fmt.Fprintf(&sb, "  - %s (%s, image: %s): %s", t.Name, t.Source, t.Workload.Image, t.Description)
+			if len(t.Requires) > 0 {
+				fmt.Fprintf(&sb, "; requires %s", strings.Join(t.Requires, ", "))
+			}
+			sb.WriteString("\n")
+		}
 		return textResult(sb.String())
 	})
 
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "agent_create",
-		Description: "Create an isolated agent namespace with resource quotas and network policies",
+		Description: "Create an isolated agent namespace with resource quotas and network policies; when LiteLLM Proxy is enabled the agent also gets a virtual key with optional budget, rate limit and model allowlist",
 	}, func(ctx context.Context, _ *mcp.CallToolRequest, input agentCreateInput) (*mcp.CallToolResult, any, error) {
 		sess, r, sv, e := loadSession(input.Cluster)
 		if sess == nil {
 			return r, sv, e
 		}
 
+		var ttl time.Duration
+		if input.TTL != "" {
+			d, err := time.ParseDuration(input.TTL)
+			if err != nil {
+				return errResult(fmt.Errorf("invalid ttl %q: %w", input.TTL, err))
+			}
+			ttl = d
+		}
 		opts := agent.CreateOpts{
 			Name:          input.Name,
 			CPURequest:    input.CPURequest,
@@ -95,15 +222,78 @@ func registerAgentTools(s *mcp.Server, deps *Deps) {
 			MemoryRequest: input.MemoryRequest,
 			MemoryLimit:   input.MemoryLimit,
 			Pods:          input.Pods,
+			TTL:           ttl,
+			Template:      input.Template,
+			Image:         input.Image,
+			NodePool:      agent.NodePoolFor(sess.K3dConfig),
+			ChartRepoURL:  agent.ChartRepoFor(sess.Bundle),
+		}
+		keyOpts := agent.KeyOpts{
+			MaxBudget: input.LLMBudget,
+			RPMLimit:  input.LLMRPM,
+			Models:    input.LLMModels,
+		}
+		if err := agent.CheckKeyOpts(sess.GitOpsPath, keyOpts); err != nil {
+			return errResult(err)
+		}
+		gw, err := agent.ConnectGateway(ctx, sess.GitOpsPath, sess.ClusterName)
+		if err != nil {
+			return errResult(fmt.Errorf("connecting to the LLM gateway: %w", err))
 		}
 		if err := agent.Create(sess.GitOpsPath, opts); err != nil {
 			return errResult(err)
 		}
 
 		result := fmt.Sprintf("Agent %q created (namespace: agent-%s).\nCommitted to gitops repo.", input.Name, input.Name)
+		if gw != nil {
+			key, err := gw.ProvisionNewKey(ctx, sess.GitOpsPath, input.Name, keyOpts)
+			if err != nil {
+				return errResult(fmt.Errorf("agent %q not created: %w", input.Name, err))
+			}
+			result += fmt.Sprintf("\nLLM key %s stored in secret agent-%s/%s.", key.Alias, input.Name, agent.KeySecretName)
+		}
 		return textResult(appendSyncStatus(ctx, deps, sess, result, "agents"))
 	})
 
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "agent_update",
+		Description: "Change an agent's resource quotas or chart version in place, keeping its namespace and workloads",
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, input agentUpdateInput) (*mcp.CallToolResult, any, error) {
+		sess, r, sv, e := loadSession(input.Cluster)
+		if sess == nil {
+			return r, sv, e
+		}
+
+		changes, err := agent.Update(sess.GitOpsPath, agent.UpdateOpts{
+			Name:          input.Name,
+			CPURequest:    input.CPURequest,
+			CPULimit:      input.CPULimit,
+			MemoryRequest: input.MemoryRequest,
+			MemoryLimit:   input.MemoryLimit,
+			Pods:          input.Pods,
+			ChartVersion:  input.ChartVersion,
+			DryRun:        input.DryRun,
+		})
+		if err != nil {
+			return errResult(err)
+		}
+		if len(changes) == 0 {
+			return textResult(fmt.Sprintf("Agent %q is unchanged.", input.Name))
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Agent %q changes:\n", input.Name)
+		for _, c := range changes {
+			fmt.Fprintf(&sb, "  - %s: %s -> %s\n", c.Field, c.From, c.To)
+		}
+		if input.DryRun {
+			sb.WriteString("Dry run: nothing was written.")
+			return textResult(sb.String())
+		}
+		sb.WriteString("Committed to gitops repo.")
+		return textResult(appendSyncStatus(ctx, deps, sess, sb.String(), "agents"))
+	})
+
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "agent_delete",
 		Description: "Delete an agent and its namespace",
@@ -116,8 +306,171 @@ func registerAgentTools(s *mcp.Server, deps *Deps) {
 		if err := agent.Delete(sess.GitOpsPath, input.Name); err != nil {
 			return errResult(err)
 		}
+		revoked := revokeAgentKey(ctx, sess, input.Name)
 
-		result := fmt.Sprintf("Agent %q deleted.\nCommitted to gitops repo.", input.Name)
+		result := fmt.Sprintf("Agent %q deleted.\nCommitted to gitops repo.%s", input.Name, revoked)
 		return textResult(appendSyncStatus(ctx, deps, sess, result, "agents"))
 	})
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name: "agent_run",
+		Description: "Run a one-off command as a Job inside an agent sandbox, with the agent's service account, env and network policy. " +
+			"Returns the exit code and the last log lines",
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, input agentRunInput) (*mcp.CallToolResult, any, error) {
+		sess, r, sv, e := loadSession(input.Cluster)
+		if sess == nil {
+			return r, sv, e
+		}
+		runner, err := agent.NewRunner(sess.ClusterName, sess.GitOpsPath)
+		if err != nil {
+			return errResult(err)
+		}
+		timeout := defaultRunTimeout
+		if input.TimeoutSeconds > 0 {
+			timeout = time.Duration(input.TimeoutSeconds) * time.Second
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		var logs bytes.Buffer
+		res, err := runner.Run(ctx, agent.RunOpts{
+			Name:    input.Name,
+			Image:   input.Image,
+			Command: input.Command,
+			Keep:    input.Keep,
+		}, &logs)
+		if err != nil {
+			return errResult(err)
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "Job %s exited with code %d.", res.Job, res.ExitCode)
+		if res.Kept {
+			sb.WriteString(" The job was kept.")
+		}
+		sb.WriteString("\n\nLogs:\n")
+		sb.WriteString(tailLines(logs.String(), maxLogTailLines))
+		return textResult(sb.String())
+	})
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name: "agent_freeze",
+		Description: "Emergency stop for an agent, bypassing GitOps: denies all of its network traffic, stops its workloads and pauses ArgoCD sync of its application. " +
+			"Use agent_unfreeze to restore it",
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, input agentFreezeInput) (*mcp.CallToolResult, any, error) {
+		sess, r, sv, e := loadSession(input.Cluster)
+		if sess == nil {
+			return r, sv, e
+		}
+		if _, err := agent.Find(sess.GitOpsPath, input.Name); err != nil {
+			return errResult(err)
+		}
+		f, err := agent.NewFreezer(sess)
+		if err != nil {
+			return errResult(err)
+		}
+		rec, err := f.Freeze(ctx, input.Name, agent.CurrentActor("mcp", input.Reason))
+		if err != nil {
+			return errResult(err)
+		}
+		return textResult(formatFreezeRecord(rec))
+	})
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "agent_unfreeze",
+		Description: "Undo agent_freeze: restore an agent's replicas, remove its deny-all policy and resume ArgoCD sync",
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, input agentUnfreezeInput) (*mcp.CallToolResult, any, error) {
+		sess, r, sv, e := loadSession(input.Cluster)
+		if sess == nil {
+			return r, sv, e
+		}
+		f, err := agent.NewFreezer(sess)
+		if err != nil {
+			return errResult(err)
+		}
+		rec, err := f.Unfreeze(ctx, input.Name, agent.CurrentActor("mcp", ""))
+		if err != nil {
+			return errResult(err)
+		}
+		return textResult(formatFreezeRecord(rec))
+	})
+}
+
+// tailLines returns the last n lines of s.
+func tailLines(s string, n int) string {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "")
+}
+
+// formatFreezeRecord describes the outcome of a freeze or unfreeze.
+func formatFreezeRecord(rec *agent.FreezeRecord) string {
+	state, workloads, sync := "frozen", "Stopped", "paused"
+	if rec.Action == agent.ActionUnfreeze {
+		state, workloads, sync = "unfrozen", "Restored", "resumed"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Agent %q %s by %s.\n", rec.Agent, state, rec.By)
+	if len(rec.Workloads) > 0 {
+		fmt.Fprintf(&sb, "%s: %s\n", workloads, strings.Join(rec.Workloads, ", "))
+	}
+	if rec.SyncPaused {
+		fmt.Fprintf(&sb, "ArgoCD sync %s.\n", sync)
+	} else {
+		sb.WriteString("No ArgoCD application found; sync unchanged.\n")
+	}
+	return sb.String()
+}
+
+// writeAgentUsage appends the live quota usage of an agent namespace,
+// flagging entries near or at their limit.
+func writeAgentUsage(ctx context.Context, sb *strings.Builder, clusterName, namespace string) {
+	r, err := agent.NewUsageReader(clusterName)
+	var u *agent.Usage
+	if err == nil {
+		u, err = r.Read(ctx, namespace)
+	}
+	if err != nil {
+		fmt.Fprintf(sb, "Quota usage: unavailable (%v)\n", err)
+		return
+	}
+	sb.WriteString("Quota usage:\n")
+	for _, q := range u.Quota {
+		fmt.Fprintf(sb, "  %s: %s", q.Resource, q)
+		switch {
+		case q.Percent >= 100:
+			sb.WriteString(" AT QUOTA, new pods are rejected")
+		case q.Percent >= agent.QuotaWarnPercent:
+			sb.WriteString(" near quota")
+		}
+		sb.WriteString("\n")
+	}
+	if u.CPU != "" {
+		fmt.Fprintf(sb, "Live usage: %s CPU, %s memory\n", u.CPU, u.Memory)
+	}
+}
+
+// revokeAgentKey revokes the LiteLLM key of an agent whose deletion is
+// committed and returns a line describing the outcome.
+func revokeAgentKey(ctx context.Context, sess *session.Session, name string) string {
+	gw, err := agent.ConnectGateway(ctx, sess.GitOpsPath, sess.ClusterName)
+	if err != nil {
+		return fmt.Sprintf("\nLLM key warning: %v", err)
+	}
+	if gw == nil {
+		return ""
+	}
+	revoked, err := gw.RevokeKey(ctx, name)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("\nLLM key warning: %v", err)
+	case revoked:
+		return "\nLLM key revoked."
+	}
+	return ""
 }
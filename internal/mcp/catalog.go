@@ -14,16 +14,41 @@ type catalogListInput struct {
 	Cluster string `json:"cluster" jsonschema:"Name of the cluster"`
 }
 
-type catalogToggleInput struct {
+type catalogEnableInput struct {
+	Cluster        string `json:"cluster" jsonschema:"Name of the cluster"`
+	Name           string `json:"name" jsonschema:"Name of the catalog app"`
+	IgnoreCapacity bool   `json:"ignore_capacity,omitempty" jsonschema:"Enable even if the app and its dependencies need more CPU or memory than Docker provides"`
+}
+
+type catalogDisableInput struct {
 	Cluster string `json:"cluster" jsonschema:"Name of the cluster"`
 	Name    string `json:"name" jsonschema:"Name of the catalog app"`
+	Cascade bool   `json:"cascade,omitempty" jsonschema:"Also disable every enabled app that depends on this one, directly or transitively"`
+}
+
+type catalogValuesShowInput struct {
+	Cluster string `json:"cluster" jsonschema:"Name of the cluster"`
+	Name    string `json:"name" jsonschema:"Name of the catalog app"`
+}
+
+type catalogValuesSetInput struct {
+	Cluster    string   `json:"cluster" jsonschema:"Name of the cluster"`
+	Name       string   `json:"name" jsonschema:"Name of the catalog app"`
+	Set        []string `json:"set,omitempty" jsonschema:"Values to set as dotted-path assignments, e.g. replicaCount=2 or ingress.enabled=true"`
+	Unset      []string `json:"unset,omitempty" jsonschema:"Dotted paths of values to remove"`
+	SkipSchema bool     `json:"skip_schema,omitempty" jsonschema:"Skip validation against the chart's values.schema.json (avoids fetching the chart)"`
 }
 
-type profileListInput struct{}
+type profileListInput struct {
+	Cluster string `json:"cluster,omitempty" jsonschema:"Optional cluster name; includes profiles from that cluster's gitops repo"`
+}
 
 type profileApplyInput struct {
-	Cluster string `json:"cluster" jsonschema:"Name of the cluster"`
-	Name    string `json:"name" jsonschema:"Profile name, e.g. agent-dev or agent-safe"`
+	Cluster        string `json:"cluster" jsonschema:"Name of the cluster"`
+	Name           string `json:"name" jsonschema:"Profile name, e.g. agent-dev or agent-safe"`
+	Exact          bool   `json:"exact,omitempty" jsonschema:"Also disable enabled apps the profile does not need, converging the catalog on the profile"`
+	DryRun         bool   `json:"dry_run,omitempty" jsonschema:"Return the plan of apps to enable and disable without changing anything"`
+	IgnoreCapacity bool   `json:"ignore_capacity,omitempty" jsonschema:"Apply even if the profile's apps need more CPU or memory than Docker provides"`
 }
 
 func registerCatalogTools(s *mcp.Server, deps *Deps) {
@@ -49,6 +74,9 @@ func registerCatalogTools(s *mcp.Server, deps *Deps) {
 			if e.Enabled {
 				status = "enabled"
 			}
+			if len(e.AutoEnabledBy) > 0 && e.Enabled {
+				status += " (auto: " + strings.Join(e.AutoEnabledBy, ", ") + ")"
+			}
 			fmt.Fprintf(&sb, "  - %-20s [%-14s] %s — %s\n",
 				e.Name, e.Category, status, e.Description)
 		}
@@ -58,40 +86,88 @@ func registerCatalogTools(s *mcp.Server, deps *Deps) {
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "catalog_enable",
 		Description: "Enable a catalog app and trigger ArgoCD sync",
-	}, func(ctx context.Context, _ *mcp.CallToolRequest, input catalogToggleInput) (*mcp.CallToolResult, any, error) {
-		return catalogToggle(ctx, deps, input.Cluster, input.Name, true)
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, input catalogEnableInput) (*mcp.CallToolResult, any, error) {
+		return catalogToggle(ctx, deps, input.Cluster, input.Name, true, catalog.DisableSafe, input.IgnoreCapacity)
 	})
 
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "catalog_disable",
-		Description: "Disable a catalog app and trigger ArgoCD sync",
-	}, func(ctx context.Context, _ *mcp.CallToolRequest, input catalogToggleInput) (*mcp.CallToolResult, any, error) {
-		return catalogToggle(ctx, deps, input.Cluster, input.Name, false)
+		Description: "Disable a catalog app and trigger ArgoCD sync. Fails if enabled apps depend on it unless cascade is set",
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, input catalogDisableInput) (*mcp.CallToolResult, any, error) {
+		mode := catalog.DisableSafe
+		if input.Cascade {
+			mode = catalog.DisableCascade
+		}
+		return catalogToggle(ctx, deps, input.Cluster, input.Name, false, mode, false)
+	})
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "catalog_values_show",
+		Description: "Show the Helm values overrides of a catalog app (catalog/values/<name>.yaml)",
+	}, func(_ context.Context, _ *mcp.CallToolRequest, input catalogValuesShowInput) (*mcp.CallToolResult, any, error) {
+		sess, r, sv, e := loadSession(input.Cluster)
+		if sess == nil {
+			return r, sv, e
+		}
+		data, err := catalog.ReadValues(sess.GitOpsPath, input.Name)
+		if err != nil {
+			return errResult(err)
+		}
+		if len(strings.TrimSpace(string(data))) == 0 {
+			return textResult(fmt.Sprintf("%s has no values overrides.", input.Name))
+		}
+		return textResult(string(data))
+	})
+
+	mcp.AddTool(s, &mcp.Tool{
+		Name:        "catalog_values_set",
+		Description: "Set or remove Helm values of a catalog app by dotted path, validate against the chart schema, commit, and trigger ArgoCD sync",
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, input catalogValuesSetInput) (*mcp.CallToolResult, any, error) {
+		sess, r, sv, e := loadSession(input.Cluster)
+		if sess == nil {
+			return r, sv, e
+		}
+		result, err := setCatalogValues(deps, sess.GitOpsPath, input)
+		if err != nil {
+			return errResult(err)
+		}
+		return textResult(appendSyncStatus(ctx, deps, sess, result, "catalog"))
 	})
 
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "profile_list",
 		Description: "List available cluster profiles with their descriptions and included apps",
-	}, func(_ context.Context, _ *mcp.CallToolRequest, _ profileListInput) (*mcp.CallToolResult, any, error) {
-		profiles := profile.List()
+	}, func(_ context.Context, _ *mcp.CallToolRequest, input profileListInput) (*mcp.CallToolResult, any, error) {
+		var gitOpsPath string
+		if input.Cluster != "" {
+			sess, r, sv, e := loadSession(input.Cluster)
+			if sess == nil {
+				return r, sv, e
+			}
+			gitOpsPath = sess.GitOpsPath
+		}
+		profiles, err := profile.List(gitOpsPath)
+		if err != nil {
+			return errResult(fmt.Errorf("loading profiles: %w", err))
+		}
 		var sb strings.Builder
 		sb.WriteString("Available profiles:\n")
 		for _, p := range profiles {
-			fmt.Fprintf(&sb, "  %s — %s\n    Apps: %s\n",
-				p.Name, p.Description, strings.Join(p.Apps, ", "))
+			fmt.Fprintf(&sb, "  %s (%s) — %s\n    Apps: %s\n",
+				p.Name, p.Source, p.Description, strings.Join(p.Apps, ", "))
 		}
 		return textResult(sb.String())
 	})
 
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "profile_apply",
-		Description: "Apply a profile to a cluster (enables its catalog apps) and trigger ArgoCD sync",
+		Description: "Apply a profile to a cluster (enables its catalog apps, or converges the catalog with exact=true) and trigger ArgoCD sync",
 	}, func(ctx context.Context, _ *mcp.CallToolRequest, input profileApplyInput) (*mcp.CallToolResult, any, error) {
 		sess, r, sv, e := loadSession(input.Cluster)
 		if sess == nil {
 			return r, sv, e
 		}
-		result, err := applyProfileToCluster(ctx, deps, sess, input.Name)
+		result, err := applyProfileToCluster(ctx, deps, sess, input.Name, input.Exact, input.DryRun, input.IgnoreCapacity)
 		if err != nil {
 			return errResult(err)
 		}
@@ -99,7 +175,9 @@ func registerCatalogTools(s *mcp.Server, deps *Deps) {
 	})
 }
 
-func catalogToggle(ctx context.Context, deps *Deps, clusterName, appName string, enable bool) (*mcp.CallToolResult, any, error) {
+// catalogToggle enables or disables a catalog app. Enabling runs the
+// capacity preflight first, which ignoreCapacity bypasses.
+func catalogToggle(ctx context.Context, deps *Deps, clusterName, appName string, enable bool, mode catalog.DisableMode, ignoreCapacity bool) (*mcp.CallToolResult, any, error) {
 	past := "enabled"
 	if !enable {
 		past = "disabled"
@@ -110,8 +188,17 @@ func catalogToggle(ctx context.Context, deps *Deps, clusterName, appName string,
 		return r, sv, e
 	}
 
-	// MCP has no --force equivalent — agents must disable dependents explicitly.
-	result, err := catalog.ToggleWithDeps(sess.GitOpsPath, appName, enable, false)
+	var notes string
+	if enable {
+		var err error
+		if notes, err = checkCapacity(ctx, sess.GitOpsPath, []string{appName}, nil, ignoreCapacity); err != nil {
+			return errResult(err)
+		}
+	}
+
+	// MCP has no --force equivalent — agents must disable dependents explicitly
+	// or ask for a cascade.
+	result, err := catalog.ToggleWithDeps(sess.GitOpsPath, appName, enable, mode)
 	if err != nil {
 		return errResult(err)
 	}
@@ -123,5 +210,36 @@ func catalogToggle(ctx context.Context, deps *Deps, clusterName, appName string,
 	if len(result.AutoDeps) > 0 {
 		msg += fmt.Sprintf(" Auto-enabled dependencies: %s.", strings.Join(result.AutoDeps, ", "))
 	}
+	if len(result.Cascaded) > 0 {
+		msg += fmt.Sprintf(" Cascade-disabled dependents: %s.", strings.Join(result.Cascaded, ", "))
+	}
+	if notes != "" {
+		msg += "\n" + notes
+	}
 	return textResult(appendSyncStatus(ctx, deps, sess, msg, "catalog"))
 }
+
+// setCatalogValues applies the set/unset edits of input to the app's values
+// file and commits the result.
+func setCatalogValues(deps *Deps, gitOpsPath string, input catalogValuesSetInput) (string, error) {
+	if len(input.Set) == 0 && len(input.Unset) == 0 {
+		return "", fmt.Errorf("nothing to change: provide set and/or unset")
+	}
+	set := make(map[string]string, len(input.Set))
+	for _, a := range input.Set {
+		key, value, err := catalog.ParseSet(a)
+		if err != nil {
+			return "", err
+		}
+		set[key] = value
+	}
+
+	updated, changed, err := catalog.EditAndSaveValues(deps.Logger, gitOpsPath, input.Name, set, input.Unset, input.SkipSchema)
+	if err != nil {
+		return "", err
+	}
+	if !changed {
+		return fmt.Sprintf("%s values unchanged.", input.Name), nil
+	}
+	return fmt.Sprintf("%s values updated and committed to gitops repo.\n%s", input.Name, updated), nil
+}
@@ -7,8 +7,12 @@ import (
 
 	"github.com/alicanalbayrak/sikifanso/internal/cluster"
 	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
+	"github.com/alicanalbayrak/sikifanso/internal/kube"
+	"github.com/alicanalbayrak/sikifanso/internal/profile"
 	"github.com/alicanalbayrak/sikifanso/internal/session"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type clusterListInput struct{}
@@ -18,9 +22,15 @@ type clusterInfoInput struct {
 }
 
 type clusterCreateInput struct {
-	Name         string `json:"name" jsonschema:"Name for the new cluster"`
-	Profile      string `json:"profile,omitempty" jsonschema:"Profile to apply after creation, e.g. agent-dev or agent-safe"`
-	BootstrapURL string `json:"bootstrap_url,omitempty" jsonschema:"Bootstrap template repo URL"`
+	Name           string   `json:"name" jsonschema:"Name for the new cluster"`
+	Profile        string   `json:"profile,omitempty" jsonschema:"Profile to apply after creation, e.g. agent-dev or agent-safe"`
+	BootstrapURL   string   `json:"bootstrap_url,omitempty" jsonschema:"Bootstrap template repo URL"`
+	Servers        *int     `json:"servers,omitempty" jsonschema:"Number of k3d server nodes (default: from infra/platform.yaml)"`
+	Agents         *int     `json:"agents,omitempty" jsonschema:"Number of general-purpose k3d agent nodes (default: from infra/platform.yaml)"`
+	AgentPools     []string `json:"agent_pools,omitempty" jsonschema:"Labelled and tainted agent node pools as NAME=COUNT, e.g. sandbox=2 to isolate agent sandboxes"`
+	Registry       bool     `json:"registry,omitempty" jsonschema:"Create a local image registry; pods pull registry.local/<name>:<tag> images from it"`
+	Bundle         string   `json:"bundle,omitempty" jsonschema:"Path to an offline bundle from sikifanso bundle create; the cluster is then created without network access and bootstrap_url is ignored"`
+	IgnoreCapacity bool     `json:"ignore_capacity,omitempty" jsonschema:"Create the cluster even if the profile's apps need more CPU or memory than Docker provides"`
 }
 
 type clusterDeleteInput struct {
@@ -61,7 +71,7 @@ func registerClusterTools(s *mcp.Server, deps *Deps) {
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "cluster_info",
 		Description: "Get detailed information about a cluster (state, services, k3d config)",
-	}, func(_ context.Context, _ *mcp.CallToolRequest, input clusterInfoInput) (*mcp.CallToolResult, any, error) {
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, input clusterInfoInput) (*mcp.CallToolResult, any, error) {
 		sess, r, s, e := loadSession(input.Cluster)
 		if sess == nil {
 			return r, s, e
@@ -73,8 +83,17 @@ func registerClusterTools(s *mcp.Server, deps *Deps) {
 		fmt.Fprintf(&sb, "GitOps Path: %s\n", sess.GitOpsPath)
 		fmt.Fprintf(&sb, "Bootstrap: %s @ %s\n", sess.BootstrapURL, sess.BootstrapVersion)
 		fmt.Fprintf(&sb, "K3d Image: %s\n", sess.K3dConfig.Image)
-		fmt.Fprintf(&sb, "K3d Servers: %d, Agents: %d\n", sess.K3dConfig.Servers, sess.K3dConfig.Agents)
+		fmt.Fprintf(&sb, "K3d Nodes: %s\n", sess.K3dConfig.Topology())
 		fmt.Fprintf(&sb, "ArgoCD URL: %s\n", sess.Services.ArgoCD.URL)
+		if sess.Registry != nil {
+			fmt.Fprintf(&sb, "Registry: push to %s, pull as %s/<image>\n", sess.Registry.PushHost(), session.RegistryHost)
+		}
+		if sess.Bundle != nil {
+			fmt.Fprintf(&sb, "Offline Bundle: %s (charts served from %s)\n", sess.Bundle.File, sess.Bundle.ChartRepo)
+		}
+		if sess.State == "running" {
+			writePoolStatus(ctx, &sb, sess.ClusterName)
+		}
 		return textResult(sb.String())
 	})
 
@@ -91,9 +110,35 @@ func registerClusterTools(s *mcp.Server, deps *Deps) {
 			bootstrapURL = gitops.DefaultBootstrapURL
 		}
 
-		sess, err := cluster.Create(ctx, deps.Logger, input.Name, cluster.Options{
+		opts := cluster.Options{
 			BootstrapURL: bootstrapURL,
-		})
+			Servers:      input.Servers,
+			Agents:       input.Agents,
+			Registry:     input.Registry,
+			Bundle:       input.Bundle,
+		}
+		for _, spec := range input.AgentPools {
+			pool, err := infraconfig.ParseNodePool(spec)
+			if err != nil {
+				return errResult(err)
+			}
+			opts.AgentPools = append(opts.AgentPools, pool)
+		}
+
+		// The profile is resolved and its capacity checked once the bootstrap
+		// repo is cloned, before any container is created.
+		if input.Profile != "" {
+			opts.Preflight = func(gitOpsPath string) error {
+				apps, err := profile.Resolve(gitOpsPath, input.Profile)
+				if err != nil {
+					return fmt.Errorf("resolving profile %q: %w", input.Profile, err)
+				}
+				_, err = checkCapacity(ctx, gitOpsPath, apps, nil, input.IgnoreCapacity)
+				return err
+			}
+		}
+
+		sess, err := cluster.Create(ctx, deps.Logger, input.Name, opts)
 		if err != nil {
 			return errResult(fmt.Errorf("creating cluster: %w", err))
 		}
@@ -102,7 +147,7 @@ func registerClusterTools(s *mcp.Server, deps *Deps) {
 			sess.ClusterName, sess.Services.ArgoCD.URL, sess.GitOpsPath)
 
 		if input.Profile != "" {
-			profileResult, profileErr := applyProfileToCluster(ctx, deps, sess, input.Profile)
+			profileResult, profileErr := applyProfileToCluster(ctx, deps, sess, input.Profile, false, false, input.IgnoreCapacity)
 			if profileErr != nil {
 				return errResult(profileErr)
 			}
@@ -148,3 +193,20 @@ func registerClusterTools(s *mcp.Server, deps *Deps) {
 		}
 	})
 }
+
+// writePoolStatus appends the readiness of each node pool to sb. It writes
+// nothing when the cluster cannot be reached.
+func writePoolStatus(ctx context.Context, sb *strings.Builder, clusterName string) {
+	cs, err := kube.ClientForCluster(clusterName)
+	if err != nil {
+		return
+	}
+	nodes, err := cs.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return
+	}
+	sb.WriteString("Node pools:\n")
+	for _, p := range kube.PoolStatuses(nodes.Items) {
+		fmt.Fprintf(sb, "  - %s: %d/%d ready\n", p.Name, p.Ready, p.Nodes)
+	}
+}
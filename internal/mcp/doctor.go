@@ -16,7 +16,8 @@ import (
 )
 
 type doctorInput struct {
-	Cluster string `json:"cluster" jsonschema:"Name of the cluster"`
+	Cluster     string `json:"cluster" jsonschema:"Name of the cluster"`
+	ProbePolicy bool   `json:"probe_policy,omitempty" jsonschema:"Also run the network policy probe from an agent sandbox, which starts a short-lived pod"`
 }
 
 type argocdAppsInput struct {
@@ -26,7 +27,7 @@ type argocdAppsInput struct {
 func registerDoctorTools(s *mcp.Server, _ *Deps) {
 	mcp.AddTool(s, &mcp.Tool{
 		Name:        "doctor",
-		Description: "Run health checks on the cluster (Docker, nodes, Cilium, ArgoCD, apps, agents)",
+		Description: "Run health checks on the cluster (Docker, host capacity, nodes, Cilium, ArgoCD, apps, agents)",
 	}, func(ctx context.Context, _ *mcp.CallToolRequest, input doctorInput) (*mcp.CallToolResult, any, error) {
 		checks := doctor.InfraChecks()
 
@@ -42,6 +43,8 @@ func registerDoctorTools(s *mcp.Server, _ *Deps) {
 			return textResult(formatDoctorResults(results))
 		}
 
+		checks = append(checks, doctor.CapacityCheck{GitOpsPath: sess.GitOpsPath})
+
 		// Build both typed and dynamic clients from a single REST config parse.
 		restCfg, err := kube.RESTConfigForCluster(input.Cluster)
 		if err != nil {
@@ -60,9 +63,12 @@ func registerDoctorTools(s *mcp.Server, _ *Deps) {
 			cfg = infraconfig.Defaults()
 		}
 
+		// A nil interface, not a nil *Clientset, skips the agent quota check.
+		var kc kubernetes.Interface
 		cs, err := kubernetes.NewForConfig(restCfg)
 		if err == nil {
-			checks = append(checks, doctor.ClusterChecks(cs, cfg)...)
+			checks = append(checks, doctor.ClusterChecks(cs, cfg, sess.K3dConfig.AgentPools)...)
+			kc = cs
 		}
 
 		dynClient, err := dynamic.NewForConfig(restCfg)
@@ -72,7 +78,10 @@ func registerDoctorTools(s *mcp.Server, _ *Deps) {
 				sess.Services.ArgoCD.Username,
 				sess.Services.ArgoCD.Password,
 			)
-			checks = append(checks, doctor.AppChecks(dynClient, sess.GitOpsPath, cfg, grpcClient)...)
+			checks = append(checks, doctor.AppChecks(dynClient, kc, sess.GitOpsPath, cfg, grpcClient)...)
+		}
+		if input.ProbePolicy {
+			checks = append(checks, doctor.AgentPolicyCheck{Client: kc, GitOpsPath: sess.GitOpsPath})
 		}
 
 		results := doctor.Run(ctx, checks)
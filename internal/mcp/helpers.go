@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/alicanalbayrak/sikifanso/internal/argocd/appsetreconcile"
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
 	"github.com/alicanalbayrak/sikifanso/internal/kube"
 	"github.com/alicanalbayrak/sikifanso/internal/preflight"
 	"github.com/alicanalbayrak/sikifanso/internal/profile"
@@ -67,27 +68,100 @@ func appendSyncStatus(ctx context.Context, deps *Deps, sess *session.Session, re
 }
 
 // applyProfileToCluster resolves and applies a profile, then triggers sync.
-func applyProfileToCluster(ctx context.Context, deps *Deps, sess *session.Session, profileName string) (string, error) {
-	apps, err := profile.Resolve(profileName)
+// With exact set, enabled apps outside the profile are disabled in the same
+// commit. With dryRun set, only the plan is returned.
+// Unless ignoreCapacity is set, it refuses a plan whose apps do not fit in
+// Docker's CPUs and memory.
+func applyProfileToCluster(ctx context.Context, deps *Deps, sess *session.Session, profileName string, exact, dryRun, ignoreCapacity bool) (string, error) {
+	apps, err := profile.Resolve(sess.GitOpsPath, profileName)
 	if err != nil {
 		return "", fmt.Errorf("resolving profile %q: %w", profileName, err)
 	}
 
-	var warnings []string
-	autoAdded, err := profile.Apply(sess.GitOpsPath, profileName, apps, func(msg string) {
-		warnings = append(warnings, msg)
-	})
+	plan, err := profile.NewPlan(sess.GitOpsPath, profileName, apps, exact)
 	if err != nil {
+		return "", fmt.Errorf("planning profile %q: %w", profileName, err)
+	}
+	summary := formatProfilePlan(plan)
+	if dryRun || plan.Empty() {
+		return summary, nil
+	}
+	notes, err := checkCapacity(ctx, sess.GitOpsPath, plan.Enable, plan.Disable, ignoreCapacity)
+	if err != nil {
+		return "", fmt.Errorf("profile %q not applied: %w", profileName, err)
+	}
+	if notes != "" {
+		summary += "\n" + notes
+	}
+
+	if err := profile.ApplyPlan(sess.GitOpsPath, plan); err != nil {
 		return "", fmt.Errorf("applying profile %q: %w", profileName, err)
 	}
 
-	result := fmt.Sprintf("Profile %q applied (%d apps enabled).", profileName, len(apps)+len(autoAdded))
-	if len(autoAdded) > 0 {
-		result += fmt.Sprintf("\n  Auto-enabled dependencies: %s", strings.Join(autoAdded, ", "))
+	result := fmt.Sprintf("Profile %q applied (%d apps enabled, %d disabled).\n%s",
+		profileName, len(plan.Enable), len(plan.Disable), summary)
+	return appendSyncStatus(ctx, deps, sess, result, "catalog"), nil
+}
+
+// checkCapacity runs the capacity preflight the CLI runs before enabling
+// apps. It returns notes for the tool result: the breakdown when the apps
+// are near or over what Docker provides, and the apps whose size is
+// unknown. It fails when they do not fit unless ignore is set.
+func checkCapacity(ctx context.Context, gitOpsPath string, enable, disable []string, ignore bool) (string, error) {
+	report, err := preflight.CheckCapacityAfter(ctx, gitOpsPath, enable, disable)
+	if err != nil || report == nil {
+		return "", err
 	}
-	for _, w := range warnings {
-		result += fmt.Sprintf("\n  Warning: %s", w)
+	notes := formatCapacity(report)
+	if err := report.Err(); err != nil && !ignore {
+		return "", fmt.Errorf("%w; disable apps, give Docker more resources, or set ignore_capacity\n%s", err, notes)
 	}
+	return notes, nil
+}
 
-	return appendSyncStatus(ctx, deps, sess, result, "catalog"), nil
+// formatCapacity renders the parts of a capacity report worth showing.
+func formatCapacity(r *preflight.CapacityReport) string {
+	var sb strings.Builder
+	if r.Status != preflight.CapacityOK {
+		fmt.Fprintf(&sb, "Capacity %s: %s", r.Status, r.Summary())
+		for _, a := range r.Apps.Apps {
+			name := a.Name
+			if a.Estimated {
+				name += " (estimate)"
+			}
+			fmt.Fprintf(&sb, "\n  - %s: CPU %s, memory %s", name, catalog.FormatCPU(a.CPUMillis), catalog.FormatBytes(a.MemoryBytes))
+		}
+	}
+	if len(r.Apps.Unknown) > 0 {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "No resource metadata (not counted): %s", strings.Join(r.Apps.Unknown, ", "))
+	}
+	return sb.String()
+}
+
+// formatProfilePlan renders a profile plan as the added/removed app lists.
+func formatProfilePlan(plan profile.Plan) string {
+	if plan.Empty() {
+		return fmt.Sprintf("Profile %q is already applied; nothing to change.", plan.Profile)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Plan for profile %q (exact=%v):", plan.Profile, plan.Exact)
+	if len(plan.Enable) > 0 {
+		fmt.Fprintf(&sb, "\n  Enable: %s", strings.Join(plan.Enable, ", "))
+	}
+	if len(plan.AutoAdded) > 0 {
+		fmt.Fprintf(&sb, "\n  Auto-enabled dependencies: %s", strings.Join(plan.AutoAdded, ", "))
+	}
+	if len(plan.Disable) > 0 {
+		fmt.Fprintf(&sb, "\n  Disable: %s", strings.Join(plan.Disable, ", "))
+	}
+	if len(plan.Promote) > 0 {
+		fmt.Fprintf(&sb, "\n  Mark explicitly enabled: %s", strings.Join(plan.Promote, ", "))
+	}
+	if len(plan.Reattribute) > 0 {
+		fmt.Fprintf(&sb, "\n  Update dependency provenance: %s", strings.Join(plan.Reattribute, ", "))
+	}
+	return sb.String()
 }
@@ -0,0 +1,137 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/hubble"
+	"github.com/alicanalbayrak/sikifanso/internal/kube"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultFlowLimit = 20
+	maxFlowLimit     = 500
+)
+
+type networkFlowsInput struct {
+	Cluster   string   `json:"cluster" jsonschema:"Name of the cluster"`
+	Agent     string   `json:"agent,omitempty" jsonschema:"Agent whose sandbox namespace to inspect; takes precedence over namespace"`
+	Namespace string   `json:"namespace,omitempty" jsonschema:"Only flows to or from pods in this namespace; omit for all"`
+	Verdicts  []string `json:"verdicts,omitempty" jsonschema:"Only flows with these verdicts: FORWARDED, DROPPED, ERROR, AUDIT"`
+	Since     string   `json:"since,omitempty" jsonschema:"Only flows from this long ago onward, e.g. 10m"`
+	Limit     int      `json:"limit,omitempty" jsonschema:"Number of recent flows to return, default 20"`
+}
+
+func registerNetworkTools(s *mcp.Server, _ *Deps) {
+	mcp.AddTool(s, &mcp.Tool{
+		Name: "network_flows",
+		Description: "Show recent network flows observed by Hubble for an agent sandbox or namespace. " +
+			"Dropped flows include the policy or reason that dropped them; use verdicts=[\"DROPPED\"] to explain why an agent's egress failed",
+	}, func(ctx context.Context, _ *mcp.CallToolRequest, input networkFlowsInput) (*mcp.CallToolResult, any, error) {
+		sess, r, sv, e := loadSession(input.Cluster)
+		if sess == nil {
+			return r, sv, e
+		}
+
+		namespace := input.Namespace
+		if input.Agent != "" {
+			info, err := agent.Find(sess.GitOpsPath, input.Agent)
+			if err != nil {
+				return errResult(err)
+			}
+			namespace = info.Namespace
+		}
+		limit := input.Limit
+		if limit <= 0 {
+			limit = defaultFlowLimit
+		}
+		limit = min(limit, maxFlowLimit)
+		req := hubble.Request{Namespace: namespace, Verdicts: input.Verdicts, Number: uint64(limit)}
+		if input.Since != "" {
+			d, err := time.ParseDuration(input.Since)
+			if err != nil {
+				return errResult(fmt.Errorf("invalid since %q: %w", input.Since, err))
+			}
+			req.Since = time.Now().Add(-d)
+		}
+
+		restCfg, err := kube.RESTConfigForCluster(sess.ClusterName)
+		if err != nil {
+			return errResult(fmt.Errorf("connecting to cluster %q: %w", sess.ClusterName, err))
+		}
+		cs, err := kubernetes.NewForConfig(restCfg)
+		if err != nil {
+			return errResult(fmt.Errorf("creating clientset: %w", err))
+		}
+		client, err := hubble.Connect(ctx, restCfg, cs)
+		if err != nil {
+			return errResult(err)
+		}
+		defer func() { _ = client.Close() }()
+
+		var flows []hubble.Flow
+		err = client.Flows(ctx, req, func(f hubble.Flow) error {
+			flows = append(flows, f)
+			return nil
+		})
+		if err != nil {
+			return errResult(err)
+		}
+		if len(flows) > limit {
+			flows = flows[len(flows)-limit:]
+		}
+		return textResult(formatFlows(namespace, flows))
+	})
+}
+
+// formatFlows renders flows one per line, followed by a summary of why
+// dropped flows were dropped.
+func formatFlows(namespace string, flows []hubble.Flow) string {
+	scope := "all namespaces"
+	if namespace != "" {
+		scope = "namespace " + namespace
+	}
+	if len(flows) == 0 {
+		return fmt.Sprintf("No flows found in %s.", scope)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Flows in %s:\n", scope)
+	drops := map[string][]string{}
+	var reasons []string
+	for _, f := range flows {
+		fmt.Fprintf(&sb, "  %s %s %s -> %s %s %s", f.Time.Format(time.RFC3339), f.Direction, f.Source, f.Destination, f.Protocol, f.Verdict)
+		if why := f.Explain(); why != "" {
+			fmt.Fprintf(&sb, " (%s)", why)
+			if _, seen := drops[why]; !seen {
+				reasons = append(reasons, why)
+			}
+			drops[why] = append(drops[why], f.Destination.String())
+		}
+		sb.WriteString("\n")
+	}
+	for _, why := range reasons {
+		fmt.Fprintf(&sb, "Dropped %d flow(s): %s. Destinations: %s\n", len(drops[why]), why, strings.Join(dedupe(drops[why]), ", "))
+	}
+	if len(reasons) > 0 && namespace != "" && strings.HasPrefix(namespace, "agent-") {
+		sb.WriteString("Egress from agent sandboxes is default deny; allow a destination with: sikifanso agent egress allow AGENT --fqdn HOST|--cidr RANGE|--app APP\n")
+	}
+	return sb.String()
+}
+
+func dedupe(s []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(s))
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/preflight"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"go.uber.org/zap"
 )
@@ -44,13 +46,14 @@ func TestNewServer_RegistersAllTools(t *testing.T) {
 	}
 
 	expected := []string{
-		"agent_create", "agent_delete", "agent_info", "agent_list",
+		"agent_create", "agent_delete", "agent_freeze", "agent_info", "agent_list", "agent_run", "agent_templates", "agent_unfreeze", "agent_update",
 		"argocd_app_detail", "argocd_app_diff", "argocd_apps", "argocd_rollback",
 		"argocd_project_detail", "argocd_projects_list",
-		"catalog_disable", "catalog_enable", "catalog_list",
+		"catalog_disable", "catalog_enable", "catalog_list", "catalog_values_set", "catalog_values_show",
 		"cluster_create", "cluster_delete", "cluster_info", "cluster_list", "cluster_start_stop",
 		"doctor",
 		"kube_events", "kube_logs", "kube_pods", "kube_services",
+		"network_flows",
 		"profile_apply", "profile_list",
 	}
 
@@ -164,3 +167,18 @@ func TestErrResult(t *testing.T) {
 		t.Errorf("text = %q, want %q", tc.Text, "something broke")
 	}
 }
+
+func TestFormatCapacity_ListsUnknownApps(t *testing.T) {
+	t.Parallel()
+	host := preflight.HostCapacity{CPUs: 64, MemoryBytes: 256 << 30}
+	r, err := preflight.EvaluateCapacity(host, []catalog.Entry{{Name: "opa"}, {Name: "my-app"}})
+	if err != nil {
+		t.Fatalf("EvaluateCapacity: %v", err)
+	}
+	if r.Status != preflight.CapacityOK {
+		t.Fatalf("Status = %s, want ok", r.Status)
+	}
+	if got, want := formatCapacity(r), "No resource metadata (not counted): my-app"; got != want {
+		t.Errorf("formatCapacity = %q, want %q", got, want)
+	}
+}
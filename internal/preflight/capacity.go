@@ -0,0 +1,151 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/docker/docker/client"
+)
+
+// HostCapacity is what the Docker daemon can give the cluster's nodes.
+type HostCapacity struct {
+	CPUs        int   `json:"cpus"`
+	MemoryBytes int64 `json:"memoryBytes"`
+}
+
+// PlatformFootprint approximates what k3s, Cilium, Hubble and ArgoCD request
+// before any catalog app is enabled.
+var PlatformFootprint = catalog.Footprint{CPUMillis: 1000, MemoryBytes: 2 << 30}
+
+// capacityWarnRatio is the share of host CPU or memory above which apps are
+// likely to contend for resources even though they still fit.
+const capacityWarnRatio = 0.8
+
+// ErrInsufficientCapacity is matched by the error CapacityReport.Err returns
+// for an app set that does not fit.
+var ErrInsufficientCapacity = errors.New("enabled apps would request more CPU or memory than Docker provides")
+
+// CapacityStatus classifies a CapacityReport.
+type CapacityStatus string
+
+// CapacityStatus values: OK fits comfortably, Warn fits above 80% of CPU or
+// memory, Exceeded does not fit.
+const (
+	CapacityOK       CapacityStatus = "ok"
+	CapacityWarn     CapacityStatus = "warn"
+	CapacityExceeded CapacityStatus = "exceeded"
+)
+
+// CapacityReport compares the footprint of a set of apps plus the platform
+// with the Docker host.
+type CapacityReport struct {
+	Status   CapacityStatus           `json:"status"`
+	Host     HostCapacity             `json:"host"`
+	Platform catalog.Footprint        `json:"platform"`
+	Apps     catalog.FootprintSummary `json:"apps"`
+	// Required is Platform plus Apps.Total.
+	Required catalog.Footprint `json:"required"`
+}
+
+// DockerCapacity reads the CPUs and memory available to the Docker daemon
+// (the Docker Desktop VM size on macOS and Windows).
+func DockerCapacity(ctx context.Context) (HostCapacity, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return HostCapacity{}, fmt.Errorf("creating docker client: %w", err)
+	}
+	defer func() { _ = cli.Close() }()
+
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return HostCapacity{}, fmt.Errorf("reading docker info: %w", err)
+	}
+	return HostCapacity{CPUs: info.NCPU, MemoryBytes: info.MemTotal}, nil
+}
+
+// EvaluateCapacity sums the footprint of the enabled entries and compares it
+// with host. Disk is reported but not checked since Docker does not expose
+// free space portably.
+func EvaluateCapacity(host HostCapacity, enabled []catalog.Entry) (*CapacityReport, error) {
+	apps, err := catalog.SumFootprint(enabled)
+	if err != nil {
+		return nil, err
+	}
+	r := &CapacityReport{
+		Host:     host,
+		Platform: PlatformFootprint,
+		Apps:     apps,
+		Required: PlatformFootprint.Add(apps.Total),
+	}
+	cpu, mem := r.Ratios()
+	switch {
+	case cpu > 1 || mem > 1:
+		r.Status = CapacityExceeded
+	case cpu > capacityWarnRatio || mem > capacityWarnRatio:
+		r.Status = CapacityWarn
+	default:
+		r.Status = CapacityOK
+	}
+	return r, nil
+}
+
+// CheckCapacity evaluates enabled against the local Docker daemon.
+func CheckCapacity(ctx context.Context, enabled []catalog.Entry) (*CapacityReport, error) {
+	host, err := DockerCapacity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return EvaluateCapacity(host, enabled)
+}
+
+// CheckCapacityAfter evaluates the apps enabled in the gitops repo at
+// gitOpsPath once enable, with their dependencies, are turned on and disable
+// turned off. The CLI and the MCP server run it before committing. The
+// report is nil when the Docker daemon cannot be reached or enable does not
+// resolve; the mutation itself reports the latter.
+func CheckCapacityAfter(ctx context.Context, gitOpsPath string, enable, disable []string) (*CapacityReport, error) {
+	all, err := catalog.List(gitOpsPath)
+	if err != nil {
+		return nil, fmt.Errorf("listing catalog: %w", err)
+	}
+	resolved, _, err := catalog.ResolveDeps(enable, all)
+	if err != nil {
+		return nil, nil
+	}
+	host, err := DockerCapacity(ctx)
+	if err != nil {
+		return nil, nil
+	}
+	return EvaluateCapacity(host, catalog.EnabledAfter(all, resolved, disable))
+}
+
+// Err returns an error wrapping ErrInsufficientCapacity when r is exceeded,
+// and nil otherwise or when r is nil.
+func (r *CapacityReport) Err() error {
+	if r == nil || r.Status != CapacityExceeded {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInsufficientCapacity, r.Summary())
+}
+
+// Ratios returns required CPU and memory as fractions of the host's.
+func (r CapacityReport) Ratios() (cpu, mem float64) {
+	if r.Host.CPUs > 0 {
+		cpu = float64(r.Required.CPUMillis) / float64(r.Host.CPUs*1000)
+	}
+	if r.Host.MemoryBytes > 0 {
+		mem = float64(r.Required.MemoryBytes) / float64(r.Host.MemoryBytes)
+	}
+	return cpu, mem
+}
+
+// Summary renders the totals on one line, e.g.
+// "CPU 9.5/8 cores (119%), memory 14.0Gi/15.6Gi (90%)".
+func (r CapacityReport) Summary() string {
+	cpu, mem := r.Ratios()
+	return fmt.Sprintf("CPU %s/%d cores (%.0f%%), memory %s/%s (%.0f%%)",
+		catalog.FormatCPU(r.Required.CPUMillis), r.Host.CPUs, cpu*100,
+		catalog.FormatBytes(r.Required.MemoryBytes), catalog.FormatBytes(r.Host.MemoryBytes), mem*100)
+}
@@ -0,0 +1,38 @@
+package preflight
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+)
+
+func TestEvaluateCapacity(t *testing.T) {
+	t.Parallel()
+	host := HostCapacity{CPUs: 4, MemoryBytes: 8 << 30}
+	tests := []struct {
+		name   string
+		memory string
+		want   CapacityStatus
+	}{
+		{"fits", "2Gi", CapacityOK},
+		{"near capacity", "5Gi", CapacityWarn},
+		{"exceeded", "7Gi", CapacityExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			entries := []catalog.Entry{{Name: "app", Resources: &catalog.Resources{CPU: "500m", Memory: tt.memory}}}
+			r, err := EvaluateCapacity(host, entries)
+			if err != nil {
+				t.Fatalf("EvaluateCapacity: %v", err)
+			}
+			if r.Status != tt.want {
+				t.Errorf("Status = %s, want %s (%s)", r.Status, tt.want, r.Summary())
+			}
+			if err := r.Err(); errors.Is(err, ErrInsufficientCapacity) != (tt.want == CapacityExceeded) {
+				t.Errorf("Err = %v for status %s", err, r.Status)
+			}
+		})
+	}
+}
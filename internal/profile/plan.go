@@ -0,0 +1,240 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
+)
+
+// Plan is the set of catalog changes needed to apply a profile. It is
+// computed without touching the gitops repo so callers can preview it.
+type Plan struct {
+	Profile string `json:"profile"`
+	Exact   bool   `json:"exact"`
+	// Enable lists apps to turn on, dependencies before dependents.
+	Enable []string `json:"enable"`
+	// AutoAdded is the subset of Enable pulled in only as a dependency.
+	AutoAdded []string `json:"autoAdded,omitempty"`
+	// Disable lists apps to turn off in reverse tier order (exact mode only).
+	Disable []string `json:"disable"`
+	// Promote lists requested apps that are already enabled but only as a
+	// dependency; applying the plan marks them as explicitly enabled.
+	Promote []string `json:"promote,omitempty"`
+	// Unchanged lists desired apps that are already enabled.
+	Unchanged []string `json:"unchanged"`
+	// Reattribute lists dependencies that are already enabled whose
+	// autoEnabledBy record changes: requested apps that now need them are
+	// added and, in exact mode, apps being disabled are dropped.
+	Reattribute []string `json:"reattribute,omitempty"`
+
+	// autoBy maps each AutoAdded and Reattribute app to the apps that need
+	// it, as recorded in autoEnabledBy.
+	autoBy map[string][]string
+	// apps is the requested app list, kept so ApplyPlan can recompute the
+	// plan under the gitops lock.
+	apps []string
+}
+
+// ErrPlanChanged is returned (wrapped) by ApplyPlan when the catalog changed
+// after the plan was computed.
+var ErrPlanChanged = errors.New("catalog changed since the plan was shown")
+
+// Empty reports whether applying the plan would change nothing.
+func (p Plan) Empty() bool {
+	return len(p.Enable) == 0 && len(p.Disable) == 0 && len(p.Promote) == 0 && len(p.Reattribute) == 0
+}
+
+// RequiredBy returns the apps the plan records as needing the dependency
+// app, for AutoAdded and Reattribute apps.
+func (p Plan) RequiredBy(app string) []string {
+	return p.autoBy[app]
+}
+
+// NewPlan computes the changes needed to apply apps to the catalog at
+// gitOpsPath. The desired set is apps plus their transitive dependencies.
+//
+// When exact is false, the plan only enables. When exact is true, every
+// enabled catalog app outside the desired set is disabled as well, so the
+// catalog converges on the profile.
+func NewPlan(gitOpsPath, profileName string, apps []string, exact bool) (Plan, error) {
+	all, err := catalog.List(gitOpsPath)
+	if err != nil {
+		return Plan{}, fmt.Errorf("listing catalog: %w", err)
+	}
+	if err := validateApps(profileName, apps, all); err != nil {
+		return Plan{}, err
+	}
+
+	plan := Plan{Profile: profileName, Exact: exact, apps: slices.Clone(apps)}
+
+	var resolved []string
+	if len(apps) > 0 {
+		resolved, _, err = catalog.ResolveDeps(apps, all)
+		if err != nil {
+			return Plan{}, fmt.Errorf("resolving dependencies: %w", err)
+		}
+	}
+
+	requested := make(map[string]bool, len(apps))
+	for _, a := range apps {
+		requested[a] = true
+	}
+	desired := make(map[string]bool, len(resolved))
+	for _, a := range resolved {
+		desired[a] = true
+	}
+	byName := make(map[string]catalog.Entry, len(all))
+	for _, e := range all {
+		byName[e.Name] = e
+	}
+
+	for _, app := range resolved {
+		e := byName[app]
+		switch {
+		case e.Enabled && requested[app] && e.Provenance() == catalog.ProvenanceAuto:
+			plan.Promote = append(plan.Promote, app)
+		case e.Enabled:
+			plan.Unchanged = append(plan.Unchanged, app)
+		default:
+			plan.Enable = append(plan.Enable, app)
+			if !requested[app] {
+				plan.AutoAdded = append(plan.AutoAdded, app)
+			}
+		}
+	}
+
+	disabled := map[string]bool{}
+	if exact {
+		var disable []catalog.Entry
+		for _, e := range all {
+			if e.Enabled && !desired[e.Name] {
+				disable = append(disable, e)
+				disabled[e.Name] = true
+			}
+		}
+		plan.Disable = catalog.TeardownOrder(disable)
+	}
+
+	// Dependencies enabled earlier keep their provenance current: apps
+	// requested now are added, apps this plan disables are dropped.
+	var autoDeps []string
+	for _, app := range resolved {
+		if e := byName[app]; !requested[app] && e.Enabled && e.Provenance() == catalog.ProvenanceAuto {
+			autoDeps = append(autoDeps, app)
+		}
+	}
+	if len(plan.AutoAdded) == 0 && len(autoDeps) == 0 {
+		return plan, nil
+	}
+	plan.autoBy, err = requiredBy(apps, append(slices.Clone(plan.AutoAdded), autoDeps...), all)
+	if err != nil {
+		return Plan{}, err
+	}
+	for _, app := range autoDeps {
+		current := byName[app].AutoEnabledBy
+		by := slices.DeleteFunc(slices.Clone(current), func(a string) bool { return disabled[a] })
+		for _, r := range plan.autoBy[app] {
+			if !slices.Contains(by, r) {
+				by = append(by, r)
+			}
+		}
+		if slices.Equal(by, current) {
+			delete(plan.autoBy, app)
+			continue
+		}
+		plan.autoBy[app] = by
+		plan.Reattribute = append(plan.Reattribute, app)
+	}
+	return plan, nil
+}
+
+// ApplyPlan writes every change in plan to the catalog and commits them
+// together. The plan is recomputed under the gitops lock first; if the
+// catalog changed since plan was computed, nothing is written and an error
+// wrapping ErrPlanChanged is returned. An empty plan is a no-op.
+func ApplyPlan(gitOpsPath string, plan Plan) error {
+	if plan.Empty() {
+		return nil
+	}
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	current, err := NewPlan(gitOpsPath, plan.Profile, plan.apps, plan.Exact)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(current, plan) {
+		return fmt.Errorf("%w, re-run", ErrPlanChanged)
+	}
+
+	commitPaths := make([]string, 0, len(plan.Enable)+len(plan.Disable))
+	for _, app := range plan.Disable {
+		if err := catalog.SetEnabled(gitOpsPath, app, false); err != nil {
+			return fmt.Errorf("disabling %s: %w", app, err)
+		}
+		commitPaths = append(commitPaths, fmt.Sprintf("catalog/%s.yaml", app))
+	}
+	for _, app := range plan.Enable {
+		if err := catalog.SetEnabled(gitOpsPath, app, true); err != nil {
+			return fmt.Errorf("enabling %s: %w", app, err)
+		}
+		if by := plan.autoBy[app]; len(by) > 0 {
+			if err := catalog.SetAutoEnabledBy(gitOpsPath, app, by); err != nil {
+				return fmt.Errorf("recording provenance for %s: %w", app, err)
+			}
+		}
+		commitPaths = append(commitPaths, fmt.Sprintf("catalog/%s.yaml", app))
+	}
+	for _, app := range plan.Promote {
+		if err := catalog.SetAutoEnabledBy(gitOpsPath, app, nil); err != nil {
+			return fmt.Errorf("marking %s explicit: %w", app, err)
+		}
+		commitPaths = append(commitPaths, fmt.Sprintf("catalog/%s.yaml", app))
+	}
+	for _, app := range plan.Reattribute {
+		if err := catalog.SetAutoEnabledBy(gitOpsPath, app, plan.autoBy[app]); err != nil {
+			return fmt.Errorf("recording provenance for %s: %w", app, err)
+		}
+		commitPaths = append(commitPaths, fmt.Sprintf("catalog/%s.yaml", app))
+	}
+
+	msg := fmt.Sprintf("profile: enable %s apps", plan.Profile)
+	if plan.Exact {
+		msg = fmt.Sprintf("profile: converge to %s", plan.Profile)
+		if len(plan.Disable) > 0 {
+			msg += fmt.Sprintf(" (disable: %s)", strings.Join(plan.Disable, ", "))
+		}
+	}
+	return gitops.Commit(gitOpsPath, msg, commitPaths...)
+}
+
+// requiredBy maps each of deps to the requested apps whose dependency
+// closure contains it, so auto-enabled apps can record who pulled them in.
+func requiredBy(requested, deps []string, all []catalog.Entry) (map[string][]string, error) {
+	want := make(map[string]bool, len(deps))
+	for _, d := range deps {
+		want[d] = true
+	}
+	by := make(map[string][]string, len(deps))
+	for _, r := range requested {
+		closure, _, err := catalog.ResolveDeps([]string{r}, all)
+		if err != nil {
+			return nil, fmt.Errorf("resolving dependencies of %s: %w", r, err)
+		}
+		for _, d := range closure {
+			if d != r && want[d] {
+				by[d] = append(by[d], r)
+			}
+		}
+	}
+	return by, nil
+}
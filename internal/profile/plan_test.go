@@ -0,0 +1,261 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+)
+
+// writeCatalog writes the given entries into <dir>/catalog and initialises a git repo.
+func writeCatalog(t *testing.T, dir string, entries []catalog.Entry) {
+	t.Helper()
+	catalogDir := filepath.Join(dir, "catalog")
+	if err := os.MkdirAll(catalogDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		e.Category, e.Description, e.RepoURL = "test", "test entry", "https://example.com"
+		e.Chart, e.TargetRevision, e.Namespace = e.Name, "1.0.0", "test"
+		data, err := yaml.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(catalogDir, e.Name+".yaml"), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	initGitRepo(t, dir)
+}
+
+func convergeCatalog() []catalog.Entry {
+	return []catalog.Entry{
+		{Name: "cnpg-operator", Tier: "0-operators", Enabled: true},
+		{Name: "postgresql", Tier: "1-data", Enabled: true, DependsOn: []string{"cnpg-operator"}},
+		{Name: "langfuse", Tier: "2-services", DependsOn: []string{"postgresql"}},
+		{Name: "ollama", Tier: "2-services", Enabled: true},
+		{Name: "qdrant", Tier: "1-data", Enabled: true},
+	}
+}
+
+func TestNewPlan_AdditiveNeverDisables(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeCatalog(t, dir, convergeCatalog())
+
+	plan, err := NewPlan(dir, "test", []string{"langfuse"}, false)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	if !slices.Equal(plan.Enable, []string{"langfuse"}) {
+		t.Errorf("Enable = %v, want [langfuse]", plan.Enable)
+	}
+	if len(plan.Disable) != 0 {
+		t.Errorf("Disable = %v, want empty", plan.Disable)
+	}
+	if !slices.Equal(plan.Unchanged, []string{"cnpg-operator", "postgresql"}) {
+		t.Errorf("Unchanged = %v, want [cnpg-operator postgresql]", plan.Unchanged)
+	}
+}
+
+func TestNewPlan_ExactDisablesOthersInReverseTierOrder(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeCatalog(t, dir, convergeCatalog())
+
+	plan, err := NewPlan(dir, "test", []string{"langfuse"}, true)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	// ollama (2-services) must go before qdrant (1-data).
+	if !slices.Equal(plan.Disable, []string{"ollama", "qdrant"}) {
+		t.Errorf("Disable = %v, want [ollama qdrant]", plan.Disable)
+	}
+	// Dependencies of the profile stay enabled.
+	for _, keep := range []string{"cnpg-operator", "postgresql"} {
+		if slices.Contains(plan.Disable, keep) {
+			t.Errorf("%s is a dependency of langfuse and must not be disabled", keep)
+		}
+	}
+}
+
+func TestApplyPlan_ExactSingleCommit(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeCatalog(t, dir, convergeCatalog())
+
+	plan, err := NewPlan(dir, "team", []string{"langfuse"}, true)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	if err := ApplyPlan(dir, plan); err != nil {
+		t.Fatalf("ApplyPlan: %v", err)
+	}
+
+	want := map[string]bool{"cnpg-operator": true, "postgresql": true, "langfuse": true, "ollama": false, "qdrant": false}
+	for name, enabled := range want {
+		e, err := catalog.Find(dir, name)
+		if err != nil {
+			t.Fatalf("Find(%s): %v", name, err)
+		}
+		if e.Enabled != enabled {
+			t.Errorf("%s enabled = %v, want %v", name, e.Enabled, enabled)
+		}
+	}
+
+	out, err := exec.Command("git", "-C", dir, "log", "--format=%s").Output()
+	if err != nil {
+		t.Fatalf("git log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d commits, want 2 (init + converge): %v", len(lines), lines)
+	}
+	if !strings.HasPrefix(lines[0], "profile: converge to team") {
+		t.Errorf("commit message = %q", lines[0])
+	}
+}
+
+func TestApplyPlan_RejectsStalePlan(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeCatalog(t, dir, convergeCatalog())
+
+	plan, err := NewPlan(dir, "team", []string{"langfuse"}, true)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	// Another writer disables an app the plan expects to leave alone.
+	if err := catalog.SetEnabled(dir, "postgresql", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApplyPlan(dir, plan); !errors.Is(err, ErrPlanChanged) {
+		t.Fatalf("ApplyPlan = %v, want ErrPlanChanged", err)
+	}
+	for _, name := range []string{"ollama", "qdrant"} {
+		if e, _ := catalog.Find(dir, name); !e.Enabled {
+			t.Errorf("%s disabled by a stale plan", name)
+		}
+	}
+}
+
+func TestApplyPlan_EmptyPlanNoCommit(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeCatalog(t, dir, convergeCatalog())
+
+	plan, err := NewPlan(dir, "test", []string{"cnpg-operator", "postgresql", "ollama", "qdrant"}, true)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	if !plan.Empty() {
+		t.Fatalf("plan = %+v, want empty", plan)
+	}
+	if err := ApplyPlan(dir, plan); err != nil {
+		t.Fatalf("ApplyPlan: %v", err)
+	}
+}
+
+func TestApplyPlan_RecordsProvenance(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeCatalog(t, dir, []catalog.Entry{
+		{Name: "cnpg-operator", Tier: "0-operators"},
+		{Name: "postgresql", Tier: "1-data", DependsOn: []string{"cnpg-operator"}},
+		{Name: "langfuse", Tier: "2-services", DependsOn: []string{"postgresql"}},
+		{Name: "temporal", Tier: "2-services", DependsOn: []string{"postgresql"}},
+	})
+
+	plan, err := NewPlan(dir, "team", []string{"langfuse", "temporal"}, false)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	if err := ApplyPlan(dir, plan); err != nil {
+		t.Fatalf("ApplyPlan: %v", err)
+	}
+
+	pg, err := catalog.Find(dir, "postgresql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pg.AutoEnabledBy, []string{"langfuse", "temporal"}) {
+		t.Errorf("postgresql AutoEnabledBy = %v, want [langfuse temporal]", pg.AutoEnabledBy)
+	}
+	lf, _ := catalog.Find(dir, "langfuse")
+	if lf.Provenance() != catalog.ProvenanceExplicit {
+		t.Errorf("langfuse provenance = %q, want explicit", lf.Provenance())
+	}
+}
+
+func TestNewPlan_ReattributesEnabledDependencies(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	writeCatalog(t, dir, []catalog.Entry{
+		{Name: "cnpg-operator", Tier: "0-operators", Enabled: true, AutoEnabledBy: []string{"langfuse"}},
+		{Name: "postgresql", Tier: "1-data", Enabled: true, AutoEnabledBy: []string{"langfuse"}, DependsOn: []string{"cnpg-operator"}},
+		{Name: "langfuse", Tier: "2-services", Enabled: true, DependsOn: []string{"postgresql"}},
+		{Name: "temporal", Tier: "2-services", DependsOn: []string{"postgresql"}},
+	})
+
+	// Additive: temporal joins langfuse as a reason to keep postgresql.
+	plan, err := NewPlan(dir, "team", []string{"temporal"}, false)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	if !slices.Equal(plan.Reattribute, []string{"cnpg-operator", "postgresql"}) {
+		t.Errorf("Reattribute = %v, want [cnpg-operator postgresql]", plan.Reattribute)
+	}
+	if got := plan.RequiredBy("postgresql"); !slices.Equal(got, []string{"langfuse", "temporal"}) {
+		t.Errorf("RequiredBy(postgresql) = %v, want [langfuse temporal]", got)
+	}
+
+	// Exact: langfuse is disabled, so temporal becomes the only reason.
+	plan, err = NewPlan(dir, "team", []string{"temporal"}, true)
+	if err != nil {
+		t.Fatalf("NewPlan(exact): %v", err)
+	}
+	if err := ApplyPlan(dir, plan); err != nil {
+		t.Fatalf("ApplyPlan: %v", err)
+	}
+	for _, name := range []string{"cnpg-operator", "postgresql"} {
+		e, err := catalog.Find(dir, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(e.AutoEnabledBy, []string{"temporal"}) {
+			t.Errorf("%s AutoEnabledBy = %v, want [temporal]", name, e.AutoEnabledBy)
+		}
+	}
+	orphans, err := catalog.Orphans(mustList(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 0 {
+		t.Errorf("orphans after apply = %v, want none", orphans)
+	}
+
+	again, err := NewPlan(dir, "team", []string{"temporal"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Empty() {
+		t.Errorf("plan after apply = %+v, want empty", again)
+	}
+}
+
+func mustList(t *testing.T, dir string) []catalog.Entry {
+	t.Helper()
+	all, err := catalog.List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return all
+}
@@ -1,12 +1,19 @@
 package profile
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
 
 	"github.com/alicanalbayrak/sikifanso/internal/catalog"
-	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+)
+
+// Profile sources reported in Profile.Source.
+const (
+	SourceBuiltin = "builtin"
+	SourceUser    = "user"
+	SourceGitOps  = "gitops"
 )
 
 // Profile defines a named set of catalog apps to enable together.
@@ -14,9 +21,16 @@ type Profile struct {
 	Name        string   `json:"name"`
 	Description string   `json:"description"`
 	Apps        []string `json:"apps"`
+	// Source records where the profile was defined: builtin, user
+	// (~/.sikifanso/profiles/) or gitops (<gitops>/profiles/).
+	Source string `json:"source,omitempty"`
 }
 
-// registry holds all known profiles keyed by name.
+// ErrNotFound is returned (wrapped) when a profile name is not defined in
+// any of the loaded sources.
+var ErrNotFound = errors.New("not found")
+
+// registry holds all built-in profiles keyed by name.
 var registry = map[string]Profile{
 	"agent-minimal": {
 		Name:        "agent-minimal",
@@ -55,30 +69,49 @@ var registry = map[string]Profile{
 	},
 }
 
-// List returns all available profiles sorted by name.
-func List() []Profile {
-	profiles := make([]Profile, 0, len(registry))
-	for _, p := range registry {
+// List returns all available profiles sorted by name: the built-ins merged
+// with user-defined profiles from ~/.sikifanso/profiles/ and, when gitOpsPath
+// is non-empty, <gitOpsPath>/profiles/.
+func List(gitOpsPath string) ([]Profile, error) {
+	set, err := load(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]Profile, 0, len(set))
+	for _, p := range set {
 		profiles = append(profiles, p)
 	}
 	sort.Slice(profiles, func(i, j int) bool {
 		return profiles[i].Name < profiles[j].Name
 	})
-	return profiles
+	return profiles, nil
 }
 
 // Get returns the profile with the given name or an error listing available profiles.
-func Get(name string) (Profile, error) {
-	p, ok := registry[name]
+func Get(gitOpsPath, name string) (Profile, error) {
+	set, err := load(gitOpsPath)
+	if err != nil {
+		return Profile{}, err
+	}
+	return get(set, name)
+}
+
+func get(set map[string]Profile, name string) (Profile, error) {
+	p, ok := set[name]
 	if !ok {
-		return Profile{}, fmt.Errorf("profile %q not found; available: %s", name, strings.Join(Names(), ", "))
+		return Profile{}, fmt.Errorf("profile %q %w; available: %s", name, ErrNotFound, strings.Join(sortedNames(set), ", "))
 	}
 	return p, nil
 }
 
 // Resolve takes a comma-separated profile string (e.g. "agent-dev,rag") and
 // returns the deduplicated union of all apps across the named profiles.
-func Resolve(profileStr string) ([]string, error) {
+func Resolve(gitOpsPath, profileStr string) ([]string, error) {
+	set, err := load(gitOpsPath)
+	if err != nil {
+		return nil, err
+	}
+
 	parts := strings.Split(profileStr, ",")
 	seen := make(map[string]bool)
 	var apps []string
@@ -88,7 +121,7 @@ func Resolve(profileStr string) ([]string, error) {
 		if name == "" {
 			continue
 		}
-		p, err := Get(name)
+		p, err := get(set, name)
 		if err != nil {
 			return nil, err
 		}
@@ -106,81 +139,49 @@ func Resolve(profileStr string) ([]string, error) {
 // changes in a single commit. Transitive dependencies are resolved and
 // auto-enabled. Returns the names of auto-added dependencies.
 //
-// Apps that don't exist in the catalog are skipped with a warning via the
-// provided warn function. The profileName is used in the commit message.
-func Apply(gitOpsPath string, profileName string, apps []string, warn func(string)) ([]string, error) {
-	all, err := catalog.List(gitOpsPath)
+// Apps that don't exist in the catalog fail validation before anything is
+// written. The profileName is used in error and commit messages.
+func Apply(gitOpsPath string, profileName string, apps []string) ([]string, error) {
+	plan, err := NewPlan(gitOpsPath, profileName, apps, false)
 	if err != nil {
-		return nil, fmt.Errorf("listing catalog: %w", err)
+		return nil, err
 	}
+	if err := ApplyPlan(gitOpsPath, plan); err != nil {
+		return nil, err
+	}
+	return plan.AutoAdded, nil
+}
 
-	// Filter out apps that don't exist in the catalog — warn and skip.
+// validateApps returns an error naming every app that is missing from the catalog.
+func validateApps(profileName string, apps []string, all []catalog.Entry) error {
 	catalogSet := make(map[string]bool, len(all))
 	for _, e := range all {
 		catalogSet[e.Name] = true
 	}
-	var known []string
+	var unknown []string
 	for _, app := range apps {
-		if catalogSet[app] {
-			known = append(known, app)
-		} else if warn != nil {
-			warn(fmt.Sprintf("skipping %s: not found in catalog", app))
+		if !catalogSet[app] {
+			unknown = append(unknown, app)
 		}
 	}
-	if len(known) == 0 {
-		return nil, nil
+	if len(unknown) > 0 {
+		return fmt.Errorf("profile %s references apps not found in catalog: %s", profileName, strings.Join(unknown, ", "))
 	}
+	return nil
+}
 
-	resolved, _, err := catalog.ResolveDeps(known, all)
+// Names returns the sorted list of available profile names (for shell completion).
+func Names(gitOpsPath string) ([]string, error) {
+	set, err := load(gitOpsPath)
 	if err != nil {
-		return nil, fmt.Errorf("resolving dependencies: %w", err)
-	}
-
-	knownSet := make(map[string]bool, len(known))
-	for _, k := range known {
-		knownSet[k] = true
-	}
-
-	enabledSet := make(map[string]bool, len(all))
-	for _, e := range all {
-		if e.Enabled {
-			enabledSet[e.Name] = true
-		}
-	}
-
-	var commitPaths []string
-	var autoAdded []string
-	for _, app := range resolved {
-		if enabledSet[app] {
-			continue // already enabled — no-op
-		}
-		if err := catalog.SetEnabled(gitOpsPath, app, true); err != nil {
-			if warn != nil {
-				warn(fmt.Sprintf("skipping %s: %s", app, err))
-			}
-			continue
-		}
-		commitPaths = append(commitPaths, fmt.Sprintf("catalog/%s.yaml", app))
-		if !knownSet[app] {
-			autoAdded = append(autoAdded, app)
-		}
-	}
-
-	if len(commitPaths) == 0 {
-		return nil, nil
-	}
-
-	msg := fmt.Sprintf("profile: enable %s apps", profileName)
-	if err := gitops.Commit(gitOpsPath, msg, commitPaths...); err != nil {
 		return nil, err
 	}
-	return autoAdded, nil
+	return sortedNames(set), nil
 }
 
-// Names returns the sorted list of available profile names (for shell completion).
-func Names() []string {
-	names := make([]string, 0, len(registry))
-	for n := range registry {
+func sortedNames(set map[string]Profile) []string {
+	names := make([]string, 0, len(set))
+	for n := range set {
 		names = append(names, n)
 	}
 	sort.Strings(names)
@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"sigs.k8s.io/yaml"
@@ -12,16 +13,22 @@ import (
 )
 
 func TestList_ReturnsAllProfiles(t *testing.T) {
-	t.Parallel()
-	profiles := List()
+	isolateHome(t)
+	profiles, err := List("")
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
 	if len(profiles) != len(registry) {
 		t.Fatalf("List() returned %d profiles, want %d", len(profiles), len(registry))
 	}
 }
 
 func TestList_SortedByName(t *testing.T) {
-	t.Parallel()
-	profiles := List()
+	isolateHome(t)
+	profiles, err := List("")
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
 	for i := 1; i < len(profiles); i++ {
 		if profiles[i].Name < profiles[i-1].Name {
 			t.Errorf("profiles not sorted: %q before %q", profiles[i-1].Name, profiles[i].Name)
@@ -30,8 +37,8 @@ func TestList_SortedByName(t *testing.T) {
 }
 
 func TestGet_ExistingProfile(t *testing.T) {
-	t.Parallel()
-	p, err := Get("agent-dev")
+	isolateHome(t)
+	p, err := Get("", "agent-dev")
 	if err != nil {
 		t.Fatalf("Get(agent-dev) error: %v", err)
 	}
@@ -44,16 +51,16 @@ func TestGet_ExistingProfile(t *testing.T) {
 }
 
 func TestGet_NotFound(t *testing.T) {
-	t.Parallel()
-	_, err := Get("nonexistent")
+	isolateHome(t)
+	_, err := Get("", "nonexistent")
 	if err == nil {
 		t.Fatal("Get(nonexistent) should return error")
 	}
 }
 
 func TestResolve_SingleProfile(t *testing.T) {
-	t.Parallel()
-	apps, err := Resolve("agent-minimal")
+	isolateHome(t)
+	apps, err := Resolve("", "agent-minimal")
 	if err != nil {
 		t.Fatalf("Resolve error: %v", err)
 	}
@@ -64,8 +71,8 @@ func TestResolve_SingleProfile(t *testing.T) {
 }
 
 func TestResolve_CompositeProfile(t *testing.T) {
-	t.Parallel()
-	apps, err := Resolve("agent-minimal,rag")
+	isolateHome(t)
+	apps, err := Resolve("", "agent-minimal,rag")
 	if err != nil {
 		t.Fatalf("Resolve error: %v", err)
 	}
@@ -95,16 +102,16 @@ func TestResolve_CompositeProfile(t *testing.T) {
 }
 
 func TestResolve_InvalidProfile(t *testing.T) {
-	t.Parallel()
-	_, err := Resolve("agent-minimal,nonexistent")
+	isolateHome(t)
+	_, err := Resolve("", "agent-minimal,nonexistent")
 	if err == nil {
 		t.Fatal("expected error for invalid profile in composite")
 	}
 }
 
 func TestResolve_EmptyString(t *testing.T) {
-	t.Parallel()
-	apps, err := Resolve("")
+	isolateHome(t)
+	apps, err := Resolve("", "")
 	if err != nil {
 		t.Fatalf("Resolve error: %v", err)
 	}
@@ -114,8 +121,11 @@ func TestResolve_EmptyString(t *testing.T) {
 }
 
 func TestNames_Sorted(t *testing.T) {
-	t.Parallel()
-	names := Names()
+	isolateHome(t)
+	names, err := Names("")
+	if err != nil {
+		t.Fatalf("Names error: %v", err)
+	}
 	if len(names) != len(registry) {
 		t.Fatalf("Names() returned %d, want %d", len(names), len(registry))
 	}
@@ -154,16 +164,9 @@ func TestApply_EnablesAppsOnDisk(t *testing.T) {
 	initGitRepo(t, dir)
 
 	apps := []string{"litellm-proxy", "langfuse", "postgresql"}
-	var warnings []string
-	_, err := Apply(dir, "agent-minimal", apps, func(msg string) {
-		warnings = append(warnings, msg)
-	})
-	if err != nil {
+	if _, err := Apply(dir, "agent-minimal", apps); err != nil {
 		t.Fatalf("Apply error: %v", err)
 	}
-	if len(warnings) > 0 {
-		t.Errorf("unexpected warnings: %v", warnings)
-	}
 
 	for _, name := range apps {
 		entry, err := catalog.Find(dir, name)
@@ -176,7 +179,7 @@ func TestApply_EnablesAppsOnDisk(t *testing.T) {
 	}
 }
 
-func TestApply_SkipsMissingApps(t *testing.T) {
+func TestApply_RejectsMissingApps(t *testing.T) {
 	t.Parallel()
 	dir := t.TempDir()
 	catalogDir := filepath.Join(dir, "catalog")
@@ -201,23 +204,30 @@ func TestApply_SkipsMissingApps(t *testing.T) {
 
 	initGitRepo(t, dir)
 
-	var warnings []string
-	_, err := Apply(dir, "test", []string{"nonexistent", "postgresql"}, func(msg string) {
-		warnings = append(warnings, msg)
-	})
-	if err != nil {
-		t.Fatalf("Apply error: %v", err)
+	_, err := Apply(dir, "test", []string{"nonexistent", "postgresql"})
+	if err == nil {
+		t.Fatal("expected error for nonexistent app")
 	}
-	if len(warnings) != 1 {
-		t.Errorf("expected 1 warning for nonexistent app, got %d", len(warnings))
+	if !strings.Contains(err.Error(), "nonexistent") {
+		t.Errorf("error %q does not name the unknown app", err)
 	}
 
+	// Validation happens before any write — postgresql must stay disabled.
 	e, _ := catalog.Find(dir, "postgresql")
-	if !e.Enabled {
-		t.Error("postgresql should be enabled")
+	if e.Enabled {
+		t.Error("postgresql should not be enabled when the profile is invalid")
 	}
 }
 
+// isolateHome points SIKIFANSO_HOME at an empty temp dir so the real
+// ~/.sikifanso/profiles/ never leaks into tests.
+func isolateHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("SIKIFANSO_HOME", home)
+	return home
+}
+
 // initGitRepo initializes a git repo with an initial commit so gitops.Commit works.
 func initGitRepo(t *testing.T, dir string) {
 	t.Helper()
@@ -0,0 +1,147 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alicanalbayrak/sikifanso/internal/paths"
+)
+
+const profilesDir = "profiles"
+
+// UserDir returns the directory holding user-defined profiles
+// (~/.sikifanso/profiles/, or $SIKIFANSO_HOME/profiles/).
+func UserDir() (string, error) {
+	root, err := paths.RootDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, profilesDir), nil
+}
+
+// GitOpsDir returns the directory holding profiles committed to the gitops repo.
+func GitOpsDir(gitOpsPath string) string {
+	return filepath.Join(gitOpsPath, profilesDir)
+}
+
+// profileDir pairs a directory with the Source label its profiles receive.
+type profileDir struct {
+	path   string
+	source string
+}
+
+// load returns the built-in profiles merged with user-defined profiles.
+// gitOpsPath may be empty when no cluster is available, in which case only
+// ~/.sikifanso/profiles/ is consulted.
+func load(gitOpsPath string) (map[string]Profile, error) {
+	userDir, err := UserDir()
+	if err != nil {
+		return nil, err
+	}
+	dirs := []profileDir{{path: userDir, source: SourceUser}}
+	if gitOpsPath != "" {
+		dirs = append(dirs, profileDir{path: GitOpsDir(gitOpsPath), source: SourceGitOps})
+	}
+	return loadFrom(dirs)
+}
+
+// loadFrom merges the built-in registry with every profile found in dirs.
+// A profile whose name collides with a built-in or with a profile from
+// another file is a validation error rather than a silent override.
+func loadFrom(dirs []profileDir) (map[string]Profile, error) {
+	set := make(map[string]Profile, len(registry))
+	origin := make(map[string]string, len(registry))
+	for name, p := range registry {
+		p.Source = SourceBuiltin
+		set[name] = p
+		origin[name] = "built-in profiles"
+	}
+
+	for _, d := range dirs {
+		profiles, files, err := readDir(d.path)
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range profiles {
+			if prev, ok := origin[p.Name]; ok {
+				if set[p.Name].Source == SourceBuiltin {
+					return nil, fmt.Errorf("profile %q in %s shadows a built-in profile; choose a different name", p.Name, files[i])
+				}
+				return nil, fmt.Errorf("profile %q in %s is already defined in %s", p.Name, files[i], prev)
+			}
+			p.Source = d.source
+			set[p.Name] = p
+			origin[p.Name] = files[i]
+		}
+	}
+	return set, nil
+}
+
+// readDir parses every *.yaml file in dir. A missing directory yields no
+// profiles. The returned file paths are parallel to the profiles.
+func readDir(dir string) ([]Profile, []string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("reading profiles directory: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var profiles []Profile
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".yaml" {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading profile %s: %w", path, err)
+		}
+		var p Profile
+		if err := yaml.UnmarshalStrict(data, &p); err != nil {
+			return nil, nil, fmt.Errorf("parsing profile %s: %w", path, err)
+		}
+		if err := validate(p); err != nil {
+			return nil, nil, fmt.Errorf("invalid profile %s: %w", path, err)
+		}
+		profiles = append(profiles, p)
+		files = append(files, path)
+	}
+	return profiles, files, nil
+}
+
+// validate checks the fields of a user-defined profile. Whether the apps
+// exist is checked against the cluster's catalog in Apply.
+func validate(p Profile) error {
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.ContainsAny(p.Name, ", \t") {
+		return fmt.Errorf("name %q must not contain commas or whitespace", p.Name)
+	}
+	if p.Source != "" {
+		return fmt.Errorf("source is set by sikifanso and must not appear in the file")
+	}
+	if len(p.Apps) == 0 {
+		return fmt.Errorf("profile %q lists no apps", p.Name)
+	}
+	seen := make(map[string]bool, len(p.Apps))
+	for _, a := range p.Apps {
+		if a == "" {
+			return fmt.Errorf("profile %q has an empty app name", p.Name)
+		}
+		if seen[a] {
+			return fmt.Errorf("profile %q lists %s more than once", p.Name, a)
+		}
+		seen[a] = true
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeProfile writes a profile YAML file into dir/<file>.
+func writeProfile(t *testing.T, dir, file, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("creating profiles dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644); err != nil {
+		t.Fatalf("writing profile %s: %v", file, err)
+	}
+}
+
+func TestList_IncludesUserAndGitOpsProfiles(t *testing.T) {
+	home := isolateHome(t)
+	gitOps := t.TempDir()
+
+	writeProfile(t, filepath.Join(home, "profiles"), "mine.yaml", `
+name: mine
+description: Personal stack
+apps: [ollama]
+`)
+	writeProfile(t, GitOpsDir(gitOps), "agent-team.yaml", `
+name: agent-team
+description: Team stack
+apps: [litellm-proxy, langfuse]
+`)
+
+	profiles, err := List(gitOps)
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if len(profiles) != len(registry)+2 {
+		t.Fatalf("List returned %d profiles, want %d", len(profiles), len(registry)+2)
+	}
+
+	sources := make(map[string]string, len(profiles))
+	for _, p := range profiles {
+		sources[p.Name] = p.Source
+	}
+	if sources["mine"] != SourceUser {
+		t.Errorf("mine source = %q, want %q", sources["mine"], SourceUser)
+	}
+	if sources["agent-team"] != SourceGitOps {
+		t.Errorf("agent-team source = %q, want %q", sources["agent-team"], SourceGitOps)
+	}
+	if sources["rag"] != SourceBuiltin {
+		t.Errorf("rag source = %q, want %q", sources["rag"], SourceBuiltin)
+	}
+}
+
+func TestList_WithoutGitOpsPathSkipsRepoProfiles(t *testing.T) {
+	isolateHome(t)
+	gitOps := t.TempDir()
+	writeProfile(t, GitOpsDir(gitOps), "agent-team.yaml", "name: agent-team\napps: [ollama]\n")
+
+	names, err := Names("")
+	if err != nil {
+		t.Fatalf("Names error: %v", err)
+	}
+	for _, n := range names {
+		if n == "agent-team" {
+			t.Fatal("gitops profile listed without a gitops path")
+		}
+	}
+}
+
+func TestResolve_UserProfileComposesWithBuiltin(t *testing.T) {
+	isolateHome(t)
+	gitOps := t.TempDir()
+	writeProfile(t, GitOpsDir(gitOps), "agent-team.yaml", "name: agent-team\napps: [temporal, qdrant]\n")
+
+	apps, err := Resolve(gitOps, "rag,agent-team")
+	if err != nil {
+		t.Fatalf("Resolve error: %v", err)
+	}
+	want := len(registry["rag"].Apps) + 1 // qdrant is already in rag
+	if len(apps) != want {
+		t.Errorf("got %d apps (%v), want %d", len(apps), apps, want)
+	}
+}
+
+func TestLoad_ShadowingBuiltinIsError(t *testing.T) {
+	home := isolateHome(t)
+	writeProfile(t, filepath.Join(home, "profiles"), "rag.yaml", "name: rag\napps: [qdrant]\n")
+
+	_, err := List("")
+	if err == nil {
+		t.Fatal("expected error for profile shadowing a built-in")
+	}
+	if !strings.Contains(err.Error(), "shadows a built-in") {
+		t.Errorf("error %q does not explain the shadowing", err)
+	}
+}
+
+func TestLoad_DuplicateAcrossDirsIsError(t *testing.T) {
+	home := isolateHome(t)
+	gitOps := t.TempDir()
+	writeProfile(t, filepath.Join(home, "profiles"), "team.yaml", "name: team\napps: [qdrant]\n")
+	writeProfile(t, GitOpsDir(gitOps), "team.yaml", "name: team\napps: [ollama]\n")
+
+	_, err := List(gitOps)
+	if err == nil {
+		t.Fatal("expected error for duplicate profile names")
+	}
+	if !strings.Contains(err.Error(), "already defined") {
+		t.Errorf("error %q does not mention the duplicate", err)
+	}
+}
+
+func TestLoad_InvalidProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"missing name", "apps: [qdrant]\n", "name is required"},
+		{"no apps", "name: empty\n", "lists no apps"},
+		{"comma in name", "name: a,b\napps: [qdrant]\n", "must not contain commas"},
+		{"duplicate app", "name: dup\napps: [qdrant, qdrant]\n", "more than once"},
+		{"unknown field", "name: typo\napp: [qdrant]\n", "parsing profile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := isolateHome(t)
+			writeProfile(t, filepath.Join(home, "profiles"), "bad.yaml", tt.content)
+
+			_, err := List("")
+			if err == nil {
+				t.Fatal("expected validation error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_IgnoresNonYAMLAndSubdirs(t *testing.T) {
+	home := isolateHome(t)
+	dir := filepath.Join(home, "profiles")
+	writeProfile(t, dir, "README.md", "# not a profile\n")
+	writeProfile(t, filepath.Join(dir, "archive"), "old.yaml", "name: old\napps: [qdrant]\n")
+
+	profiles, err := List("")
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if len(profiles) != len(registry) {
+		t.Errorf("List returned %d profiles, want %d", len(profiles), len(registry))
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
 	"github.com/alicanalbayrak/sikifanso/internal/paths"
 	"sigs.k8s.io/yaml"
 )
@@ -20,6 +23,38 @@ type Session struct {
 	GitOpsPath       string        `json:"gitOpsPath"`
 	Services         ServiceInfo   `json:"services"`
 	K3dConfig        K3dConfigInfo `json:"k3dConfig"`
+	// Registry is set for clusters created with a local image registry.
+	Registry *RegistryInfo `json:"registry,omitempty"`
+	// Bundle is set for clusters created offline from a bundle.
+	Bundle *BundleInfo `json:"bundle,omitempty"`
+}
+
+// BundleInfo records the offline bundle a cluster was created from.
+type BundleInfo struct {
+	// File is the bundle archive the cluster was created from.
+	File string `json:"file"`
+	// ChartRepo is the in-cluster Helm repository serving the bundled
+	// charts; catalog entries and agents are pointed at it.
+	ChartRepo string `json:"chartRepo"`
+}
+
+// RegistryHost is the name cluster nodes pull local registry images by,
+// e.g. registry.local/my-agent:dev.
+const RegistryHost = "registry.local"
+
+// RegistryInfo holds the cluster's local image registry details.
+type RegistryInfo struct {
+	// Name is the registry's container name and its host on the cluster
+	// network.
+	Name string `json:"name"`
+	// HostPort is where the registry is published on 127.0.0.1; images are
+	// pushed to localhost:HostPort.
+	HostPort int `json:"hostPort"`
+}
+
+// PushHost returns the registry address to push to from the host.
+func (r RegistryInfo) PushHost() string {
+	return fmt.Sprintf("localhost:%d", r.HostPort)
 }
 
 // ServiceInfo groups all service access details.
@@ -45,13 +80,43 @@ type HubbleInfo struct {
 type K3dConfigInfo struct {
 	Image   string `json:"image"`
 	Servers int    `json:"servers"`
-	Agents  int    `json:"agents"`
+	// Agents counts the general-purpose agent nodes; pool nodes are in
+	// AgentPools.
+	Agents     int                    `json:"agents"`
+	AgentPools []infraconfig.NodePool `json:"agentPools,omitempty"`
+}
+
+// Topology describes the node layout, e.g. "1 server, 1 agent, sandbox=2".
+func (k K3dConfigInfo) Topology() string {
+	parts := []string{plural(k.Servers, "server"), plural(k.Agents, "agent")}
+	for _, p := range k.AgentPools {
+		parts = append(parts, fmt.Sprintf("%s=%d", p.Name, p.Count))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// HasPool reports whether the cluster was created with the named agent pool.
+func (k K3dConfigInfo) HasPool(name string) bool {
+	for _, p := range k.AgentPools {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 const (
 	clusterDir  = "clusters"
 	sessionFile = "session.yaml"
 	gitopsDir   = "gitops"
+	bundleDir   = "bundle"
 )
 
 // Dir returns the session directory for the given cluster name:
@@ -74,7 +139,18 @@ func GitOpsDir(clusterName string) (string, error) {
 	return filepath.Join(dir, gitopsDir), nil
 }
 
-// Save marshals the session to YAML and writes it to the session directory.
+// BundleDir returns the directory an offline bundle is unpacked into for
+// the given cluster name: ~/.sikifanso/clusters/<name>/bundle/
+func BundleDir(clusterName string) (string, error) {
+	dir, err := Dir(clusterName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, bundleDir), nil
+}
+
+// Save marshals the session to YAML and writes it to the session directory,
+// holding the cluster's mutation lock while it writes.
 func Save(s *Session) error {
 	dir, err := Dir(s.ClusterName)
 	if err != nil {
@@ -89,11 +165,13 @@ func Save(s *Session) error {
 		return fmt.Errorf("marshaling session: %w", err)
 	}
 
-	path := filepath.Join(dir, sessionFile)
-	if err := os.WriteFile(path, data, 0o600); err != nil {
-		return fmt.Errorf("writing session file: %w", err)
-	}
-	return nil
+	return lock.Do(dir, func() error {
+		path := filepath.Join(dir, sessionFile)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return fmt.Errorf("writing session file: %w", err)
+		}
+		return nil
+	})
 }
 
 // Load reads and unmarshals the session for the given cluster name.
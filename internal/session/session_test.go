@@ -4,6 +4,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
 )
 
 func setupTestHome(t *testing.T) {
@@ -143,3 +145,22 @@ func TestDir(t *testing.T) {
 		t.Errorf("Dir = %q, want %q", dir, want)
 	}
 }
+
+func TestK3dConfigInfo_Topology(t *testing.T) {
+	t.Parallel()
+	k := K3dConfigInfo{Servers: 1, Agents: 0}
+	if got := k.Topology(); got != "1 server, 0 agents" {
+		t.Errorf("Topology() = %q", got)
+	}
+	if k.HasPool("sandbox") {
+		t.Error("HasPool(sandbox) = true without pools")
+	}
+
+	k = K3dConfigInfo{Servers: 3, Agents: 1, AgentPools: []infraconfig.NodePool{{Name: "sandbox", Count: 2}}}
+	if got := k.Topology(); got != "3 servers, 1 agent, sandbox=2" {
+		t.Errorf("Topology() = %q", got)
+	}
+	if !k.HasPool("sandbox") {
+		t.Error("HasPool(sandbox) = false")
+	}
+}
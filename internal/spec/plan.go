@@ -0,0 +1,542 @@
+package spec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/app"
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/gitops"
+	"github.com/alicanalbayrak/sikifanso/internal/litellm"
+	"github.com/alicanalbayrak/sikifanso/internal/lock"
+	"github.com/alicanalbayrak/sikifanso/internal/profile"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"go.uber.org/zap"
+	"sigs.k8s.io/yaml"
+)
+
+// Plan is the set of changes that converges a cluster on a spec. It is
+// computed without touching the gitops repo so callers can preview it.
+type Plan struct {
+	Catalog profile.Plan `json:"catalog"`
+	// Values lists catalog apps whose values overrides are replaced.
+	Values     []string `json:"values,omitempty"`
+	CustomApps Changes  `json:"customApps"`
+	Agents     Changes  `json:"agents"`
+	// Warnings reports spec settings that only apply at cluster creation
+	// and differ from the cluster, and agent changes Apply cannot make.
+	Warnings []string `json:"warnings,omitempty"`
+
+	spec *Spec
+	// resync lists Values apps that stay enabled and need a sync.
+	resync       []string
+	coordsChange map[string]bool
+	valuesChange map[string]bool
+	egressChange map[string]bool
+}
+
+// Changes lists the entries of one kind to add, update and remove.
+type Changes struct {
+	Add    []string `json:"add,omitempty"`
+	Update []string `json:"update,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+func (c Changes) empty() bool {
+	return len(c.Add) == 0 && len(c.Update) == 0 && len(c.Remove) == 0
+}
+
+// Empty reports whether applying the plan would change nothing.
+func (p *Plan) Empty() bool {
+	return p.Catalog.Empty() && len(p.Values) == 0 && p.CustomApps.empty() && p.Agents.empty()
+}
+
+// Resync returns the catalog apps whose values change while they stay
+// enabled, so they need a sync once the plan is applied.
+func (p *Plan) Resync() []string {
+	return p.resync
+}
+
+// NewPlan computes the changes needed to converge the cluster of sess on s.
+//
+// When exact is false, the plan only adds and updates. When exact is true,
+// catalog apps, custom apps and agents the spec does not list are removed
+// as well; ephemeral agents are left to agent reap.
+func NewPlan(sess *session.Session, s *Spec, exact bool) (*Plan, error) {
+	gitOpsPath := sess.GitOpsPath
+	p := &Plan{
+		spec:         s,
+		coordsChange: map[string]bool{},
+		valuesChange: map[string]bool{},
+		egressChange: map[string]bool{},
+		Warnings:     drift(sess, s),
+	}
+
+	apps, err := CatalogApps(gitOpsPath, s)
+	if err != nil {
+		return nil, err
+	}
+	label := "spec"
+	if s.Name != "" {
+		label = s.Name + " spec"
+	}
+	if p.Catalog, err = profile.NewPlan(gitOpsPath, label, apps, exact); err != nil {
+		return nil, err
+	}
+
+	if err := p.planValues(gitOpsPath); err != nil {
+		return nil, err
+	}
+	if err := p.planCustomApps(gitOpsPath, exact); err != nil {
+		return nil, err
+	}
+	if err := p.planAgents(sess, exact); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// CatalogApps returns the catalog apps s asks for: its apps followed by
+// those of its profiles, resolved against the gitops repo at gitOpsPath.
+func CatalogApps(gitOpsPath string, s *Spec) ([]string, error) {
+	apps := slices.Clone(s.Apps)
+	if len(s.Profiles) == 0 {
+		return apps, nil
+	}
+	profileApps, err := profile.Resolve(gitOpsPath, strings.Join(s.Profiles, ","))
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range profileApps {
+		if !slices.Contains(apps, a) {
+			apps = append(apps, a)
+		}
+	}
+	return apps, nil
+}
+
+// planValues lists the catalog apps whose values differ from the spec.
+func (p *Plan) planValues(gitOpsPath string) error {
+	for _, name := range sortedKeys(p.spec.Values) {
+		entry, err := catalog.Find(gitOpsPath, name)
+		if err != nil {
+			return fmt.Errorf("values: %w", err)
+		}
+		data, err := catalog.ReadValues(gitOpsPath, name)
+		if err != nil {
+			return err
+		}
+		current, err := catalog.ParseValues(data)
+		if err != nil {
+			return fmt.Errorf("values for %s: %w", name, err)
+		}
+		if sameValues(current, p.spec.Values[name]) {
+			continue
+		}
+		p.Values = append(p.Values, name)
+		if entry.Enabled && !slices.Contains(p.Catalog.Disable, name) {
+			p.resync = append(p.resync, name)
+		}
+	}
+	return nil
+}
+
+// planCustomApps compares the spec's custom apps with apps/coordinates.
+func (p *Plan) planCustomApps(gitOpsPath string, exact bool) error {
+	existing, err := app.List(gitOpsPath)
+	if err != nil {
+		return fmt.Errorf("listing apps: %w", err)
+	}
+	byName := make(map[string]app.AppInfo, len(existing))
+	for _, a := range existing {
+		byName[a.Name] = a
+	}
+
+	for _, a := range p.spec.CustomApps {
+		cur, ok := byName[a.Name]
+		if !ok {
+			p.CustomApps.Add = append(p.CustomApps.Add, a.Name)
+			continue
+		}
+		if cur.RepoURL != a.RepoURL || cur.Chart != a.Chart || cur.Version != a.Version || cur.Namespace != a.namespace() {
+			p.coordsChange[a.Name] = true
+		}
+		if a.Values != nil {
+			vals, err := customValues(gitOpsPath, a.Name)
+			if err != nil {
+				return err
+			}
+			if !sameValues(vals, a.Values) {
+				p.valuesChange[a.Name] = true
+			}
+		}
+		if p.coordsChange[a.Name] || p.valuesChange[a.Name] {
+			p.CustomApps.Update = append(p.CustomApps.Update, a.Name)
+		}
+	}
+
+	if exact {
+		for _, a := range existing {
+			if !slices.ContainsFunc(p.spec.CustomApps, func(c CustomApp) bool { return c.Name == a.Name }) {
+				p.CustomApps.Remove = append(p.CustomApps.Remove, a.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// planAgents compares the spec's agents with agents/, using a dry-run
+// agent.Update and agent.SetEgress to find quota, chart version and egress
+// changes.
+func (p *Plan) planAgents(sess *session.Session, exact bool) error {
+	gitOpsPath := sess.GitOpsPath
+	existing, err := agent.List(gitOpsPath)
+	if err != nil {
+		return fmt.Errorf("listing agents: %w", err)
+	}
+	byName := make(map[string]agent.Info, len(existing))
+	for _, a := range existing {
+		byName[a.Name] = a
+	}
+
+	for _, a := range p.spec.Agents {
+		cur, ok := byName[a.Name]
+		if !ok {
+			if err := p.checkNewAgent(sess, a); err != nil {
+				return fmt.Errorf("agent %s: %w", a.Name, err)
+			}
+			p.Agents.Add = append(p.Agents.Add, a.Name)
+			continue
+		}
+		if a.Template != cur.Template {
+			p.Warnings = append(p.Warnings, fmt.Sprintf(
+				"agent %s runs template %q, not %q; delete it to recreate it from the spec", a.Name, cur.Template, a.Template))
+		}
+		// A template that no longer renders only hides the image, so it
+		// does not block the rest of the plan.
+		if image, err := imageOverride(gitOpsPath, cur); err != nil {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("%v; its image is not compared", err))
+		} else if a.Image != image {
+			p.Warnings = append(p.Warnings, fmt.Sprintf(
+				"agent %s runs image %q, not %q; delete it to recreate it from the spec", a.Name, image, a.Image))
+		}
+		if a.NodePool != nil && *a.NodePool != cur.NodePool {
+			p.Warnings = append(p.Warnings, fmt.Sprintf(
+				"agent %s is pinned to node pool %q, not %q; delete it to recreate it from the spec", a.Name, cur.NodePool, *a.NodePool))
+		}
+		opts := updateOpts(a)
+		opts.DryRun = true
+		changes, err := agent.Update(gitOpsPath, opts)
+		if err != nil {
+			return fmt.Errorf("agent %s: %w", a.Name, err)
+		}
+		if a.Egress != nil {
+			if p.egressChange[a.Name], err = agent.SetEgress(gitOpsPath, a.Name, a.Egress, true); err != nil {
+				return fmt.Errorf("agent %s: %w", a.Name, err)
+			}
+		}
+		if len(changes) > 0 || p.egressChange[a.Name] {
+			p.Agents.Update = append(p.Agents.Update, a.Name)
+		}
+	}
+
+	if exact {
+		for _, a := range existing {
+			if a.ExpiresAt != nil {
+				continue
+			}
+			if !slices.ContainsFunc(p.spec.Agents, func(s Agent) bool { return s.Name == a.Name }) {
+				p.Agents.Remove = append(p.Agents.Remove, a.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkNewAgent rejects settings of an agent to be created that cannot be
+// applied: a node pool the cluster lacks, and key limits on a cluster that
+// will not run the LLM gateway.
+func (p *Plan) checkNewAgent(sess *session.Session, a Agent) error {
+	if a.NodePool != nil && *a.NodePool != "" && !sess.K3dConfig.HasPool(*a.NodePool) {
+		return fmt.Errorf("the cluster has no node pool %s", *a.NodePool)
+	}
+	if a.LLM == nil || slices.Contains(p.Catalog.Enable, litellm.AppName) {
+		return nil
+	}
+	err := agent.CheckKeyOpts(sess.GitOpsPath, a.LLM.opts())
+	if err == nil && slices.Contains(p.Catalog.Disable, litellm.AppName) {
+		err = agent.ErrNoGateway
+	}
+	if errors.Is(err, agent.ErrNoGateway) {
+		return fmt.Errorf("llm limits need the %s app; add it to the spec's apps", litellm.AppName)
+	}
+	return err
+}
+
+// Apply writes every change in the plan through the catalog, app and agent
+// packages and commits them together, all under the gitops lock. The steps
+// run against a staged copy of the repo, so a failing step leaves the repo
+// as it was. The plan is recomputed under the lock first; if the repo
+// changed since p was computed, nothing is written and an error wrapping
+// profile.ErrPlanChanged is returned. Values are validated against their
+// chart schemas before anything is written unless skipSchema is set.
+func (p *Plan) Apply(log *zap.Logger, sess *session.Session, skipSchema bool) error {
+	gitOpsPath := sess.GitOpsPath
+
+	values := make(map[string][]byte, len(p.Values))
+	for _, name := range p.Values {
+		data, err := yaml.Marshal(p.spec.Values[name])
+		if err != nil {
+			return fmt.Errorf("marshaling values for %s: %w", name, err)
+		}
+		if !skipSchema {
+			entry, err := catalog.Find(gitOpsPath, name)
+			if err != nil {
+				return err
+			}
+			if err := catalog.ValidateValues(log, *entry, data); err != nil {
+				return fmt.Errorf("values for %s: %w", name, err)
+			}
+		}
+		values[name] = data
+	}
+
+	l, err := lock.GitOps(gitOpsPath)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	stage, err := gitops.NewStage(gitOpsPath)
+	if err != nil {
+		return err
+	}
+	defer stage.Discard()
+	staged := *sess
+	staged.GitOpsPath = stage.Dir()
+
+	// The stage is a copy of the repo taken under the lock, so a plan
+	// computed on it is what applying would do now.
+	current, err := NewPlan(&staged, p.spec, p.Catalog.Exact)
+	if err != nil {
+		return err
+	}
+	if !samePlan(current, p) {
+		return fmt.Errorf("%w, re-run", profile.ErrPlanChanged)
+	}
+	if err := p.applySteps(&staged, values); err != nil {
+		return fmt.Errorf("%w; nothing was committed", err)
+	}
+
+	msg := "spec: apply"
+	if p.spec.Name != "" {
+		msg += " " + p.spec.Name
+	}
+	_, err = stage.Commit(msg)
+	return err
+}
+
+// samePlan reports whether a and b make the same changes. Warnings are not
+// compared; they may name the repo's path.
+func samePlan(a, b *Plan) bool {
+	x, y := *a, *b
+	x.Warnings, y.Warnings = nil, nil
+	return reflect.DeepEqual(x, y)
+}
+
+// applySteps makes the plan's changes in the gitops repo of sess, each step
+// committing on its own.
+func (p *Plan) applySteps(sess *session.Session, values map[string][]byte) error {
+	gitOpsPath := sess.GitOpsPath
+	if err := profile.ApplyPlan(gitOpsPath, p.Catalog); err != nil {
+		return fmt.Errorf("applying catalog apps: %w", err)
+	}
+	for _, name := range p.Values {
+		if err := catalog.SaveValues(gitOpsPath, name, values[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := p.applyCustomApps(gitOpsPath); err != nil {
+		return err
+	}
+	return p.applyAgents(sess)
+}
+
+func (p *Plan) applyCustomApps(gitOpsPath string) error {
+	for _, name := range p.CustomApps.Remove {
+		if err := app.Remove(gitOpsPath, name); err != nil {
+			return err
+		}
+	}
+	for _, a := range p.spec.CustomApps {
+		opts := app.AddOpts{
+			GitOpsPath: gitOpsPath,
+			Name:       a.Name,
+			RepoURL:    a.RepoURL,
+			Chart:      a.Chart,
+			Version:    a.Version,
+			Namespace:  a.namespace(),
+		}
+		added := slices.Contains(p.CustomApps.Add, a.Name)
+		switch {
+		case added:
+			if err := app.Add(opts); err != nil {
+				return err
+			}
+		case p.coordsChange[a.Name]:
+			if _, err := app.Update(opts); err != nil {
+				return err
+			}
+		}
+		if (added && a.Values != nil) || p.valuesChange[a.Name] {
+			data, err := yaml.Marshal(a.Values)
+			if err != nil {
+				return fmt.Errorf("marshaling values for app %s: %w", a.Name, err)
+			}
+			if err := app.SaveValues(gitOpsPath, a.Name, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Plan) applyAgents(sess *session.Session) error {
+	gitOpsPath := sess.GitOpsPath
+	for _, name := range p.Agents.Remove {
+		if err := agent.Delete(gitOpsPath, name); err != nil {
+			return err
+		}
+	}
+	for _, a := range p.spec.Agents {
+		switch {
+		case slices.Contains(p.Agents.Add, a.Name):
+			pool := agent.NodePoolFor(sess.K3dConfig)
+			if a.NodePool != nil {
+				pool = *a.NodePool
+			}
+			if err := agent.Create(gitOpsPath, agent.CreateOpts{
+				Name:          a.Name,
+				CPURequest:    a.CPURequest,
+				CPULimit:      a.CPULimit,
+				MemoryRequest: a.MemoryRequest,
+				MemoryLimit:   a.MemoryLimit,
+				Pods:          a.Pods,
+				ChartVersion:  a.ChartVersion,
+				Template:      a.Template,
+				Image:         a.Image,
+				NodePool:      pool,
+				ChartRepoURL:  agent.ChartRepoFor(sess.Bundle),
+			}); err != nil {
+				return fmt.Errorf("creating agent %s: %w", a.Name, err)
+			}
+			if a.Egress != nil {
+				if _, err := agent.SetEgress(gitOpsPath, a.Name, a.Egress, false); err != nil {
+					return fmt.Errorf("setting egress of agent %s: %w", a.Name, err)
+				}
+			}
+		case slices.Contains(p.Agents.Update, a.Name):
+			if _, err := agent.Update(gitOpsPath, updateOpts(a)); err != nil {
+				return fmt.Errorf("updating agent %s: %w", a.Name, err)
+			}
+			if p.egressChange[a.Name] {
+				if _, err := agent.SetEgress(gitOpsPath, a.Name, a.Egress, false); err != nil {
+					return fmt.Errorf("setting egress of agent %s: %w", a.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ProvisionKeys gives the agents Apply created their LiteLLM keys, limited
+// as the spec says, when the litellm-proxy app is enabled. Call it once the
+// plan is synced: the proxy may only just have been deployed. An agent
+// whose key cannot be provisioned is deleted again, as agent create does.
+func (p *Plan) ProvisionKeys(ctx context.Context, sess *session.Session) (map[string]*litellm.Key, error) {
+	if len(p.Agents.Add) == 0 {
+		return nil, nil
+	}
+	gw, err := agent.ConnectGateway(ctx, sess.GitOpsPath, sess.ClusterName)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the LLM gateway, agents %s have no LLM key: %w", strings.Join(p.Agents.Add, ", "), err)
+	}
+	if gw == nil {
+		return nil, nil
+	}
+	keys := make(map[string]*litellm.Key, len(p.Agents.Add))
+	for _, a := range p.spec.Agents {
+		if !slices.Contains(p.Agents.Add, a.Name) {
+			continue
+		}
+		key, err := gw.ProvisionNewKey(ctx, sess.GitOpsPath, a.Name, a.LLM.opts())
+		if err != nil {
+			return keys, fmt.Errorf("agent %s not created: %w", a.Name, err)
+		}
+		keys[a.Name] = key
+	}
+	return keys, nil
+}
+
+func updateOpts(a Agent) agent.UpdateOpts {
+	return agent.UpdateOpts{
+		Name:          a.Name,
+		CPURequest:    a.CPURequest,
+		CPULimit:      a.CPULimit,
+		MemoryRequest: a.MemoryRequest,
+		MemoryLimit:   a.MemoryLimit,
+		Pods:          a.Pods,
+		ChartVersion:  a.ChartVersion,
+	}
+}
+
+// drift describes the spec settings that cannot change on an existing
+// cluster and differ from sess.
+func drift(sess *session.Session, s *Spec) []string {
+	var out []string
+	if b := s.Bootstrap; b != nil {
+		if b.URL != "" && b.URL != sess.BootstrapURL {
+			out = append(out, fmt.Sprintf("bootstrap %s differs from the cluster's %s", b.URL, sess.BootstrapURL))
+		} else if b.Version != nil && *b.Version != sess.BootstrapVersion {
+			out = append(out, fmt.Sprintf("bootstrap version %q differs from the cluster's %q", *b.Version, sess.BootstrapVersion))
+		}
+	}
+	if t := s.Topology; t != nil {
+		k := sess.K3dConfig
+		if (t.Servers != nil && *t.Servers != k.Servers) ||
+			(t.Agents != nil && *t.Agents != k.Agents) ||
+			(t.AgentPools != nil && !slices.Equal(t.AgentPools, k.AgentPools)) {
+			out = append(out, fmt.Sprintf("topology differs from the cluster's %s", k.Topology()))
+		}
+	}
+	if s.Registry != (sess.Registry != nil) {
+		out = append(out, "registry setting differs from the cluster")
+	}
+	if len(out) > 0 {
+		out = append(out, "these settings only apply at creation; recreate the cluster to change them")
+	}
+	return out
+}
+
+// sameValues reports whether two values documents are equal. A missing
+// document equals an empty one.
+func sameValues(a, b map[string]interface{}) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,385 @@
+// Package spec reads and writes sikifanso.yaml cluster specs: one file
+// describing a cluster's bootstrap source, topology, catalog apps and their
+// values, custom Helm apps and agent sandboxes, so a cluster can be
+// recreated or converged from it.
+package spec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/app"
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+	"sigs.k8s.io/yaml"
+)
+
+// Version is the spec format this build reads and writes.
+const Version = 1
+
+// DefaultFile is the spec file name used in docs and examples.
+const DefaultFile = "sikifanso.yaml"
+
+// Spec is a declarative description of a cluster.
+type Spec struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name,omitempty"`
+	Bootstrap *Bootstrap `json:"bootstrap,omitempty"`
+	Topology  *Topology  `json:"topology,omitempty"`
+	// Registry creates the cluster with a local image registry.
+	Registry bool `json:"registry,omitempty"`
+	// Profiles and Apps together are the catalog apps to enable; their
+	// dependencies are enabled as well.
+	Profiles []string `json:"profiles,omitempty"`
+	Apps     []string `json:"apps,omitempty"`
+	// Values maps catalog apps to their complete values overrides.
+	Values     map[string]map[string]interface{} `json:"values,omitempty"`
+	CustomApps []CustomApp                       `json:"customApps,omitempty"`
+	Agents     []Agent                           `json:"agents,omitempty"`
+}
+
+// Bootstrap is the gitops template the cluster is scaffolded from.
+type Bootstrap struct {
+	URL string `json:"url,omitempty"`
+	// Version is the tag to clone. Unset follows cluster create's default;
+	// an empty string forces HEAD.
+	Version *string `json:"version,omitempty"`
+}
+
+// Topology is the node layout. Unset counts come from the bootstrap repo's
+// infra/platform.yaml.
+type Topology struct {
+	Servers    *int                   `json:"servers,omitempty"`
+	Agents     *int                   `json:"agents,omitempty"`
+	AgentPools []infraconfig.NodePool `json:"agentPools,omitempty"`
+}
+
+// CustomApp is a Helm chart deployed outside the catalog (see app add).
+type CustomApp struct {
+	Name      string `json:"name"`
+	RepoURL   string `json:"repoURL"`
+	Chart     string `json:"chart"`
+	Version   string `json:"version"`
+	Namespace string `json:"namespace,omitempty"`
+	// Values replaces the app's values file when set.
+	Values map[string]interface{} `json:"values,omitempty"`
+}
+
+// namespace returns the app's target namespace, which defaults to its name.
+func (a CustomApp) namespace() string {
+	if a.Namespace != "" {
+		return a.Namespace
+	}
+	return a.Name
+}
+
+// Agent is an agent sandbox. Empty quotas keep the current value of an
+// existing agent and take the template's or the defaults for a new one.
+type Agent struct {
+	Name          string `json:"name"`
+	Template      string `json:"template,omitempty"`
+	Image         string `json:"image,omitempty"`
+	CPURequest    string `json:"cpuRequest,omitempty"`
+	CPULimit      string `json:"cpuLimit,omitempty"`
+	MemoryRequest string `json:"memoryRequest,omitempty"`
+	MemoryLimit   string `json:"memoryLimit,omitempty"`
+	Pods          string `json:"pods,omitempty"`
+	ChartVersion  string `json:"chartVersion,omitempty"`
+	// NodePool is the agent pool a new agent is pinned to. Unset follows
+	// agent create's default, the cluster's sandbox pool when it has one;
+	// an empty string lets the agent run on any node.
+	NodePool *string `json:"nodePool,omitempty"`
+	// Egress replaces the agent's egress allowlist when set. Unset keeps
+	// the current rules, or the template's for a new agent.
+	Egress []agent.EgressRule `json:"egress,omitempty"`
+	// LLM limits the LiteLLM key a new agent is given.
+	LLM *LLMKey `json:"llm,omitempty"`
+}
+
+// LLMKey limits an agent's LiteLLM key, as the agent create --llm-* flags
+// do. Zero values mean unlimited.
+type LLMKey struct {
+	Budget float64  `json:"budget,omitempty"`
+	RPM    int      `json:"rpm,omitempty"`
+	Models []string `json:"models,omitempty"`
+}
+
+func (k *LLMKey) opts() agent.KeyOpts {
+	if k == nil {
+		return agent.KeyOpts{}
+	}
+	return agent.KeyOpts{MaxBudget: k.Budget, RPMLimit: k.RPM, Models: k.Models}
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Load reads and validates the spec at path.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading spec: %w", err)
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse decodes and validates a spec. Unknown fields are rejected so typos
+// do not silently drop settings.
+func Parse(data []byte) (*Spec, error) {
+	var s Spec
+	if err := yaml.UnmarshalStrict(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing spec: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks the spec for errors that do not need a cluster: the
+// format version, names, the topology and duplicate entries.
+func (s *Spec) Validate() error {
+	if s.Version != Version {
+		return fmt.Errorf("unsupported spec version %d (this sikifanso reads version %d)", s.Version, Version)
+	}
+	if s.Name != "" && !validName.MatchString(s.Name) {
+		return fmt.Errorf("invalid cluster name %q: must match [a-z0-9][a-z0-9-]*", s.Name)
+	}
+	if t := s.Topology; t != nil {
+		topo := infraconfig.PlatformConfig{Servers: 1, AgentPools: t.AgentPools}
+		if t.Servers != nil {
+			topo.Servers = *t.Servers
+		}
+		if t.Agents != nil {
+			topo.Agents = *t.Agents
+		}
+		if err := topo.ValidateTopology(); err != nil {
+			return err
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, a := range s.CustomApps {
+		if !validName.MatchString(a.Name) {
+			return fmt.Errorf("invalid custom app name %q: must match [a-z0-9][a-z0-9-]*", a.Name)
+		}
+		if a.RepoURL == "" || a.Chart == "" {
+			return fmt.Errorf("custom app %s: repoURL and chart are required", a.Name)
+		}
+		if seen[a.Name] {
+			return fmt.Errorf("custom app %s is listed twice", a.Name)
+		}
+		seen[a.Name] = true
+	}
+	seen = map[string]bool{}
+	for _, a := range s.Agents {
+		if !validName.MatchString(a.Name) {
+			return fmt.Errorf("invalid agent name %q: must match [a-z0-9][a-z0-9-]*", a.Name)
+		}
+		if a.Image != "" && a.Template == "" {
+			return fmt.Errorf("agent %s: an image can only be set together with a template", a.Name)
+		}
+		if seen[a.Name] {
+			return fmt.Errorf("agent %s is listed twice", a.Name)
+		}
+		seen[a.Name] = true
+	}
+	return nil
+}
+
+// Marshal encodes the spec as YAML.
+func (s *Spec) Marshal() ([]byte, error) {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling spec: %w", err)
+	}
+	return data, nil
+}
+
+// Export builds the spec of a live cluster from its session and gitops
+// repo. Explicitly enabled catalog apps are listed under apps, since the
+// profiles a cluster was created with are not recorded; dependencies are
+// left out as applying the spec enables them again. Agent key limits are
+// read from the LiteLLM proxy when it is enabled.
+//
+// The warnings name what the spec does not reproduce: ephemeral agents,
+// which are skipped, and key limits that could not be read.
+func Export(ctx context.Context, sess *session.Session) (*Spec, []string, error) {
+	version := sess.BootstrapVersion
+	s := &Spec{
+		Version:   Version,
+		Name:      sess.ClusterName,
+		Bootstrap: &Bootstrap{URL: sess.BootstrapURL, Version: &version},
+		Registry:  sess.Registry != nil,
+	}
+	if k := sess.K3dConfig; k.Servers > 0 {
+		servers, agents := k.Servers, k.Agents
+		s.Topology = &Topology{Servers: &servers, Agents: &agents, AgentPools: k.AgentPools}
+	}
+
+	entries, err := catalog.List(sess.GitOpsPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing catalog: %w", err)
+	}
+	for _, e := range entries {
+		if !e.Enabled {
+			continue
+		}
+		if e.Provenance() == catalog.ProvenanceExplicit {
+			s.Apps = append(s.Apps, e.Name)
+		}
+		data, err := catalog.ReadValues(sess.GitOpsPath, e.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		vals, err := catalog.ParseValues(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("values for %s: %w", e.Name, err)
+		}
+		if len(vals) > 0 {
+			if s.Values == nil {
+				s.Values = map[string]map[string]interface{}{}
+			}
+			s.Values[e.Name] = vals
+		}
+	}
+
+	apps, err := app.List(sess.GitOpsPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing apps: %w", err)
+	}
+	for _, a := range apps {
+		vals, err := customValues(sess.GitOpsPath, a.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.CustomApps = append(s.CustomApps, CustomApp{
+			Name:      a.Name,
+			RepoURL:   a.RepoURL,
+			Chart:     a.Chart,
+			Version:   a.Version,
+			Namespace: a.Namespace,
+			Values:    vals,
+		})
+	}
+
+	agents, err := agent.List(sess.GitOpsPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing agents: %w", err)
+	}
+	var warnings []string
+	defaultPool := agent.NodePoolFor(sess.K3dConfig)
+	for _, a := range agents {
+		if a.ExpiresAt != nil {
+			warnings = append(warnings, fmt.Sprintf("agent %s is ephemeral (expires %s) and is not exported",
+				a.Name, a.ExpiresAt.Format(time.RFC3339)))
+			continue
+		}
+		sa := Agent{
+			Name:          a.Name,
+			Template:      a.Template,
+			CPURequest:    a.CPURequest,
+			CPULimit:      a.CPULimit,
+			MemoryRequest: a.MemoryRequest,
+			MemoryLimit:   a.MemoryLimit,
+			Pods:          a.Pods,
+			ChartVersion:  a.ChartVersion,
+		}
+		if a.NodePool != defaultPool {
+			pool := a.NodePool
+			sa.NodePool = &pool
+		}
+		if sa.Image, err = imageOverride(sess.GitOpsPath, a); err != nil {
+			return nil, nil, err
+		}
+		if sa.Egress, err = agent.ListEgress(sess.GitOpsPath, a.Name); err != nil {
+			return nil, nil, err
+		}
+		for i := range sa.Egress {
+			// Filled from the catalog entry when the rule is written.
+			sa.Egress[i].Namespace = ""
+		}
+		s.Agents = append(s.Agents, sa)
+	}
+	warnings = append(warnings, exportKeys(ctx, sess, s.Agents)...)
+	return s, warnings, nil
+}
+
+// imageOverride returns the image of an agent created from a template when
+// it is not the template's own, i.e. it was set with --image.
+func imageOverride(gitOpsPath string, a agent.Info) (string, error) {
+	if a.Template == "" || a.Image == "" {
+		return "", nil
+	}
+	t, err := agent.RenderTemplate(gitOpsPath, a.Template, a.Name)
+	if err != nil {
+		return "", fmt.Errorf("agent %s: %w", a.Name, err)
+	}
+	if t.Workload.Image == a.Image {
+		return "", nil
+	}
+	return a.Image, nil
+}
+
+// exportKeys sets the LLM key limits of agents from the LiteLLM proxy. It
+// returns warnings for limits it could not read rather than failing, so a
+// cluster whose proxy is down can still be exported.
+func exportKeys(ctx context.Context, sess *session.Session, agents []Agent) []string {
+	if len(agents) == 0 {
+		return nil
+	}
+	gw, err := agent.ConnectGateway(ctx, sess.GitOpsPath, sess.ClusterName)
+	if err != nil {
+		return []string{fmt.Sprintf("LLM key limits not exported: %v", err)}
+	}
+	if gw == nil {
+		return nil
+	}
+	var warnings []string
+	for i := range agents {
+		key, err := gw.KeyUsage(ctx, agents[i].Name)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("LLM key limits of agent %s not exported: %v", agents[i].Name, err))
+			continue
+		}
+		if key == nil {
+			continue
+		}
+		k := &LLMKey{Models: key.Models}
+		if key.MaxBudget != nil {
+			k.Budget = *key.MaxBudget
+		}
+		if key.RPMLimit != nil {
+			k.RPM = *key.RPMLimit
+		}
+		if k.Budget != 0 || k.RPM != 0 || len(k.Models) != 0 {
+			agents[i].LLM = k
+		}
+	}
+	return warnings
+}
+
+// customValues returns a custom app's parsed values, or nil when it has
+// none.
+func customValues(gitOpsPath, name string) (map[string]interface{}, error) {
+	data, err := app.ReadValues(gitOpsPath, name)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := catalog.ParseValues(data)
+	if err != nil {
+		return nil, fmt.Errorf("values for app %s: %w", name, err)
+	}
+	if len(vals) == 0 {
+		return nil, nil
+	}
+	return vals, nil
+}
@@ -0,0 +1,394 @@
+package spec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alicanalbayrak/sikifanso/internal/agent"
+	"github.com/alicanalbayrak/sikifanso/internal/app"
+	"github.com/alicanalbayrak/sikifanso/internal/catalog"
+	"github.com/alicanalbayrak/sikifanso/internal/infraconfig"
+	"github.com/alicanalbayrak/sikifanso/internal/profile"
+	"github.com/alicanalbayrak/sikifanso/internal/session"
+)
+
+// setupCluster writes a gitops repo with a small catalog, one custom app and
+// one agent, and returns a session pointing at it.
+func setupCluster(t *testing.T) *session.Session {
+	t.Helper()
+	t.Setenv("SIKIFANSO_HOME", t.TempDir())
+	dir := t.TempDir()
+	for _, sub := range []string{"catalog/values", "apps/coordinates", "apps/values", "agents/values"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range []catalog.Entry{
+		{Name: "cnpg-operator", Tier: "0-operators", Enabled: true, AutoEnabledBy: []string{"postgresql"}},
+		{Name: "postgresql", Tier: "1-data", Enabled: true, DependsOn: []string{"cnpg-operator"}},
+		{Name: "langfuse", Tier: "2-services", DependsOn: []string{"postgresql"}},
+		{Name: "qdrant", Tier: "1-data", Enabled: true},
+	} {
+		e.Category, e.Description, e.RepoURL = "test", "test entry", "https://example.com"
+		e.Chart, e.TargetRevision, e.Namespace = e.Name, "1.0.0", "test"
+		writeYAML(t, filepath.Join(dir, "catalog", e.Name+".yaml"), e)
+	}
+	writeYAML(t, filepath.Join(dir, "catalog", "values", "qdrant.yaml"), map[string]interface{}{"replicaCount": 2})
+	initGitRepo(t, dir)
+
+	if err := app.Add(app.AddOpts{
+		GitOpsPath: dir, Name: "echo", RepoURL: "https://charts.example.com", Chart: "echo", Version: "1.0.0", Namespace: "echo",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Create(dir, agent.CreateOpts{Name: "bot"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.Create(dir, agent.CreateOpts{Name: "scratch", TTL: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	return &session.Session{
+		ClusterName:  "dev",
+		BootstrapURL: "https://github.com/example/bootstrap.git",
+		GitOpsPath:   dir,
+		K3dConfig: session.K3dConfigInfo{
+			Servers:    1,
+			Agents:     1,
+			AgentPools: []infraconfig.NodePool{{Name: "sandbox", Count: 1}},
+		},
+	}
+}
+
+func writeYAML(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func initGitRepo(t *testing.T, dir string) {
+	t.Helper()
+	gitRun(t, dir, "init")
+	gitRun(t, dir, "config", "user.email", "test@test.com")
+	gitRun(t, dir, "config", "user.name", "test")
+	gitRun(t, dir, "add", ".")
+	gitRun(t, dir, "commit", "-m", "init")
+}
+
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %s: %v", args, out, err)
+	}
+	return string(out)
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(`
+version: 1
+name: dev
+bootstrap:
+  url: https://github.com/example/bootstrap.git
+  version: ""
+topology:
+  servers: 1
+  agentPools:
+    - name: sandbox
+      count: 2
+profiles: [rag]
+apps: [langfuse]
+values:
+  langfuse:
+    replicaCount: 2
+customApps:
+  - name: echo
+    repoURL: https://charts.example.com
+    chart: echo
+    version: 1.0.0
+agents:
+  - name: bot
+    template: claude-code
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if s.Bootstrap.Version == nil || *s.Bootstrap.Version != "" {
+		t.Errorf("bootstrap version = %v, want explicit empty (HEAD)", s.Bootstrap.Version)
+	}
+	if s.Topology.Agents != nil || len(s.Topology.AgentPools) != 1 {
+		t.Errorf("topology = %+v, want unset agents and one pool", s.Topology)
+	}
+	if s.CustomApps[0].namespace() != "echo" {
+		t.Errorf("custom app namespace = %q, want it to default to the name", s.CustomApps[0].namespace())
+	}
+}
+
+func TestParse_Rejects(t *testing.T) {
+	for name, doc := range map[string]string{
+		"unknown field":     "version: 1\nprofile: rag\n",
+		"missing version":   "name: dev\n",
+		"bad name":          "version: 1\nname: Dev\n",
+		"bad topology":      "version: 1\ntopology:\n  servers: 0\n",
+		"custom app chart":  "version: 1\ncustomApps:\n  - name: echo\n    repoURL: https://x\n",
+		"duplicate agent":   "version: 1\nagents:\n  - name: bot\n  - name: bot\n",
+		"image no template": "version: 1\nagents:\n  - name: bot\n    image: busybox\n",
+	} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("%s: Parse succeeded, want error", name)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	sess := setupCluster(t)
+	if _, err := agent.AllowEgress(sess.GitOpsPath, "bot", agent.EgressRule{App: "qdrant", Ports: []int{6333}}); err != nil {
+		t.Fatal(err)
+	}
+	s, warnings, err := Export(context.Background(), sess)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	// Dependencies are implied; only explicitly enabled apps are listed.
+	if !slices.Equal(s.Apps, []string{"postgresql", "qdrant"}) {
+		t.Errorf("apps = %v, want [postgresql qdrant]", s.Apps)
+	}
+	if got := s.Values["qdrant"]["replicaCount"]; got != float64(2) {
+		t.Errorf("qdrant replicaCount = %v, want 2", got)
+	}
+	if len(s.CustomApps) != 1 || s.CustomApps[0].Name != "echo" || s.CustomApps[0].Values != nil {
+		t.Errorf("custom apps = %+v, want echo without values", s.CustomApps)
+	}
+	if len(s.Agents) != 1 || s.Agents[0].Name != "bot" || s.Agents[0].ChartVersion != agent.DefaultChartVersion {
+		t.Errorf("agents = %+v, want bot only (ephemeral scratch skipped)", s.Agents)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "agent scratch is ephemeral") {
+		t.Errorf("warnings = %v, want one for the skipped ephemeral agent", warnings)
+	}
+	// bot was created without a pool on a cluster with a sandbox pool.
+	if bot := s.Agents[0]; bot.NodePool == nil || *bot.NodePool != "" || len(bot.Egress) != 1 || bot.Egress[0].App != "qdrant" {
+		t.Errorf("bot = %+v, want no node pool and the qdrant egress rule", bot)
+	}
+	if s.Topology == nil || *s.Topology.Servers != 1 || len(s.Topology.AgentPools) != 1 {
+		t.Errorf("topology = %+v, want the session's", s.Topology)
+	}
+
+	// The exported spec round-trips and already matches the cluster.
+	data, err := s.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse(exported): %v\n%s", err, data)
+	}
+	plan, err := NewPlan(sess, parsed, true)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	if !plan.Empty() || len(plan.Warnings) != 0 {
+		t.Errorf("plan for exported spec = %+v, want empty", plan)
+	}
+}
+
+func TestNewPlan_AdditiveAndExact(t *testing.T) {
+	sess := setupCluster(t)
+	s := &Spec{
+		Version: Version,
+		Apps:    []string{"langfuse"},
+		Values:  map[string]map[string]interface{}{"qdrant": {"replicaCount": float64(3)}},
+		CustomApps: []CustomApp{
+			{Name: "echo", RepoURL: "https://charts.example.com", Chart: "echo", Version: "2.0.0"},
+			{Name: "web", RepoURL: "https://charts.example.com", Chart: "web", Version: "1.0.0"},
+		},
+		Agents:   []Agent{{Name: "bot", CPULimit: "2"}, {Name: "coder"}},
+		Topology: &Topology{AgentPools: []infraconfig.NodePool{{Name: "sandbox", Count: 2}}},
+	}
+
+	plan, err := NewPlan(sess, s, false)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	if !slices.Equal(plan.Catalog.Enable, []string{"langfuse"}) || len(plan.Catalog.Disable) != 0 {
+		t.Errorf("catalog = +%v -%v, want +[langfuse]", plan.Catalog.Enable, plan.Catalog.Disable)
+	}
+	if !slices.Equal(plan.Values, []string{"qdrant"}) || !slices.Equal(plan.Resync(), []string{"qdrant"}) {
+		t.Errorf("values = %v resync = %v, want [qdrant]", plan.Values, plan.Resync())
+	}
+	want := Changes{Add: []string{"web"}, Update: []string{"echo"}}
+	if !slices.Equal(plan.CustomApps.Add, want.Add) || !slices.Equal(plan.CustomApps.Update, want.Update) {
+		t.Errorf("custom apps = %+v, want %+v", plan.CustomApps, want)
+	}
+	want = Changes{Add: []string{"coder"}, Update: []string{"bot"}}
+	if !slices.Equal(plan.Agents.Add, want.Add) || !slices.Equal(plan.Agents.Update, want.Update) || len(plan.Agents.Remove) != 0 {
+		t.Errorf("agents = %+v, want %+v", plan.Agents, want)
+	}
+	if len(plan.Warnings) == 0 || !strings.Contains(plan.Warnings[0], "topology") {
+		t.Errorf("warnings = %v, want a topology warning", plan.Warnings)
+	}
+
+	s.Apps, s.CustomApps, s.Agents = []string{"qdrant"}, nil, nil
+	plan, err = NewPlan(sess, s, true)
+	if err != nil {
+		t.Fatalf("NewPlan exact: %v", err)
+	}
+	if !slices.Equal(plan.Catalog.Disable, []string{"postgresql", "cnpg-operator"}) {
+		t.Errorf("exact disable = %v, want [postgresql cnpg-operator]", plan.Catalog.Disable)
+	}
+	if !slices.Equal(plan.CustomApps.Remove, []string{"echo"}) || !slices.Equal(plan.Agents.Remove, []string{"bot"}) {
+		t.Errorf("exact removes apps %v agents %v, want [echo] [bot] (ephemeral agents kept)", plan.CustomApps.Remove, plan.Agents.Remove)
+	}
+}
+
+func TestApply(t *testing.T) {
+	sess := setupCluster(t)
+	dir := sess.GitOpsPath
+	s := &Spec{
+		Version: Version,
+		Apps:    []string{"langfuse"},
+		Values:  map[string]map[string]interface{}{"langfuse": {"web": map[string]interface{}{"replicas": float64(2)}}},
+		CustomApps: []CustomApp{
+			{Name: "echo", RepoURL: "https://charts.example.com", Chart: "echo", Version: "2.0.0", Namespace: "echo",
+				Values: map[string]interface{}{"greeting": "hi"}},
+		},
+		Agents: []Agent{
+			{Name: "bot", CPULimit: "2", Egress: []agent.EgressRule{{FQDN: "api.openai.com", Ports: []int{443}}}},
+			{Name: "coder", MemoryLimit: "2Gi", Egress: []agent.EgressRule{{App: "langfuse"}}},
+		},
+	}
+	plan, err := NewPlan(sess, s, false)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	before := gitRun(t, dir, "rev-parse", "HEAD")
+	if err := plan.Apply(nil, sess, true); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if parent := gitRun(t, dir, "rev-parse", "HEAD~1"); parent != before {
+		t.Error("Apply made more than one commit")
+	}
+	if subject := gitRun(t, dir, "log", "-1", "--format=%s"); subject != "spec: apply\n" {
+		t.Errorf("commit subject = %q", subject)
+	}
+
+	if e, err := catalog.Find(dir, "langfuse"); err != nil || !e.Enabled {
+		t.Errorf("langfuse enabled = %v (%v), want true", e != nil && e.Enabled, err)
+	}
+	data, err := catalog.ReadValues(dir, "langfuse")
+	if err != nil || !strings.Contains(string(data), "replicas: 2") {
+		t.Errorf("langfuse values = %q (%v), want web.replicas", data, err)
+	}
+	apps, err := app.List(dir)
+	if err != nil || len(apps) != 1 || apps[0].Version != "2.0.0" {
+		t.Errorf("apps = %+v (%v), want echo at 2.0.0", apps, err)
+	}
+	if data, _ := app.ReadValues(dir, "echo"); !strings.Contains(string(data), "greeting: hi") {
+		t.Errorf("echo values = %q, want greeting", data)
+	}
+	bot, err := agent.Find(dir, "bot")
+	if err != nil || bot.CPULimit != "2" {
+		t.Errorf("bot = %+v (%v), want cpuLimit 2", bot, err)
+	}
+	coder, err := agent.Find(dir, "coder")
+	if err != nil || coder.MemoryLimit != "2Gi" || coder.CPULimit != agent.DefaultCPULimit {
+		t.Errorf("coder = %+v (%v), want memoryLimit 2Gi and default CPU", coder, err)
+	}
+	if coder.NodePool != "sandbox" {
+		t.Errorf("coder node pool = %q, want the cluster's sandbox pool", coder.NodePool)
+	}
+	for name, want := range map[string]string{"bot": "api.openai.com:443", "coder": "app:langfuse"} {
+		rules, err := agent.ListEgress(dir, name)
+		if err != nil || len(rules) != 1 || rules[0].String() != want {
+			t.Errorf("%s egress = %v (%v), want %s", name, rules, err, want)
+		}
+	}
+	if status := gitRun(t, dir, "status", "--porcelain"); status != "" {
+		t.Errorf("uncommitted changes after Apply:\n%s", status)
+	}
+
+	// Applying the same spec again changes nothing.
+	plan, err = NewPlan(sess, s, false)
+	if err != nil {
+		t.Fatalf("NewPlan again: %v", err)
+	}
+	if !plan.Empty() {
+		t.Errorf("second plan = %+v, want empty", plan)
+	}
+}
+
+func TestApply_AllOrNothing(t *testing.T) {
+	sess := setupCluster(t)
+	dir := sess.GitOpsPath
+	head := gitRun(t, dir, "rev-parse", "HEAD")
+
+	// The catalog step succeeds, then creating the agent fails.
+	s := &Spec{Version: Version, Apps: []string{"langfuse"}, Agents: []Agent{{Name: "coder", Template: "missing"}}}
+	plan, err := NewPlan(sess, s, false)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	if err := plan.Apply(nil, sess, true); err == nil || !strings.Contains(err.Error(), "nothing was committed") {
+		t.Fatalf("Apply = %v, want a failure that committed nothing", err)
+	}
+	if e, _ := catalog.Find(dir, "langfuse"); e.Enabled {
+		t.Error("failed Apply left langfuse enabled")
+	}
+
+	// A plan computed before the repo changed is refused.
+	s = &Spec{Version: Version, Apps: []string{"langfuse"}}
+	if plan, err = NewPlan(sess, s, false); err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	if err := catalog.SetEnabled(dir, "langfuse", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.Apply(nil, sess, true); !errors.Is(err, profile.ErrPlanChanged) {
+		t.Errorf("Apply of a stale plan = %v, want ErrPlanChanged", err)
+	}
+	if got := gitRun(t, dir, "rev-parse", "HEAD"); got != head {
+		t.Error("refused Apply made a commit")
+	}
+}
+
+func TestNewPlan_RejectsUnappliableAgents(t *testing.T) {
+	sess := setupCluster(t)
+	none := ""
+	for name, a := range map[string]Agent{
+		"unknown pool": {Name: "coder", NodePool: ptr("gpu")},
+		"no gateway":   {Name: "coder", NodePool: &none, LLM: &LLMKey{Budget: 5}},
+	} {
+		s := &Spec{Version: Version, Agents: []Agent{a}}
+		if _, err := NewPlan(sess, s, false); err == nil {
+			t.Errorf("%s: NewPlan succeeded, want error", name)
+		}
+	}
+
+	// Settings agent update cannot change are reported, not applied.
+	s := &Spec{Version: Version, Agents: []Agent{{Name: "bot", NodePool: ptr("sandbox")}}}
+	plan, err := NewPlan(sess, s, false)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+	if len(plan.Warnings) != 1 || !strings.Contains(plan.Warnings[0], "node pool") {
+		t.Errorf("warnings = %v, want a node pool warning", plan.Warnings)
+	}
+}
+
+func ptr(s string) *string { return &s }